@@ -0,0 +1,287 @@
+// Package auth implements the API tokens of piladb, which grant
+// access to its resources given a scope, and optionally limited to
+// some databases.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pila"
+	"github.com/fern4lvarez/piladb/pkg/uuid"
+)
+
+// AUTH represents the name of the database that will hold
+// all the tokens.
+const AUTH = "_auth"
+
+// AdminToken is the name of the setting of the token with admin
+// scope created on start-up, which enables authentication.
+const AdminToken = "ADMIN_TOKEN"
+
+// Scopes of a Token, from the least to the most privileged.
+const (
+	// ScopeReadOnly grants reading databases and stacks.
+	ScopeReadOnly = "read-only"
+	// ScopeReadWrite grants reading and modifying databases
+	// and stacks.
+	ScopeReadWrite = "read-write"
+	// ScopeAdmin grants access to all resources, including
+	// configuration, snapshots and tokens.
+	ScopeAdmin = "admin"
+)
+
+// levels sorts the scopes by privilege.
+var levels = map[string]int{
+	ScopeReadOnly:  1,
+	ScopeReadWrite: 2,
+	ScopeAdmin:     3,
+}
+
+// Token represents an API token. Its secret is never stored, only
+// its hash.
+type Token struct {
+	ID        string    `json:"id"`
+	Scope     string    `json:"scope"`
+	Databases []string  `json:"databases,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	hash []byte
+	// stored is true if the Token was created or restored,
+	// so it must be persisted.
+	stored bool
+}
+
+// StoredToken represents a Token along with the hash of its secret,
+// so it can be persisted and restored without knowing the secret.
+type StoredToken struct {
+	Token
+	Hash string `json:"hash"`
+}
+
+// Validate returns an error if the scope of the Token is unknown,
+// or it is an admin one limited to some databases.
+func (token Token) Validate() error {
+	if _, ok := levels[token.Scope]; !ok {
+		return fmt.Errorf("unknown scope %q", token.Scope)
+	}
+	if token.Scope == ScopeAdmin && len(token.Databases) > 0 {
+		return errors.New("admin tokens cannot be limited to databases")
+	}
+	return nil
+}
+
+// Allows returns true if the Token grants a scope on a database, or
+// on the resources that are not part of any database if it is empty.
+// Tokens limited to some databases are not granted the latter.
+func (token Token) Allows(scope, database string) bool {
+	if levels[token.Scope] < levels[scope] {
+		return false
+	}
+	if len(token.Databases) == 0 {
+		return true
+	}
+	for _, name := range token.Databases {
+		if name == database && database != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Auth represents a Database containing all
+// tokens that grant access to piladb.
+type Auth struct {
+	Tokens *pila.Database
+
+	// mu guards Tokens, as they are read on
+	// every request.
+	mu sync.RWMutex
+}
+
+// NewAuth creates a new Auth with no tokens.
+func NewAuth() *Auth {
+	return &Auth{Tokens: pila.NewDatabase(AUTH)}
+}
+
+// Enabled returns true if there are any tokens, so requests must
+// be authenticated.
+func (a *Auth) Enabled() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return len(a.Tokens.Stacks) > 0
+}
+
+// NewToken generates a Token with a random secret given its scope and
+// the databases it is limited to, if any, without adding it. It
+// returns the secret of the Token, which cannot be recovered
+// afterwards, and the Token to add with Store.
+func NewToken(scope string, databases []string, t time.Time) (string, StoredToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", StoredToken{}, err
+	}
+	secret := hex.EncodeToString(b)
+
+	token, err := newToken(secret, scope, databases, t)
+	if err != nil {
+		return "", StoredToken{}, err
+	}
+	return secret, StoredToken{Token: token, Hash: hex.EncodeToString(token.hash)}, nil
+}
+
+// Create creates a Token given its scope and the databases it is
+// limited to, if any. It returns the secret of the Token, which
+// cannot be recovered afterwards.
+func (a *Auth) Create(scope string, databases []string, t time.Time) (string, Token, error) {
+	secret, token, err := NewToken(scope, databases, t)
+	if err != nil {
+		return "", Token{}, err
+	}
+
+	if err := a.Store(token); err != nil {
+		return "", Token{}, err
+	}
+	return secret, token.Token, nil
+}
+
+// Add adds a Token given its secret, its scope and the databases it
+// is limited to, if any. Tokens added given their secret, as the admin
+// one set on start-up, are not returned by Stored.
+func (a *Auth) Add(secret, scope string, databases []string, t time.Time) (Token, error) {
+	token, err := newToken(secret, scope, databases, t)
+	if err != nil {
+		return Token{}, err
+	}
+
+	if err := a.add(token); err != nil {
+		return Token{}, err
+	}
+	return token, nil
+}
+
+// Store adds a StoredToken, e.g. when restoring it after a restart.
+// It returns an error if its hash is not valid, or if a Token with
+// the same ID already exists.
+func (a *Auth) Store(st StoredToken) error {
+	hash, err := hex.DecodeString(st.Hash)
+	if err != nil || len(hash) != sha256.Size {
+		return fmt.Errorf("invalid hash of token %s", st.ID)
+	}
+
+	token := st.Token
+	token.hash = hash
+	token.stored = true
+	if err := token.Validate(); err != nil {
+		return err
+	}
+	return a.add(token)
+}
+
+// Stored returns the tokens created or restored with Store, along
+// with their hashes, sorted by ID.
+func (a *Auth) Stored() []StoredToken {
+	var tokens []StoredToken
+	for _, token := range a.List() {
+		if token.stored {
+			tokens = append(tokens, StoredToken{Token: token, Hash: hex.EncodeToString(token.hash)})
+		}
+	}
+	return tokens
+}
+
+// newToken returns a new Token given its secret, its scope and the
+// databases it is limited to, if any.
+func newToken(secret, scope string, databases []string, t time.Time) (Token, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Token{}, err
+	}
+
+	hash := sha256.Sum256([]byte(secret))
+	token := Token{
+		ID:        hex.EncodeToString(id),
+		Scope:     scope,
+		Databases: databases,
+		CreatedAt: t,
+		hash:      hash[:],
+	}
+	if err := token.Validate(); err != nil {
+		return Token{}, err
+	}
+	return token, nil
+}
+
+// add adds a Token as a Stack named by its ID.
+func (a *Auth) add(token Token) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stack := pila.NewStack(token.ID, token.CreatedAt)
+	stack.Push(token)
+	return a.Tokens.AddStack(stack)
+}
+
+// Token returns a Token given its ID, and a boolean flag stating
+// whether it exists.
+func (a *Auth) Token(id string) (Token, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	s, ok := a.Tokens.Stack(uuid.New(AUTH + id))
+	if !ok {
+		return Token{}, false
+	}
+	token, ok := s.Peek().(Token)
+	return token, ok
+}
+
+// List returns all tokens sorted by ID.
+func (a *Auth) List() []Token {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var tokens []Token
+	for _, s := range a.Tokens.Stacks {
+		if token, ok := s.Peek().(Token); ok {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID < tokens[j].ID
+	})
+	return tokens
+}
+
+// Revoke removes a Token given its ID. It returns false if it does
+// not exist.
+func (a *Auth) Revoke(id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.Tokens.RemoveStack(uuid.New(AUTH + id))
+}
+
+// Authenticate returns the Token given its secret, and a boolean
+// flag stating whether it exists.
+func (a *Auth) Authenticate(secret string) (Token, bool) {
+	if secret == "" {
+		return Token{}, false
+	}
+
+	hash := sha256.Sum256([]byte(secret))
+	for _, token := range a.List() {
+		if subtle.ConstantTimeCompare(token.hash, hash[:]) == 1 {
+			return token, true
+		}
+	}
+	return Token{}, false
+}
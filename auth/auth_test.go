@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pkg/uuid"
+)
+
+func TestNewAuth(t *testing.T) {
+	auth := NewAuth()
+
+	inputOutput := []struct {
+		input, output interface{}
+	}{
+		{auth.Tokens.Name, AUTH},
+		{auth.Tokens.ID, uuid.New(AUTH)},
+		{len(auth.Tokens.Stacks), 0},
+		{auth.Enabled(), false},
+	}
+
+	for _, io := range inputOutput {
+		if io.input != io.output {
+			t.Errorf("got %v, expected %v", io.input, io.output)
+		}
+	}
+}
+
+func TestAuthCreate(t *testing.T) {
+	auth := NewAuth()
+	now := time.Now()
+
+	secret, token, err := auth.Create(ScopeReadWrite, []string{"db"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 64 {
+		t.Errorf("secret is %s, expected 64 hex characters", secret)
+	}
+	if token.ID == "" || token.Scope != ScopeReadWrite || token.Databases[0] != "db" || !token.CreatedAt.Equal(now) {
+		t.Errorf("token is %v, unexpected", token)
+	}
+	if !auth.Enabled() {
+		t.Error("auth is not enabled, expected enabled")
+	}
+
+	if got, ok := auth.Token(token.ID); !ok || got.ID != token.ID {
+		t.Errorf("token is %v, %v, expected %v", got, ok, token)
+	}
+	if got, ok := auth.Authenticate(secret); !ok || got.ID != token.ID {
+		t.Errorf("token is %v, %v, expected %v", got, ok, token)
+	}
+	if _, ok := auth.Authenticate(secret + "0"); ok {
+		t.Error("token is authenticated, expected not")
+	}
+	if _, ok := auth.Authenticate(""); ok {
+		t.Error("token is authenticated, expected not")
+	}
+}
+
+func TestAuthCreate_Invalid(t *testing.T) {
+	auth := NewAuth()
+
+	if _, _, err := auth.Create("root", nil, time.Now()); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if _, _, err := auth.Create(ScopeAdmin, []string{"db"}, time.Now()); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if auth.Enabled() {
+		t.Error("auth is enabled, expected not")
+	}
+}
+
+func TestAuthAdd(t *testing.T) {
+	auth := NewAuth()
+
+	token, err := auth.Add("secret", ScopeAdmin, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := auth.Authenticate("secret"); !ok || got.ID != token.ID || got.Scope != ScopeAdmin {
+		t.Errorf("token is %v, %v, expected %v", got, ok, token)
+	}
+}
+
+func TestAuthStore(t *testing.T) {
+	auth := NewAuth()
+	_, _ = auth.Add("admin", ScopeAdmin, nil, time.Now())
+	secret, token, _ := auth.Create(ScopeReadOnly, []string{"db"}, time.Now())
+
+	stored := auth.Stored()
+	if len(stored) != 1 || stored[0].ID != token.ID || stored[0].Hash == "" {
+		t.Fatalf("stored tokens are %v, expected only %v", stored, token)
+	}
+
+	restored := NewAuth()
+	if err := restored.Store(stored[0]); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := restored.Authenticate(secret); !ok || got.ID != token.ID || got.Databases[0] != "db" {
+		t.Errorf("token is %v, %v, expected %v", got, ok, token)
+	}
+	if err := restored.Store(stored[0]); err == nil {
+		t.Error("err is nil, expected error")
+	}
+
+	invalid := stored[0]
+	invalid.Hash = "foo"
+	if err := NewAuth().Store(invalid); err == nil {
+		t.Error("err is nil, expected error")
+	}
+}
+
+func TestAuthListRevoke(t *testing.T) {
+	auth := NewAuth()
+	secret, token, _ := auth.Create(ScopeReadOnly, nil, time.Now())
+	_, _, _ = auth.Create(ScopeAdmin, nil, time.Now())
+
+	tokens := auth.List()
+	if len(tokens) != 2 || tokens[0].ID > tokens[1].ID {
+		t.Errorf("tokens are %v, expected 2 sorted by ID", tokens)
+	}
+
+	if !auth.Revoke(token.ID) {
+		t.Error("token is not revoked, expected revoked")
+	}
+	if auth.Revoke(token.ID) {
+		t.Error("token is revoked twice, expected once")
+	}
+	if _, ok := auth.Authenticate(secret); ok {
+		t.Error("revoked token is authenticated, expected not")
+	}
+	if _, ok := auth.Token(token.ID); ok {
+		t.Error("revoked token exists, expected not")
+	}
+	if len(auth.List()) != 1 {
+		t.Errorf("tokens are %v, expected 1", auth.List())
+	}
+}
+
+func TestTokenAllows(t *testing.T) {
+	for _, tc := range []struct {
+		token    Token
+		scope    string
+		database string
+		expected bool
+	}{
+		{Token{Scope: ScopeReadOnly}, ScopeReadOnly, "db", true},
+		{Token{Scope: ScopeReadOnly}, ScopeReadOnly, "", true},
+		{Token{Scope: ScopeReadOnly}, ScopeReadWrite, "db", false},
+		{Token{Scope: ScopeReadWrite}, ScopeReadOnly, "db", true},
+		{Token{Scope: ScopeReadWrite}, ScopeReadWrite, "db", true},
+		{Token{Scope: ScopeReadWrite}, ScopeAdmin, "", false},
+		{Token{Scope: ScopeAdmin}, ScopeAdmin, "", true},
+		{Token{Scope: ScopeAdmin}, ScopeReadWrite, "db", true},
+		{Token{Scope: ScopeReadWrite, Databases: []string{"db"}}, ScopeReadWrite, "db", true},
+		{Token{Scope: ScopeReadWrite, Databases: []string{"db"}}, ScopeReadWrite, "other", false},
+		{Token{Scope: ScopeReadWrite, Databases: []string{"db"}}, ScopeReadOnly, "", false},
+		{Token{Scope: "unknown"}, ScopeReadOnly, "db", false},
+	} {
+		if allows := tc.token.Allows(tc.scope, tc.database); allows != tc.expected {
+			t.Errorf("%v allows %s on %q is %v, expected %v", tc.token, tc.scope, tc.database, allows, tc.expected)
+		}
+	}
+}
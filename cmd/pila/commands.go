@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fern4lvarez/piladb/pkg/client"
+)
+
+// Placeholders of the arguments of a command. Arguments with a
+// placeholder of an existing resource are completed in the REPL.
+const (
+	argDatabase = "DATABASE"
+	argStack    = "STACK"
+	argKey      = "KEY"
+	argName     = "NAME"
+	argElement  = "ELEMENT"
+	argValue    = "VALUE"
+)
+
+// command represents a command of pila, given by one or more words,
+// and followed by its arguments.
+type command struct {
+	name        string
+	args        []string
+	description string
+	run         func(ctx context.Context, c *client.Client, args []string) (interface{}, error)
+}
+
+// usage returns the name of the command followed by the placeholders
+// of its arguments.
+func (cmd command) usage() string {
+	return strings.TrimSpace(cmd.name + " " + strings.Join(cmd.args, " "))
+}
+
+// commands are the commands of pila.
+var commands = []command{
+	{"status", nil, "Show the status of pilad", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.Status(ctx)
+	}},
+	{"db list", nil, "List the databases", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.Databases(ctx)
+	}},
+	{"db create", []string{argName}, "Create a database", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.CreateDatabase(ctx, args[0])
+	}},
+	{"db status", []string{argDatabase}, "Show the status of a database", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.Database(args[0]).Status(ctx)
+	}},
+	{"db delete", []string{argDatabase}, "Delete a database and its stacks", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return nil, c.Database(args[0]).Delete(ctx)
+	}},
+	{"stack list", []string{argDatabase}, "List the stacks of a database", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.Database(args[0]).Stacks(ctx)
+	}},
+	{"stack create", []string{argDatabase, argName}, "Create a stack", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.Database(args[0]).CreateStack(ctx, args[1])
+	}},
+	{"stack status", []string{argDatabase, argStack}, "Show the status of a stack", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.Database(args[0]).Stack(args[1]).Status(ctx)
+	}},
+	{"stack delete", []string{argDatabase, argStack}, "Delete a stack", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return nil, c.Database(args[0]).Stack(args[1]).Delete(ctx)
+	}},
+	{"push", []string{argDatabase, argStack, argElement}, "Push an element on top of a stack", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.Database(args[0]).Stack(args[1]).Push(ctx, parseValue(args[2]))
+	}},
+	{"pop", []string{argDatabase, argStack}, "Pop the element on top of a stack", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.Database(args[0]).Stack(args[1]).Pop(ctx)
+	}},
+	{"peek", []string{argDatabase, argStack}, "Show the element on top of a stack", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.Database(args[0]).Stack(args[1]).Peek(ctx)
+	}},
+	{"size", []string{argDatabase, argStack}, "Show the size of a stack", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.Database(args[0]).Stack(args[1]).Size(ctx)
+	}},
+	{"flush", []string{argDatabase, argStack}, "Remove all elements of a stack", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.Database(args[0]).Stack(args[1]).Flush(ctx)
+	}},
+	{"config list", nil, "List the configuration values", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.Config(ctx)
+	}},
+	{"config get", []string{argKey}, "Show a configuration value", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.ConfigValue(ctx, args[0])
+	}},
+	{"config set", []string{argKey, argValue}, "Set a configuration value", func(ctx context.Context, c *client.Client, args []string) (interface{}, error) {
+		return c.SetConfigValue(ctx, args[0], parseValue(args[1]))
+	}},
+}
+
+// findCommand returns the command whose name are the first words,
+// and the rest of words as its arguments.
+func findCommand(words []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		name := strings.Fields(cmd.name)
+		if len(words) >= len(name) && strings.Join(words[:len(name)], " ") == cmd.name {
+			return cmd, words[len(name):], true
+		}
+	}
+	return command{}, nil, false
+}
+
+// run runs the command given by words, and writes its result into
+// w as indented JSON.
+func run(ctx context.Context, c *client.Client, words []string, w io.Writer) error {
+	cmd, args, ok := findCommand(words)
+	if !ok {
+		return fmt.Errorf("unknown command %q, see help", strings.Join(words, " "))
+	}
+	if len(args) != len(cmd.args) {
+		return fmt.Errorf("usage: %s", cmd.usage())
+	}
+
+	result, err := cmd.run(ctx, c, args)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// help writes the usage of all commands into w.
+func help(w io.Writer) {
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-36s %s\n", cmd.usage(), cmd.description)
+	}
+}
+
+// parseValue returns the value of an argument decoded from JSON, or
+// the argument itself as a string if it is not valid JSON, so strings
+// do not need to be quoted.
+func parseValue(arg string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(arg), &value); err != nil {
+		return arg
+	}
+	return value
+}
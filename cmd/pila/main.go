@@ -0,0 +1,106 @@
+// Binary pila provides a command-line client of piladb, which runs a
+// single command given by its arguments, or reads them interactively
+// if there are none.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fern4lvarez/piladb/pkg/client"
+	"github.com/fern4lvarez/piladb/pkg/version"
+)
+
+// defaultHost is the address of pilad if neither the host flag nor
+// the PILADB_HOST environment variable are set.
+const defaultHost = "127.0.0.1:1205"
+
+// These vars represent the command line flags.
+var (
+	hostFlag    string
+	tokenFlag   string
+	historyFlag string
+	versionFlag bool
+)
+
+func init() {
+	flag.StringVar(&hostFlag, "host", "", "Address of pilad, PILADB_HOST or "+defaultHost+" by default")
+	flag.StringVar(&tokenFlag, "token", "", "Token to authenticate to pilad, PILADB_TOKEN by default")
+	flag.StringVar(&historyFlag, "history", defaultHistoryPath(), "Path of the history file of the interactive mode")
+	flag.BoolVar(&versionFlag, "v", false, "Version")
+	flag.Usage = usage
+}
+
+func main() {
+	flag.Parse()
+	if versionFlag {
+		fmt.Println(version.Version(version.VERSION))
+		return
+	}
+
+	c := client.New(host())
+	c.Token = token()
+	ctx := context.Background()
+
+	args := flag.Args()
+	if len(args) == 0 || (len(args) == 1 && args[0] == "repl") {
+		if err := repl(ctx, c, historyFlag); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if args[0] == "help" {
+		usage()
+		return
+	}
+
+	if err := run(ctx, c, args, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// host returns the address of pilad, from the host flag, or the
+// PILADB_HOST environment variable.
+func host() string {
+	if hostFlag != "" {
+		return hostFlag
+	}
+	if h := os.Getenv("PILADB_HOST"); h != "" {
+		return h
+	}
+	return defaultHost
+}
+
+// token returns the token to authenticate to pilad, from the token
+// flag, or the PILADB_TOKEN environment variable.
+func token() string {
+	if tokenFlag != "" {
+		return tokenFlag
+	}
+	return os.Getenv("PILADB_TOKEN")
+}
+
+// defaultHistoryPath returns the path of the history file in the
+// home directory, or an empty path if there is none.
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pila_history")
+}
+
+// usage writes the usage of pila into the standard error.
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] [command]\n\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "Without a command, commands are read interactively.")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	help(os.Stderr)
+	fmt.Fprintln(os.Stderr, "\nFlags:")
+	flag.PrintDefaults()
+}
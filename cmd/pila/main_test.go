@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fern4lvarez/piladb/pkg/client"
+)
+
+// fakePilad is a pilad server replying to the requests of the
+// commands with fixed responses, and recording them.
+type fakePilad struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (f *fakePilad) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	request := r.Method + " " + r.URL.RequestURI()
+	if len(body) > 0 {
+		request += " " + string(body)
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, request)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.URL.Path == "/databases" && r.Method == "GET":
+		w.Write([]byte(`{"number_of_databases":3,"databases":[{"name":"db"},{"name":"dogs"},{"name":"cats"}]}`))
+	case r.URL.Path == "/databases/db/stacks" && r.Method == "GET":
+		w.Write([]byte(`{"stacks":[{"name":"stack"},{"name":"other"}]}`))
+	case r.URL.Path == "/_config":
+		w.Write([]byte(`{"stacks":{"MAX_STACK_SIZE":-1,"PORT":1205}}`))
+	case r.URL.Path == "/databases/db/stacks/gone":
+		w.WriteHeader(http.StatusGone)
+	case r.Method == "PUT":
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"name":"db"}`))
+	case r.Method == "DELETE" && r.URL.RawQuery == "full":
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "POST":
+		w.Write(body)
+	default:
+		w.Write([]byte(`{"element":1}`))
+	}
+}
+
+func fakePiladClient(t *testing.T) (*client.Client, *fakePilad) {
+	f := &fakePilad{}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return client.New(server.URL), f
+}
+
+func TestRun(t *testing.T) {
+	c, f := fakePiladClient(t)
+
+	for _, tc := range []struct {
+		args     []string
+		request  string
+		expected string
+	}{
+		{[]string{"db", "create", "db"}, "PUT /databases?name=db", `"name": "db"`},
+		{[]string{"stack", "create", "db", "my stack"}, "PUT /databases/db/stacks?name=my+stack", `"name": "db"`},
+		{[]string{"push", "db", "stack", `{"a":1}`}, `POST /databases/db/stacks/stack {"element":{"a":1}}`, `"a": 1`},
+		{[]string{"push", "db", "stack", "foo"}, `POST /databases/db/stacks/stack {"element":"foo"}`, `"foo"`},
+		{[]string{"pop", "db", "stack"}, "DELETE /databases/db/stacks/stack", "1"},
+		{[]string{"peek", "db", "stack"}, "GET /databases/db/stacks/stack?peek", "1"},
+		{[]string{"flush", "db", "stack"}, "DELETE /databases/db/stacks/stack?flush", ""},
+		{[]string{"stack", "delete", "db", "stack"}, "DELETE /databases/db/stacks/stack?full", ""},
+		{[]string{"status"}, "GET /_status", `"status": ""`},
+		{[]string{"config", "set", "MAX_STACK_SIZE", "100"}, `POST /_config/MAX_STACK_SIZE {"element":100}`, "100"},
+	} {
+		f.requests = nil
+		var out bytes.Buffer
+		if err := run(context.Background(), c, tc.args, &out); err != nil {
+			t.Errorf("%v: err is %v, expected nil", tc.args, err)
+			continue
+		}
+		if len(f.requests) != 1 || f.requests[0] != tc.request {
+			t.Errorf("%v: requests are %v, expected %s", tc.args, f.requests, tc.request)
+		}
+		if !strings.Contains(out.String(), tc.expected) {
+			t.Errorf("%v: output is %s, expected %s", tc.args, out.String(), tc.expected)
+		}
+	}
+}
+
+func TestRun_Error(t *testing.T) {
+	c, f := fakePiladClient(t)
+
+	for _, tc := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"foo"}, `unknown command "foo"`},
+		{[]string{"db"}, `unknown command "db"`},
+		{[]string{"push", "db", "stack"}, "usage: push DATABASE STACK ELEMENT"},
+		{[]string{"pop", "db", "gone"}, "410 Gone"},
+	} {
+		if err := run(context.Background(), c, tc.args, io.Discard); err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("%v: err is %v, expected %s", tc.args, err, tc.expected)
+		}
+	}
+	if len(f.requests) != 1 {
+		t.Errorf("requests are %v, expected only one", f.requests)
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	for _, tc := range []struct {
+		line     string
+		expected []string
+	}{
+		{"", nil},
+		{"  pop  db stack ", []string{"pop", "db", "stack"}},
+		{`push db stack '{"a": 1}'`, []string{"push", "db", "stack", `{"a": 1}`}},
+		{`push db stack "say \"hi\""`, []string{"push", "db", "stack", `say "hi"`}},
+		{`push db stack ""`, []string{"push", "db", "stack", ""}},
+		{`push db st'a'ck`, []string{"push", "db", "stack"}},
+	} {
+		words, err := splitArgs(tc.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(words, tc.expected) {
+			t.Errorf("words of %q are %q, expected %q", tc.line, words, tc.expected)
+		}
+	}
+
+	if _, err := splitArgs(`push db stack "foo`); err == nil {
+		t.Error("err is nil, expected error")
+	}
+}
+
+func TestComplete(t *testing.T) {
+	c, _ := fakePiladClient(t)
+
+	for _, tc := range []struct {
+		line       string
+		expected   string
+		candidates []string
+	}{
+		{"pu", "push ", []string{"push"}},
+		{"p", "p", []string{"peek", "pop", "push"}},
+		{"st", "sta", []string{"stack", "status"}},
+		{"stack ", "stack ", []string{"create", "delete", "list", "status"}},
+		{"stack cr", "stack create ", []string{"create"}},
+		{"pop d", "pop d", []string{"db", "dogs"}},
+		{"pop c", "pop cats ", []string{"cats"}},
+		{"pop db ", "pop db ", []string{"other", "stack"}},
+		{"pop db st", "pop db stack ", []string{"stack"}},
+		{"pop db stack ", "pop db stack ", nil},
+		{"config get M", "config get MAX_STACK_SIZE ", []string{"MAX_STACK_SIZE"}},
+		{"foo", "foo", nil},
+	} {
+		line, pos, candidates := complete(context.Background(), c, tc.line, len(tc.line))
+		if line != tc.expected || pos != len(tc.expected) {
+			t.Errorf("completion of %q is %q at %d, expected %q", tc.line, line, pos, tc.expected)
+		}
+		if !reflect.DeepEqual(candidates, tc.candidates) {
+			t.Errorf("candidates of %q are %v, expected %v", tc.line, candidates, tc.candidates)
+		}
+	}
+
+	// the rest of the line is kept
+	if line, pos, _ := complete(context.Background(), c, "pu db stack", 2); line != "push  db stack" || pos != 5 {
+		t.Errorf("completion is %q at %d, expected %q at %d", line, pos, "push  db stack", 5)
+	}
+}
+
+func TestReadLoop(t *testing.T) {
+	c, f := fakePiladClient(t)
+
+	input := "help\n\npop db stack\npush db 'unterminated\nfoo\nexit\npop db stack\n"
+	var out, errOut bytes.Buffer
+	if err := readLoop(context.Background(), c, scanLines(strings.NewReader(input)), &out, &errOut); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "push DATABASE STACK ELEMENT") {
+		t.Errorf("output is %s, expected help", out.String())
+	}
+	if expected := []string{"DELETE /databases/db/stacks/stack"}; !reflect.DeepEqual(f.requests, expected) {
+		t.Errorf("requests are %v, expected %v", f.requests, expected)
+	}
+	if expected := "error: unterminated quote\nerror: unknown command \"foo\", see help\n"; errOut.String() != expected {
+		t.Errorf("errors are %q, expected %q", errOut.String(), expected)
+	}
+}
+
+func TestFileHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	h := newFileHistory(path)
+	for _, line := range []string{"pop db stack", "", "pop db stack", "size db stack"} {
+		h.Add(line)
+	}
+	if h.Len() != 2 || h.At(0) != "size db stack" || h.At(1) != "pop db stack" {
+		t.Errorf("history is %v, expected 2 lines", h.lines)
+	}
+
+	// the history is recalled from its file
+	h = newFileHistory(path)
+	if h.Len() != 2 || h.At(0) != "size db stack" {
+		t.Errorf("history is %v, expected 2 lines", h.lines)
+	}
+
+	h = newFileHistory("")
+	for i := 0; i <= maxHistory; i++ {
+		h.Add(strings.Repeat("a", i+1))
+	}
+	if h.Len() != maxHistory {
+		t.Errorf("history has %d lines, expected %d", h.Len(), maxHistory)
+	}
+}
+
+func TestHost(t *testing.T) {
+	defer func(h string) { hostFlag = h }(hostFlag)
+	defer os.Setenv("PILADB_HOST", os.Getenv("PILADB_HOST"))
+
+	hostFlag = ""
+	os.Setenv("PILADB_HOST", "")
+	if h := host(); h != defaultHost {
+		t.Errorf("host is %s, expected %s", h, defaultHost)
+	}
+
+	os.Setenv("PILADB_HOST", "pila:1205")
+	if h := host(); h != "pila:1205" {
+		t.Errorf("host is %s, expected %s", h, "pila:1205")
+	}
+
+	hostFlag = "localhost:8080"
+	if h := host(); h != "localhost:8080" {
+		t.Errorf("host is %s, expected %s", h, "localhost:8080")
+	}
+}
+
+func TestToken(t *testing.T) {
+	defer func(token string) { tokenFlag = token }(tokenFlag)
+	defer os.Setenv("PILADB_TOKEN", os.Getenv("PILADB_TOKEN"))
+
+	tokenFlag = ""
+	os.Setenv("PILADB_TOKEN", "env")
+	if tk := token(); tk != "env" {
+		t.Errorf("token is %s, expected %s", tk, "env")
+	}
+
+	tokenFlag = "flag"
+	if tk := token(); tk != "flag" {
+		t.Errorf("token is %s, expected %s", tk, "flag")
+	}
+}
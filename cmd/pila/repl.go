@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fern4lvarez/piladb/pkg/client"
+
+	"golang.org/x/term"
+)
+
+// prompt is shown before each line read by the REPL.
+const prompt = "pila> "
+
+// maxHistory is the number of lines kept in the history.
+const maxHistory = 1000
+
+// repl reads commands line by line and runs them until the input
+// ends or the exit command is read. If the input is a terminal, lines
+// can be edited, recalled from the history, and completed with tab.
+func repl(ctx context.Context, c *client.Client, historyPath string) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return readLoop(ctx, c, scanLines(os.Stdin), os.Stdout, os.Stderr)
+	}
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, prompt)
+	t.History = newFileHistory(historyPath)
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		newLine, newPos, candidates := complete(ctx, c, line, pos)
+		if len(candidates) > 1 {
+			fmt.Fprintln(t, strings.Join(candidates, "  "))
+		}
+		return newLine, newPos, true
+	}
+
+	return readLoop(ctx, c, t.ReadLine, t, t)
+}
+
+// scanLines returns a function reading the lines of r one by one.
+func scanLines(r io.Reader) func() (string, error) {
+	scanner := bufio.NewScanner(r)
+	return func() (string, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+		return scanner.Text(), nil
+	}
+}
+
+// readLoop runs the commands of the lines returned by readLine until
+// it returns an error, writing their results into out and their errors
+// into errOut. The end of the input is not an error.
+func readLoop(ctx context.Context, c *client.Client, readLine func() (string, error), out, errOut io.Writer) error {
+	for {
+		line, err := readLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		words, err := splitArgs(line)
+		if err != nil {
+			fmt.Fprintln(errOut, "error:", err)
+			continue
+		}
+		if len(words) == 0 {
+			continue
+		}
+
+		switch words[0] {
+		case "exit", "quit":
+			return nil
+		case "help":
+			help(out)
+			continue
+		}
+		if err := run(ctx, c, words, out); err != nil {
+			fmt.Fprintln(errOut, "error:", err)
+		}
+	}
+}
+
+// splitArgs splits a line into words separated by spaces. Words can
+// be quoted with single quotes, taken literally, or double quotes,
+// where a backslash escapes the next character.
+func splitArgs(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+				continue
+			}
+			word.WriteRune(r)
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				if i+1 < len(runes) {
+					i++
+					word.WriteRune(runes[i])
+				}
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// complete completes the word before pos in line with the names of
+// commands, or with the names of databases, stacks or config keys
+// fetched from pilad, depending on the argument. It returns the new
+// line and position, and the candidates of the word. The word is
+// completed up to the longest prefix common to all candidates.
+func complete(ctx context.Context, c *client.Client, line string, pos int) (string, int, []string) {
+	before, after := line[:pos], line[pos:]
+	words := strings.Fields(before)
+	current := ""
+	if len(words) > 0 && !strings.HasSuffix(before, " ") {
+		current = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	var candidates []string
+	for _, candidate := range candidatesOf(ctx, c, words) {
+		if strings.HasPrefix(candidate, current) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	sort.Strings(candidates)
+	if len(candidates) == 0 {
+		return line, pos, nil
+	}
+
+	completed := commonPrefix(candidates)
+	if len(candidates) == 1 {
+		completed += " "
+	}
+	newBefore := before[:len(before)-len(current)] + completed
+	return newBefore + after, len(newBefore), candidates
+}
+
+// candidatesOf returns the candidates of the word following words.
+func candidatesOf(ctx context.Context, c *client.Client, words []string) []string {
+	cmd, args, ok := findCommand(words)
+	if !ok {
+		// complete the next word of the name of the commands
+		// starting with words
+		var names []string
+		seen := make(map[string]bool)
+		for _, cmd := range append(commands[:len(commands):len(commands)], command{name: "help"}, command{name: "exit"}) {
+			name := strings.Fields(cmd.name)
+			if len(name) <= len(words) || strings.Join(name[:len(words)], " ") != strings.Join(words, " ") {
+				continue
+			}
+			if next := name[len(words)]; !seen[next] {
+				seen[next] = true
+				names = append(names, next)
+			}
+		}
+		return names
+	}
+	if len(args) >= len(cmd.args) {
+		return nil
+	}
+
+	switch cmd.args[len(args)] {
+	case argDatabase:
+		status, err := c.Databases(ctx)
+		if err != nil {
+			return nil
+		}
+		var names []string
+		for _, db := range status.Databases {
+			names = append(names, db.Name)
+		}
+		return names
+	case argStack:
+		status, err := c.Database(args[0]).Stacks(ctx)
+		if err != nil {
+			return nil
+		}
+		var names []string
+		for _, stack := range status.Stacks {
+			names = append(names, stack.Name)
+		}
+		return names
+	case argKey:
+		config, err := c.Config(ctx)
+		if err != nil {
+			return nil
+		}
+		var keys []string
+		for key := range config {
+			keys = append(keys, key)
+		}
+		return keys
+	}
+	return nil
+}
+
+// commonPrefix returns the longest prefix common to all words.
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// fileHistory is a term.History that appends its lines into a file,
+// so they are recalled in the next sessions.
+type fileHistory struct {
+	path  string
+	lines []string
+}
+
+// newFileHistory returns a fileHistory with the lines of a file, if
+// it exists. An empty path keeps the history in memory.
+func newFileHistory(path string) *fileHistory {
+	h := &fileHistory{path: path}
+	if path == "" {
+		return h
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		h.add(scanner.Text())
+	}
+	return h
+}
+
+// Add adds a line to the history, and appends it into its file.
+func (h *fileHistory) Add(line string) {
+	if line == "" || (len(h.lines) > 0 && h.lines[len(h.lines)-1] == line) {
+		return
+	}
+	h.add(line)
+	if h.path == "" {
+		return
+	}
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	// Do not check error as the history is
+	// still kept in memory.
+	_, _ = fmt.Fprintln(f, line)
+}
+
+// add adds a line to the history, dropping the oldest one if it is
+// full.
+func (h *fileHistory) add(line string) {
+	h.lines = append(h.lines, line)
+	if len(h.lines) > maxHistory {
+		h.lines = h.lines[len(h.lines)-maxHistory:]
+	}
+}
+
+// Len returns the number of lines of the history.
+func (h *fileHistory) Len() int {
+	return len(h.lines)
+}
+
+// At returns a line of the history, the most recent at index 0.
+func (h *fileHistory) At(i int) string {
+	return h.lines[len(h.lines)-1-i]
+}
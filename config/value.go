@@ -10,56 +10,177 @@ import (
 // MaxStackSize returns the value of MAX_STACK_SIZE.
 // Type: int, Default: -1
 func (c *Config) MaxStackSize() int {
-	maxSize := c.Get(vars.MaxStackSize)
-	return intValue(maxSize, vars.MaxStackSizeDefault)
+	return c.intValue(vars.MaxStackSize)
 }
 
 // ReadTimeout returns the value of READ_TIMEOUT.
 // Type: time.Duration, Default: 30
 func (c *Config) ReadTimeout() time.Duration {
-	readTimeout := c.Get(vars.ReadTimeout)
-	t := intValue(readTimeout, vars.ReadTimeoutDefault)
-	return time.Duration(t)
+	return time.Duration(c.intValue(vars.ReadTimeout))
 }
 
 // WriteTimeout returns the value of WRITE_TIMEOUT.
 // Type: time.Duration, Default: 45
 func (c *Config) WriteTimeout() time.Duration {
-	writeTimeout := c.Get(vars.WriteTimeout)
-	t := intValue(writeTimeout, vars.WriteTimeoutDefault)
-	return time.Duration(t)
+	return time.Duration(c.intValue(vars.WriteTimeout))
 }
 
 // Port returns the value of PORT.
 // Type: int, Default: 1205
 func (c *Config) Port() int {
-	port := c.Get(vars.Port)
-	t := intValue(port, vars.PortDefault)
+	return c.intValue(vars.Port)
+}
 
-	if t < 1025 || t > 65536 {
-		return vars.PortDefault
-	}
-	return t
+// SnapshotPath returns the value of SNAPSHOT_PATH.
+// Type: string, Default: ""
+func (c *Config) SnapshotPath() string {
+	return c.stringValue(vars.SnapshotPath)
 }
 
-// intValue returns an Integer value given another value as an
-// interface. If conversion fails, a default value is used.
+// SnapshotInterval returns the value of SNAPSHOT_INTERVAL.
+// Type: time.Duration, Default: 0
+func (c *Config) SnapshotInterval() time.Duration {
+	return time.Duration(c.intValue(vars.SnapshotInterval))
+}
+
+// AOFPath returns the value of AOF_PATH.
+// Type: string, Default: ""
+func (c *Config) AOFPath() string {
+	return c.stringValue(vars.AOFPath)
+}
+
+// AOFFsync returns the value of AOF_FSYNC.
+// Type: string, Default: "everysec"
+func (c *Config) AOFFsync() string {
+	return c.stringValue(vars.AOFFsync)
+}
+
+// AOFRewriteMinSize returns the value of AOF_REWRITE_MIN_SIZE.
+// Type: int, Default: 67108864
+func (c *Config) AOFRewriteMinSize() int {
+	return c.intValue(vars.AOFRewriteMinSize)
+}
+
+// DiskPath returns the value of DISK_PATH.
+// Type: string, Default: ""
+func (c *Config) DiskPath() string {
+	return c.stringValue(vars.DiskPath)
+}
+
+// StackEngine returns the value of STACK_ENGINE.
+// Type: string, Default: "memory"
+func (c *Config) StackEngine() string {
+	return c.stringValue(vars.StackEngine)
+}
+
+// RESPPort returns the value of RESP_PORT.
+// Type: int, Default: 0
+func (c *Config) RESPPort() int {
+	return c.intValue(vars.RESPPort)
+}
+
+// GRPCPort returns the value of GRPC_PORT.
+// Type: int, Default: 0
+func (c *Config) GRPCPort() int {
+	return c.intValue(vars.GRPCPort)
+}
+
+// intValue returns a non-negative Integer value given another value
+// as an interface. If conversion fails, or the value is negative, a
+// default value is used.
 func intValue(value interface{}, defaultValue int) int {
+	var i int
 	switch value.(type) {
 	case int:
-		if i := value.(int); i < 0 {
+		i = value.(int)
+	case float64:
+		if value.(float64) < 0 {
 			return defaultValue
 		}
-		return value.(int)
-	case float64:
-		return int(value.(float64))
+		i = int(value.(float64))
 	case string:
-		i, err := strconv.Atoi(value.(string))
+		var err error
+		i, err = strconv.Atoi(value.(string))
 		if err != nil {
 			return defaultValue
 		}
-		return i
 	default:
 		return defaultValue
 	}
+
+	if i < 0 {
+		return defaultValue
+	}
+	return i
+}
+
+// stringValue returns a String value given another value as an
+// interface. If conversion fails, a default value is used.
+func stringValue(value interface{}, defaultValue string) string {
+	switch value.(type) {
+	case string:
+		return value.(string)
+	default:
+		return defaultValue
+	}
+}
+
+// intValue returns the value of an int config value given its name,
+// or its default value if it cannot be converted or is not accepted
+// by its declaration in the registry.
+func (c *Config) intValue(name string) int {
+	defaultValue := vars.DefaultInt(name)
+	i := intValue(c.Get(name), defaultValue)
+	if _, err := vars.Validate(name, i); err != nil {
+		return defaultValue
+	}
+	return i
+}
+
+// stringValue returns the value of a string config value given its
+// name, or its default value if it is not a string or is not accepted
+// by its declaration in the registry.
+func (c *Config) stringValue(name string) string {
+	defaultValue := vars.DefaultString(name)
+	s := stringValue(c.Get(name), defaultValue)
+	if _, err := vars.Validate(name, s); err != nil {
+		return defaultValue
+	}
+	return s
+}
+
+// TLSCert returns the value of TLS_CERT.
+// Type: string, Default: ""
+func (c *Config) TLSCert() string {
+	return c.stringValue(vars.TLSCert)
+}
+
+// TLSKey returns the value of TLS_KEY.
+// Type: string, Default: ""
+func (c *Config) TLSKey() string {
+	return c.stringValue(vars.TLSKey)
+}
+
+// TLSCA returns the value of TLS_CA.
+// Type: string, Default: ""
+func (c *Config) TLSCA() string {
+	return c.stringValue(vars.TLSCA)
+}
+
+// MetricsMaxStacks returns the value of METRICS_MAX_STACKS.
+// Type: int, Default: 0
+func (c *Config) MetricsMaxStacks() int {
+	return c.intValue(vars.MetricsMaxStacks)
+}
+
+// LogLevel returns the value of LOG_LEVEL.
+// Type: string, Default: "info"
+func (c *Config) LogLevel() string {
+	return c.stringValue(vars.LogLevel)
+}
+
+// LogFormat returns the value of LOG_FORMAT.
+// Type: string, Default: "text"
+func (c *Config) LogFormat() string {
+	return c.stringValue(vars.LogFormat)
 }
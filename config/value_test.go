@@ -20,6 +20,9 @@ func TestMaxStackSize(t *testing.T) {
 		{-1, vars.MaxStackSizeDefault},
 		{"foo", vars.MaxStackSizeDefault},
 		{-35, vars.MaxStackSizeDefault},
+		{-23.7, vars.MaxStackSizeDefault},
+		{-0.5, vars.MaxStackSizeDefault},
+		{"-3", vars.MaxStackSizeDefault},
 		{[]byte("foo"), vars.MaxStackSizeDefault},
 	}
 
@@ -44,6 +47,8 @@ func TestReadTimeout(t *testing.T) {
 		{"3", 3},
 		{-1, vars.ReadTimeoutDefault},
 		{"foo", vars.ReadTimeoutDefault},
+		{-23.7, vars.ReadTimeoutDefault},
+		{"-3", vars.ReadTimeoutDefault},
 		{[]byte("foo"), vars.ReadTimeoutDefault},
 	}
 
@@ -93,6 +98,8 @@ func TestPort(t *testing.T) {
 		{-1, vars.PortDefault},
 		{"foo", vars.PortDefault},
 		{[]byte("foo"), vars.PortDefault},
+		{65535, 65535},
+		{65536, vars.PortDefault},
 		{6736373635, vars.PortDefault},
 	}
 
@@ -104,3 +111,348 @@ func TestPort(t *testing.T) {
 		}
 	}
 }
+
+func TestSnapshotPath(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output string
+	}{
+		{"/tmp/piladb.snapshot", "/tmp/piladb.snapshot"},
+		{"", ""},
+		{8, vars.SnapshotPathDefault},
+		{[]byte("foo"), vars.SnapshotPathDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.SnapshotPath, io.input)
+
+		if s := c.SnapshotPath(); s != io.output {
+			t.Errorf("SnapshotPath is %s, expected %s", s, io.output)
+		}
+	}
+}
+
+func TestSnapshotInterval(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output time.Duration
+	}{
+		{60, 60},
+		{23.7, 23},
+		{"3", 3},
+		{-1, vars.SnapshotIntervalDefault},
+		{"foo", vars.SnapshotIntervalDefault},
+		{[]byte("foo"), vars.SnapshotIntervalDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.SnapshotInterval, io.input)
+
+		if s := c.SnapshotInterval(); s != io.output {
+			t.Errorf("SnapshotInterval is %d, expected %d", s, io.output)
+		}
+	}
+}
+
+func TestAOFPath(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output string
+	}{
+		{"/tmp/piladb.aof", "/tmp/piladb.aof"},
+		{"", ""},
+		{8, vars.AOFPathDefault},
+		{[]byte("foo"), vars.AOFPathDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.AOFPath, io.input)
+
+		if s := c.AOFPath(); s != io.output {
+			t.Errorf("AOFPath is %s, expected %s", s, io.output)
+		}
+	}
+}
+
+func TestAOFFsync(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output string
+	}{
+		{"always", "always"},
+		{"everysec", "everysec"},
+		{"no", "no"},
+		{"sometimes", vars.AOFFsyncDefault},
+		{8, vars.AOFFsyncDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.AOFFsync, io.input)
+
+		if s := c.AOFFsync(); s != io.output {
+			t.Errorf("AOFFsync is %s, expected %s", s, io.output)
+		}
+	}
+}
+
+func TestAOFRewriteMinSize(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output int
+	}{
+		{1024, 1024},
+		{"2048", 2048},
+		{-1, vars.AOFRewriteMinSizeDefault},
+		{"foo", vars.AOFRewriteMinSizeDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.AOFRewriteMinSize, io.input)
+
+		if s := c.AOFRewriteMinSize(); s != io.output {
+			t.Errorf("AOFRewriteMinSize is %d, expected %d", s, io.output)
+		}
+	}
+}
+
+func TestDiskPath(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output string
+	}{
+		{"/var/lib/piladb", "/var/lib/piladb"},
+		{"", ""},
+		{8, vars.DiskPathDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.DiskPath, io.input)
+
+		if s := c.DiskPath(); s != io.output {
+			t.Errorf("DiskPath is %s, expected %s", s, io.output)
+		}
+	}
+}
+
+func TestStackEngine(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output string
+	}{
+		{"slice", "slice"},
+		{"", vars.StackEngineDefault},
+		{8, vars.StackEngineDefault},
+		{"tape", vars.StackEngineDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.StackEngine, io.input)
+
+		if s := c.StackEngine(); s != io.output {
+			t.Errorf("StackEngine is %s, expected %s", s, io.output)
+		}
+	}
+}
+
+func TestRESPPort(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output int
+	}{
+		{6379, 6379},
+		{"6380", 6380},
+		{0, vars.RESPPortDefault},
+		{-1, vars.RESPPortDefault},
+		{80, vars.RESPPortDefault},
+		{"foo", vars.RESPPortDefault},
+		{65535, 65535},
+		{65536, vars.RESPPortDefault},
+		{6736373635, vars.RESPPortDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.RESPPort, io.input)
+
+		if s := c.RESPPort(); s != io.output {
+			t.Errorf("RESPPort is %d, expected %d", s, io.output)
+		}
+	}
+}
+
+func TestGRPCPort(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output int
+	}{
+		{1206, 1206},
+		{"1207", 1207},
+		{0, vars.GRPCPortDefault},
+		{-1, vars.GRPCPortDefault},
+		{80, vars.GRPCPortDefault},
+		{"foo", vars.GRPCPortDefault},
+		{65535, 65535},
+		{65536, vars.GRPCPortDefault},
+		{6736373635, vars.GRPCPortDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.GRPCPort, io.input)
+
+		if s := c.GRPCPort(); s != io.output {
+			t.Errorf("GRPCPort is %d, expected %d", s, io.output)
+		}
+	}
+}
+
+func TestTLSCert(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output string
+	}{
+		{"/etc/piladb/cert.pem", "/etc/piladb/cert.pem"},
+		{"", ""},
+		{8, vars.TLSCertDefault},
+		{[]byte("foo"), vars.TLSCertDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.TLSCert, io.input)
+
+		if s := c.TLSCert(); s != io.output {
+			t.Errorf("TLSCert is %s, expected %s", s, io.output)
+		}
+	}
+}
+
+func TestTLSKey(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output string
+	}{
+		{"/etc/piladb/key.pem", "/etc/piladb/key.pem"},
+		{"", ""},
+		{8, vars.TLSKeyDefault},
+		{[]byte("foo"), vars.TLSKeyDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.TLSKey, io.input)
+
+		if s := c.TLSKey(); s != io.output {
+			t.Errorf("TLSKey is %s, expected %s", s, io.output)
+		}
+	}
+}
+
+func TestTLSCA(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output string
+	}{
+		{"/etc/piladb/ca.pem", "/etc/piladb/ca.pem"},
+		{"", ""},
+		{8, vars.TLSCADefault},
+		{[]byte("foo"), vars.TLSCADefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.TLSCA, io.input)
+
+		if s := c.TLSCA(); s != io.output {
+			t.Errorf("TLSCA is %s, expected %s", s, io.output)
+		}
+	}
+}
+
+func TestMetricsMaxStacks(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output int
+	}{
+		{100, 100},
+		{"1000", 1000},
+		{0, vars.MetricsMaxStacksDefault},
+		{-1, vars.MetricsMaxStacksDefault},
+		{"foo", vars.MetricsMaxStacksDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.MetricsMaxStacks, io.input)
+
+		if s := c.MetricsMaxStacks(); s != io.output {
+			t.Errorf("MetricsMaxStacks is %d, expected %d", s, io.output)
+		}
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output string
+	}{
+		{"debug", "debug"},
+		{"info", "info"},
+		{"warn", "warn"},
+		{"error", "error"},
+		{"verbose", vars.LogLevelDefault},
+		{8, vars.LogLevelDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.LogLevel, io.input)
+
+		if s := c.LogLevel(); s != io.output {
+			t.Errorf("LogLevel is %s, expected %s", s, io.output)
+		}
+	}
+}
+
+func TestLogFormat(t *testing.T) {
+	c := NewConfig()
+
+	inputOutput := []struct {
+		input  interface{}
+		output string
+	}{
+		{"text", "text"},
+		{"json", "json"},
+		{"xml", vars.LogFormatDefault},
+		{8, vars.LogFormatDefault},
+	}
+
+	for _, io := range inputOutput {
+		c.Set(vars.LogFormat, io.input)
+
+		if s := c.LogFormat(); s != io.output {
+			t.Errorf("LogFormat is %s, expected %s", s, io.output)
+		}
+	}
+}
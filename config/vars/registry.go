@@ -0,0 +1,244 @@
+package vars
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Type is the type of a config value.
+type Type string
+
+// Types of the config values.
+const (
+	// TypeInt is the type of integer config values.
+	TypeInt Type = "int"
+	// TypeString is the type of string config values.
+	TypeString Type = "string"
+)
+
+// Var declares a config value: its type, the values it accepts,
+// its default value, and whether it can be changed at runtime.
+type Var struct {
+	// Name is the name of the config value, e.g. MAX_STACK_SIZE.
+	Name string `json:"-"`
+	// Type is the type of the config value.
+	Type Type `json:"type"`
+	// Default is the default value of the config value.
+	Default interface{} `json:"default"`
+	// Min and Max bound the values of an int config value,
+	// if set.
+	Min *int `json:"min,omitempty"`
+	Max *int `json:"max,omitempty"`
+	// AllowZero is true if an int config value accepts 0 out of
+	// its bounds, usually to disable a feature.
+	AllowZero bool `json:"allow_zero,omitempty"`
+	// Values are the values accepted by a string config
+	// value, any if empty.
+	Values []string `json:"values,omitempty"`
+	// Description explains what the config value is for.
+	Description string `json:"description"`
+	// Mutable is true if changes of the config value at
+	// runtime take effect, and false if it is only read
+	// on start-up.
+	Mutable bool `json:"mutable"`
+}
+
+// bound returns a pointer to a bound of an int config value.
+func bound(i int) *int {
+	return &i
+}
+
+// registry declares every config value.
+var registry = []Var{
+	{
+		Name: MaxStackSize, Type: TypeInt, Default: MaxStackSizeDefault,
+		Min: bound(-1), Mutable: true,
+		Description: "Maximum number of elements of a stack, unlimited if -1",
+	},
+	{
+		Name: ReadTimeout, Type: TypeInt, Default: ReadTimeoutDefault,
+		Min:         bound(0),
+		Description: "Seconds before timing out the read of a request",
+	},
+	{
+		Name: WriteTimeout, Type: TypeInt, Default: WriteTimeoutDefault,
+		Min:         bound(0),
+		Description: "Seconds before timing out the write of a response",
+	},
+	{
+		Name: Port, Type: TypeInt, Default: PortDefault,
+		Min: bound(1025), Max: bound(65535),
+		Description: "TCP port number of the HTTP API",
+	},
+	{
+		Name: SnapshotPath, Type: TypeString, Default: SnapshotPathDefault,
+		Mutable:     true,
+		Description: "Path of the snapshot file, snapshots are disabled if empty",
+	},
+	{
+		Name: SnapshotInterval, Type: TypeInt, Default: SnapshotIntervalDefault,
+		Min: bound(0), Mutable: true,
+		Description: "Seconds between periodic snapshots, disabled if 0",
+	},
+	{
+		Name: AOFPath, Type: TypeString, Default: AOFPathDefault,
+		Description: "Path of the append-only file, disabled if empty",
+	},
+	{
+		Name: AOFFsync, Type: TypeString, Default: AOFFsyncDefault,
+		Values:      []string{"always", "everysec", "no"},
+		Description: "Policy to sync the append-only file to disk",
+	},
+	{
+		Name: AOFRewriteMinSize, Type: TypeInt, Default: AOFRewriteMinSizeDefault,
+		Min: bound(0), Mutable: true,
+		Description: "Minimum size in bytes of the append-only file to be rewritten",
+	},
+	{
+		Name: DiskPath, Type: TypeString, Default: DiskPathDefault,
+		Description: "Path of the directory of disk stacks, the disk engine is disabled if empty",
+	},
+	{
+		Name: StackEngine, Type: TypeString, Default: StackEngineDefault,
+		Values: []string{"memory", "slice", "disk", "ring"}, Mutable: true,
+		Description: "Engine of the stacks created without an explicit one",
+	},
+	{
+		Name: RESPPort, Type: TypeInt, Default: RESPPortDefault,
+		Min: bound(1025), Max: bound(65535), AllowZero: true,
+		Description: "TCP port number of the RESP listener, disabled if 0",
+	},
+	{
+		Name: GRPCPort, Type: TypeInt, Default: GRPCPortDefault,
+		Min: bound(1025), Max: bound(65535), AllowZero: true,
+		Description: "TCP port number of the gRPC listener, disabled if 0",
+	},
+	{
+		Name: TLSCert, Type: TypeString, Default: TLSCertDefault,
+		Description: "Path of the TLS certificate, served over HTTPS if set",
+	},
+	{
+		Name: TLSKey, Type: TypeString, Default: TLSKeyDefault,
+		Description: "Path of the private key of the TLS certificate",
+	},
+	{
+		Name: TLSCA, Type: TypeString, Default: TLSCADefault,
+		Description: "Path of the CA certificates of clients, required if set",
+	},
+	{
+		Name: MetricsMaxStacks, Type: TypeInt, Default: MetricsMaxStacksDefault,
+		Min: bound(0), Mutable: true,
+		Description: "Maximum number of stacks whose sizes are reported as metrics",
+	},
+	{
+		Name: LogLevel, Type: TypeString, Default: LogLevelDefault,
+		Values: []string{"debug", "info", "warn", "error"}, Mutable: true,
+		Description: "Minimum level of the logged lines",
+	},
+	{
+		Name: LogFormat, Type: TypeString, Default: LogFormatDefault,
+		Values: []string{"text", "json"}, Mutable: true,
+		Description: "Format of the logged lines",
+	},
+}
+
+// Lookup returns the declaration of a config value
+// given its name, if any.
+func Lookup(name string) (Var, bool) {
+	for _, v := range registry {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Var{}, false
+}
+
+// Schema returns the declarations of every config
+// value by their names.
+func Schema() map[string]Var {
+	schema := make(map[string]Var, len(registry))
+	for _, v := range registry {
+		schema[v.Name] = v
+	}
+	return schema
+}
+
+// Validate returns a value of a config value given its name,
+// converted to its type, e.g. 10 for 10.0. It returns an error
+// if the config value is unknown, or the value is not accepted.
+func Validate(name string, value interface{}) (interface{}, error) {
+	v, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown config value %s", name)
+	}
+	return v.Validate(value)
+}
+
+// Check returns an error if the values of the config values, given
+// by their names, are not accepted together, e.g. the ring engine
+// requires a positive MAX_STACK_SIZE, as it is the capacity of its
+// stacks by default. Unset values are given by their defaults.
+func Check(values func(name string) interface{}) error {
+	value := func(name string) interface{} {
+		if v := values(name); v != nil {
+			return v
+		}
+		v, _ := Lookup(name)
+		return v.Default
+	}
+
+	if value(StackEngine) == "ring" {
+		if size, ok := value(MaxStackSize).(int); !ok || size < 1 {
+			return fmt.Errorf("%s ring requires a positive %s", StackEngine, MaxStackSize)
+		}
+	}
+	return nil
+}
+
+// Validate returns a value converted to the type of the config
+// value, e.g. 10 for 10.0. It returns an error if the value is
+// not of its type or is not accepted.
+func (v Var) Validate(value interface{}) (interface{}, error) {
+	if v.Type == TypeString {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string", v.Name)
+		}
+		if len(v.Values) == 0 {
+			return s, nil
+		}
+		for _, accepted := range v.Values {
+			if s == accepted {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("%s must be one of %s", v.Name, strings.Join(v.Values, ", "))
+	}
+
+	var i int
+	switch n := value.(type) {
+	case int:
+		i = n
+	case int64:
+		i = int(n)
+	case float64:
+		if n != math.Trunc(n) || math.Abs(n) >= 1<<63 {
+			return nil, fmt.Errorf("%s must be an integer", v.Name)
+		}
+		i = int(n)
+	default:
+		return nil, fmt.Errorf("%s must be an integer", v.Name)
+	}
+
+	if i == 0 && v.AllowZero {
+		return i, nil
+	}
+	if v.Min != nil && i < *v.Min {
+		return nil, fmt.Errorf("%s must be at least %d", v.Name, *v.Min)
+	}
+	if v.Max != nil && i > *v.Max {
+		return nil, fmt.Errorf("%s must be at most %d", v.Name, *v.Max)
+	}
+	return i, nil
+}
@@ -0,0 +1,102 @@
+package vars
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	v, ok := Lookup(MaxStackSize)
+	if !ok {
+		t.Fatalf("%s is not declared", MaxStackSize)
+	}
+	if v.Name != MaxStackSize || v.Type != TypeInt || v.Default != MaxStackSizeDefault || !v.Mutable {
+		t.Errorf("declaration is %+v, unexpected", v)
+	}
+
+	if _, ok := Lookup("foo"); ok {
+		t.Error("foo is declared, expected not to")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema := Schema()
+	if len(schema) != len(registry) {
+		t.Errorf("schema has %d values, expected %d", len(schema), len(registry))
+	}
+
+	for name, v := range schema {
+		if v.Name != name {
+			t.Errorf("name of %s is %s", name, v.Name)
+		}
+		if v.Description == "" {
+			t.Errorf("%s has no description", name)
+		}
+		if _, err := v.Validate(v.Default); err != nil {
+			t.Errorf("default of %s is not valid: %v", name, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	inputOutput := []struct {
+		name   string
+		value  interface{}
+		output interface{}
+		valid  bool
+	}{
+		{MaxStackSize, 10, 10, true},
+		{MaxStackSize, 10.0, 10, true},
+		{MaxStackSize, int64(10), 10, true},
+		{MaxStackSize, -1, -1, true},
+		{MaxStackSize, -2, nil, false},
+		{MaxStackSize, -2.0, nil, false},
+		{MaxStackSize, 10.5, nil, false},
+		{MaxStackSize, 1e100, nil, false},
+		{MaxStackSize, "10", nil, false},
+		{Port, 8080, 8080, true},
+		{Port, 80, nil, false},
+		{Port, 65535, 65535, true},
+		{Port, 65536, nil, false},
+		{Port, 70000, nil, false},
+		{RESPPort, 0, 0, true},
+		{RESPPort, 6379, 6379, true},
+		{RESPPort, 1, nil, false},
+		{LogLevel, "debug", "debug", true},
+		{LogLevel, "trace", nil, false},
+		{LogLevel, 1, nil, false},
+		{SnapshotPath, "/tmp/pilad.snapshot", "/tmp/pilad.snapshot", true},
+		{SnapshotPath, nil, nil, false},
+		{"foo", 1, nil, false},
+	}
+
+	for _, io := range inputOutput {
+		output, err := Validate(io.name, io.value)
+		if (err == nil) != io.valid {
+			t.Errorf("err for %s %v is %v, expected valid %v", io.name, io.value, err, io.valid)
+		}
+		if output != io.output {
+			t.Errorf("value for %s %v is %v, expected %v", io.name, io.value, output, io.output)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	inputOutput := []struct {
+		values map[string]interface{}
+		valid  bool
+	}{
+		{map[string]interface{}{}, true},
+		{map[string]interface{}{StackEngine: "memory"}, true},
+		{map[string]interface{}{StackEngine: "ring", MaxStackSize: 10}, true},
+		{map[string]interface{}{StackEngine: "ring", MaxStackSize: -1}, false},
+		{map[string]interface{}{StackEngine: "ring", MaxStackSize: 0}, false},
+		{map[string]interface{}{StackEngine: "ring"}, false},
+	}
+
+	for _, io := range inputOutput {
+		err := Check(func(name string) interface{} {
+			return io.values[name]
+		})
+		if (err == nil) != io.valid {
+			t.Errorf("err for %v is %v, expected valid %v", io.values, err, io.valid)
+		}
+	}
+}
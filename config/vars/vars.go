@@ -29,11 +29,127 @@ const (
 	WriteTimeoutDefault = 45
 
 	// Port is the TCP port number where pilad
-	// is running. Port number range is 1025-65536.
+	// is running. Port number range is 1025-65535.
 	Port = "PORT"
 	// PortDefault represents the default value
 	// of Port.
 	PortDefault = 1205
+
+	// SnapshotPath is the path of the file where
+	// pilad stores the snapshots of its data.
+	// Snapshots are disabled if empty.
+	SnapshotPath = "SNAPSHOT_PATH"
+	// SnapshotPathDefault represents the default value
+	// of SnapshotPath.
+	SnapshotPathDefault = ""
+
+	// SnapshotInterval is the number of seconds between
+	// two periodic snapshots. Periodic snapshots are
+	// disabled if 0.
+	SnapshotInterval = "SNAPSHOT_INTERVAL"
+	// SnapshotIntervalDefault represents the default value
+	// of SnapshotInterval.
+	SnapshotIntervalDefault = 0
+
+	// AOFPath is the path of the append-only file where
+	// pilad logs every operation that modifies its data.
+	// The append-only file is disabled if empty.
+	AOFPath = "AOF_PATH"
+	// AOFPathDefault represents the default value
+	// of AOFPath.
+	AOFPathDefault = ""
+
+	// AOFFsync is the policy used to sync the append-only
+	// file to disk: "always", "everysec" or "no".
+	AOFFsync = "AOF_FSYNC"
+	// AOFFsyncDefault represents the default value
+	// of AOFFsync.
+	AOFFsyncDefault = "everysec"
+
+	// AOFRewriteMinSize is the minimum size in bytes that
+	// the append-only file must reach before being rewritten.
+	// It is rewritten once it doubles its size since the last
+	// rewrite.
+	AOFRewriteMinSize = "AOF_REWRITE_MIN_SIZE"
+	// AOFRewriteMinSizeDefault represents the default value
+	// of AOFRewriteMinSize.
+	AOFRewriteMinSizeDefault = 67108864
+
+	// DiskPath is the path of the directory where stacks
+	// using the disk engine store their elements. The disk
+	// engine is disabled if empty.
+	DiskPath = "DISK_PATH"
+	// DiskPathDefault represents the default value
+	// of DiskPath.
+	DiskPathDefault = ""
+
+	// StackEngine is the name of the engine used by
+	// stacks created without an explicit one.
+	StackEngine = "STACK_ENGINE"
+	// StackEngineDefault represents the default value
+	// of StackEngine.
+	StackEngineDefault = "memory"
+
+	// RESPPort is the TCP port number where pilad listens
+	// to Redis clients, speaking the RESP2 protocol. The
+	// RESP listener is disabled if 0.
+	RESPPort = "RESP_PORT"
+	// RESPPortDefault represents the default value
+	// of RESPPort.
+	RESPPortDefault = 0
+
+	// GRPCPort is the TCP port number where pilad serves
+	// the gRPC API. The gRPC listener is disabled if 0.
+	GRPCPort = "GRPC_PORT"
+	// GRPCPortDefault represents the default value
+	// of GRPCPort.
+	GRPCPortDefault = 0
+
+	// TLSCert is the path of the PEM encoded certificate
+	// of pilad. pilad serves HTTPS if it is set along with
+	// TLSKey, and HTTP otherwise.
+	TLSCert = "TLS_CERT"
+	// TLSCertDefault represents the default value
+	// of TLSCert.
+	TLSCertDefault = ""
+
+	// TLSKey is the path of the PEM encoded private key
+	// of the certificate of pilad.
+	TLSKey = "TLS_KEY"
+	// TLSKeyDefault represents the default value
+	// of TLSKey.
+	TLSKeyDefault = ""
+
+	// TLSCA is the path of the PEM encoded certificates
+	// of the authorities that sign client certificates. If
+	// set, clients must present a certificate signed by one
+	// of them.
+	TLSCA = "TLS_CA"
+	// TLSCADefault represents the default value
+	// of TLSCA.
+	TLSCADefault = ""
+
+	// MetricsMaxStacks is the maximum number of stacks
+	// whose sizes are reported as metrics. Sizes are not
+	// reported if there are more stacks, or if 0.
+	MetricsMaxStacks = "METRICS_MAX_STACKS"
+	// MetricsMaxStacksDefault represents the default value
+	// of MetricsMaxStacks.
+	MetricsMaxStacksDefault = 0
+
+	// LogLevel is the minimum level of the lines that
+	// pilad logs: "debug", "info", "warn" or "error".
+	LogLevel = "LOG_LEVEL"
+	// LogLevelDefault represents the default value
+	// of LogLevel.
+	LogLevelDefault = "info"
+
+	// LogFormat is the format of the lines that pilad
+	// logs: "text" or "json".
+	LogFormat = "LOG_FORMAT"
+	// LogFormatDefault represents the default value
+	// of LogFormat.
+	LogFormatDefault = "text"
 )
 
 // Env returns the environment variable name
@@ -45,15 +161,17 @@ func Env(name string) string {
 // DefaultInt returns the default value of a config
 // name of int type.
 func DefaultInt(name string) int {
-	switch name {
-	case MaxStackSize:
-		return MaxStackSizeDefault
-	case ReadTimeout:
-		return ReadTimeoutDefault
-	case WriteTimeout:
-		return WriteTimeoutDefault
-	case Port:
-		return PortDefault
+	if v, ok := Lookup(name); ok && v.Type == TypeInt {
+		return v.Default.(int)
 	}
 	return -1
 }
+
+// DefaultString returns the default value of a config
+// name of string type.
+func DefaultString(name string) string {
+	if v, ok := Lookup(name); ok && v.Type == TypeString {
+		return v.Default.(string)
+	}
+	return ""
+}
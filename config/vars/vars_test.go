@@ -20,6 +20,11 @@ func TestDefaultInt(t *testing.T) {
 		{ReadTimeout, ReadTimeoutDefault},
 		{WriteTimeout, WriteTimeoutDefault},
 		{Port, PortDefault},
+		{SnapshotInterval, SnapshotIntervalDefault},
+		{AOFRewriteMinSize, AOFRewriteMinSizeDefault},
+		{RESPPort, RESPPortDefault},
+		{GRPCPort, GRPCPortDefault},
+		{MetricsMaxStacks, MetricsMaxStacksDefault},
 		{"foo", -1},
 	}
 
@@ -29,3 +34,28 @@ func TestDefaultInt(t *testing.T) {
 		}
 	}
 }
+
+func TestDefaultString(t *testing.T) {
+	inputOutput := []struct {
+		input  string
+		output string
+	}{
+		{SnapshotPath, SnapshotPathDefault},
+		{AOFPath, AOFPathDefault},
+		{AOFFsync, AOFFsyncDefault},
+		{DiskPath, DiskPathDefault},
+		{StackEngine, StackEngineDefault},
+		{TLSCert, TLSCertDefault},
+		{TLSKey, TLSKeyDefault},
+		{TLSCA, TLSCADefault},
+		{LogLevel, LogLevelDefault},
+		{LogFormat, LogFormatDefault},
+		{"foo", ""},
+	}
+
+	for _, io := range inputOutput {
+		if o := DefaultString(io.input); o != io.output {
+			t.Errorf("DefaultString is %v, expected %v", o, io.output)
+		}
+	}
+}
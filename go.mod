@@ -1,7 +1,12 @@
 module "github.com/fern4lvarez/piladb"
 
 require (
+	"github.com/BurntSushi/toml" v1.6.0
 	"github.com/gorilla/context" v0.0.0-20160226214623-1ea25387ff6f
 	"github.com/gorilla/mux" v1.6.1
 	"github.com/mitchellh/go-homedir" v0.0.0-20161203194507-b8bc1bf76747
+	"golang.org/x/net" v0.57.0
+	"golang.org/x/term" v0.46.0
+	"google.golang.org/grpc" v1.84.0
+	"google.golang.org/protobuf" v1.36.11
 )
@@ -21,9 +21,23 @@ type Database struct {
 	Pila *Pila
 	// Stacks associated to Database mapped by their ID
 	Stacks map[fmt.Stringer]*Stack
+	// IdleTTL is how long the Database can go without being
+	// read, given by ReadAt and the ReadAt of its Stacks, before
+	// it is considered idle. Zero means that the Database never
+	// becomes idle.
+	IdleTTL time.Duration
+	// ReadAt represents the date when the Database was read for
+	// the last time. It must be set by hand, like in Stacks.
+	ReadAt time.Time
+	// dateMu serves as a mutex to lock ReadAt on concurrent
+	// updates in order to avoid race conditions.
+	dateMu sync.Mutex
 	// mu provides a mutex mechanism to avoid data races
 	// when manipulating Databases concurrently.
 	mu sync.Mutex
+	// events is where the changes of the Stacks of the
+	// Database are published.
+	events *EventBus
 }
 
 // NewDatabase creates a new Database given a name,
@@ -34,6 +48,7 @@ func NewDatabase(name string) *Database {
 		ID:     uuid.New(name),
 		Name:   name,
 		Stacks: stacks,
+		events: NewEventBus(name),
 	}
 }
 
@@ -68,6 +83,9 @@ func (db *Database) AddStack(stack *Stack) error {
 	stack.SetDatabase(db)
 	if _, ok := db.Stacks[stack.UUID()]; ok {
 		stack.Database = nil
+		stack.mu.Lock()
+		stack.events = nil
+		stack.mu.Unlock()
 		return fmt.Errorf("database %v already contains stack %v", db.Name, stack.Name)
 	}
 
@@ -75,9 +93,18 @@ func (db *Database) AddStack(stack *Stack) error {
 	return nil
 }
 
+// Subscribe returns a Subscription to the events of the Stack of
+// the Database given by its ID, or of all its Stacks if it is nil.
+// The Subscription is closed once the Stack or the Database is
+// removed.
+func (db *Database) Subscribe(stackID fmt.Stringer) *Subscription {
+	return db.events.Subscribe(stackID)
+}
+
 // RemoveStack removes a Stack from the Database given an id,
 // returning true if it succeeded. It will return false if the
-// Stack wasn't added to the Database.
+// Stack wasn't added to the Database. If the base of the Stack
+// implements io.Closer, it is closed to release its resources.
 func (db *Database) RemoveStack(id fmt.Stringer) bool {
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -86,18 +113,147 @@ func (db *Database) RemoveStack(id fmt.Stringer) bool {
 	if !ok {
 		return false
 	}
+	stack.remove()
 	stack.Database = nil
-	stack.base = nil
 	delete(db.Stacks, id)
 	return true
 }
 
+// removeStacks removes all the Stacks of the Database, so
+// their resources are released and the callers waiting on
+// them are woken up.
+func (db *Database) removeStacks() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for id, stack := range db.Stacks {
+		stack.remove()
+		stack.Database = nil
+		delete(db.Stacks, id)
+	}
+}
+
+// Stack returns the Stack of the Database given by an ID,
+// and a boolean flag stating whether it exists.
+func (db *Database) Stack(id fmt.Stringer) (*Stack, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	stack, ok := db.Stacks[id]
+	return stack, ok
+}
+
+// Move pops the element on top of the Stack given by srcID and pushes
+// it on top of the Stack given by dstID atomically, returning the moved
+// element. It returns ErrEmptyStack if the source Stack was empty, or
+// an error if any of the Stacks does not belong to the Database.
+func (db *Database) Move(srcID, dstID fmt.Stringer) (interface{}, error) {
+	src, ok := db.Stack(srcID)
+	if !ok {
+		return nil, fmt.Errorf("database %v does not contain stack %v", db.Name, srcID)
+	}
+	dst, ok := db.Stack(dstID)
+	if !ok {
+		return nil, fmt.Errorf("database %v does not contain stack %v", db.Name, dstID)
+	}
+
+	element, ok := Move(src, dst)
+	if !ok {
+		return nil, ErrEmptyStack
+	}
+	return element, nil
+}
+
+// Expire removes the expired elements of all the Stacks of the
+// Database, and returns how many were removed.
+func (db *Database) Expire() int {
+	db.mu.Lock()
+	stacks := make([]*Stack, 0, len(db.Stacks))
+	for _, s := range db.Stacks {
+		stacks = append(stacks, s)
+	}
+	db.mu.Unlock()
+
+	var n int
+	for _, s := range stacks {
+		n += s.Expire()
+	}
+	return n
+}
+
+// Sizes returns the sizes of the Stacks of the Database, mapped by
+// their names.
+func (db *Database) Sizes() map[string]int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	sizes := make(map[string]int, len(db.Stacks))
+	for _, s := range db.Stacks {
+		sizes[s.Name] = s.Size()
+	}
+	return sizes
+}
+
+// Read takes a date and updates ReadAt field
+// of the Database.
+func (db *Database) Read(t time.Time) {
+	db.dateMu.Lock()
+	db.ReadAt = t
+	db.dateMu.Unlock()
+}
+
+// Idle returns true if the Database has an IdleTTL, and neither
+// the Database nor any of its Stacks were read for that long at
+// time t.
+func (db *Database) Idle(t time.Time) bool {
+	if db.IdleTTL <= 0 {
+		return false
+	}
+
+	db.dateMu.Lock()
+	readAt := db.ReadAt
+	db.dateMu.Unlock()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, s := range db.Stacks {
+		if r := s.readAt(); r.After(readAt) {
+			readAt = r
+		}
+	}
+	return idle(db.IdleTTL, readAt, t)
+}
+
+// IdleStacks returns the Stacks of the Database that are idle
+// at time t, sorted by name.
+func (db *Database) IdleStacks(t time.Time) []*Stack {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var stacks []*Stack
+	for _, s := range db.Stacks {
+		if s.Idle(t) {
+			stacks = append(stacks, s)
+		}
+	}
+	sort.Slice(stacks, func(i, j int) bool { return stacks[i].Name < stacks[j].Name })
+	return stacks
+}
+
+// idle returns true if something last read at readAt is idle
+// at time t given its idle TTL. It is never idle if the TTL
+// is not positive or if it was never read.
+func idle(ttl time.Duration, readAt, t time.Time) bool {
+	return ttl > 0 && !readAt.IsZero() && t.Sub(readAt) >= ttl
+}
+
 // Status returns the status of the Database.
 func (db *Database) Status() DatabaseStatus {
 	dbs := DatabaseStatus{}
 	dbs.ID = db.ID.String()
 	dbs.Name = db.Name
 	dbs.NumberStacks = len(db.Stacks)
+	dbs.IdleTTL = db.IdleTTL.Seconds()
 
 	var ss sort.StringSlice = make([]string, len(db.Stacks))
 	n := 0
@@ -146,6 +302,7 @@ type DatabaseStatus struct {
 	Name         string   `json:"name"`
 	NumberStacks int      `json:"number_of_stacks"`
 	Stacks       []string `json:"stacks,omitempty"`
+	IdleTTL      float64  `json:"idle_ttl,omitempty"`
 }
 
 // ToJSON converts a DatabaseStatus into JSON.
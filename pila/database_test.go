@@ -4,6 +4,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/fern4lvarez/piladb/pkg/stack"
 )
 
 func TestNewDatabase(t *testing.T) {
@@ -144,12 +146,39 @@ func TestDatabaseRemoveStack(t *testing.T) {
 		t.Errorf("stack %s still associated to database %s", stack.Name, stack.Database.Name)
 	}
 
-	if stack.base != nil {
-		t.Errorf("stack %s still points to a base stack", stack.Name)
+	if !stack.removed {
+		t.Errorf("stack %s is not marked as removed", stack.Name)
 	}
 
 }
 
+type closerStack struct {
+	*stack.Stack
+	closed bool
+}
+
+func (s *closerStack) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestDatabaseRemoveStack_Closer(t *testing.T) {
+	db := NewDatabase("test-db")
+	base := &closerStack{Stack: stack.NewStack()}
+	s := NewStackWithBase("test-stack", time.Now(), base)
+
+	if err := db.AddStack(s); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok := db.RemoveStack(s.ID); !ok {
+		t.Errorf("stack %s was not removed from database %s", s.Name, db.Name)
+	}
+	if !base.closed {
+		t.Errorf("base of stack %s was not closed", s.Name)
+	}
+}
+
 func TestDatabaseRemoveStack_False(t *testing.T) {
 	db := NewDatabase("test-db")
 	stack := NewStack("test-stack", time.Now())
@@ -160,6 +189,46 @@ func TestDatabaseRemoveStack_False(t *testing.T) {
 	}
 }
 
+func TestDatabaseIdle(t *testing.T) {
+	now := time.Now()
+	db := NewDatabase("db")
+	db.Read(now)
+	if db.Idle(now.Add(time.Hour)) {
+		t.Error("database without idle TTL is idle")
+	}
+
+	db.IdleTTL = time.Minute
+	if !db.Idle(now.Add(time.Minute)) {
+		t.Error("database read a minute ago is not idle")
+	}
+
+	s := NewStack("stack", now)
+	s.Read(now.Add(30 * time.Second))
+	_ = db.AddStack(s)
+	if db.Idle(now.Add(time.Minute)) {
+		t.Error("database with a stack read 30 seconds ago is idle")
+	}
+	if !db.Idle(now.Add(90 * time.Second)) {
+		t.Error("database with a stack read a minute ago is not idle")
+	}
+}
+
+func TestDatabaseIdleStacks(t *testing.T) {
+	now := time.Now()
+	db := NewDatabase("db")
+	for i, name := range []string{"b", "c", "a"} {
+		s := NewStack(name, now)
+		s.IdleTTL = time.Duration(i+1) * time.Minute
+		s.Read(now)
+		_ = db.AddStack(s)
+	}
+
+	stacks := db.IdleStacks(now.Add(2 * time.Minute))
+	if len(stacks) != 2 || stacks[0].Name != "b" || stacks[1].Name != "c" {
+		t.Errorf("idle stacks are %v, expected b and c", stacks)
+	}
+}
+
 func TestDatabaseStatus(t *testing.T) {
 	db := NewDatabase("db")
 	s0ID := db.CreateStack("s0", time.Now())
@@ -312,3 +381,55 @@ func TestDatabaseRace(t *testing.T) {
 	go func() { _ = NewStack("test-stack-2", time.Now()) }()
 	go func() { _ = db.AddStack(stack) }()
 }
+
+func TestDatabaseStack(t *testing.T) {
+	db := NewDatabase("db")
+	stack := NewStack("stack", time.Now())
+	_ = db.AddStack(stack)
+
+	if s, ok := db.Stack(stack.ID); !ok || s != stack {
+		t.Errorf("Stack is %v, %v, expected %v, true", s, ok, stack)
+	}
+	if s, ok := db.Stack(NewStack("foo", time.Now()).ID); ok || s != nil {
+		t.Errorf("Stack is %v, %v, expected nil, false", s, ok)
+	}
+}
+
+func TestDatabaseMove(t *testing.T) {
+	db := NewDatabase("db")
+	srcID := db.CreateStack("src", time.Now())
+	dstID := db.CreateStack("dst", time.Now())
+	db.Stacks[srcID].Push("foo")
+
+	element, err := db.Move(srcID, dstID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if element != "foo" {
+		t.Errorf("element is %v, expected foo", element)
+	}
+	if db.Stacks[dstID].Peek() != "foo" {
+		t.Errorf("dst peek is %v, expected foo", db.Stacks[dstID].Peek())
+	}
+
+	if _, err := db.Move(srcID, dstID); err != ErrEmptyStack {
+		t.Errorf("err is %v, expected %v", err, ErrEmptyStack)
+	}
+}
+
+func TestDatabaseMove_Error(t *testing.T) {
+	db := NewDatabase("db")
+	id := db.CreateStack("stack", time.Now())
+	db.Stacks[id].Push("foo")
+	missing := NewStack("missing", time.Now()).ID
+
+	if _, err := db.Move(missing, id); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if _, err := db.Move(id, missing); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if db.Stacks[id].Size() != 1 {
+		t.Errorf("size is %d, expected 1", db.Stacks[id].Size())
+	}
+}
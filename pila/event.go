@@ -0,0 +1,199 @@
+package pila
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Types of the events published when a Stack changes.
+const (
+	// EventPush is published when an element is pushed
+	// on top of a Stack.
+	EventPush = "push"
+	// EventPop is published when the element on top of
+	// a Stack is popped.
+	EventPop = "pop"
+	// EventFlush is published when a Stack is flushed.
+	EventFlush = "flush"
+	// EventDelete is published when a Stack is removed
+	// from its Database.
+	EventDelete = "delete"
+)
+
+// OpPeek is the type of the operation that reads the element on
+// top of a Stack, which does not publish any event.
+const OpPeek = "peek"
+
+// Hook is called after the operations on the Stacks of a Database,
+// given the name of the Database, the name of the Stack, the type of
+// the operation, which is the type of the event it publishes or
+// OpPeek, and the number of elements it involves. It is called while
+// the Stack is locked, so it must be fast and must not access it.
+type Hook func(database, stack, op string, n int)
+
+// EventBufferSize is the number of events that a Subscription
+// buffers. Subscriptions that fall further behind are closed, so
+// publishing never blocks the operations on Stacks.
+const EventBufferSize = 256
+
+// Event represents a change of a Stack. Element is the pushed or
+// popped element, and Size is the size of the Stack after the change.
+type Event struct {
+	Type     string      `json:"type"`
+	Database string      `json:"database"`
+	StackID  string      `json:"stack_id"`
+	Stack    string      `json:"stack"`
+	Element  interface{} `json:"element,omitempty"`
+	Size     int         `json:"size"`
+	Time     time.Time   `json:"time"`
+}
+
+// ToJSON converts an Event into JSON.
+func (event Event) ToJSON() ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// EventBus delivers the events of the Stacks of a Database to
+// its subscribers.
+type EventBus struct {
+	database string
+
+	// mu guards subscriptions, closed and hook.
+	mu            sync.Mutex
+	subscriptions map[*Subscription]struct{}
+	closed        bool
+	hook          Hook
+}
+
+// NewEventBus creates an EventBus for the Stacks of the Database
+// given by its name.
+func NewEventBus(database string) *EventBus {
+	return &EventBus{
+		database:      database,
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe returns a Subscription to the events of the Stack
+// given by its ID, or of all Stacks if it is nil.
+func (bus *EventBus) Subscribe(stackID fmt.Stringer) *Subscription {
+	c := make(chan Event, EventBufferSize)
+	sub := &Subscription{C: c, c: c, bus: bus}
+	if stackID != nil {
+		sub.stackID = stackID.String()
+	}
+
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	if bus.closed {
+		close(c)
+		return sub
+	}
+	bus.subscriptions[sub] = struct{}{}
+	return sub
+}
+
+// Subscribed returns true if the EventBus has any subscribers.
+func (bus *EventBus) Subscribed() bool {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	return len(bus.subscriptions) > 0
+}
+
+// SetHook sets the Hook called after the operations on the Stacks
+// of the Database, or removes it if nil.
+func (bus *EventBus) SetHook(hook Hook) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	bus.hook = hook
+}
+
+// observe calls the Hook, if any, with an operation on a Stack.
+func (bus *EventBus) observe(stack, op string, n int) {
+	bus.mu.Lock()
+	hook := bus.hook
+	bus.mu.Unlock()
+
+	if hook != nil && n > 0 {
+		hook(bus.database, stack, op, n)
+	}
+}
+
+// Publish delivers an event to the subscribers of its Stack,
+// setting its Database. Subscribers whose buffer is full are
+// unsubscribed.
+func (bus *EventBus) Publish(event Event) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	event.Database = bus.database
+	for sub := range bus.subscriptions {
+		if sub.stackID != "" && sub.stackID != event.StackID {
+			continue
+		}
+
+		select {
+		case sub.c <- event:
+		default:
+			bus.unsubscribe(sub)
+		}
+	}
+}
+
+// CloseStack closes the subscriptions to the events of the Stack
+// given by its ID.
+func (bus *EventBus) CloseStack(stackID fmt.Stringer) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	for sub := range bus.subscriptions {
+		if sub.stackID == stackID.String() {
+			bus.unsubscribe(sub)
+		}
+	}
+}
+
+// Close closes all subscriptions, and the ones made afterwards.
+func (bus *EventBus) Close() {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	for sub := range bus.subscriptions {
+		bus.unsubscribe(sub)
+	}
+	bus.closed = true
+}
+
+// unsubscribe removes a Subscription from a locked EventBus,
+// and closes its channel.
+func (bus *EventBus) unsubscribe(sub *Subscription) {
+	if _, ok := bus.subscriptions[sub]; !ok {
+		return
+	}
+	delete(bus.subscriptions, sub)
+	close(sub.c)
+}
+
+// Subscription receives the events of the Stacks of a Database.
+type Subscription struct {
+	// C delivers the events. It is closed once the Subscription
+	// is closed, falls behind, or its Stack or Database is removed.
+	C <-chan Event
+
+	c       chan Event
+	stackID string
+	bus     *EventBus
+}
+
+// Close stops the delivery of events, and closes C.
+func (sub *Subscription) Close() {
+	sub.bus.mu.Lock()
+	defer sub.bus.mu.Unlock()
+
+	sub.bus.unsubscribe(sub)
+}
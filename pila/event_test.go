@@ -0,0 +1,244 @@
+package pila
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pkg/stack"
+	"github.com/fern4lvarez/piladb/pkg/uuid"
+)
+
+// events returns the events received by a Subscription
+// until it has none buffered.
+func events(sub *Subscription) []Event {
+	var es []Event
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return es
+			}
+			e.Time = time.Time{}
+			es = append(es, e)
+		default:
+			return es
+		}
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus("db")
+	all := bus.Subscribe(nil)
+	foo := bus.Subscribe(uuid.New("dbfoo"))
+
+	if !bus.Subscribed() {
+		t.Error("bus.Subscribed() is false, expected true")
+	}
+
+	bus.Publish(Event{Type: EventPush, StackID: uuid.New("dbfoo").String(), Stack: "foo", Element: 1, Size: 1})
+	bus.Publish(Event{Type: EventPush, StackID: uuid.New("dbbar").String(), Stack: "bar", Element: 2, Size: 1})
+
+	if es := events(all); len(es) != 2 || es[0].Database != "db" || es[1].Stack != "bar" {
+		t.Errorf("events are %v, expected both events of db", es)
+	}
+	if es := events(foo); len(es) != 1 || es[0].Stack != "foo" {
+		t.Errorf("events are %v, expected the event of foo", es)
+	}
+
+	bus.CloseStack(uuid.New("dbfoo"))
+	if _, ok := <-foo.C; ok {
+		t.Error("subscription to foo is open, expected closed")
+	}
+
+	all.Close()
+	all.Close()
+	if _, ok := <-all.C; ok {
+		t.Error("subscription is open, expected closed")
+	}
+	if bus.Subscribed() {
+		t.Error("bus.Subscribed() is true, expected false")
+	}
+}
+
+func TestEventBus_Full(t *testing.T) {
+	bus := NewEventBus("db")
+	sub := bus.Subscribe(nil)
+
+	for i := 0; i <= EventBufferSize; i++ {
+		bus.Publish(Event{Type: EventPush, Element: i})
+	}
+
+	if n := len(events(sub)); n != EventBufferSize {
+		t.Errorf("received %d events, expected %d", n, EventBufferSize)
+	}
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription is open, expected closed")
+	}
+}
+
+func TestEventBus_Close(t *testing.T) {
+	bus := NewEventBus("db")
+	sub := bus.Subscribe(nil)
+	bus.Close()
+
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription is open, expected closed")
+	}
+	if _, ok := <-bus.Subscribe(nil).C; ok {
+		t.Error("subscription is open, expected closed")
+	}
+}
+
+func TestStackEvents(t *testing.T) {
+	db := NewDatabase("db")
+	s := NewStack("stack", time.Now())
+	_ = db.AddStack(s)
+	other := NewStack("other", time.Now())
+	_ = db.AddStack(other)
+
+	sub := db.Subscribe(s.UUID())
+	all := db.Subscribe(nil)
+
+	s.Push("foo")
+	_ = s.PushExpiring("bar", time.Now().Add(time.Hour))
+	_ = s.CompareAndPush(s.Version(), "baz")
+	s.Pop()
+	_, _ = s.CompareAndPop(s.Version())
+	s.PushMany([]interface{}{1, 2})
+	s.PopMany(3)
+	s.Pop()
+	s.Flush()
+	Move(other, s)
+	other.Push("qux")
+	Move(other, s)
+
+	id := s.UUID().String()
+	expected := []Event{
+		{Type: EventPush, Database: "db", StackID: id, Stack: "stack", Element: "foo", Size: 1},
+		{Type: EventPush, Database: "db", StackID: id, Stack: "stack", Element: "bar", Size: 2},
+		{Type: EventPush, Database: "db", StackID: id, Stack: "stack", Element: "baz", Size: 3},
+		{Type: EventPop, Database: "db", StackID: id, Stack: "stack", Element: "baz", Size: 2},
+		{Type: EventPop, Database: "db", StackID: id, Stack: "stack", Element: "bar", Size: 1},
+		{Type: EventPush, Database: "db", StackID: id, Stack: "stack", Element: 1, Size: 2},
+		{Type: EventPush, Database: "db", StackID: id, Stack: "stack", Element: 2, Size: 3},
+		{Type: EventPop, Database: "db", StackID: id, Stack: "stack", Element: 2, Size: 2},
+		{Type: EventPop, Database: "db", StackID: id, Stack: "stack", Element: 1, Size: 1},
+		{Type: EventPop, Database: "db", StackID: id, Stack: "stack", Element: "foo", Size: 0},
+		{Type: EventFlush, Database: "db", StackID: id, Stack: "stack", Size: 0},
+		{Type: EventPush, Database: "db", StackID: id, Stack: "stack", Element: "qux", Size: 1},
+	}
+	if es := events(sub); !reflect.DeepEqual(es, expected) {
+		t.Errorf("events are %v, expected %v", es, expected)
+	}
+
+	// the subscription to the database also receives
+	// the events of the other Stack
+	if n := len(events(all)); n != len(expected)+2 {
+		t.Errorf("received %d events, expected %d", n, len(expected)+2)
+	}
+
+	db.RemoveStack(s.UUID())
+	expected = []Event{{Type: EventDelete, Database: "db", StackID: id, Stack: "stack"}}
+	if es := events(sub); !reflect.DeepEqual(es, expected) {
+		t.Errorf("events are %v, expected %v", es, expected)
+	}
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription to stack is open, expected closed")
+	}
+	if es := events(all); !reflect.DeepEqual(es, expected) {
+		t.Errorf("events are %v, expected %v", es, expected)
+	}
+
+	// pushes into a removed Stack are not published
+	s = NewStack("stack", time.Now())
+	s.Push("foo")
+	if es := events(all); len(es) != 0 {
+		t.Errorf("events are %v, expected none", es)
+	}
+}
+
+func TestStackEvents_Evict(t *testing.T) {
+	db := NewDatabase("db")
+	base, _ := stack.NewRingStack(2)
+	s := NewStackWithBase("stack", time.Now(), base)
+	_ = db.AddStack(s)
+	sub := db.Subscribe(s.UUID())
+
+	s.PushMany([]interface{}{1, 2, 3})
+
+	var sizes []int
+	for _, e := range events(sub) {
+		sizes = append(sizes, e.Size)
+	}
+	if expected := []int{1, 2, 2}; !reflect.DeepEqual(sizes, expected) {
+		t.Errorf("sizes are %v, expected %v", sizes, expected)
+	}
+}
+
+func TestStackEvents_NotAdded(t *testing.T) {
+	db := NewDatabase("db")
+	_ = db.AddStack(NewStack("stack", time.Now()))
+	sub := db.Subscribe(nil)
+
+	// the Stack is not added as there is one with its name
+	s := NewStack("stack", time.Now())
+	if err := db.AddStack(s); err == nil {
+		t.Fatal("err is nil, expected error")
+	}
+	s.Push("foo")
+
+	if es := events(sub); len(es) != 0 {
+		t.Errorf("events are %v, expected none", es)
+	}
+}
+
+func TestTransactionEvents(t *testing.T) {
+	db := NewDatabase("db")
+	db.CreateStack("foo", time.Now())
+	db.CreateStack("bar", time.Now())
+	sub := db.Subscribe(nil)
+
+	_, err := db.Transaction([]TxOp{
+		{Op: TxPush, Stack: "foo", Element: 1},
+		{Op: TxPush, Stack: "bar", Element: 2},
+		{Op: TxPop, Stack: "foo"},
+		{Op: TxFlush, Stack: "bar"},
+	}, -1, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types []string
+	for _, e := range events(sub) {
+		types = append(types, e.Type+" "+e.Stack)
+	}
+	expected := []string{"push foo", "push bar", "pop foo", "flush bar"}
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("events are %v, expected %v", types, expected)
+	}
+
+	// operations rolled back are not published
+	_, err = db.Transaction([]TxOp{
+		{Op: TxPush, Stack: "foo", Element: 1},
+		{Op: TxPop, Stack: "bar"},
+	}, -1, time.Now())
+	if err == nil {
+		t.Fatal("err is nil, expected error")
+	}
+	if es := events(sub); len(es) != 0 {
+		t.Errorf("events are %v, expected none", es)
+	}
+}
+
+func TestPilaRemoveDatabase_Events(t *testing.T) {
+	p := NewPila()
+	db := NewDatabase("db")
+	_ = p.AddDatabase(db)
+	sub := db.Subscribe(nil)
+
+	p.RemoveDatabase(db.ID)
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription is open, expected closed")
+	}
+}
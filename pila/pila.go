@@ -6,12 +6,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 )
 
 // Pila contains a reference to all the existing Databases, i.e.
 // the currently running piladb instance.
 type Pila struct {
 	Databases map[fmt.Stringer]*Database
+	// mu provides a mutex mechanism to avoid data races
+	// when manipulating Databases concurrently.
+	mu sync.RWMutex
+	// hook is set to the Databases of the Pila, so it is
+	// called after the operations on their Stacks.
+	hook Hook
 }
 
 // Status contains the status of the Pila instance.
@@ -34,8 +43,12 @@ func NewPila() *Pila {
 // If a Database called `name` already exists, it will be restarted. So
 // please consider using AddDatabase in case of possible conflicts.
 func (p *Pila) CreateDatabase(name string) fmt.Stringer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	db := NewDatabase(name)
 	db.Pila = p
+	db.events.SetHook(p.hook)
 	p.Databases[db.ID] = db
 	return db.ID
 }
@@ -43,6 +56,9 @@ func (p *Pila) CreateDatabase(name string) fmt.Stringer {
 // AddDatabase adds a given Database to the Pila. It returns and error if the Database
 // already had an assigned Pila, or if the Pila already contained the Database.
 func (p *Pila) AddDatabase(db *Database) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	if db.Pila != nil {
 		return errors.New("database already added to a pila")
 	}
@@ -51,13 +67,30 @@ func (p *Pila) AddDatabase(db *Database) error {
 	}
 
 	db.Pila = p
+	db.events.SetHook(p.hook)
 	p.Databases[db.ID] = db
 	return nil
 }
 
+// SetHook sets the Hook called after the operations on the Stacks of
+// all the Databases of the Pila, including the ones added afterwards,
+// or removes it if nil.
+func (p *Pila) SetHook(hook Hook) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.hook = hook
+	for _, db := range p.Databases {
+		db.events.SetHook(hook)
+	}
+}
+
 // RemoveDatabase deletes a Database given an ID from the Pila and returns
 // true if it succeeded.
 func (p *Pila) RemoveDatabase(id fmt.Stringer) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	db, ok := p.Databases[id]
 	if !ok {
 		return false
@@ -65,6 +98,9 @@ func (p *Pila) RemoveDatabase(id fmt.Stringer) bool {
 
 	delete(p.Databases, id)
 	db.Pila = nil
+	db.events.SetHook(nil)
+	db.removeStacks()
+	db.events.Close()
 	return true
 }
 
@@ -72,12 +108,121 @@ func (p *Pila) RemoveDatabase(id fmt.Stringer) bool {
 // of the Pila, returning a pointer to the Database and a boolean
 // flag.
 func (p *Pila) Database(id fmt.Stringer) (*Database, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	db, ok := p.Databases[id]
 	return db, ok
 }
 
+// Move pops the element on top of the Stack srcStack of the Database
+// srcDatabase and pushes it on top of the Stack dstStack of the Database
+// dstDatabase atomically, returning the moved element. It returns
+// ErrEmptyStack if the source Stack was empty, or an error if any of
+// the Databases or Stacks does not exist.
+func (p *Pila) Move(srcDatabase, srcStack, dstDatabase, dstStack fmt.Stringer) (interface{}, error) {
+	srcDB, ok := p.Database(srcDatabase)
+	if !ok {
+		return nil, fmt.Errorf("pila does not contain database %v", srcDatabase)
+	}
+	dstDB, ok := p.Database(dstDatabase)
+	if !ok {
+		return nil, fmt.Errorf("pila does not contain database %v", dstDatabase)
+	}
+
+	src, ok := srcDB.Stack(srcStack)
+	if !ok {
+		return nil, fmt.Errorf("database %v does not contain stack %v", srcDB.Name, srcStack)
+	}
+	dst, ok := dstDB.Stack(dstStack)
+	if !ok {
+		return nil, fmt.Errorf("database %v does not contain stack %v", dstDB.Name, dstStack)
+	}
+
+	element, ok := Move(src, dst)
+	if !ok {
+		return nil, ErrEmptyStack
+	}
+	return element, nil
+}
+
+// Expire removes the expired elements of all the Stacks of the
+// Pila, and returns how many were removed.
+func (p *Pila) Expire() int {
+	p.mu.RLock()
+	databases := make([]*Database, 0, len(p.Databases))
+	for _, db := range p.Databases {
+		databases = append(databases, db)
+	}
+	p.mu.RUnlock()
+
+	var n int
+	for _, db := range databases {
+		n += db.Expire()
+	}
+	return n
+}
+
+// IdleDatabases returns the Databases of the Pila that are
+// idle at time t, sorted by name.
+func (p *Pila) IdleDatabases(t time.Time) []*Database {
+	p.mu.RLock()
+	databases := make([]*Database, 0, len(p.Databases))
+	for _, db := range p.Databases {
+		databases = append(databases, db)
+	}
+	p.mu.RUnlock()
+
+	var idle []*Database
+	for _, db := range databases {
+		if db.Idle(t) {
+			idle = append(idle, db)
+		}
+	}
+	sort.Slice(idle, func(i, j int) bool { return idle[i].Name < idle[j].Name })
+	return idle
+}
+
+// IdleStacks returns the Stacks of all the Databases of the Pila
+// that are idle at time t, sorted by Database and Stack name.
+func (p *Pila) IdleStacks(t time.Time) []*Stack {
+	p.mu.RLock()
+	databases := make([]*Database, 0, len(p.Databases))
+	for _, db := range p.Databases {
+		databases = append(databases, db)
+	}
+	p.mu.RUnlock()
+	sort.Slice(databases, func(i, j int) bool { return databases[i].Name < databases[j].Name })
+
+	var idle []*Stack
+	for _, db := range databases {
+		idle = append(idle, db.IdleStacks(t)...)
+	}
+	return idle
+}
+
+// Sizes returns the sizes of the Stacks of all the Databases of the
+// Pila, mapped by the name of their Database and their own name.
+func (p *Pila) Sizes() map[string]map[string]int {
+	p.mu.RLock()
+	databases := make([]*Database, 0, len(p.Databases))
+	for _, db := range p.Databases {
+		databases = append(databases, db)
+	}
+	p.mu.RUnlock()
+
+	sizes := make(map[string]map[string]int, len(databases))
+	for _, db := range databases {
+		sizes[db.Name] = db.Sizes()
+	}
+	return sizes
+}
+
 // Status returns the status of the Pila.
 func (p *Pila) Status() Status {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	ps := Status{}
 	ps.NumberDatabases = len(p.Databases)
 
@@ -88,6 +233,7 @@ func (p *Pila) Status() Status {
 			ID:           db.ID.String(),
 			Name:         db.Name,
 			NumberStacks: len(db.Stacks),
+			IdleTTL:      db.IdleTTL.Seconds(),
 		}
 		dbs[n] = ds
 		n++
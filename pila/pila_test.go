@@ -1,8 +1,13 @@
 package pila
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pkg/stack"
+	"github.com/fern4lvarez/piladb/pkg/uuid"
 )
 
 func TestNewPila(t *testing.T) {
@@ -82,6 +87,34 @@ func TestPilaRemoveDatabase(t *testing.T) {
 	}
 }
 
+func TestPilaRemoveDatabase_Stacks(t *testing.T) {
+	pila := NewPila()
+	db := NewDatabase("test")
+	pila.AddDatabase(db)
+	base := &closerStack{Stack: stack.NewStack()}
+	s := NewStackWithBase("test-stack", time.Now(), base)
+	_ = db.AddStack(s)
+
+	done := make(chan bool)
+	go func() { done <- s.Wait(time.Hour, false) }()
+	for waiters(s) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if ok := pila.RemoveDatabase(db.ID); !ok {
+		t.Errorf("RemoveDatabase did not succeed")
+	}
+	if ok := <-done; ok {
+		t.Error("s.Wait() is true, expected false")
+	}
+	if !base.closed {
+		t.Errorf("base of stack %s was not closed", s.Name)
+	}
+	if len(db.Stacks) != 0 || s.Database != nil {
+		t.Errorf("stack %s was not removed from database %s", s.Name, db.Name)
+	}
+}
+
 func TestPilaRemoveDatabase_False(t *testing.T) {
 	pila := NewPila()
 	db := NewDatabase("test")
@@ -118,6 +151,169 @@ func TestPilaDatabase_False(t *testing.T) {
 	}
 }
 
+func TestPilaMove(t *testing.T) {
+	pila := NewPila()
+	db1 := pila.CreateDatabase("db1")
+	db2 := pila.CreateDatabase("db2")
+	src := pila.Databases[db1].CreateStack("src", time.Now())
+	dst := pila.Databases[db2].CreateStack("dst", time.Now())
+	pila.Databases[db1].Stacks[src].Push("foo")
+
+	element, err := pila.Move(db1, src, db2, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if element != "foo" {
+		t.Errorf("element is %v, expected foo", element)
+	}
+	if size := pila.Databases[db1].Stacks[src].Size(); size != 0 {
+		t.Errorf("src size is %d, expected 0", size)
+	}
+	if peek := pila.Databases[db2].Stacks[dst].Peek(); peek != "foo" {
+		t.Errorf("dst peek is %v, expected foo", peek)
+	}
+
+	if _, err := pila.Move(db1, src, db2, dst); err != ErrEmptyStack {
+		t.Errorf("err is %v, expected %v", err, ErrEmptyStack)
+	}
+}
+
+func TestPilaMove_Error(t *testing.T) {
+	pila := NewPila()
+	db := pila.CreateDatabase("db")
+	id := pila.Databases[db].CreateStack("stack", time.Now())
+	missing := NewDatabase("missing").ID
+
+	if _, err := pila.Move(missing, id, db, id); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if _, err := pila.Move(db, id, missing, id); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if _, err := pila.Move(db, missing, db, id); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if _, err := pila.Move(db, id, db, missing); err == nil {
+		t.Error("err is nil, expected error")
+	}
+}
+
+func TestPilaExpire(t *testing.T) {
+	pila := NewPila()
+	db1 := pila.CreateDatabase("db1")
+	db2 := pila.CreateDatabase("db2")
+	s1 := pila.Databases[db1].CreateStack("s1", time.Now())
+	s2 := pila.Databases[db2].CreateStack("s2", time.Now())
+	_ = pila.Databases[db1].Stacks[s1].PushExpiring("foo", time.Now().Add(-time.Second))
+	_ = pila.Databases[db2].Stacks[s2].PushExpiring("bar", time.Now().Add(-time.Second))
+	_ = pila.Databases[db2].Stacks[s2].PushExpiring("baz", time.Now().Add(-time.Second))
+	pila.Databases[db2].Stacks[s2].Push("qux")
+
+	if n := pila.Expire(); n != 3 {
+		t.Errorf("expired %d elements, expected %d", n, 3)
+	}
+	if n := pila.Expire(); n != 0 {
+		t.Errorf("expired %d elements, expected %d", n, 0)
+	}
+}
+
+func TestPilaIdle(t *testing.T) {
+	now := time.Now()
+	pila := NewPila()
+	for _, name := range []string{"db2", "db1", "db3"} {
+		db := NewDatabase(name)
+		db.IdleTTL = time.Minute
+		db.Read(now)
+		_ = pila.AddDatabase(db)
+
+		s := NewStack("stack", now)
+		s.IdleTTL = time.Second
+		s.Read(now)
+		_ = db.AddStack(s)
+	}
+	pila.Databases[uuid.New("db3")].Stacks[uuid.New("db3stack")].Read(now.Add(time.Hour))
+
+	databases := pila.IdleDatabases(now.Add(time.Minute))
+	if len(databases) != 2 || databases[0].Name != "db1" || databases[1].Name != "db2" {
+		t.Errorf("idle databases are %v, expected db1 and db2", databases)
+	}
+
+	stacks := pila.IdleStacks(now.Add(time.Minute))
+	if len(stacks) != 2 || stacks[0].Database.Name != "db1" || stacks[1].Database.Name != "db2" {
+		t.Errorf("idle stacks are %v, expected the stacks of db1 and db2", stacks)
+	}
+}
+
+func TestPilaSetHook(t *testing.T) {
+	pila := NewPila()
+	db1 := pila.CreateDatabase("db1")
+	s1 := pila.Databases[db1].CreateStack("s1", time.Now())
+
+	var ops []string
+	pila.SetHook(func(database, stack, op string, n int) {
+		ops = append(ops, fmt.Sprintf("%s/%s %s %d", database, stack, op, n))
+	})
+	db2 := NewDatabase("db2")
+	_ = pila.AddDatabase(db2)
+	s2 := db2.CreateStack("s2", time.Now())
+
+	pila.Databases[db1].Stacks[s1].Push("foo")
+	pila.Databases[db1].Stacks[s1].PushMany([]interface{}{1, 2})
+	pila.Databases[db1].Stacks[s1].Peek()
+	pila.Databases[db1].Stacks[s1].PopMany(5)
+	pila.Databases[db1].Stacks[s1].PopMany(5)
+	_, _ = pila.Move(db1, s1, db2.ID, s2)
+	db2.Stacks[s2].Push("bar")
+	_, _ = pila.Move(db2.ID, s2, db1, s1)
+	pila.Databases[db1].Stacks[s1].Flush()
+	_, _ = db2.Transaction([]TxOp{{Op: TxPush, Stack: "s2", Element: "baz"}}, -1, time.Now())
+
+	expected := []string{
+		"db1/s1 push 1",
+		"db1/s1 push 2",
+		"db1/s1 peek 1",
+		"db1/s1 pop 3",
+		"db2/s2 push 1",
+		"db2/s2 pop 1",
+		"db1/s1 push 1",
+		"db1/s1 flush 1",
+		"db2/s2 push 1",
+	}
+	if !reflect.DeepEqual(ops, expected) {
+		t.Errorf("operations are %v, expected %v", ops, expected)
+	}
+
+	// removed databases are not observed anymore
+	ops = nil
+	removed := db2.Stacks[s2]
+	pila.RemoveDatabase(db2.ID)
+	removed.Push("qux")
+	pila.SetHook(nil)
+	pila.Databases[db1].Stacks[s1].Push("qux")
+	if ops != nil {
+		t.Errorf("operations are %v, expected none", ops)
+	}
+}
+
+func TestPilaSizes(t *testing.T) {
+	pila := NewPila()
+	db1 := pila.CreateDatabase("db1")
+	db2 := pila.CreateDatabase("db2")
+	s1 := pila.Databases[db1].CreateStack("s1", time.Now())
+	pila.Databases[db1].CreateStack("s2", time.Now())
+	s3 := pila.Databases[db2].CreateStack("s3", time.Now())
+	pila.Databases[db1].Stacks[s1].Push("foo")
+	pila.Databases[db2].Stacks[s3].PushMany([]interface{}{1, 2, 3})
+
+	expected := map[string]map[string]int{
+		"db1": {"s1": 1, "s2": 0},
+		"db2": {"s3": 3},
+	}
+	if sizes := pila.Sizes(); !reflect.DeepEqual(sizes, expected) {
+		t.Errorf("sizes are %v, expected %v", sizes, expected)
+	}
+}
+
 func TestPilaStatusToJSON(t *testing.T) {
 	pila := NewPila()
 	db0 := NewDatabase("db0")
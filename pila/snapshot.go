@@ -0,0 +1,337 @@
+package pila
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pkg/stack"
+)
+
+// SnapshotVersion is the version of the snapshot format. It must be
+// increased whenever the format changes in a backwards incompatible way.
+const SnapshotVersion = 1
+
+// Snapshot represents the serializable state of a Pila at a
+// given time.
+type Snapshot struct {
+	Version   int                `json:"version"`
+	CreatedAt time.Time          `json:"created_at"`
+	Databases []DatabaseSnapshot `json:"databases"`
+	// Config is the DatabaseSnapshot of the config values, if
+	// any, which is kept apart from Databases so no Database
+	// can be restored as config.
+	Config *DatabaseSnapshot `json:"config,omitempty"`
+	// Tokens contains the stored tokens, if any.
+	Tokens []interface{} `json:"tokens,omitempty"`
+}
+
+// DatabaseSnapshot represents the serializable state of a Database.
+type DatabaseSnapshot struct {
+	Name    string          `json:"name"`
+	IdleTTL float64         `json:"idle_ttl,omitempty"`
+	ReadAt  time.Time       `json:"read_at"`
+	Stacks  []StackSnapshot `json:"stacks"`
+}
+
+// StackSnapshot represents the serializable state of a Stack.
+// Elements are sorted from bottom to top, so they can be pushed
+// in order when restoring the Stack.
+type StackSnapshot struct {
+	Name      string        `json:"name"`
+	Engine    string        `json:"engine,omitempty"`
+	Overflow  string        `json:"overflow,omitempty"`
+	Capacity  int           `json:"capacity,omitempty"`
+	IdleTTL   float64       `json:"idle_ttl,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	ReadAt    time.Time     `json:"read_at"`
+	Version   uint64        `json:"version,omitempty"`
+	Elements  []interface{} `json:"elements"`
+	// Checkpoint is the directory where the elements of the
+	// Stack were saved instead, if its base implements the
+	// stack.Checkpointer interface.
+	Checkpoint string `json:"checkpoint,omitempty"`
+	// Expirations contains the time at which each element
+	// expires, zero if it does not. It is only set if any
+	// element expires.
+	Expirations []time.Time `json:"expirations,omitempty"`
+}
+
+// CheckpointFunc returns the directory where the elements of a Stack
+// whose base implements the stack.Checkpointer interface are saved
+// when taking a snapshot, given the name of its Database and its name.
+type CheckpointFunc func(database, stack string) string
+
+// Snapshot returns the Snapshot of the Pila at a given time. The
+// elements of the Stacks whose base implements the stack.Checkpointer
+// interface are saved in the directory returned by checkpoint, unless
+// it is nil, so they are not copied into the Snapshot.
+func (p *Pila) Snapshot(t time.Time, checkpoint CheckpointFunc) (*Snapshot, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	// lock all the Databases and their Stacks, as Move and
+	// Transaction do, so no element is moved between two
+	// Stacks while they are captured
+	databases := make([]*Database, 0, len(p.Databases))
+	for _, db := range p.Databases {
+		databases = append(databases, db)
+	}
+	sort.Slice(databases, func(i, j int) bool {
+		return databases[i].ID.String() < databases[j].ID.String()
+	})
+	var stacks []*Stack
+	for _, db := range databases {
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		for _, s := range db.Stacks {
+			stacks = append(stacks, s)
+		}
+	}
+	unlock := lockStacks(stacks...)
+	defer unlock()
+
+	snapshot := &Snapshot{
+		Version:   SnapshotVersion,
+		CreatedAt: t,
+		Databases: make([]DatabaseSnapshot, 0, len(databases)),
+	}
+
+	for _, db := range databases {
+		ds, err := db.snapshotLocked(checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Databases = append(snapshot.Databases, ds)
+	}
+
+	return snapshot, nil
+}
+
+// BaseFunc returns the stack.Stacker base of a Stack being restored
+// from a snapshot, given the name of its Database and its StackSnapshot.
+type BaseFunc func(database string, ss StackSnapshot) (stack.Stacker, error)
+
+// Restore adds all the Databases contained in a Snapshot to the Pila,
+// using newBase to create the base of their Stacks, or the default
+// implementation if nil. It returns an error if the Pila already
+// contained any of the Databases.
+func (p *Pila) Restore(snapshot *Snapshot, newBase BaseFunc) error {
+	for _, ds := range snapshot.Databases {
+		db, err := ds.Restore(newBase)
+		if err != nil {
+			return fmt.Errorf("database %v: %v", ds.Name, err)
+		}
+		if err := p.AddDatabase(db); err != nil {
+			return fmt.Errorf("database %v: %v", ds.Name, err)
+		}
+	}
+	return nil
+}
+
+// Snapshot returns the DatabaseSnapshot of the Database.
+func (db *Database) Snapshot() (DatabaseSnapshot, error) {
+	return db.snapshot(nil)
+}
+
+// snapshot returns the DatabaseSnapshot of the Database, saving
+// the elements of its Stacks in the directory returned by
+// checkpoint, if any.
+func (db *Database) snapshot(checkpoint CheckpointFunc) (DatabaseSnapshot, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	stacks := make([]*Stack, 0, len(db.Stacks))
+	for _, s := range db.Stacks {
+		stacks = append(stacks, s)
+	}
+	unlock := lockStacks(stacks...)
+	defer unlock()
+
+	return db.snapshotLocked(checkpoint)
+}
+
+// snapshotLocked returns the DatabaseSnapshot of a locked Database,
+// whose Stacks are locked as well.
+func (db *Database) snapshotLocked(checkpoint CheckpointFunc) (DatabaseSnapshot, error) {
+	db.dateMu.Lock()
+	ds := DatabaseSnapshot{
+		Name:    db.Name,
+		IdleTTL: db.IdleTTL.Seconds(),
+		ReadAt:  db.ReadAt,
+		Stacks:  make([]StackSnapshot, 0, len(db.Stacks)),
+	}
+	db.dateMu.Unlock()
+
+	for _, s := range db.Stacks {
+		var dir string
+		if checkpoint != nil {
+			dir = checkpoint(db.Name, s.Name)
+		}
+		ss, err := s.snapshotLocked(dir)
+		if err != nil {
+			return DatabaseSnapshot{}, err
+		}
+		ds.Stacks = append(ds.Stacks, ss)
+	}
+
+	return ds, nil
+}
+
+// Restore creates a new Database, without any link to a Pila,
+// containing all the Stacks of the DatabaseSnapshot. newBase creates
+// the base of the Stacks, or the default implementation is used if nil.
+func (ds DatabaseSnapshot) Restore(newBase BaseFunc) (*Database, error) {
+	db := NewDatabase(ds.Name)
+	db.IdleTTL = Seconds(ds.IdleTTL)
+	db.ReadAt = ds.ReadAt
+	for _, ss := range ds.Stacks {
+		base := stack.Stacker(stack.NewStack())
+		if newBase != nil {
+			var err error
+			if base, err = newBase(ds.Name, ss); err != nil {
+				return nil, fmt.Errorf("stack %v: %v", ss.Name, err)
+			}
+		}
+
+		// Do not check error as the Database is new and
+		// Stack names are unique within a snapshot.
+		_ = db.AddStack(ss.Restore(base))
+	}
+	return db, nil
+}
+
+// Snapshot returns the StackSnapshot of the Stack. It returns an
+// error if the base of the Stack cannot be walked.
+func (s *Stack) Snapshot() (StackSnapshot, error) {
+	return s.snapshot("")
+}
+
+// snapshot returns the StackSnapshot of the Stack. If dir is set and
+// the base of the Stack implements the stack.Checkpointer interface,
+// its elements are saved in dir instead of being copied.
+func (s *Stack) snapshot(dir string) (StackSnapshot, error) {
+	// read the elements and the version of the Stack at once
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.snapshotLocked(dir)
+}
+
+// snapshotLocked returns the StackSnapshot of a locked Stack.
+func (s *Stack) snapshotLocked(dir string) (StackSnapshot, error) {
+	var elements []interface{}
+	var expirations []time.Time
+	var expires bool
+	var checkpoint string
+	walker, ok := s.base.(stack.Walker)
+	checkpointer, isCheckpointer := s.base.(stack.Checkpointer)
+	// a removed Stack is empty
+	if isCheckpointer && dir != "" && !s.removed {
+		if err := checkpointer.Checkpoint(dir); err != nil {
+			return StackSnapshot{}, fmt.Errorf("stack %v: %v", s.Name, err)
+		}
+		checkpoint = dir
+		ok = true
+	} else if expirer, isExpirer := s.base.(stack.Expirer); isExpirer && !s.removed {
+		expirer.WalkExpiring(func(element interface{}, expiresAt time.Time) bool {
+			elements = append(elements, element)
+			expirations = append(expirations, expiresAt)
+			expires = expires || !expiresAt.IsZero()
+			return true
+		})
+	} else if ok && !s.removed {
+		walker.Walk(func(element interface{}) bool {
+			elements = append(elements, element)
+			return true
+		})
+	}
+	if !ok {
+		return StackSnapshot{}, fmt.Errorf("stack %v does not support snapshots", s.Name)
+	}
+	if !expires {
+		expirations = nil
+	}
+
+	// reverse elements so they are sorted from bottom to top
+	for i, j := 0, len(elements)-1; i < j; i, j = i+1, j-1 {
+		elements[i], elements[j] = elements[j], elements[i]
+	}
+	for i, j := 0, len(expirations)-1; i < j; i, j = i+1, j-1 {
+		expirations[i], expirations[j] = expirations[j], expirations[i]
+	}
+
+	var capacity int
+	if bounded, ok := s.base.(stack.Bounded); ok {
+		capacity = bounded.Capacity()
+	}
+
+	s.dateMu.Lock()
+	defer s.dateMu.Unlock()
+
+	return StackSnapshot{
+		Name:        s.Name,
+		Engine:      s.Engine,
+		Overflow:    s.Overflow,
+		Capacity:    capacity,
+		IdleTTL:     s.IdleTTL.Seconds(),
+		CreatedAt:   s.CreatedAt,
+		UpdatedAt:   s.UpdatedAt,
+		ReadAt:      s.ReadAt,
+		Version:     s.version,
+		Elements:    elements,
+		Checkpoint:  checkpoint,
+		Expirations: expirations,
+	}, nil
+}
+
+// Restore creates a new Stack given a stack.Stacker base, without an
+// association to any Database, containing all the elements of the
+// StackSnapshot.
+func (ss StackSnapshot) Restore(base stack.Stacker) *Stack {
+	s := NewStackWithBase(ss.Name, ss.CreatedAt, base)
+	s.Engine = ss.Engine
+	s.Overflow = ss.Overflow
+	s.IdleTTL = Seconds(ss.IdleTTL)
+	for i, element := range ss.Elements {
+		var expiresAt time.Time
+		if i < len(ss.Expirations) {
+			expiresAt = ss.Expirations[i]
+		}
+		// elements are kept if the base of the Stack
+		// does not support expiration
+		if err := s.pushExpiring(element, expiresAt); err != nil {
+			s.push(element)
+		}
+	}
+	if ss.Version > 0 {
+		s.version = ss.Version
+	}
+	s.UpdatedAt = ss.UpdatedAt
+	s.ReadAt = ss.ReadAt
+	return s
+}
+
+// Encode writes the Snapshot into w in JSON format.
+func (snapshot *Snapshot) Encode(w io.Writer) error {
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+// DecodeSnapshot reads a Snapshot from r. It returns an error if the
+// data is malformed or if its version is not supported.
+func DecodeSnapshot(r io.Reader) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+
+	if snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d, expected %d",
+			snapshot.Version, SnapshotVersion)
+	}
+
+	return &snapshot, nil
+}
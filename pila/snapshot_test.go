@@ -0,0 +1,452 @@
+package pila
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pkg/stack"
+	"github.com/fern4lvarez/piladb/pkg/uuid"
+)
+
+func TestPilaSnapshot(t *testing.T) {
+	now := time.Now().UTC()
+
+	p := NewPila()
+	db := NewDatabase("db")
+	_ = p.AddDatabase(db)
+
+	s := NewStack("stack", now)
+	s.Push("foo")
+	s.Push(8)
+	s.Update(now)
+	_ = db.AddStack(s)
+
+	snapshot, err := p.Snapshot(now, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Snapshot{
+		Version:   SnapshotVersion,
+		CreatedAt: now,
+		Databases: []DatabaseSnapshot{
+			{
+				Name: "db",
+				Stacks: []StackSnapshot{
+					{
+						Name:      "stack",
+						CreatedAt: now,
+						UpdatedAt: now,
+						ReadAt:    now,
+						Version:   2,
+						Elements:  []interface{}{"foo", 8},
+					},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(snapshot, expected) {
+		t.Errorf("snapshot is %v, expected %v", snapshot, expected)
+	}
+}
+
+func TestPilaSnapshot_Move(t *testing.T) {
+	now := time.Now().UTC()
+	p := NewPila()
+	db0 := NewDatabase("db0")
+	db1 := NewDatabase("db1")
+	_ = p.AddDatabase(db0)
+	_ = p.AddDatabase(db1)
+	s0 := NewStack("s0", now)
+	s1 := NewStack("s1", now)
+	_ = db0.AddStack(s0)
+	_ = db1.AddStack(s1)
+	for i := 0; i < 10; i++ {
+		s0.Push(i)
+	}
+
+	// elements moved between two snapshotted Stacks are
+	// never saved twice nor missed
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			Move(s0, s1)
+			Move(s1, s0)
+		}
+	}()
+
+	for {
+		snapshot, err := p.Snapshot(now, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		for _, ds := range snapshot.Databases {
+			for _, ss := range ds.Stacks {
+				n += len(ss.Elements)
+			}
+		}
+		if n != 10 {
+			t.Fatalf("snapshot has %d elements, expected %d", n, 10)
+		}
+
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
+func TestPilaSnapshot_Error(t *testing.T) {
+	p := NewPila()
+	db := NewDatabase("db")
+	_ = p.AddDatabase(db)
+	db.CreateStackWithBase("stack", time.Now(), &TestBaseStack{})
+
+	if _, err := p.Snapshot(time.Now(), nil); err == nil {
+		t.Error("err is nil")
+	}
+}
+
+func TestPilaSnapshot_Checkpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := NewPila()
+	db := NewDatabase("db")
+	_ = p.AddDatabase(db)
+	base, err := stack.NewDiskStack(filepath.Join(dir, "stack"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := db.CreateStackWithBase("stack", time.Now(), base)
+	db.Stacks[id].PushMany([]interface{}{"foo", "bar", "baz"})
+	db.CreateStack("memory", time.Now())
+
+	checkpoint := func(database, stack string) string {
+		return filepath.Join(dir, "checkpoint", database, stack)
+	}
+	snapshot, err := p.Snapshot(time.Now(), checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ss := range snapshot.Databases[0].Stacks {
+		switch ss.Name {
+		case "stack":
+			if ss.Checkpoint != checkpoint("db", "stack") || ss.Elements != nil {
+				t.Errorf("stack has checkpoint %q and elements %v, expected %q and none",
+					ss.Checkpoint, ss.Elements, checkpoint("db", "stack"))
+			}
+		case "memory":
+			if ss.Checkpoint != "" {
+				t.Errorf("memory stack has checkpoint %q, expected none", ss.Checkpoint)
+			}
+		}
+	}
+
+	restored, err := stack.OpenDiskStack(filepath.Join(dir, "restored"), 2, checkpoint("db", "stack"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Size() != 3 || restored.Peek() != "baz" {
+		t.Errorf("size is %d and peek %v, expected %d and %v", restored.Size(), restored.Peek(), 3, "baz")
+	}
+}
+
+func TestPilaRestore(t *testing.T) {
+	now := time.Now().UTC()
+	snapshot := &Snapshot{
+		Version:   SnapshotVersion,
+		CreatedAt: now,
+		Databases: []DatabaseSnapshot{
+			{
+				Name: "db",
+				Stacks: []StackSnapshot{
+					{
+						Name:      "stack",
+						CreatedAt: now,
+						UpdatedAt: now,
+						ReadAt:    now,
+						Elements:  []interface{}{"foo", "bar"},
+					},
+				},
+			},
+		},
+	}
+
+	p := NewPila()
+	if err := p.Restore(snapshot, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	db, ok := p.Database(NewDatabase("db").ID)
+	if !ok {
+		t.Fatal("database db was not restored")
+	}
+	if db.Pila != p {
+		t.Error("db.Pila is not p")
+	}
+
+	s, ok := db.Stacks[uuid.New("db"+"stack")]
+	if !ok {
+		t.Fatal("stack was not restored")
+	}
+	if s.Database != db {
+		t.Error("s.Database is not db")
+	}
+	if s.Size() != 2 {
+		t.Errorf("s.Size() is %d, expected %d", s.Size(), 2)
+	}
+	if s.Peek() != "bar" {
+		t.Errorf("s.Peek() is %v, expected %v", s.Peek(), "bar")
+	}
+	if s.CreatedAt != now || s.UpdatedAt != now || s.ReadAt != now {
+		t.Errorf("s dates are %v, %v, %v, expected %v", s.CreatedAt, s.UpdatedAt, s.ReadAt, now)
+	}
+}
+
+func TestPilaRestore_Error(t *testing.T) {
+	snapshot := &Snapshot{
+		Version:   SnapshotVersion,
+		Databases: []DatabaseSnapshot{{Name: "db"}},
+	}
+
+	p := NewPila()
+	p.CreateDatabase("db")
+	if err := p.Restore(snapshot, nil); err == nil {
+		t.Error("err is nil")
+	}
+}
+
+func TestSnapshotEncodeDecode(t *testing.T) {
+	now := time.Now().UTC()
+
+	p := NewPila()
+	db := NewDatabase("db")
+	_ = p.AddDatabase(db)
+	s := NewStack("stack", now)
+	s.Push("foo")
+	s.Push(map[string]interface{}{"bar": "baz"})
+	_ = db.AddStack(s)
+
+	snapshot, err := p.Snapshot(now, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := snapshot.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.CreatedAt.Equal(now) {
+		t.Errorf("decoded.CreatedAt is %v, expected %v", decoded.CreatedAt, now)
+	}
+	if n := len(decoded.Databases); n != 1 {
+		t.Fatalf("decoded has %d databases, expected %d", n, 1)
+	}
+	if elements := decoded.Databases[0].Stacks[0].Elements; !reflect.DeepEqual(elements, snapshot.Databases[0].Stacks[0].Elements) {
+		t.Errorf("elements are %v, expected %v", elements, snapshot.Databases[0].Stacks[0].Elements)
+	}
+}
+
+func TestDecodeSnapshot_Error(t *testing.T) {
+	inputs := []string{
+		"",
+		"{",
+		`{"version":0,"databases":[]}`,
+		`{"version":999,"databases":[]}`,
+	}
+
+	for _, input := range inputs {
+		if _, err := DecodeSnapshot(strings.NewReader(input)); err == nil {
+			t.Errorf("err is nil for input %q", input)
+		}
+	}
+}
+
+func TestPilaRestore_BaseFunc(t *testing.T) {
+	snapshot := &Snapshot{
+		Version: SnapshotVersion,
+		Databases: []DatabaseSnapshot{
+			{
+				Name: "db",
+				Stacks: []StackSnapshot{
+					{Name: "stack", Engine: "test", Elements: []interface{}{"foo"}},
+				},
+			},
+		},
+	}
+
+	var database, engine string
+	newBase := func(db string, ss StackSnapshot) (stack.Stacker, error) {
+		database, engine = db, ss.Engine
+		return stack.NewStack(), nil
+	}
+
+	p := NewPila()
+	if err := p.Restore(snapshot, newBase); err != nil {
+		t.Fatal(err)
+	}
+	if database != "db" || engine != "test" {
+		t.Errorf("newBase got %s and %s, expected %s and %s", database, engine, "db", "test")
+	}
+
+	db, _ := p.Database(uuid.New("db"))
+	s := db.Stacks[uuid.New("db"+"stack")]
+	if s.Engine != "test" {
+		t.Errorf("s.Engine is %s, expected %s", s.Engine, "test")
+	}
+	if s.Peek() != "foo" {
+		t.Errorf("s.Peek() is %v, expected %v", s.Peek(), "foo")
+	}
+}
+
+func TestStackSnapshotRestore_Overflow(t *testing.T) {
+	now := time.Now().UTC()
+	base, _ := stack.NewRingStack(2)
+	s := NewStackWithBase("stack", now, base)
+	s.Overflow = OverflowEvict
+	s.Push("foo")
+
+	ss, err := s.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.Overflow != OverflowEvict || ss.Capacity != 2 {
+		t.Errorf("snapshot overflow and capacity are %s and %d, expected %s and %d",
+			ss.Overflow, ss.Capacity, OverflowEvict, 2)
+	}
+
+	base, _ = stack.NewRingStack(ss.Capacity)
+	restored := ss.Restore(base)
+	if restored.Overflow != OverflowEvict {
+		t.Errorf("restored.Overflow is %s, expected %s", restored.Overflow, OverflowEvict)
+	}
+	if restored.Capacity() != 2 {
+		t.Errorf("restored.Capacity() is %d, expected %d", restored.Capacity(), 2)
+	}
+}
+
+func TestStackSnapshotRestore_Version(t *testing.T) {
+	s := NewStack("stack", time.Now().UTC())
+	s.Push("foo")
+	s.Push("bar")
+	s.Pop()
+
+	ss, err := s.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.Version != 3 {
+		t.Errorf("snapshot version is %d, expected %d", ss.Version, 3)
+	}
+
+	restored := ss.Restore(stack.NewStack())
+	if v := restored.Version(); v != 3 {
+		t.Errorf("restored.Version() is %d, expected %d", v, 3)
+	}
+}
+
+func TestStackSnapshotRestore_Expirations(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).UTC()
+	s := NewStack("stack", time.Now().UTC())
+	s.Push("foo")
+	_ = s.PushExpiring("bar", expiresAt)
+	_ = s.PushExpiring("baz", time.Now().Add(-time.Second))
+
+	ss, err := s.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ss.Elements, []interface{}{"foo", "bar"}) {
+		t.Errorf("snapshot elements are %v, expected [foo bar]", ss.Elements)
+	}
+	if !reflect.DeepEqual(ss.Expirations, []time.Time{{}, expiresAt}) {
+		t.Errorf("snapshot expirations are %v, expected [zero %v]", ss.Expirations, expiresAt)
+	}
+
+	restored := ss.Restore(stack.NewStack())
+	var expirations []time.Time
+	restored.base.(stack.Expirer).WalkExpiring(func(_ interface{}, t time.Time) bool {
+		expirations = append(expirations, t)
+		return true
+	})
+	if !reflect.DeepEqual(expirations, []time.Time{expiresAt, {}}) {
+		t.Errorf("restored expirations are %v, expected [%v zero]", expirations, expiresAt)
+	}
+
+	s = NewStack("stack", time.Now().UTC())
+	s.Push("foo")
+	if ss, _ := s.Snapshot(); ss.Expirations != nil {
+		t.Errorf("snapshot expirations are %v, expected nil", ss.Expirations)
+	}
+}
+
+func TestDatabaseSnapshotRestore_IdleTTL(t *testing.T) {
+	now := time.Now().UTC()
+	db := NewDatabase("db")
+	db.IdleTTL = time.Hour
+	db.Read(now)
+	s := NewStack("stack", now)
+	s.IdleTTL = 1500 * time.Millisecond
+	_ = db.AddStack(s)
+
+	ds, err := db.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.IdleTTL != 3600 || ds.ReadAt != now || ds.Stacks[0].IdleTTL != 1.5 {
+		t.Errorf("snapshot idle TTLs are %v and %v, expected %v and %v", ds.IdleTTL, ds.Stacks[0].IdleTTL, 3600, 1.5)
+	}
+
+	restored, err := ds.Restore(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.IdleTTL != time.Hour || restored.ReadAt != now {
+		t.Errorf("restored idle TTL is %v and read at %v, expected %v and %v", restored.IdleTTL, restored.ReadAt, time.Hour, now)
+	}
+	if rs := restored.Stacks[s.ID]; rs == nil || rs.IdleTTL != s.IdleTTL {
+		t.Errorf("restored stack is %v, expected idle TTL %v", rs, s.IdleTTL)
+	}
+}
+
+func TestPilaRestore_BaseFuncError(t *testing.T) {
+	snapshot := &Snapshot{
+		Version: SnapshotVersion,
+		Databases: []DatabaseSnapshot{
+			{Name: "db", Stacks: []StackSnapshot{{Name: "stack"}}},
+		},
+	}
+
+	newBase := func(db string, ss StackSnapshot) (stack.Stacker, error) {
+		return nil, errors.New("fail")
+	}
+
+	p := NewPila()
+	if err := p.Restore(snapshot, newBase); err == nil {
+		t.Error("err is nil")
+	}
+	if len(p.Databases) != 0 {
+		t.Errorf("p has %d databases, expected %d", len(p.Databases), 0)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,6 +14,30 @@ import (
 	"github.com/fern4lvarez/piladb/pkg/uuid"
 )
 
+// Overflow policies define what happens when an element
+// is pushed into a full Stack.
+const (
+	// OverflowReject rejects the element. This is the
+	// default policy.
+	OverflowReject = "reject"
+	// OverflowEvict discards the element at the bottom of
+	// the Stack to make room for the new one.
+	OverflowEvict = "evict"
+)
+
+// ErrEmptyStack is returned when moving or popping an
+// element from an empty Stack.
+var ErrEmptyStack = errors.New("stack is empty")
+
+// ErrNoExpiration is returned when an element that expires is
+// pushed into a Stack whose base does not implement the
+// stack.Expirer interface.
+var ErrNoExpiration = errors.New("stack does not support expiration")
+
+// ErrVersionMismatch is returned when a conditional operation
+// is applied on a Stack whose version is not the expected one.
+var ErrVersionMismatch = errors.New("stack version mismatch")
+
 // Stack represents a stack entity in piladb.
 type Stack struct {
 	// ID is a unique identifier of the Stack
@@ -52,8 +77,48 @@ type Stack struct {
 	// writes on the Stack ID.
 	IDMu sync.RWMutex
 
+	// Engine is the name of the stack.Stacker implementation
+	// used as base of the Stack. It is only informative, and
+	// empty if the default implementation is used.
+	Engine string
+
+	// Overflow is the policy applied when an element is pushed
+	// into the Stack once it is full. It is only informative, as
+	// the policy is enforced by the base of the Stack or by its
+	// users, and empty if the default policy is used.
+	Overflow string
+
+	// IdleTTL is how long the Stack can go without being read,
+	// given by ReadAt, before it is considered idle. Zero means
+	// that the Stack never becomes idle.
+	IdleTTL time.Duration
+
 	// base represents the Stack data structure
 	base stack.Stacker
+
+	// mu guards the operations on base, so that
+	// operations on several Stacks can be atomic.
+	mu sync.RWMutex
+
+	// version is increased on every operation that modifies
+	// the content of the Stack. It is guarded by mu.
+	version uint64
+
+	// events is the EventBus of the Database of the Stack,
+	// where its changes are published. It is guarded by mu,
+	// so events are published in the order of the changes.
+	events *EventBus
+
+	// waiters contains the channels of the callers blocked
+	// in Wait, in order of arrival. waitMu guards waiters.
+	waiters []chan struct{}
+	waitMu  sync.Mutex
+
+	// removed is true once the Stack is removed from its
+	// Database. A removed Stack keeps its base, but it is
+	// empty and ignores any change. It is set holding both
+	// mu and waitMu, so it is guarded by any of them.
+	removed bool
 }
 
 // NewStack creates a new Stack given a name and a creation date,
@@ -74,30 +139,650 @@ func NewStackWithBase(name string, t time.Time, base stack.Stacker) *Stack {
 	return s
 }
 
-// Push an element on top of the Stack.
+// Push an element on top of the Stack, and notifies
+// the first caller waiting for it, if any.
 func (s *Stack) Push(element interface{}) {
-	s.base.Push(element)
+	s.mu.Lock()
+	s.push(element)
+	s.publish(EventPush, element)
+	s.mu.Unlock()
+
+	s.notify(1)
 }
 
 // Pop removes and returns the element on top of the Stack.
 // If the Stack was empty, it returns false.
 func (s *Stack) Pop() (interface{}, bool) {
-	return s.base.Pop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.pop()
+	if ok {
+		s.publish(EventPop, element)
+	}
+	return element, ok
+}
+
+// PushExpiring pushes an element on top of the Stack that expires at
+// the given time, and notifies the first caller waiting for it, if any.
+// Once expired, the element is not visible anymore. It returns
+// ErrNoExpiration if the base of the Stack does not implement the
+// stack.Expirer interface.
+func (s *Stack) PushExpiring(element interface{}, expiresAt time.Time) error {
+	s.mu.Lock()
+	err := s.pushExpiring(element, expiresAt)
+	if err == nil {
+		s.publish(EventPush, element)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.notify(1)
+	return nil
+}
+
+// CompareAndPush pushes an element on top of the Stack only if its
+// version is the given one, returning ErrVersionMismatch otherwise.
+func (s *Stack) CompareAndPush(version uint64, element interface{}) error {
+	return s.CompareAndPushExpiring(version, element, time.Time{})
+}
+
+// CompareAndPushExpiring pushes an element on top of the Stack that
+// expires at the given time, or never if it is zero, only if its version
+// is the given one, returning ErrVersionMismatch otherwise.
+func (s *Stack) CompareAndPushExpiring(version uint64, element interface{}, expiresAt time.Time) error {
+	s.mu.Lock()
+	if s.version != version {
+		s.mu.Unlock()
+		return ErrVersionMismatch
+	}
+	err := s.pushExpiring(element, expiresAt)
+	if err == nil {
+		s.publish(EventPush, element)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.notify(1)
+	return nil
+}
+
+// Expire removes the expired elements of the Stack, and returns how
+// many were removed. Expired elements are not visible anyway, so it
+// only releases their resources, and the version of the Stack is not
+// changed.
+func (s *Stack) Expire() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.removed {
+		return 0
+	}
+	if expirer, ok := s.base.(stack.Expirer); ok {
+		return expirer.Expire()
+	}
+	return 0
+}
+
+// CompareAndPop removes and returns the element on top of the Stack
+// only if its version is the given one, returning ErrVersionMismatch
+// otherwise. It returns ErrEmptyStack if the Stack was empty.
+func (s *Stack) CompareAndPop(version uint64) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.version != version {
+		return nil, ErrVersionMismatch
+	}
+	element, ok := s.pop()
+	if !ok {
+		return nil, ErrEmptyStack
+	}
+	s.publish(EventPop, element)
+	return element, nil
+}
+
+// Version returns the version of the Stack, which is increased
+// on every operation that modifies its content.
+func (s *Stack) Version() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.version
+}
+
+// SetVersion sets the version of the Stack, e.g. when
+// restoring it from a persisted state.
+func (s *Stack) SetVersion(version uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.version = version
+}
+
+// SetClock sets the function that returns the current time to
+// expire the elements of the Stack, or the system clock if nil,
+// e.g. when replaying operations at the time they were applied.
+// It does nothing if the base of the Stack does not implement
+// the stack.Clocked interface.
+func (s *Stack) SetClock(clock func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if clocked, ok := s.base.(stack.Clocked); ok {
+		clocked.SetClock(clock)
+	}
+}
+
+// push pushes an element into a locked Stack. Removed
+// Stacks ignore it.
+func (s *Stack) push(element interface{}) {
+	if s.removed {
+		return
+	}
+	s.base.Push(element)
+	s.version++
+}
+
+// pushExpiring pushes an element that expires at the given
+// time, or never if it is zero. Removed Stacks ignore it.
+func (s *Stack) pushExpiring(element interface{}, expiresAt time.Time) error {
+	if expiresAt.IsZero() || s.removed {
+		s.push(element)
+		return nil
+	}
+
+	expirer, ok := s.base.(stack.Expirer)
+	if !ok {
+		return ErrNoExpiration
+	}
+	expirer.PushExpiring(element, expiresAt)
+	s.version++
+	return nil
+}
+
+// peekExpiresAt returns the time the element on top of a
+// locked Stack expires at, zero if it does not expire.
+func peekExpiresAt(s *Stack) time.Time {
+	var expiresAt time.Time
+	if expirer, ok := s.base.(stack.Expirer); ok {
+		expirer.WalkExpiring(func(_ interface{}, t time.Time) bool {
+			expiresAt = t
+			return false
+		})
+	}
+	return expiresAt
+}
+
+func (s *Stack) pop() (interface{}, bool) {
+	// a removed Stack is empty for the callers
+	// that were waiting on it.
+	if s.removed {
+		return nil, false
+	}
+	element, ok := s.base.Pop()
+	if ok {
+		s.version++
+	}
+	return element, ok
+}
+
+// PushMany pushes elements on top of the Stack atomically and in
+// order, so the last one ends up on top.
+func (s *Stack) PushMany(elements []interface{}) {
+	_ = s.PushManyBounded(elements, -1)
+}
+
+// PushManyBounded pushes elements on top of the Stack atomically and
+// in order, only if the Stack does not end up with more than maxSize
+// elements, returning ErrStackFull otherwise. The size is not bounded
+// if the Stack evicts elements on overflow, or maxSize is -1.
+func (s *Stack) PushManyBounded(elements []interface{}, maxSize int) error {
+	s.mu.Lock()
+	if err := s.checkSize(len(elements), maxSize); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.pushMany(elements)
+	s.mu.Unlock()
+
+	s.notify(len(elements))
+	return nil
+}
+
+// CompareAndPushMany pushes elements on top of the Stack atomically
+// and in order, only if its version is the given one, returning
+// ErrVersionMismatch otherwise. The size of the Stack is bounded by
+// maxSize as in PushManyBounded.
+func (s *Stack) CompareAndPushMany(version uint64, elements []interface{}, maxSize int) error {
+	s.mu.Lock()
+	if s.version != version {
+		s.mu.Unlock()
+		return ErrVersionMismatch
+	}
+	if err := s.checkSize(len(elements), maxSize); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.pushMany(elements)
+	s.mu.Unlock()
+
+	s.notify(len(elements))
+	return nil
+}
+
+// checkSize returns ErrStackFull if pushing n elements into a
+// locked Stack makes it exceed maxSize elements.
+func (s *Stack) checkSize(n, maxSize int) error {
+	if s.Overflow != OverflowEvict && maxSize != -1 && s.size()+n > maxSize {
+		return ErrStackFull
+	}
+	return nil
+}
+
+// pushMany pushes elements into a locked Stack. Removed
+// Stacks ignore them.
+func (s *Stack) pushMany(elements []interface{}) {
+	if s.removed {
+		return
+	}
+	subscribed := s.subscribed()
+	var before int
+	if subscribed {
+		before = s.base.Size()
+	}
+	if batcher, ok := s.base.(stack.Batcher); ok {
+		batcher.PushMany(elements)
+	} else {
+		for _, element := range elements {
+			s.base.Push(element)
+		}
+	}
+	if len(elements) > 0 {
+		s.version++
+	}
+	s.observe(EventPush, len(elements))
+	if subscribed {
+		// elements evicted on overflow keep the size
+		// from growing past the final one
+		after := s.base.Size()
+		for i, element := range elements {
+			size := before + i + 1
+			if size > after {
+				size = after
+			}
+			s.publishSize(EventPush, element, size)
+		}
+	}
+}
+
+// PopMany removes up to n elements from the top of the Stack
+// atomically, and returns them from top to bottom.
+func (s *Stack) PopMany(n int) []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.popMany(n)
+}
+
+// CompareAndPopMany removes up to n elements from the top of the
+// Stack atomically, and returns them from top to bottom, only if its
+// version is the given one, returning ErrVersionMismatch otherwise.
+func (s *Stack) CompareAndPopMany(version uint64, n int) ([]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.version != version {
+		return nil, ErrVersionMismatch
+	}
+	return s.popMany(n), nil
+}
+
+// popMany removes up to n elements from a locked Stack.
+func (s *Stack) popMany(n int) []interface{} {
+	if s.removed {
+		return nil
+	}
+	subscribed := s.subscribed()
+	var before int
+	if subscribed {
+		before = s.base.Size()
+	}
+	var elements []interface{}
+	if batcher, ok := s.base.(stack.Batcher); ok {
+		elements = batcher.PopMany(n)
+	} else {
+		for ; n > 0; n-- {
+			element, ok := s.base.Pop()
+			if !ok {
+				break
+			}
+			elements = append(elements, element)
+		}
+	}
+	if len(elements) > 0 {
+		s.version++
+	}
+	s.observe(EventPop, len(elements))
+	if subscribed {
+		for i, element := range elements {
+			s.publishSize(EventPop, element, before-i-1)
+		}
+	}
+	return elements
 }
 
 // Size returns the size of the Stack.
 func (s *Stack) Size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.size()
+}
+
+// size returns the size of a locked Stack, which is
+// empty once removed.
+func (s *Stack) size() int {
+	if s.removed {
+		return 0
+	}
 	return s.base.Size()
 }
 
+// peek returns the element on top of a locked Stack,
+// which is empty once removed.
+func (s *Stack) peek() interface{} {
+	if s.removed {
+		return nil
+	}
+	return s.base.Peek()
+}
+
 // Peek returns the element on top of the Stack.
 func (s *Stack) Peek() interface{} {
-	return s.base.Peek()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.observe(OpPeek, 1)
+	return s.peek()
 }
 
 // Flush flushes the content of the Stack.
 func (s *Stack) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.removed {
+		return
+	}
 	s.base.Flush()
+	s.version++
+	s.observe(EventFlush, 1)
+	s.publishSize(EventFlush, nil, 0)
+}
+
+// Walk calls fn for each element of the Stack, from top to bottom,
+// until fn returns false. The Stack is locked for reading during the
+// walk, so fn must not modify it. It returns false if the base of the
+// Stack does not implement the stack.Walker interface.
+func (s *Stack) Walk(fn func(element interface{}) bool) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	walker, ok := s.base.(stack.Walker)
+	if !ok {
+		return false
+	}
+
+	if !s.removed {
+		walker.Walk(fn)
+	}
+	return true
+}
+
+// Range returns up to limit elements of the Stack, from top to bottom,
+// skipping the offset topmost ones, without modifying it. The elements
+// are read atomically. It returns false if the base of the Stack does
+// not implement the stack.Walker interface.
+func (s *Stack) Range(offset, limit int) ([]interface{}, bool) {
+	elements := []interface{}{}
+	if limit < 1 {
+		return elements, s.Walk(func(interface{}) bool { return false })
+	}
+
+	var i int
+	ok := s.Walk(func(element interface{}) bool {
+		if i >= offset {
+			elements = append(elements, element)
+		}
+		i++
+		return len(elements) < limit
+	})
+	return elements, ok
+}
+
+// Capacity returns the maximum number of elements that the Stack can
+// contain, or -1 if the base of the Stack does not implement the
+// stack.Bounded interface.
+func (s *Stack) Capacity() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bounded, ok := s.base.(stack.Bounded)
+	if !ok {
+		return -1
+	}
+	return bounded.Capacity()
+}
+
+// Move pops the element on top of the src Stack and pushes it on top
+// of the dst Stack atomically, so the element is never missing from
+// both Stacks, and returns it. The element keeps its expiration time,
+// unless dst does not support expiration. It returns false if src was
+// empty, or if any of the Stacks was removed from its Database.
+func Move(src, dst *Stack) (interface{}, bool) {
+	if src == dst {
+		src.mu.RLock()
+		defer src.mu.RUnlock()
+		if src.size() == 0 {
+			return nil, false
+		}
+		return src.peek(), true
+	}
+
+	unlock := lockStacks(src, dst)
+	var element interface{}
+	var ok bool
+	if !src.removed && !dst.removed {
+		expiresAt := peekExpiresAt(src)
+		element, ok = src.pop()
+		if ok && dst.pushExpiring(element, expiresAt) != nil {
+			dst.push(element)
+		}
+		if ok {
+			src.publish(EventPop, element)
+			dst.publish(EventPush, element)
+		}
+	}
+	unlock()
+
+	if ok {
+		dst.notify(1)
+	}
+	return element, ok
+}
+
+// subscribed returns true if a locked Stack belongs to a Database
+// with subscribers to its events, so the events of its changes must
+// be published.
+func (s *Stack) subscribed() bool {
+	return s.events != nil && s.events.Subscribed()
+}
+
+// observe calls the Hook of the Database of a locked Stack, if any,
+// with an operation on n elements of the Stack.
+func (s *Stack) observe(op string, n int) {
+	if s.events != nil {
+		s.events.observe(s.Name, op, n)
+	}
+}
+
+// publish observes the operation of a locked Stack on one element,
+// and publishes its event given its type and the element pushed or
+// popped, with the current size of the Stack.
+func (s *Stack) publish(eventType string, element interface{}) {
+	s.observe(eventType, 1)
+	if s.subscribed() {
+		s.publishSize(eventType, element, s.size())
+	}
+}
+
+// publishSize publishes an event of a locked Stack given its type,
+// the element pushed or popped, and the size after the change. It
+// does nothing if there are no subscribers.
+func (s *Stack) publishSize(eventType string, element interface{}, size int) {
+	if !s.subscribed() {
+		return
+	}
+
+	s.events.Publish(Event{
+		Type:    eventType,
+		StackID: s.UUID().String(),
+		Stack:   s.Name,
+		Element: element,
+		Size:    size,
+		Time:    time.Now().UTC(),
+	})
+}
+
+// lockStacks locks a list of different Stacks for writing, always in
+// the same order to avoid deadlocks, and returns a function that
+// unlocks them.
+func lockStacks(stacks ...*Stack) func() {
+	sorted := make([]*Stack, len(stacks))
+	copy(sorted, stacks)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].UUID().String() < sorted[j].UUID().String()
+	})
+
+	for _, s := range sorted {
+		s.mu.Lock()
+	}
+	return func() {
+		for i := len(sorted) - 1; i >= 0; i-- {
+			sorted[i].mu.Unlock()
+		}
+	}
+}
+
+// Wait blocks until an element is pushed into the Stack, returning
+// true, or until the timeout elapses or the Stack is removed from its
+// Database, returning false. It returns true straight away if the
+// Stack is not empty. Callers are notified in order of arrival, one
+// per pushed element; front puts the caller first in line, which is
+// meant for callers that were notified but lost the element to
+// another consumer.
+// Note that being notified does not reserve the element, so it must
+// be popped afterwards, and may be gone by then.
+func (s *Stack) Wait(timeout time.Duration, front bool) bool {
+	w := make(chan struct{}, 1)
+
+	s.waitMu.Lock()
+	if s.removed {
+		s.waitMu.Unlock()
+		return false
+	}
+	// elements are pushed before notifying, so any element
+	// pushed after this check will notify the caller
+	if s.base.Size() > 0 {
+		s.waitMu.Unlock()
+		return true
+	}
+	if front {
+		s.waiters = append([]chan struct{}{w}, s.waiters...)
+	} else {
+		s.waiters = append(s.waiters, w)
+	}
+	s.waitMu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-w:
+	case <-timer.C:
+		// a notification could have been sent
+		// right after the timer fired
+		if s.dequeue(w) {
+			return false
+		}
+	}
+
+	s.waitMu.Lock()
+	defer s.waitMu.Unlock()
+	return !s.removed
+}
+
+// notify wakes up to n callers blocked in Wait, in
+// order of arrival.
+func (s *Stack) notify(n int) {
+	s.waitMu.Lock()
+	defer s.waitMu.Unlock()
+
+	for ; n > 0 && len(s.waiters) > 0; n-- {
+		s.waiters[0] <- struct{}{}
+		s.waiters[0] = nil
+		s.waiters = s.waiters[1:]
+	}
+}
+
+// dequeue removes the channel of a caller from the waiters,
+// returning false if it was not waiting anymore.
+func (s *Stack) dequeue(w chan struct{}) bool {
+	s.waitMu.Lock()
+	defer s.waitMu.Unlock()
+
+	for i := range s.waiters {
+		if s.waiters[i] == w {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// remove marks the Stack as removed, and wakes up all the
+// callers blocked in Wait. It publishes its deletion, and
+// closes the subscriptions to its events. If the base of the
+// Stack implements io.Closer, it is closed to release its
+// resources.
+func (s *Stack) remove() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.waitMu.Lock()
+	defer s.waitMu.Unlock()
+
+	if closer, ok := s.base.(io.Closer); ok {
+		// Do not check error as the Stack is
+		// removed anyway.
+		_ = closer.Close()
+	}
+
+	s.publishSize(EventDelete, nil, 0)
+	if s.events != nil {
+		s.events.CloseStack(s.UUID())
+		s.events = nil
+	}
+
+	s.removed = true
+	for _, w := range s.waiters {
+		w <- struct{}{}
+	}
+	s.waiters = nil
 }
 
 // Update takes a date and updates UpdateAt and ReadAt
@@ -117,11 +802,32 @@ func (s *Stack) Read(t time.Time) {
 	s.dateMu.Unlock()
 }
 
+// Idle returns true if the Stack has an IdleTTL and was
+// not read for that long at time t.
+func (s *Stack) Idle(t time.Time) bool {
+	return idle(s.IdleTTL, s.readAt(), t)
+}
+
+// readAt returns ReadAt providing thread safety.
+func (s *Stack) readAt() time.Time {
+	s.dateMu.Lock()
+	defer s.dateMu.Unlock()
+
+	return s.ReadAt
+}
+
 // SetDatabase links the Stack with a given Database and
 // recalculates its ID.
 func (s *Stack) SetDatabase(db *Database) {
 	s.Database = db
 	s.SetID()
+
+	s.mu.Lock()
+	s.events = nil
+	if db != nil {
+		s.events = db.events
+	}
+	s.mu.Unlock()
 }
 
 // UUID returns the unique Stack ID providing thread safety.
@@ -159,18 +865,29 @@ func (s *Stack) Status() StackStatus {
 	status := StackStatus{}
 	status.ID = s.UUID().String()
 	status.Name = s.Name
-	status.Size = s.Size()
-	status.Peek = s.Peek()
+	s.mu.RLock()
+	status.Size = s.size()
+	status.Peek = s.peek()
+	status.Version = s.version
+	s.mu.RUnlock()
 	status.CreatedAt = s.CreatedAt.Local()
 	status.UpdatedAt = s.UpdatedAt.Local()
 	status.ReadAt = s.ReadAt.Local()
+	status.Overflow = s.Overflow
+	status.IdleTTL = s.IdleTTL.Seconds()
+	if c := s.Capacity(); c != -1 {
+		status.Capacity = c
+	}
 
 	return status
 }
 
-// Element represents the payload of a Stack element.
+// Element represents the payload of a Stack element. TTL is
+// the number of seconds after which the element expires, if
+// set when it is pushed.
 type Element struct {
 	Value interface{} `json:"element"`
+	TTL   float64     `json:"ttl,omitempty"`
 }
 
 // ToJSON converts an Element into JSON.
@@ -188,5 +905,53 @@ func (element *Element) Decode(r io.Reader) error {
 	}
 
 	decoder := json.NewDecoder(elementBuffer)
-	return decoder.Decode(element)
+	if err := decoder.Decode(element); err != nil {
+		return err
+	}
+
+	if element.TTL < 0 {
+		return errors.New("malformed payload, negative ttl")
+	}
+	return nil
+}
+
+// ExpiresAt returns the time at which the Element expires if it
+// is pushed at t, or zero if it does not expire.
+func (element Element) ExpiresAt(t time.Time) time.Time {
+	if element.TTL <= 0 {
+		return time.Time{}
+	}
+	return t.Add(Seconds(element.TTL))
+}
+
+// Seconds returns the duration of a number of seconds, which
+// can be fractional.
+func Seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Elements represents a list of Stack elements, encoded in
+// JSON as an array.
+type Elements []interface{}
+
+// ToJSON converts Elements into JSON. An empty list is
+// encoded as an empty array.
+func (elements Elements) ToJSON() ([]byte, error) {
+	if elements == nil {
+		elements = Elements{}
+	}
+	return json.Marshal([]interface{}(elements))
+}
+
+// Decode decodes a json array into Elements.
+func (elements *Elements) Decode(r io.Reader) error {
+	elementsBuffer := new(bytes.Buffer)
+	elementsBuffer.ReadFrom(r)
+
+	if !bytes.HasPrefix(bytes.TrimSpace(elementsBuffer.Bytes()), []byte("[")) {
+		return errors.New("malformed payload, not an array?")
+	}
+
+	decoder := json.NewDecoder(elementsBuffer)
+	return decoder.Decode(elements)
 }
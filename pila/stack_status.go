@@ -21,6 +21,10 @@ type StackStatus struct {
 	CreatedAt time.Time   `json:"created_at"`
 	UpdatedAt time.Time   `json:"updated_at"`
 	ReadAt    time.Time   `json:"read_at"`
+	Overflow  string      `json:"overflow,omitempty"`
+	Capacity  int         `json:"capacity,omitempty"`
+	Version   uint64      `json:"version,omitempty"`
+	IdleTTL   float64     `json:"idle_ttl,omitempty"`
 }
 
 // ToJSON converts a StackStatus into JSON.
@@ -28,6 +32,23 @@ func (stackStatus StackStatus) ToJSON() ([]byte, error) {
 	return json.Marshal(stackStatus)
 }
 
+// StackRange represents a page of the elements of a Stack,
+// sorted from top to bottom.
+type StackRange struct {
+	Offset   int      `json:"offset"`
+	Limit    int      `json:"limit"`
+	Size     int      `json:"size"`
+	Elements Elements `json:"elements"`
+}
+
+// ToJSON converts a StackRange into JSON.
+func (stackRange StackRange) ToJSON() ([]byte, error) {
+	if stackRange.Elements == nil {
+		stackRange.Elements = Elements{}
+	}
+	return json.Marshal(stackRange)
+}
+
 // StacksStatus represents the status of a list of Stacks.
 type StacksStatus struct {
 	Stacks []StackStatus `json:"stacks"`
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/fern4lvarez/piladb/pkg/date"
+	"github.com/fern4lvarez/piladb/pkg/stack"
 )
 
 func TestStackStatusJSON(t *testing.T) {
@@ -20,7 +21,7 @@ func TestStackStatusJSON(t *testing.T) {
 	stack.Push([]byte("test"))
 	stack.Update(after)
 
-	expectedStatus := fmt.Sprintf(`{"id":"2f44edeaa249ba81db20e9ddf000ba65","name":"test-stack","peek":"dGVzdA==","size":4,"created_at":"%v","updated_at":"%v","read_at":"%v"}`,
+	expectedStatus := fmt.Sprintf(`{"id":"2f44edeaa249ba81db20e9ddf000ba65","name":"test-stack","peek":"dGVzdA==","size":4,"created_at":"%v","updated_at":"%v","read_at":"%v","version":4}`,
 		date.Format(now.Local()),
 		date.Format(after.Local()),
 		date.Format(after.Local()))
@@ -47,6 +48,25 @@ func TestStackStatusJSON_Empty(t *testing.T) {
 	}
 }
 
+func TestStackStatusJSON_Overflow(t *testing.T) {
+	now := time.Now().UTC()
+	base, _ := stack.NewRingStack(3)
+	s := NewStackWithBase("test-stack", now, base)
+	s.Overflow = OverflowEvict
+	s.Push("test")
+	s.Update(now)
+
+	expectedStatus := fmt.Sprintf(`{"id":"2f44edeaa249ba81db20e9ddf000ba65","name":"test-stack","peek":"test","size":1,"created_at":"%v","updated_at":"%v","read_at":"%v","overflow":"evict","capacity":3,"version":1}`,
+		date.Format(now.Local()),
+		date.Format(now.Local()),
+		date.Format(now.Local()))
+	if status, err := s.Status().ToJSON(); err != nil {
+		t.Fatal(err)
+	} else if string(status) != expectedStatus {
+		t.Errorf("status is %s, expected %s", string(status), expectedStatus)
+	}
+}
+
 func TestStackStatusJSON_Error(t *testing.T) {
 	// From https://golang.org/src/encoding/json/encode.go?s=5438:5481#L125
 	// Channel, complex, and function values cannot be encoded in JSON.
@@ -71,6 +91,30 @@ func TestStackStatusJSON_Error(t *testing.T) {
 	}
 }
 
+func TestStackRangeJSON(t *testing.T) {
+	inputOutput := []struct {
+		input  StackRange
+		output string
+	}{
+		{StackRange{Offset: 1, Limit: 2, Size: 3, Elements: Elements{"foo", 8}},
+			`{"offset":1,"limit":2,"size":3,"elements":["foo",8]}`},
+		{StackRange{Offset: 5, Limit: 2, Size: 3},
+			`{"offset":5,"limit":2,"size":3,"elements":[]}`},
+	}
+
+	for _, io := range inputOutput {
+		if b, err := io.input.ToJSON(); err != nil {
+			t.Fatal(err)
+		} else if string(b) != io.output {
+			t.Errorf("range is %s, expected %s", string(b), io.output)
+		}
+	}
+
+	if _, err := (StackRange{Elements: Elements{make(chan int)}}).ToJSON(); err == nil {
+		t.Error("err is nil, expected UnsupportedTypeError")
+	}
+}
+
 func TestStacksStatusJSON(t *testing.T) {
 	now := time.Now().UTC().UTC()
 	after := time.Now().UTC().UTC()
@@ -92,7 +136,7 @@ func TestStacksStatusJSON(t *testing.T) {
 		Stacks: []StackStatus{stack1.Status(), stack2.Status()},
 	}
 
-	expectedStatus := fmt.Sprintf(`{"stacks":[{"id":"a0bfff209889f6f782997a7bd5b3d536","name":"test-stack-1","peek":"dGVzdA==","size":4,"created_at":"%v","updated_at":"%v","read_at":"%v"},{"id":"f0d682fdfb3396c6f21e6f4d1d0da1cd","name":"test-stack-2","peek":999,"size":3,"created_at":"%v","updated_at":"%v","read_at":"%v"}]}`,
+	expectedStatus := fmt.Sprintf(`{"stacks":[{"id":"a0bfff209889f6f782997a7bd5b3d536","name":"test-stack-1","peek":"dGVzdA==","size":4,"created_at":"%v","updated_at":"%v","read_at":"%v","version":4},{"id":"f0d682fdfb3396c6f21e6f4d1d0da1cd","name":"test-stack-2","peek":999,"size":3,"created_at":"%v","updated_at":"%v","read_at":"%v","version":3}]}`,
 		date.Format(now.Local()), date.Format(after.Local()), date.Format(after.Local()),
 		date.Format(now.Local()), date.Format(after.Local()), date.Format(after.Local()))
 	if status, err := stacksStatus.ToJSON(); err != nil {
@@ -5,6 +5,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/fern4lvarez/piladb/pkg/stack"
 )
 
 type TestBaseStack struct{}
@@ -169,6 +171,670 @@ func TestStackFlush(t *testing.T) {
 	}
 }
 
+func TestStackWalk(t *testing.T) {
+	s := NewStack("stack", time.Now())
+	s.Push("foo")
+	s.Push("bar")
+
+	var elements []interface{}
+	ok := s.Walk(func(element interface{}) bool {
+		elements = append(elements, element)
+		return true
+	})
+	if !ok {
+		t.Fatal("s.Walk() is not ok")
+	}
+	if expected := []interface{}{"bar", "foo"}; !reflect.DeepEqual(elements, expected) {
+		t.Errorf("elements are %v, expected %v", elements, expected)
+	}
+}
+
+func TestStackWalk_False(t *testing.T) {
+	s := NewStackWithBase("stack", time.Now(), &TestBaseStack{})
+	if s.Walk(func(element interface{}) bool { return true }) {
+		t.Error("s.Walk() is ok")
+	}
+}
+
+func TestStackRange(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+	s.PushMany([]interface{}{"a", "b", "c", "d", "e"})
+
+	inputOutput := []struct {
+		offset, limit int
+		output        []interface{}
+	}{
+		{0, 2, []interface{}{"e", "d"}},
+		{2, 2, []interface{}{"c", "b"}},
+		{4, 2, []interface{}{"a"}},
+		{0, 10, []interface{}{"e", "d", "c", "b", "a"}},
+		{5, 2, []interface{}{}},
+		{0, 0, []interface{}{}},
+	}
+
+	for _, io := range inputOutput {
+		elements, ok := s.Range(io.offset, io.limit)
+		if !ok {
+			t.Fatal("s.Range() is not ok")
+		}
+		if !reflect.DeepEqual(elements, io.output) {
+			t.Errorf("s.Range(%d, %d) is %v, expected %v", io.offset, io.limit, elements, io.output)
+		}
+	}
+
+	if s.Size() != 5 {
+		t.Errorf("s.Size() is %d, expected %d", s.Size(), 5)
+	}
+}
+
+func TestStackRange_False(t *testing.T) {
+	s := NewStackWithBase("test-stack", time.Now(), &TestBaseStack{})
+	if _, ok := s.Range(0, 10); ok {
+		t.Error("s.Range() is ok")
+	}
+}
+
+func TestStackCapacity(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+	if c := s.Capacity(); c != -1 {
+		t.Errorf("s.Capacity() is %d, expected %d", c, -1)
+	}
+
+	base, _ := stack.NewRingStack(5)
+	s = NewStackWithBase("test-stack", time.Now(), base)
+	if c := s.Capacity(); c != 5 {
+		t.Errorf("s.Capacity() is %d, expected %d", c, 5)
+	}
+}
+
+func TestStackPushManyPopMany(t *testing.T) {
+	// the second base hides the stack.Batcher methods
+	bases := []stack.Stacker{
+		stack.NewStack(),
+		struct{ stack.Stacker }{stack.NewStack()},
+	}
+	for _, base := range bases {
+		s := NewStackWithBase("test-stack", time.Now(), base)
+		s.PushMany([]interface{}{"foo", "bar", "baz"})
+
+		elements := s.PopMany(2)
+		if expected := []interface{}{"baz", "bar"}; !reflect.DeepEqual(elements, expected) {
+			t.Errorf("elements are %v, expected %v", elements, expected)
+		}
+		if s.Size() != 1 {
+			t.Errorf("s.Size() is %d, expected %d", s.Size(), 1)
+		}
+	}
+}
+
+func TestStackWait(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+
+	done := make(chan bool)
+	go func() { done <- s.Wait(time.Second, false) }()
+
+	// wait until the caller is queued
+	for waiters(s) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	s.Push("foo")
+
+	if ok := <-done; !ok {
+		t.Error("s.Wait() is false, expected true")
+	}
+	if n := waiters(s); n != 0 {
+		t.Errorf("stack has %d waiters, expected %d", n, 0)
+	}
+}
+
+func TestStackWait_NotEmpty(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+	s.Push("foo")
+
+	if !s.Wait(time.Hour, false) {
+		t.Error("s.Wait() is false, expected true")
+	}
+	if n := waiters(s); n != 0 {
+		t.Errorf("stack has %d waiters, expected %d", n, 0)
+	}
+}
+
+func TestStackWait_Timeout(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+
+	if s.Wait(10*time.Millisecond, false) {
+		t.Error("s.Wait() is true, expected false")
+	}
+	if n := waiters(s); n != 0 {
+		t.Errorf("stack has %d waiters, expected %d", n, 0)
+	}
+}
+
+func TestStackWait_Order(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+
+	// callers are queued in order, but the last one goes first
+	order := make(chan int, 3)
+	for i, front := range []bool{false, false, true} {
+		go func(i int, front bool) {
+			if s.Wait(time.Second, front) {
+				order <- i
+			}
+		}(i, front)
+		for waiters(s) != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	for _, expected := range []int{2, 0, 1} {
+		s.Push("foo")
+		if i := <-order; i != expected {
+			t.Errorf("caller %d was notified, expected %d", i, expected)
+		}
+	}
+}
+
+func TestStackWait_PushMany(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+
+	done := make(chan bool, 3)
+	for i := 0; i < 3; i++ {
+		go func() { done <- s.Wait(time.Second, false) }()
+	}
+	for waiters(s) != 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	s.PushMany([]interface{}{"foo", "bar"})
+	for i := 0; i < 2; i++ {
+		if ok := <-done; !ok {
+			t.Error("s.Wait() is false, expected true")
+		}
+	}
+	if n := waiters(s); n != 1 {
+		t.Errorf("stack has %d waiters, expected %d", n, 1)
+	}
+}
+
+func TestStackWait_Removed(t *testing.T) {
+	db := NewDatabase("db")
+	s := NewStack("test-stack", time.Now())
+	_ = db.AddStack(s)
+
+	done := make(chan bool)
+	go func() { done <- s.Wait(time.Hour, false) }()
+	for waiters(s) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	db.RemoveStack(s.ID)
+
+	if ok := <-done; ok {
+		t.Error("s.Wait() is true, expected false")
+	}
+	if s.Wait(time.Hour, false) {
+		t.Error("s.Wait() is true, expected false")
+	}
+	if _, ok := s.Pop(); ok {
+		t.Error("s.Pop() is true, expected false")
+	}
+}
+
+func TestStack_Removed(t *testing.T) {
+	db := NewDatabase("db")
+	s := NewStack("test-stack", time.Now())
+	_ = db.AddStack(s)
+	s.Push("foo")
+	db.RemoveStack(s.ID)
+
+	s.Push("bar")
+	s.PushMany([]interface{}{"bar", "baz"})
+	if err := s.PushExpiring("bar", time.Now().Add(time.Hour)); err != nil {
+		t.Errorf("s.PushExpiring() is %v, expected nil", err)
+	}
+	if size := s.Size(); size != 0 {
+		t.Errorf("s.Size() is %d, expected 0", size)
+	}
+	if peek := s.Peek(); peek != nil {
+		t.Errorf("s.Peek() is %v, expected nil", peek)
+	}
+	if status := s.Status(); status.Size != 0 || status.Peek != nil {
+		t.Errorf("s.Status() is %+v, expected empty", status)
+	}
+	if elements := s.PopMany(2); len(elements) != 0 {
+		t.Errorf("s.PopMany() is %v, expected empty", elements)
+	}
+	if elements, _ := s.Range(0, 10); len(elements) != 0 {
+		t.Errorf("s.Range() is %v, expected empty", elements)
+	}
+	s.Flush()
+
+	other := NewStack("other-stack", time.Now())
+	other.Push("foo")
+	if _, ok := Move(other, s); ok {
+		t.Error("Move() is true, expected false")
+	}
+	if size := other.Size(); size != 1 {
+		t.Errorf("other.Size() is %d, expected 1", size)
+	}
+}
+
+func waiters(s *Stack) int {
+	s.waitMu.Lock()
+	defer s.waitMu.Unlock()
+	return len(s.waiters)
+}
+
+func TestStackVersion(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+	other := NewStack("other-stack", time.Now())
+
+	operations := []struct {
+		name    string
+		op      func()
+		version uint64
+	}{
+		{"push", func() { s.Push("foo") }, 1},
+		{"pop", func() { s.Pop() }, 2},
+		{"pop empty", func() { s.Pop() }, 2},
+		{"push many", func() { s.PushMany([]interface{}{"foo", "bar"}) }, 3},
+		{"push many empty", func() { s.PushMany(nil) }, 3},
+		{"pop many", func() { s.PopMany(5) }, 4},
+		{"pop many empty", func() { s.PopMany(5) }, 4},
+		{"flush", func() { s.Flush() }, 5},
+		{"move empty", func() { Move(s, other) }, 5},
+		{"move from", func() { other.Push("foo"); Move(other, s) }, 6},
+		{"move to", func() { Move(s, other) }, 7},
+		{"peek", func() { s.Peek() }, 7},
+	}
+
+	for _, o := range operations {
+		o.op()
+		if v := s.Version(); v != o.version {
+			t.Errorf("version after %s is %d, expected %d", o.name, v, o.version)
+		}
+	}
+}
+
+func TestStackSetVersion(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+	s.SetVersion(42)
+	s.Push("foo")
+
+	if v := s.Version(); v != 43 {
+		t.Errorf("version is %d, expected %d", v, 43)
+	}
+}
+
+func TestStackCompareAndPush(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+
+	if err := s.CompareAndPush(1, "foo"); err != ErrVersionMismatch {
+		t.Errorf("err is %v, expected %v", err, ErrVersionMismatch)
+	}
+	if s.Size() != 0 {
+		t.Errorf("size is %d, expected %d", s.Size(), 0)
+	}
+
+	if err := s.CompareAndPush(0, "foo"); err != nil {
+		t.Errorf("err is %v, expected nil", err)
+	}
+	if s.Peek() != "foo" || s.Version() != 1 {
+		t.Errorf("peek is %v and version %d, expected %v and %d", s.Peek(), s.Version(), "foo", 1)
+	}
+}
+
+func TestStackCompareAndPop(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+
+	if _, err := s.CompareAndPop(0); err != ErrEmptyStack {
+		t.Errorf("err is %v, expected %v", err, ErrEmptyStack)
+	}
+
+	s.Push("foo")
+	if _, err := s.CompareAndPop(0); err != ErrVersionMismatch {
+		t.Errorf("err is %v, expected %v", err, ErrVersionMismatch)
+	}
+	if s.Size() != 1 {
+		t.Errorf("size is %d, expected %d", s.Size(), 1)
+	}
+
+	element, err := s.CompareAndPop(1)
+	if err != nil {
+		t.Errorf("err is %v, expected nil", err)
+	}
+	if element != "foo" || s.Version() != 2 {
+		t.Errorf("element is %v and version %d, expected %v and %d", element, s.Version(), "foo", 2)
+	}
+}
+
+func TestStackPushManyBounded(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+	s.Push("foo")
+
+	if err := s.PushManyBounded([]interface{}{"bar", "baz"}, 2); err != ErrStackFull {
+		t.Errorf("err is %v, expected %v", err, ErrStackFull)
+	}
+	if s.Size() != 1 {
+		t.Errorf("size is %d, expected %d", s.Size(), 1)
+	}
+
+	if err := s.PushManyBounded([]interface{}{"bar"}, 2); err != nil {
+		t.Errorf("err is %v, expected nil", err)
+	}
+	if err := s.PushManyBounded([]interface{}{"baz"}, -1); err != nil {
+		t.Errorf("err is %v, expected nil", err)
+	}
+
+	s.Overflow = OverflowEvict
+	if err := s.PushManyBounded([]interface{}{"qux"}, 2); err != nil {
+		t.Errorf("err is %v, expected nil", err)
+	}
+	if s.Size() != 4 {
+		t.Errorf("size is %d, expected %d", s.Size(), 4)
+	}
+}
+
+func TestStackCompareAndPushMany(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+
+	if err := s.CompareAndPushMany(1, []interface{}{"foo", "bar"}, -1); err != ErrVersionMismatch {
+		t.Errorf("err is %v, expected %v", err, ErrVersionMismatch)
+	}
+	if err := s.CompareAndPushMany(0, []interface{}{"foo", "bar"}, 1); err != ErrStackFull {
+		t.Errorf("err is %v, expected %v", err, ErrStackFull)
+	}
+	if s.Size() != 0 {
+		t.Errorf("size is %d, expected %d", s.Size(), 0)
+	}
+
+	if err := s.CompareAndPushMany(0, []interface{}{"foo", "bar"}, 2); err != nil {
+		t.Errorf("err is %v, expected nil", err)
+	}
+	if s.Peek() != "bar" || s.Version() != 1 {
+		t.Errorf("peek is %v and version %d, expected %v and %d", s.Peek(), s.Version(), "bar", 1)
+	}
+}
+
+func TestStackCompareAndPopMany(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+	s.PushMany([]interface{}{"foo", "bar", "baz"})
+
+	if _, err := s.CompareAndPopMany(0, 2); err != ErrVersionMismatch {
+		t.Errorf("err is %v, expected %v", err, ErrVersionMismatch)
+	}
+	if s.Size() != 3 {
+		t.Errorf("size is %d, expected %d", s.Size(), 3)
+	}
+
+	elements, err := s.CompareAndPopMany(1, 2)
+	if err != nil {
+		t.Errorf("err is %v, expected nil", err)
+	}
+	if !reflect.DeepEqual(elements, []interface{}{"baz", "bar"}) || s.Version() != 2 {
+		t.Errorf("elements are %v and version %d, expected %v and %d",
+			elements, s.Version(), []interface{}{"baz", "bar"}, 2)
+	}
+}
+
+func TestStackCompareAndPush_Concurrent(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+
+	// only one of the clients reading the same
+	// version can push
+	results := make(chan error)
+	for i := 0; i < 10; i++ {
+		go func(i int) { results <- s.CompareAndPush(0, i) }(i)
+	}
+
+	var pushed int
+	for i := 0; i < 10; i++ {
+		if err := <-results; err == nil {
+			pushed++
+		}
+	}
+	if pushed != 1 || s.Size() != 1 {
+		t.Errorf("pushed %d elements and size is %d, expected %d and %d", pushed, s.Size(), 1, 1)
+	}
+}
+
+func TestStackPushExpiring(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+	s.Push("foo")
+
+	if err := s.PushExpiring("bar", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if s.Size() != 1 || s.Peek() != "foo" {
+		t.Errorf("size is %d and peek %v, expected %d and %v", s.Size(), s.Peek(), 1, "foo")
+	}
+
+	if err := s.PushExpiring("baz", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if s.Size() != 2 || s.Peek() != "baz" {
+		t.Errorf("size is %d and peek %v, expected %d and %v", s.Size(), s.Peek(), 2, "baz")
+	}
+	if v := s.Version(); v != 3 {
+		t.Errorf("version is %d, expected %d", v, 3)
+	}
+}
+
+func TestStackPushExpiring_NoExpiration(t *testing.T) {
+	s := NewStackWithBase("test-stack", time.Now(), &TestBaseStack{})
+
+	if err := s.PushExpiring("foo", time.Now().Add(time.Hour)); err != ErrNoExpiration {
+		t.Errorf("err is %v, expected %v", err, ErrNoExpiration)
+	}
+	if err := s.CompareAndPushExpiring(0, "foo", time.Now().Add(time.Hour)); err != ErrNoExpiration {
+		t.Errorf("err is %v, expected %v", err, ErrNoExpiration)
+	}
+	if v := s.Version(); v != 0 {
+		t.Errorf("version is %d, expected %d", v, 0)
+	}
+}
+
+func TestStackCompareAndPushExpiring(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+
+	if err := s.CompareAndPushExpiring(1, "foo", time.Now().Add(time.Hour)); err != ErrVersionMismatch {
+		t.Errorf("err is %v, expected %v", err, ErrVersionMismatch)
+	}
+	if err := s.CompareAndPushExpiring(0, "foo", time.Now().Add(-time.Second)); err != nil {
+		t.Errorf("err is %v, expected nil", err)
+	}
+	if s.Size() != 0 || s.Version() != 1 {
+		t.Errorf("size is %d and version %d, expected %d and %d", s.Size(), s.Version(), 0, 1)
+	}
+}
+
+func TestStackExpire(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+	_ = s.PushExpiring("foo", time.Now().Add(-time.Second))
+	_ = s.PushExpiring("bar", time.Now().Add(time.Hour))
+
+	if n := s.Expire(); n != 1 {
+		t.Errorf("expired %d elements, expected %d", n, 1)
+	}
+	if v := s.Version(); v != 2 {
+		t.Errorf("version is %d, expected %d", v, 2)
+	}
+
+	s = NewStackWithBase("test-stack", time.Now(), &TestBaseStack{})
+	if n := s.Expire(); n != 0 {
+		t.Errorf("expired %d elements, expected %d", n, 0)
+	}
+}
+
+func TestStackIdle(t *testing.T) {
+	now := time.Now()
+	s := NewStack("test-stack", now)
+	if s.Idle(now.Add(time.Hour)) {
+		t.Error("stack without idle TTL is idle")
+	}
+
+	s.IdleTTL = time.Minute
+	if s.Idle(now.Add(time.Hour)) {
+		t.Error("stack never read is idle")
+	}
+
+	s.Read(now)
+	if s.Idle(now.Add(time.Second)) {
+		t.Error("stack read a second ago is idle")
+	}
+	if !s.Idle(now.Add(time.Minute)) {
+		t.Error("stack read a minute ago is not idle")
+	}
+}
+
+func TestMove(t *testing.T) {
+	src := NewStack("src", time.Now())
+	dst := NewStack("dst", time.Now())
+	src.Push("foo")
+	src.Push("bar")
+
+	element, ok := Move(src, dst)
+	if !ok {
+		t.Fatal("Move() is false, expected true")
+	}
+	if element != "bar" {
+		t.Errorf("element is %v, expected %v", element, "bar")
+	}
+	if src.Size() != 1 || src.Peek() != "foo" {
+		t.Errorf("src is %v with size %d, expected foo and 1", src.Peek(), src.Size())
+	}
+	if dst.Size() != 1 || dst.Peek() != "bar" {
+		t.Errorf("dst is %v with size %d, expected bar and 1", dst.Peek(), dst.Size())
+	}
+}
+
+func TestMove_Expiring(t *testing.T) {
+	src := NewStack("src", time.Now())
+	dst := NewStack("dst", time.Now())
+	disk := NewStackWithBase("disk", time.Now(), struct{ stack.Stacker }{stack.NewStack()})
+	expiresAt := time.Now().Add(time.Hour)
+	_ = src.PushExpiring("foo", expiresAt)
+	_ = src.PushExpiring("bar", expiresAt)
+
+	if _, ok := Move(src, dst); !ok {
+		t.Fatal("Move() is false, expected true")
+	}
+	dst.base.(stack.Expirer).WalkExpiring(func(element interface{}, at time.Time) bool {
+		if element != "bar" || at != expiresAt {
+			t.Errorf("dst top is %v expiring at %v, expected %v expiring at %v", element, at, "bar", expiresAt)
+		}
+		return false
+	})
+
+	if _, ok := Move(src, disk); !ok {
+		t.Fatal("Move() is false, expected true")
+	}
+	if disk.Peek() != "foo" {
+		t.Errorf("disk peek is %v, expected %v", disk.Peek(), "foo")
+	}
+}
+
+func TestMove_Empty(t *testing.T) {
+	src := NewStack("src", time.Now())
+	dst := NewStack("dst", time.Now())
+
+	if element, ok := Move(src, dst); ok {
+		t.Errorf("Move() is %v, expected false", element)
+	}
+	if dst.Size() != 0 {
+		t.Errorf("dst size is %d, expected 0", dst.Size())
+	}
+}
+
+func TestMove_Same(t *testing.T) {
+	s := NewStack("test-stack", time.Now())
+	if _, ok := Move(s, s); ok {
+		t.Error("Move() is true, expected false")
+	}
+
+	s.Push("foo")
+	element, ok := Move(s, s)
+	if !ok || element != "foo" {
+		t.Errorf("Move() is %v, %v, expected foo, true", element, ok)
+	}
+	if s.Size() != 1 {
+		t.Errorf("size is %d, expected 1", s.Size())
+	}
+}
+
+func TestMove_Removed(t *testing.T) {
+	db := NewDatabase("db")
+	src := NewStack("src", time.Now())
+	dst := NewStack("dst", time.Now())
+	_ = db.AddStack(dst)
+	src.Push("foo")
+	db.RemoveStack(dst.ID)
+
+	if _, ok := Move(src, dst); ok {
+		t.Error("Move() is true, expected false")
+	}
+	if src.Size() != 1 {
+		t.Errorf("src size is %d, expected 1", src.Size())
+	}
+}
+
+func TestMove_Notify(t *testing.T) {
+	src := NewStack("src", time.Now())
+	dst := NewStack("dst", time.Now())
+	src.Push("foo")
+
+	done := make(chan bool)
+	go func() { done <- dst.Wait(time.Hour, false) }()
+	for waiters(dst) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	Move(src, dst)
+
+	if ok := <-done; !ok {
+		t.Error("dst.Wait() is false, expected true")
+	}
+}
+
+func TestMove_Concurrent(t *testing.T) {
+	a := NewStack("a", time.Now())
+	b := NewStack("b", time.Now())
+	for i := 0; i < 100; i++ {
+		a.Push(i)
+		b.Push(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			Move(a, b)
+			Move(b, a)
+		}
+		close(done)
+	}()
+	go func() {
+		for i := 0; i < 1000; i++ {
+			Move(b, a)
+			Move(a, b)
+		}
+	}()
+
+	// the element being moved is never missing
+	// from both Stacks
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		unlock := lockStacks(a, b)
+		size := a.base.Size() + b.base.Size()
+		unlock()
+		if size != 200 {
+			t.Fatalf("size is %d, expected 200", size)
+		}
+	}
+}
+
 func TestStackUpdate(t *testing.T) {
 	now := time.Now()
 	updateTime := time.Now()
@@ -355,6 +1021,8 @@ func TestElementDecode_Error(t *testing.T) {
 		`$`,
 		`%{}`,
 		`{"ement":"foo"}`,
+		`{"element":"foo","ttl":-1}`,
+		`{"element":"foo","ttl":"1m"}`,
 	}
 
 	for _, elementReader := range elementReaders {
@@ -366,3 +1034,78 @@ func TestElementDecode_Error(t *testing.T) {
 		}
 	}
 }
+
+func TestElementDecode_TTL(t *testing.T) {
+	var element Element
+	if err := element.Decode(bytes.NewBufferString(`{"element":"foo","ttl":1.5}`)); err != nil {
+		t.Fatal(err)
+	}
+	if element.Value != "foo" || element.TTL != 1.5 {
+		t.Errorf("element is %#v, expected foo with ttl 1.5", element)
+	}
+}
+
+func TestElementExpiresAt(t *testing.T) {
+	now := time.Now()
+	if expiresAt := (Element{Value: "foo"}).ExpiresAt(now); !expiresAt.IsZero() {
+		t.Errorf("expiresAt is %v, expected zero", expiresAt)
+	}
+	if expiresAt := (Element{Value: "foo", TTL: 1.5}).ExpiresAt(now); expiresAt != now.Add(1500*time.Millisecond) {
+		t.Errorf("expiresAt is %v, expected %v", expiresAt, now.Add(1500*time.Millisecond))
+	}
+}
+
+func TestElementsJSON(t *testing.T) {
+	inputOutput := []struct {
+		input  Elements
+		output string
+	}{
+		{Elements{"foo", 8, map[string]int{"one": 1}}, `["foo",8,{"one":1}]`},
+		{Elements{}, `[]`},
+		{nil, `[]`},
+	}
+
+	for _, io := range inputOutput {
+		if b, err := io.input.ToJSON(); err != nil {
+			t.Fatal(err)
+		} else if string(b) != io.output {
+			t.Errorf("elements are %s, expected %s", string(b), io.output)
+		}
+	}
+
+	if _, err := (Elements{make(chan int)}).ToJSON(); err == nil {
+		t.Error("err is nil, expected UnsupportedTypeError")
+	}
+}
+
+func TestElementsDecode(t *testing.T) {
+	r := bytes.NewBuffer([]byte(` ["foo", 42, {"one":1}, null]`))
+
+	var elements Elements
+	if err := elements.Decode(r); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Elements{"foo", 42.0, map[string]interface{}{"one": 1.0}, nil}
+	if !reflect.DeepEqual(elements, expected) {
+		t.Errorf("elements are %#v, expected %#v", elements, expected)
+	}
+}
+
+func TestElementsDecode_Error(t *testing.T) {
+	elementsReaders := []string{
+		`[`,
+		``,
+		`{"element":"foo"}`,
+		`"foo"`,
+	}
+
+	for _, elementsReader := range elementsReaders {
+		r := bytes.NewBuffer([]byte(elementsReader))
+
+		var elements Elements
+		if err := elements.Decode(r); err == nil {
+			t.Errorf("err is nil for %q, expected error", elementsReader)
+		}
+	}
+}
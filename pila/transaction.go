@@ -0,0 +1,289 @@
+package pila
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pkg/stack"
+	"github.com/fern4lvarez/piladb/pkg/uuid"
+)
+
+// Operations that can be run in a transaction.
+const (
+	// TxPush pushes an element on top of a Stack.
+	TxPush = "push"
+	// TxPop pops the element on top of a Stack. It fails
+	// if the Stack is empty.
+	TxPop = "pop"
+	// TxFlush flushes the content of a Stack.
+	TxFlush = "flush"
+)
+
+// txEvents maps the operations of a transaction to the
+// type of the events they publish.
+var txEvents = map[string]string{
+	TxPush:  EventPush,
+	TxPop:   EventPop,
+	TxFlush: EventFlush,
+}
+
+// ErrStackFull is returned when elements are pushed in a
+// transaction or in a batch into a Stack that would exceed
+// its maximum size.
+var ErrStackFull = errors.New("stack is full")
+
+// TxOp represents an operation of a transaction on a Stack,
+// given by its ID or its name.
+type TxOp struct {
+	Op      string      `json:"op"`
+	Stack   string      `json:"stack"`
+	Element interface{} `json:"element,omitempty"`
+}
+
+// TxResult represents the result of an operation of a transaction.
+// Element is the popped or pushed element, Size is the size of the
+// Stack after the operation, and Error is set if it failed.
+type TxResult struct {
+	Op      string      `json:"op"`
+	Stack   string      `json:"stack"`
+	Element interface{} `json:"element,omitempty"`
+	Size    int         `json:"size"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// TxStatus represents the outcome of a transaction.
+type TxStatus struct {
+	Committed bool       `json:"committed"`
+	Results   []TxResult `json:"results"`
+}
+
+// ToJSON converts a TxStatus into JSON.
+func (txStatus TxStatus) ToJSON() ([]byte, error) {
+	if txStatus.Results == nil {
+		txStatus.Results = []TxResult{}
+	}
+	return json.Marshal(txStatus)
+}
+
+// Validate returns an error if the operation is unknown
+// or is missing the Stack.
+func (op TxOp) Validate() error {
+	switch op.Op {
+	case TxPush, TxPop, TxFlush:
+	default:
+		return fmt.Errorf("unknown operation %q", op.Op)
+	}
+	if op.Stack == "" {
+		return fmt.Errorf("operation %s has no stack", op.Op)
+	}
+	return nil
+}
+
+// Transaction runs a list of operations on the Stacks of the Database
+// in order and atomically, given a time t that is set as update date
+// of the modified Stacks. The Database and all its Stacks are locked
+// during the transaction. Elements cannot be pushed into Stacks holding
+// maxStackSize elements or more, unless they evict elements on overflow,
+// or maxStackSize is -1.
+//
+// If any operation fails, the Stacks are rolled back to their previous
+// state, and an error is returned. The result of every operation that
+// ran is returned in any case.
+func (db *Database) Transaction(ops []TxOp, maxStackSize int, t time.Time) ([]TxResult, error) {
+	for _, op := range ops {
+		if err := op.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	stacks := make([]*Stack, 0, len(db.Stacks))
+	for _, s := range db.Stacks {
+		stacks = append(stacks, s)
+	}
+	unlock := lockStacks(stacks...)
+
+	tx := &transaction{
+		versions: make(map[*Stack]uint64),
+		pushed:   make(map[*Stack]int),
+	}
+	results := make([]TxResult, 0, len(ops))
+	// applied contains the Stack of every operation
+	// applied, to publish its event on commit.
+	applied := make([]*Stack, 0, len(ops))
+	var err error
+	for _, op := range ops {
+		result := TxResult{Op: op.Op, Stack: op.Stack}
+
+		s, ok := db.txStack(op.Stack)
+		if !ok {
+			err = fmt.Errorf("database %v does not contain stack %v", db.Name, op.Stack)
+		} else {
+			result.Element, err = tx.apply(s, op, maxStackSize)
+			result.Size = s.base.Size()
+		}
+
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			break
+		}
+		results = append(results, result)
+		applied = append(applied, s)
+	}
+
+	if err != nil {
+		tx.rollback()
+		unlock()
+		return results, err
+	}
+	for i, s := range applied {
+		s.observe(txEvents[results[i].Op], 1)
+		s.publishSize(txEvents[results[i].Op], results[i].Element, results[i].Size)
+	}
+	unlock()
+
+	for s, n := range tx.pushed {
+		s.notify(n)
+	}
+	for s := range tx.versions {
+		s.Update(t)
+	}
+	return results, nil
+}
+
+// txStack returns the Stack of the Database given by its ID
+// or its name. The Database must be locked.
+func (db *Database) txStack(input string) (*Stack, bool) {
+	s, ok := db.Stacks[uuid.UUID(input)]
+	if !ok {
+		s, ok = db.Stacks[uuid.New(db.Name+input)]
+	}
+	return s, ok
+}
+
+// transaction holds the state needed to roll back the operations
+// applied on a set of locked Stacks.
+type transaction struct {
+	// undo contains the functions reverting the operations
+	// applied, in order.
+	undo []func()
+	// versions contains the version of the modified Stacks
+	// before the transaction.
+	versions map[*Stack]uint64
+	// pushed contains the number of elements pushed into the
+	// modified Stacks, to notify their waiters.
+	pushed map[*Stack]int
+}
+
+// apply applies an operation on a locked Stack, and returns the
+// popped or pushed element.
+func (tx *transaction) apply(s *Stack, op TxOp, maxStackSize int) (interface{}, error) {
+	bounded, isBounded := s.base.(stack.Bounded)
+
+	switch op.Op {
+	case TxPush:
+		if s.Overflow != OverflowEvict && maxStackSize != -1 && s.base.Size() >= maxStackSize {
+			return nil, ErrStackFull
+		}
+
+		if isBounded && s.base.Size() >= bounded.Capacity() {
+			// the bottom element is evicted, so the
+			// whole content must be restored
+			if err := tx.save(s); err != nil {
+				return nil, err
+			}
+		} else {
+			tx.track(s)
+			tx.undo = append(tx.undo, func() { s.base.Pop() })
+		}
+		s.push(op.Element)
+		tx.pushed[s]++
+		return op.Element, nil
+
+	case TxPop:
+		tx.track(s)
+		expiresAt := peekExpiresAt(s)
+		element, ok := s.pop()
+		if !ok {
+			return nil, ErrEmptyStack
+		}
+		tx.undo = append(tx.undo, func() { pushBase(s, element, expiresAt) })
+		return element, nil
+
+	case TxFlush:
+		if err := tx.save(s); err != nil {
+			return nil, err
+		}
+		s.base.Flush()
+		s.version++
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// track records the version of a Stack before the
+// transaction modifies it for the first time.
+func (tx *transaction) track(s *Stack) {
+	if _, ok := tx.versions[s]; !ok {
+		tx.versions[s] = s.version
+	}
+}
+
+// save records the content of a Stack, so it can be restored on
+// rollback. It returns an error if the Stack cannot be walked.
+func (tx *transaction) save(s *Stack) error {
+	var elements []interface{}
+	var expirations []time.Time
+	if expirer, ok := s.base.(stack.Expirer); ok {
+		expirer.WalkExpiring(func(element interface{}, expiresAt time.Time) bool {
+			elements = append(elements, element)
+			expirations = append(expirations, expiresAt)
+			return true
+		})
+	} else if walker, ok := s.base.(stack.Walker); ok {
+		walker.Walk(func(element interface{}) bool {
+			elements = append(elements, element)
+			expirations = append(expirations, time.Time{})
+			return true
+		})
+	} else {
+		return fmt.Errorf("stack %v does not support transactions", s.Name)
+	}
+
+	tx.track(s)
+	tx.undo = append(tx.undo, func() {
+		s.base.Flush()
+		for i := len(elements) - 1; i >= 0; i-- {
+			pushBase(s, elements[i], expirations[i])
+		}
+	})
+	return nil
+}
+
+// pushBase pushes an element that expires at the given time,
+// if any, into the base of a locked Stack, without modifying
+// its version.
+func pushBase(s *Stack, element interface{}, expiresAt time.Time) {
+	if expirer, ok := s.base.(stack.Expirer); ok && !expiresAt.IsZero() {
+		expirer.PushExpiring(element, expiresAt)
+		return
+	}
+	s.base.Push(element)
+}
+
+// rollback reverts the applied operations in reverse order,
+// and restores the version of the modified Stacks.
+func (tx *transaction) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	for s, version := range tx.versions {
+		s.version = version
+	}
+}
@@ -0,0 +1,258 @@
+package pila
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pkg/stack"
+)
+
+func txTestDatabase() (*Database, *Stack, *Stack, *Stack) {
+	db := NewDatabase("db")
+	a := NewStack("a", time.Now())
+	b := NewStack("b", time.Now())
+	c := NewStack("c", time.Now())
+	_ = db.AddStack(a)
+	_ = db.AddStack(b)
+	_ = db.AddStack(c)
+
+	a.PushMany([]interface{}{"foo", "bar"})
+	c.PushMany([]interface{}{"one", "two", "three"})
+	return db, a, b, c
+}
+
+func TestDatabaseTransaction(t *testing.T) {
+	db, a, b, c := txTestDatabase()
+	now := time.Now()
+
+	results, err := db.Transaction([]TxOp{
+		{Op: TxPop, Stack: "a"},
+		{Op: TxPush, Stack: "b", Element: "x"},
+		{Op: TxPush, Stack: b.ID.String(), Element: "y"},
+		{Op: TxFlush, Stack: "c"},
+	}, -1, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []TxResult{
+		{Op: TxPop, Stack: "a", Element: "bar", Size: 1},
+		{Op: TxPush, Stack: "b", Element: "x", Size: 1},
+		{Op: TxPush, Stack: b.ID.String(), Element: "y", Size: 2},
+		{Op: TxFlush, Stack: "c", Size: 0},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("results are %v, expected %v", results, expected)
+	}
+
+	if a.Size() != 1 || b.Peek() != "y" || c.Size() != 0 {
+		t.Errorf("sizes are %d, %d and %d, expected %d, %d and %d", a.Size(), b.Size(), c.Size(), 1, 2, 0)
+	}
+	if a.Version() != 2 || b.Version() != 2 || c.Version() != 2 {
+		t.Errorf("versions are %d, %d and %d, expected 2", a.Version(), b.Version(), c.Version())
+	}
+	if b.UpdatedAt != now {
+		t.Errorf("b.UpdatedAt is %v, expected %v", b.UpdatedAt, now)
+	}
+}
+
+func TestDatabaseTransaction_Rollback(t *testing.T) {
+	inputs := []struct {
+		name string
+		op   TxOp
+	}{
+		{"missing stack", TxOp{Op: TxPush, Stack: "nostack", Element: "x"}},
+		{"empty stack", TxOp{Op: TxPop, Stack: "b"}},
+		{"full stack", TxOp{Op: TxPush, Stack: "c", Element: "x"}},
+	}
+
+	for _, in := range inputs {
+		db, a, b, c := txTestDatabase()
+		updatedAt := a.UpdatedAt
+
+		results, err := db.Transaction([]TxOp{
+			{Op: TxPop, Stack: "a"},
+			{Op: TxPush, Stack: "b", Element: "x"},
+			{Op: TxPop, Stack: "b"},
+			{Op: TxFlush, Stack: "c"},
+			{Op: TxPush, Stack: "c", Element: "1"},
+			{Op: TxPush, Stack: "c", Element: "2"},
+			{Op: TxPush, Stack: "c", Element: "3"},
+			in.op,
+			{Op: TxPush, Stack: "a", Element: "never"},
+		}, 3, time.Now())
+		if err == nil {
+			t.Fatalf("%s: err is nil, expected error", in.name)
+		}
+		if len(results) != 8 {
+			t.Errorf("%s: %d results, expected %d", in.name, len(results), 8)
+		} else if results[7].Error != err.Error() {
+			t.Errorf("%s: last error is %q, expected %q", in.name, results[7].Error, err.Error())
+		}
+
+		if elements, _ := a.Range(0, 10); !reflect.DeepEqual(elements, []interface{}{"bar", "foo"}) {
+			t.Errorf("%s: a is %v, expected [bar foo]", in.name, elements)
+		}
+		if b.Size() != 0 {
+			t.Errorf("%s: b size is %d, expected 0", in.name, b.Size())
+		}
+		if elements, _ := c.Range(0, 10); !reflect.DeepEqual(elements, []interface{}{"three", "two", "one"}) {
+			t.Errorf("%s: c is %v, expected [three two one]", in.name, elements)
+		}
+		if a.Version() != 1 || b.Version() != 0 || c.Version() != 1 {
+			t.Errorf("%s: versions are %d, %d and %d, expected 1, 0 and 1", in.name, a.Version(), b.Version(), c.Version())
+		}
+		if a.UpdatedAt != updatedAt {
+			t.Errorf("%s: a.UpdatedAt is %v, expected %v", in.name, a.UpdatedAt, updatedAt)
+		}
+	}
+}
+
+func TestDatabaseTransaction_Evict(t *testing.T) {
+	db := NewDatabase("db")
+	base, _ := stack.NewRingStack(2)
+	s := NewStackWithBase("ring", time.Now(), base)
+	s.Overflow = OverflowEvict
+	_ = db.AddStack(s)
+	s.PushMany([]interface{}{"foo", "bar"})
+
+	_, err := db.Transaction([]TxOp{
+		{Op: TxPush, Stack: "ring", Element: "baz"},
+		{Op: TxPop, Stack: "nostack"},
+	}, 1, time.Now())
+	if err == nil {
+		t.Fatal("err is nil, expected error")
+	}
+	if elements, _ := s.Range(0, 10); !reflect.DeepEqual(elements, []interface{}{"bar", "foo"}) {
+		t.Errorf("ring is %v, expected [bar foo]", elements)
+	}
+
+	if _, err := db.Transaction([]TxOp{
+		{Op: TxPush, Stack: "ring", Element: "baz"},
+	}, 1, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if elements, _ := s.Range(0, 10); !reflect.DeepEqual(elements, []interface{}{"baz", "bar"}) {
+		t.Errorf("ring is %v, expected [baz bar]", elements)
+	}
+}
+
+func TestDatabaseTransaction_RollbackExpiring(t *testing.T) {
+	db, a, _, _ := txTestDatabase()
+	expiresAt := time.Now().Add(time.Hour)
+	_ = a.PushExpiring("baz", expiresAt)
+
+	_, err := db.Transaction([]TxOp{
+		{Op: TxPop, Stack: "a"},
+		{Op: TxFlush, Stack: "a"},
+		{Op: TxPop, Stack: "nostack"},
+	}, -1, time.Now())
+	if err == nil {
+		t.Fatal("err is nil, expected error")
+	}
+
+	var expirations []time.Time
+	a.base.(stack.Expirer).WalkExpiring(func(_ interface{}, t time.Time) bool {
+		expirations = append(expirations, t)
+		return true
+	})
+	if !reflect.DeepEqual(expirations, []time.Time{expiresAt, {}, {}}) {
+		t.Errorf("expirations are %v, expected [%v zero zero]", expirations, expiresAt)
+	}
+}
+
+func TestDatabaseTransaction_Invalid(t *testing.T) {
+	db, a, _, _ := txTestDatabase()
+
+	for _, op := range []TxOp{
+		{Op: "peek", Stack: "a"},
+		{Op: TxPop},
+	} {
+		results, err := db.Transaction([]TxOp{{Op: TxPop, Stack: "a"}, op}, -1, time.Now())
+		if err == nil {
+			t.Errorf("err for %v is nil, expected error", op)
+		}
+		if results != nil {
+			t.Errorf("results for %v are %v, expected nil", op, results)
+		}
+	}
+
+	if a.Size() != 2 {
+		t.Errorf("a size is %d, expected %d", a.Size(), 2)
+	}
+}
+
+func TestDatabaseTransaction_Notify(t *testing.T) {
+	db, _, b, _ := txTestDatabase()
+
+	done := make(chan bool)
+	go func() { done <- b.Wait(time.Hour, false) }()
+	for waiters(b) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := db.Transaction([]TxOp{{Op: TxPush, Stack: "b", Element: "x"}}, -1, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if ok := <-done; !ok {
+		t.Error("b.Wait() is false, expected true")
+	}
+}
+
+func TestDatabaseTransaction_Concurrent(t *testing.T) {
+	db, a, b, _ := txTestDatabase()
+
+	// elements moved by transactions are never
+	// missing from both Stacks
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 500; i++ {
+			_, _ = db.Transaction([]TxOp{
+				{Op: TxPop, Stack: "a"},
+				{Op: TxPush, Stack: "b", Element: i},
+			}, -1, time.Now())
+			_, _ = db.Transaction([]TxOp{
+				{Op: TxPop, Stack: "b"},
+				{Op: TxPush, Stack: "a", Element: i},
+			}, -1, time.Now())
+		}
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		unlock := lockStacks(a, b)
+		size := a.base.Size() + b.base.Size()
+		unlock()
+		if size != 2 {
+			t.Fatalf("size is %d, expected %d", size, 2)
+		}
+	}
+}
+
+func TestTxStatusToJSON(t *testing.T) {
+	inputOutput := []struct {
+		input  TxStatus
+		output string
+	}{
+		{TxStatus{}, `{"committed":false,"results":[]}`},
+		{TxStatus{Committed: true, Results: []TxResult{{Op: TxPop, Stack: "a", Element: "foo", Size: 1}}},
+			`{"committed":true,"results":[{"op":"pop","stack":"a","element":"foo","size":1}]}`},
+		{TxStatus{Results: []TxResult{{Op: TxPop, Stack: "a", Error: "stack is empty"}}},
+			`{"committed":false,"results":[{"op":"pop","stack":"a","size":0,"error":"stack is empty"}]}`},
+	}
+
+	for _, io := range inputOutput {
+		if b, err := io.input.ToJSON(); err != nil {
+			t.Fatal(err)
+		} else if string(b) != io.output {
+			t.Errorf("JSON is %s, expected %s", b, io.output)
+		}
+	}
+}
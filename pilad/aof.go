@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pila"
+	"github.com/fern4lvarez/piladb/pkg/aof"
+	"github.com/fern4lvarez/piladb/pkg/uuid"
+)
+
+// openAOF replays the append-only file set in AOF_PATH, if any, to
+// rebuild the Pila, and opens it to log the following operations. If
+// the file does not exist yet, the snapshot is restored instead, and
+// its state is written into the new file.
+func (c *Conn) openAOF() error {
+	path := c.Config.AOFPath()
+	if path == "" {
+		return nil
+	}
+
+	_, err := os.Stat(path)
+	fromSnapshot := os.IsNotExist(err)
+	if fromSnapshot {
+		err = c.restore()
+	} else {
+		err = aof.Replay(path, c.replay)
+	}
+	if err != nil {
+		return err
+	}
+	// elements expire on the system clock from now on
+	for _, db := range c.Pila.Databases {
+		for _, stack := range db.Stacks {
+			stack.SetClock(nil)
+		}
+	}
+
+	l, err := aof.Open(path, aof.Policy(c.Config.AOFFsync()))
+	if err != nil {
+		return err
+	}
+	c.aof = l
+
+	if fromSnapshot {
+		if len(c.Pila.Databases) == 0 && len(c.Auth.Stored()) == 0 {
+			return nil
+		}
+		return c.rewriteAOF()
+	}
+	c.Logger.Info("append-only file replayed", "databases", len(c.Pila.Databases), "path", path)
+	return nil
+}
+
+// replay applies an operation logged in the append-only file
+// to the Pila. Elements expire at the time of the entries, so
+// operations see the same elements they saw when applied.
+func (c *Conn) replay(entry aof.Entry) error {
+	c.replayTime = entry.Time
+
+	switch entry.Op {
+	case aof.CreateDatabase:
+		if err := checkDatabaseName(entry.Database); err != nil {
+			return err
+		}
+		db := pila.NewDatabase(entry.Database)
+		db.IdleTTL = pila.Seconds(entry.IdleTTL)
+		db.Read(entry.Time)
+		return c.Pila.AddDatabase(db)
+	case aof.DeleteDatabase:
+		if !c.Pila.RemoveDatabase(uuid.New(entry.Database)) {
+			return fmt.Errorf("database %s does not exist", entry.Database)
+		}
+		return nil
+	case aof.CreateToken:
+		return c.storeToken(entry.Element)
+	case aof.RevokeToken:
+		if !c.Auth.Revoke(entry.Stack) {
+			return fmt.Errorf("token %s does not exist", entry.Stack)
+		}
+		return nil
+	}
+
+	db, ok := c.Pila.Database(uuid.New(entry.Database))
+	if !ok {
+		return fmt.Errorf("database %s does not exist", entry.Database)
+	}
+
+	if entry.Op == aof.Tx {
+		// operations of committed transactions are
+		// replayed in order, as they never failed
+		for _, e := range entry.Entries {
+			e.Database = entry.Database
+			e.Time = entry.Time
+			if err := c.replay(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if entry.Op == aof.CreateStack {
+		if _, ok := db.Stacks[uuid.New(db.Name+entry.Stack)]; ok {
+			return fmt.Errorf("database %s already contains stack %s", entry.Database, entry.Stack)
+		}
+
+		base, err := c.openBase(entry.Engine, db.Name, entry.Stack, entry.Capacity, entry.Checkpoint)
+		if err != nil {
+			return err
+		}
+
+		stack := pila.NewStackWithBase(entry.Stack, entry.Time, base)
+		stack.Engine = engineName(entry.Engine)
+		stack.Overflow = entry.Overflow
+		stack.IdleTTL = pila.Seconds(entry.IdleTTL)
+		stack.SetVersion(entry.Version)
+		stack.SetClock(c.replayClock)
+		if err := db.AddStack(stack); err != nil {
+			return err
+		}
+		stack.Update(entry.Time)
+		return nil
+	}
+
+	stack, ok := db.Stacks[uuid.New(db.Name+entry.Stack)]
+	if !ok {
+		return fmt.Errorf("stack %s does not exist in database %s", entry.Stack, entry.Database)
+	}
+
+	switch entry.Op {
+	case aof.DeleteStack:
+		stack.Flush()
+		_ = db.RemoveStack(stack.ID)
+		return nil
+	case aof.Push:
+		if entry.ExpiresAt != nil {
+			if err := stack.PushExpiring(entry.Element, *entry.ExpiresAt); err != nil {
+				return err
+			}
+			break
+		}
+		stack.Push(entry.Element)
+	case aof.Pop:
+		stack.Pop()
+	case aof.PushMany:
+		stack.PushMany(entry.Elements)
+	case aof.PopMany:
+		stack.PopMany(entry.Count)
+	case aof.Move:
+		dstDB, ok := c.Pila.Database(uuid.New(entry.ToDatabase))
+		if !ok {
+			return fmt.Errorf("database %s does not exist", entry.ToDatabase)
+		}
+		dst, ok := dstDB.Stack(uuid.New(dstDB.Name + entry.ToStack))
+		if !ok {
+			return fmt.Errorf("stack %s does not exist in database %s", entry.ToStack, entry.ToDatabase)
+		}
+		pila.Move(stack, dst)
+		dst.Update(entry.Time)
+	case aof.Flush:
+		stack.Flush()
+	default:
+		return fmt.Errorf("unknown operation %s", entry.Op)
+	}
+
+	stack.Update(entry.Time)
+	return nil
+}
+
+// replayClock returns the time of the append-only
+// file entry being replayed.
+func (c *Conn) replayClock() time.Time {
+	return c.replayTime
+}
+
+// persist applies an operation calling apply and, if the append-only
+// file is enabled, logs entry when apply returns true.
+func (c *Conn) persist(entry aof.Entry, apply func() bool) {
+	if c.aof == nil {
+		apply()
+		return
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	if err := c.aof.Append(entry, apply); err != nil {
+		c.Logger.Error("error on appending to append-only file", "op", entry.Op, "error", err)
+	}
+}
+
+// stackEntry returns the append-only file entry of an operation
+// on a Stack at a given time.
+func (c *Conn) stackEntry(op string, stack *pila.Stack, t time.Time) aof.Entry {
+	entry := aof.Entry{
+		Op:    op,
+		Stack: stack.Name,
+		Time:  t,
+	}
+	if stack.Database != nil {
+		entry.Database = stack.Database.Name
+	}
+	return entry
+}
+
+// rewriteAOF compacts the append-only file, saving the elements of disk
+// Stacks in checkpoints, which replace the previous ones once the file
+// is rewritten.
+func (c *Conn) rewriteAOF() error {
+	checkpoint := checkpointName("aof")
+	err := c.aof.Rewrite(func() ([]aof.Entry, error) {
+		return c.aofState(checkpoint)
+	})
+	if err != nil {
+		if err != aof.ErrRewriteInProgress {
+			c.removeCheckpoint(checkpoint)
+		}
+		return err
+	}
+	c.removeCheckpoints("aof-", checkpoint)
+	return nil
+}
+
+// aofState returns the shortest list of append-only file entries that
+// rebuild the current state of the Pila and the stored tokens. The
+// elements of disk Stacks are saved in checkpoint instead of being
+// pushed by the entries.
+func (c *Conn) aofState(checkpoint string) ([]aof.Entry, error) {
+	snapshot, err := c.Pila.Snapshot(time.Now().UTC(), c.checkpoint(checkpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []aof.Entry
+	for _, ds := range snapshot.Databases {
+		readAt := ds.ReadAt
+		if readAt.IsZero() {
+			readAt = snapshot.CreatedAt
+		}
+		entries = append(entries, aof.Entry{
+			Op:       aof.CreateDatabase,
+			Database: ds.Name,
+			IdleTTL:  ds.IdleTTL,
+			Time:     readAt,
+		})
+
+		for _, ss := range ds.Stacks {
+			// the version of the Stack is set before pushing its
+			// elements, so it is not lower than the current one
+			var version uint64
+			if n := uint64(len(ss.Elements)); ss.Version > n {
+				version = ss.Version - n
+			}
+			entries = append(entries, aof.Entry{
+				Op:         aof.CreateStack,
+				Database:   ds.Name,
+				Stack:      ss.Name,
+				Engine:     ss.Engine,
+				Overflow:   ss.Overflow,
+				Capacity:   ss.Capacity,
+				IdleTTL:    ss.IdleTTL,
+				Version:    version,
+				Checkpoint: ss.Checkpoint,
+				Time:       ss.CreatedAt,
+			})
+
+			for i, element := range ss.Elements {
+				entry := aof.Entry{
+					Op:       aof.Push,
+					Database: ds.Name,
+					Stack:    ss.Name,
+					Element:  element,
+					Time:     ss.UpdatedAt,
+				}
+				if i < len(ss.Expirations) && !ss.Expirations[i].IsZero() {
+					entry.ExpiresAt = &ss.Expirations[i]
+				}
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	for _, token := range c.Auth.Stored() {
+		entries = append(entries, tokenEntry(token))
+	}
+
+	return entries, nil
+}
+
+// aofRewriteLoop rewrites the append-only file in the background every
+// time it doubles its size, once it reaches AOF_REWRITE_MIN_SIZE bytes.
+func (c *Conn) aofRewriteLoop() {
+	for range time.Tick(time.Second) {
+		if c.aof == nil || !c.aof.ShouldRewrite(int64(c.Config.AOFRewriteMinSize())) {
+			continue
+		}
+
+		if err := c.rewriteAOF(); err != nil {
+			c.Logger.Error("error on rewriting append-only file", "error", err)
+			continue
+		}
+		c.Logger.Info("append-only file rewritten", "size", MemOutput(uint64(c.aof.Size())))
+	}
+}
@@ -0,0 +1,592 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/auth"
+	"github.com/fern4lvarez/piladb/config/vars"
+	"github.com/fern4lvarez/piladb/pila"
+	"github.com/fern4lvarez/piladb/pkg/aof"
+)
+
+func aofTestConn(t *testing.T, path string) *Conn {
+	conn := NewConn()
+	conn.Config.Set(vars.AOFPath, path)
+	conn.Config.Set(vars.AOFFsync, "always")
+	if err := conn.openAOF(); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func serve(t *testing.T, conn *Conn, method, url string, body []byte) int {
+	request, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := httptest.NewRecorder()
+	Router(conn).ServeHTTP(response, request)
+	return response.Code
+}
+
+func TestAOF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	conn := aofTestConn(t, path)
+
+	requests := []struct {
+		method, url string
+		body        string
+		code        int
+	}{
+		{"PUT", "/databases?name=db", "", http.StatusCreated},
+		{"PUT", "/databases?name=db", "", http.StatusConflict},
+		{"PUT", "/databases?name=tmp", "", http.StatusCreated},
+		{"PUT", "/databases/db/stacks?name=stack", "", http.StatusCreated},
+		{"PUT", "/databases/db/stacks?name=flushed", "", http.StatusCreated},
+		{"PUT", "/databases/db/stacks?name=deleted", "", http.StatusCreated},
+		{"POST", "/databases/db/stacks/stack", `{"element":"foo"}`, http.StatusOK},
+		{"POST", "/databases/db/stacks/stack", `{"element":"bar"}`, http.StatusOK},
+		{"POST", "/databases/db/stacks/stack", `{"element":{"baz":1}}`, http.StatusOK},
+		{"DELETE", "/databases/db/stacks/stack", "", http.StatusOK},
+		{"POST", "/databases/db/stacks/flushed", `{"element":"foo"}`, http.StatusOK},
+		{"DELETE", "/databases/db/stacks/flushed?flush", "", http.StatusOK},
+		{"DELETE", "/databases/db/stacks/flushed", "", http.StatusNoContent},
+		{"DELETE", "/databases/db/stacks/deleted?full", "", http.StatusNoContent},
+		{"DELETE", "/databases/tmp", "", http.StatusNoContent},
+	}
+
+	for _, r := range requests {
+		if code := serve(t, conn, r.method, r.url, []byte(r.body)); code != r.code {
+			t.Errorf("%s %s response code is %d, expected %d", r.method, r.url, code, r.code)
+		}
+	}
+
+	if err := conn.aof.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := aofTestConn(t, path)
+	defer replayed.aof.Close()
+
+	if n := len(replayed.Pila.Databases); n != 1 {
+		t.Fatalf("replayed %d databases, expected %d", n, 1)
+	}
+
+	db, ok := ResourceDatabase(replayed, "db")
+	if !ok {
+		t.Fatal("database db was not replayed")
+	}
+	if n := len(db.Stacks); n != 2 {
+		t.Errorf("replayed %d stacks, expected %d", n, 2)
+	}
+
+	stack, ok := ResourceStack(db, "stack")
+	if !ok {
+		t.Fatal("stack stack was not replayed")
+	}
+	if stack.Size() != 2 {
+		t.Errorf("stack size is %d, expected %d", stack.Size(), 2)
+	}
+	if stack.Peek() != "bar" {
+		t.Errorf("stack peek is %v, expected %v", stack.Peek(), "bar")
+	}
+
+	flushed, ok := ResourceStack(db, "flushed")
+	if !ok {
+		t.Fatal("stack flushed was not replayed")
+	}
+	if flushed.Size() != 0 {
+		t.Errorf("stack size is %d, expected %d", flushed.Size(), 0)
+	}
+}
+
+func TestAOF_Batch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	conn := aofTestConn(t, path)
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=stack", nil)
+	serve(t, conn, "POST", "/databases/db/stacks/stack?batch", []byte(`["foo","bar","baz","qux"]`))
+	serve(t, conn, "DELETE", "/databases/db/stacks/stack?pop=3", nil)
+	serve(t, conn, "DELETE", "/databases/db/stacks/stack?pop=3", nil)
+	serve(t, conn, "POST", "/databases/db/stacks/stack?batch", []byte(`["one","two"]`))
+	conn.aof.Close()
+
+	var entries []aof.Entry
+	_ = aof.Replay(path, func(e aof.Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if n := len(entries); n != 6 {
+		t.Errorf("file has %d entries, expected %d", n, 6)
+	}
+
+	replayed := aofTestConn(t, path)
+	defer replayed.aof.Close()
+
+	db, _ := ResourceDatabase(replayed, "db")
+	stack, ok := ResourceStack(db, "stack")
+	if !ok {
+		t.Fatal("stack was not replayed")
+	}
+	if stack.Size() != 2 || stack.Peek() != "two" {
+		t.Errorf("stack size is %d and peek %v, expected %d and %v", stack.Size(), stack.Peek(), 2, "two")
+	}
+}
+
+func TestAOF_Move(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	conn := aofTestConn(t, path)
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases?name=db2", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=src", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=dst", nil)
+	serve(t, conn, "PUT", "/databases/db2/stacks?name=dst", nil)
+	serve(t, conn, "POST", "/databases/db/stacks/src?batch", []byte(`["foo","bar"]`))
+	serve(t, conn, "POST", "/databases/db/stacks/src/_move?to=dst", nil)
+	serve(t, conn, "POST", "/databases/db/stacks/src/_move?to=dst&database=db2", nil)
+	serve(t, conn, "POST", "/databases/db/stacks/src/_move?to=dst", nil)
+	conn.aof.Close()
+
+	var entries []aof.Entry
+	_ = aof.Replay(path, func(e aof.Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if n := len(entries); n != 8 {
+		t.Errorf("file has %d entries, expected %d", n, 8)
+	}
+
+	replayed := aofTestConn(t, path)
+	defer replayed.aof.Close()
+
+	db, _ := ResourceDatabase(replayed, "db")
+	db2, _ := ResourceDatabase(replayed, "db2")
+	src, _ := ResourceStack(db, "src")
+	dst, _ := ResourceStack(db, "dst")
+	dst2, _ := ResourceStack(db2, "dst")
+	if src.Size() != 0 {
+		t.Errorf("src size is %d, expected %d", src.Size(), 0)
+	}
+	if dst.Size() != 1 || dst.Peek() != "bar" {
+		t.Errorf("dst size is %d and peek %v, expected %d and %v", dst.Size(), dst.Peek(), 1, "bar")
+	}
+	if dst2.Size() != 1 || dst2.Peek() != "foo" {
+		t.Errorf("dst2 size is %d and peek %v, expected %d and %v", dst2.Size(), dst2.Peek(), 1, "foo")
+	}
+}
+
+func TestAOF_TTL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	conn := aofTestConn(t, path)
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=stack", nil)
+	serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"foo","ttl":3600}`))
+	serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"bar","ttl":0.001}`))
+	conn.aof.Close()
+
+	var entries []aof.Entry
+	_ = aof.Replay(path, func(e aof.Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if n := len(entries); n != 4 {
+		t.Fatalf("file has %d entries, expected %d", n, 4)
+	}
+	if entries[2].ExpiresAt == nil || entries[3].ExpiresAt == nil {
+		t.Fatal("push entries have no expiration")
+	}
+	expiresAt := *entries[2].ExpiresAt
+
+	time.Sleep(10 * time.Millisecond)
+
+	replayed := aofTestConn(t, path)
+	defer replayed.aof.Close()
+
+	db, _ := ResourceDatabase(replayed, "db")
+	s, _ := ResourceStack(db, "stack")
+	if s.Size() != 1 || s.Peek() != "foo" {
+		t.Errorf("size is %d and peek %v, expected %d and %v", s.Size(), s.Peek(), 1, "foo")
+	}
+
+	if err := replayed.rewriteAOF(); err != nil {
+		t.Fatal(err)
+	}
+	entries = nil
+	_ = aof.Replay(path, func(e aof.Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if n := len(entries); n != 3 {
+		t.Fatalf("file has %d entries, expected %d", n, 3)
+	}
+	if entries[2].ExpiresAt == nil || !entries[2].ExpiresAt.Equal(expiresAt) {
+		t.Errorf("push entry expires at %v, expected %v", entries[2].ExpiresAt, expiresAt)
+	}
+}
+
+func TestAOF_TTLReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	l, err := aof.Open(path, aof.Always)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().UTC().Add(-time.Hour)
+	expiresAt := start.Add(10 * time.Second)
+	for _, entry := range []aof.Entry{
+		{Op: aof.CreateDatabase, Database: "db", Time: start},
+		{Op: aof.CreateStack, Database: "db", Stack: "stack", Time: start},
+		{Op: aof.CreateStack, Database: "db", Stack: "other", Time: start},
+		{Op: aof.Push, Database: "db", Stack: "stack", Element: "a", Time: start},
+		{Op: aof.Push, Database: "db", Stack: "stack", Element: "b", ExpiresAt: &expiresAt, Time: start},
+		{Op: aof.Push, Database: "db", Stack: "stack", Element: "c", ExpiresAt: &expiresAt, Time: start},
+		{Op: aof.Pop, Database: "db", Stack: "stack", Time: start.Add(time.Second)},
+		{Op: aof.Move, Database: "db", Stack: "stack", ToDatabase: "db", ToStack: "other", Time: start.Add(time.Second)},
+		{Op: aof.Push, Database: "db", Stack: "stack", Element: "d", ExpiresAt: &expiresAt, Time: start.Add(time.Second)},
+		{Op: aof.PopMany, Database: "db", Stack: "stack", Count: 1, Time: start.Add(2 * time.Second)},
+	} {
+		if err := l.Append(entry, func() bool { return true }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	replayed := aofTestConn(t, path)
+	defer replayed.aof.Close()
+
+	db, _ := ResourceDatabase(replayed, "db")
+	s, _ := ResourceStack(db, "stack")
+	if s.Size() != 1 || s.Peek() != "a" {
+		t.Errorf("size is %d and peek %v, expected %d and %v", s.Size(), s.Peek(), 1, "a")
+	}
+	other, _ := ResourceStack(db, "other")
+	if v := other.Version(); v != 1 {
+		t.Errorf("version of other is %d, expected %d", v, 1)
+	}
+}
+
+func TestAOF_Tx(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	conn := aofTestConn(t, path)
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=a", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=b", nil)
+	db, _ := ResourceDatabase(conn, "db")
+	b, _ := ResourceStack(db, "b")
+	serve(t, conn, "POST", "/databases/db/stacks/a?batch", []byte(`["foo","bar"]`))
+	serve(t, conn, "POST", "/databases/db/_tx",
+		[]byte(`[{"op":"pop","stack":"a"},{"op":"push","stack":"`+b.ID.String()+`","element":"x"}]`))
+	serve(t, conn, "POST", "/databases/db/_tx",
+		[]byte(`[{"op":"flush","stack":"a"},{"op":"pop","stack":"nostack"}]`))
+	conn.aof.Close()
+
+	var entries []aof.Entry
+	_ = aof.Replay(path, func(e aof.Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if n := len(entries); n != 5 {
+		t.Errorf("file has %d entries, expected %d", n, 5)
+	}
+
+	replayed := aofTestConn(t, path)
+	defer replayed.aof.Close()
+
+	db, _ = ResourceDatabase(replayed, "db")
+	a, _ := ResourceStack(db, "a")
+	b, _ = ResourceStack(db, "b")
+	if a.Size() != 1 || a.Peek() != "foo" {
+		t.Errorf("a size is %d and peek %v, expected %d and %v", a.Size(), a.Peek(), 1, "foo")
+	}
+	if b.Size() != 1 || b.Peek() != "x" {
+		t.Errorf("b size is %d and peek %v, expected %d and %v", b.Size(), b.Peek(), 1, "x")
+	}
+}
+
+func TestAOFRewrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	conn := aofTestConn(t, path)
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=stack", nil)
+	for i := 0; i < 10; i++ {
+		serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"foo"}`))
+		serve(t, conn, "DELETE", "/databases/db/stacks/stack", nil)
+	}
+	serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"foo"}`))
+	serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"bar"}`))
+
+	before := conn.aof.Size()
+	if err := conn.rewriteAOF(); err != nil {
+		t.Fatal(err)
+	}
+	if after := conn.aof.Size(); after >= before {
+		t.Errorf("size after rewrite is %d, expected less than %d", after, before)
+	}
+	conn.aof.Close()
+
+	var entries []aof.Entry
+	_ = aof.Replay(path, func(e aof.Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if n := len(entries); n != 4 {
+		t.Errorf("rewritten file has %d entries, expected %d", n, 4)
+	}
+
+	replayed := aofTestConn(t, path)
+	defer replayed.aof.Close()
+
+	db, _ := ResourceDatabase(replayed, "db")
+	stack, ok := ResourceStack(db, "stack")
+	if !ok {
+		t.Fatal("stack was not replayed")
+	}
+	if stack.Size() != 2 || stack.Peek() != "bar" {
+		t.Errorf("stack size is %d and peek %v, expected %d and %v", stack.Size(), stack.Peek(), 2, "bar")
+	}
+	if v := stack.Version(); v != 22 {
+		t.Errorf("stack version is %d, expected %d", v, 22)
+	}
+}
+
+// createToken creates a token through the tokens endpoint, and
+// returns its secret and its ID.
+func createToken(t *testing.T, conn *Conn, body string) (string, string) {
+	request, err := http.NewRequest("POST", "/_tokens", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := httptest.NewRecorder()
+	conn.tokensHandler(response, request)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("response code is %v, expected %v", response.Code, http.StatusCreated)
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(response.Body.Bytes(), &token); err != nil {
+		t.Fatal(err)
+	}
+	return token.Secret, token.ID
+}
+
+func TestAOF_Tokens(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	conn := aofTestConn(t, path)
+	_, _ = conn.Auth.Add("admin", auth.ScopeAdmin, nil, time.Now())
+	secret, _ := createToken(t, conn, `{"scope":"read-only","databases":["db"]}`)
+	revoked, id := createToken(t, conn, `{"scope":"read-write"}`)
+
+	request, err := http.NewRequest("DELETE", "/_tokens/"+id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := httptest.NewRecorder()
+	conn.tokenHandler(id).ServeHTTP(response, request)
+	if response.Code != http.StatusNoContent {
+		t.Fatalf("response code is %v, expected %v", response.Code, http.StatusNoContent)
+	}
+	conn.aof.Close()
+
+	replayed := aofTestConn(t, path)
+	if err := replayed.rewriteAOF(); err != nil {
+		t.Fatal(err)
+	}
+	replayed.aof.Close()
+
+	// tokens survive the rewrite of the file
+	rewritten := aofTestConn(t, path)
+	defer rewritten.aof.Close()
+
+	for _, conn := range []*Conn{replayed, rewritten} {
+		if token, ok := conn.Auth.Authenticate(secret); !ok || token.Databases[0] != "db" {
+			t.Errorf("token is %v, %v, expected it to be replayed", token, ok)
+		}
+		if _, ok := conn.Auth.Authenticate(revoked); ok {
+			t.Error("revoked token was replayed")
+		}
+		// the admin token is added on start-up
+		if _, ok := conn.Auth.Authenticate("admin"); ok {
+			t.Error("admin token was replayed")
+		}
+	}
+}
+
+func TestOpenAOF_Disabled(t *testing.T) {
+	conn := NewConn()
+	if err := conn.openAOF(); err != nil {
+		t.Fatal(err)
+	}
+	if conn.aof != nil {
+		t.Error("conn.aof is not nil")
+	}
+}
+
+func TestOpenAOF_Error(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	if err := ioutil.WriteFile(path, []byte("{\"op\":\"push\",\"database\":\"db\"}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conn := NewConn()
+	conn.Config.Set(vars.AOFPath, path)
+	if err := conn.openAOF(); err == nil {
+		t.Error("err is nil")
+	}
+
+	conn.Config.Set(vars.AOFPath, "/no/exist/piladb.aof")
+	if err := conn.openAOF(); err == nil {
+		t.Error("err is nil")
+	}
+}
+
+func TestOpenAOF_Snapshot(t *testing.T) {
+	conn, dir := snapshotTestConn(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=stack", nil)
+	serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"foo"}`))
+	if _, err := conn.snapshot(time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewConn()
+	restored.Config.Set(vars.SnapshotPath, conn.Config.SnapshotPath())
+	restored.Config.Set(vars.AOFPath, path)
+	if err := restored.openAOF(); err != nil {
+		t.Fatal(err)
+	}
+	serve(t, restored, "POST", "/databases/db/stacks/stack", []byte(`{"element":"bar"}`))
+	restored.aof.Close()
+
+	// the snapshot is not read once the append-only file exists
+	if err := os.Remove(conn.Config.SnapshotPath()); err != nil {
+		t.Fatal(err)
+	}
+	replayed := aofTestConn(t, path)
+	defer replayed.aof.Close()
+
+	db, ok := ResourceDatabase(replayed, "db")
+	if !ok {
+		t.Fatal("database db was not replayed")
+	}
+	s, _ := ResourceStack(db, "stack")
+	if s.Size() != 2 || s.Peek() != "bar" {
+		t.Errorf("size is %d and peek %v, expected %d and %v", s.Size(), s.Peek(), 2, "bar")
+	}
+}
+
+func TestReplay_Errors(t *testing.T) {
+	conn := NewConn()
+	_ = conn.replay(aof.Entry{Op: aof.CreateDatabase, Database: "db"})
+	_ = conn.replay(aof.Entry{Op: aof.CreateStack, Database: "db", Stack: "stack"})
+
+	entries := []aof.Entry{
+		{Op: aof.CreateDatabase, Database: "db"},
+		{Op: aof.CreateDatabase, Database: auth.AUTH},
+		{Op: aof.DeleteDatabase, Database: "no-db"},
+		{Op: aof.Push, Database: "no-db", Stack: "stack"},
+		{Op: aof.CreateStack, Database: "db", Stack: "stack"},
+		{Op: aof.Push, Database: "db", Stack: "no-stack"},
+		{Op: "foo", Database: "db", Stack: "stack"},
+	}
+
+	for _, entry := range entries {
+		if err := conn.replay(entry); err == nil {
+			t.Errorf("err is nil for entry %v", entry)
+		}
+	}
+}
+
+func TestPersist_Disabled(t *testing.T) {
+	conn := NewConn()
+
+	var applied bool
+	conn.persist(aof.Entry{Op: aof.Push}, func() bool {
+		applied = true
+		return true
+	})
+	if !applied {
+		t.Error("operation was not applied")
+	}
+}
+
+func TestStackEntry(t *testing.T) {
+	conn := NewConn()
+	now := time.Now().UTC()
+
+	s := pila.NewStack("stack", now)
+	if entry := conn.stackEntry(aof.Pop, s, now); entry.Database != "" {
+		t.Errorf("entry.Database is %s, expected empty", entry.Database)
+	}
+
+	db := pila.NewDatabase("db")
+	_ = db.AddStack(s)
+
+	entry := conn.stackEntry(aof.Pop, s, now)
+	if entry.Op != aof.Pop || entry.Database != "db" || entry.Stack != "stack" || entry.Time != now {
+		t.Errorf("entry is %v", entry)
+	}
+}
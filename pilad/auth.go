@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fern4lvarez/piladb/auth"
+	"github.com/fern4lvarez/piladb/config/vars"
+	"github.com/fern4lvarez/piladb/pkg/aof"
+
+	"github.com/gorilla/mux"
+)
+
+// errInvalidToken is returned when a request is not authenticated
+// by a valid token.
+var errInvalidToken = errors.New("invalid token")
+
+// buildAuth adds the admin token given by the environment variable or
+// the cli flag, if any, which enables authentication.
+func (c *Conn) buildAuth() error {
+	secret := adminTokenFlag
+	if e := os.Getenv(vars.Env(auth.AdminToken)); e != "" {
+		secret = e
+	}
+	if secret == "" {
+		return nil
+	}
+
+	_, err := c.Auth.Add(secret, auth.ScopeAdmin, nil, time.Now().UTC())
+	return err
+}
+
+// authMiddleware authenticates requests by the token in their
+// Authorization header, and checks that its scope grants access to
+// the requested resource. Requests are not authenticated if there are
+// no tokens.
+func (c *Conn) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope, databases := c.requiredScope(r)
+		if scope == "" || !c.Auth.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		secret := bearerToken(r.Header.Get("Authorization"))
+		if err := c.authorize(secret, scope, databases); err != nil {
+			if err == errInvalidToken {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="piladb"`)
+				c.problem(w, r, http.StatusUnauthorized, problemUnauthorized, err)
+				return
+			}
+			c.problem(w, r, http.StatusForbidden, problemForbidden, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorize authenticates a token given its secret, and checks that
+// its scope grants access to the databases. It returns errInvalidToken
+// if there is no such token, or an error stating the access it is not
+// granted.
+func (c *Conn) authorize(secret, scope string, databases []string) error {
+	token, ok := c.Auth.Authenticate(secret)
+	if !ok {
+		return errInvalidToken
+	}
+
+	for _, database := range databases {
+		if !token.Allows(scope, database) {
+			return fmt.Errorf("token %s is not granted %s on %s", token.ID, scope, database)
+		}
+	}
+	return nil
+}
+
+// bearerToken returns the secret of a token given in an Authorization
+// header with the Bearer scheme, or an empty string if the header uses
+// any other scheme.
+func bearerToken(header string) string {
+	const scheme = "Bearer "
+	if len(header) <= len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) {
+		return ""
+	}
+	return header[len(scheme):]
+}
+
+// requiredScope returns the scope required by a request, and the names
+// of the databases it accesses, or an empty name if it accesses none.
+// Public endpoints require no scope.
+func (c *Conn) requiredScope(r *http.Request) (string, []string) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", nil
+	}
+	path, _ := route.GetPathTemplate()
+	vars := mux.Vars(r)
+
+	scope := auth.ScopeReadOnly
+	if r.Method != "GET" && r.Method != "HEAD" {
+		scope = auth.ScopeReadWrite
+	}
+
+	switch {
+	case path == "/" || path == "/_ping":
+		return "", nil
+	case path == "/_status" || path == "/_metrics" || path == "/_config" || path == "/_config/{key}":
+		if scope != auth.ScopeReadOnly {
+			return auth.ScopeAdmin, []string{""}
+		}
+		return scope, []string{""}
+	case path == "/databases":
+		if r.Method == "PUT" {
+			return scope, []string{r.URL.Query().Get("name")}
+		}
+		return scope, []string{""}
+	case path == "/databases/{id}":
+		return scope, []string{c.databaseName(vars["id"])}
+	case strings.HasPrefix(path, "/databases/{database_id}"):
+		databases := []string{c.databaseName(vars["database_id"])}
+		if database := r.URL.Query().Get("database"); database != "" {
+			databases = append(databases, c.databaseName(database))
+		}
+		return scope, databases
+	}
+	return auth.ScopeAdmin, []string{""}
+}
+
+// databaseName returns the name of a database given its ID or name, or
+// the input itself if the database does not exist.
+func (c *Conn) databaseName(idOrName string) string {
+	if db, ok := ResourceDatabase(c, idOrName); ok {
+		return db.Name
+	}
+	return idOrName
+}
+
+// tokenRequest represents the body of a request to create a token.
+type tokenRequest struct {
+	Scope     string   `json:"scope"`
+	Databases []string `json:"databases"`
+}
+
+// tokenResponse represents a created token along with its secret.
+type tokenResponse struct {
+	Secret string `json:"token"`
+	auth.Token
+}
+
+// tokensHandler lists the tokens, or creates one and returns 201 and
+// its secret, which cannot be recovered afterwards.
+func (c *Conn) tokensHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		tokens := c.Auth.List()
+		if tokens == nil {
+			tokens = []auth.Token{}
+		}
+
+		// Do not check error as the tokens do not
+		// contain types that could cause such case.
+		b, _ := json.Marshal(map[string][]auth.Token{"tokens": tokens})
+		c.logRequest(r, http.StatusOK, len(tokens))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+		return
+	}
+
+	var req tokenRequest
+	if r.Body == nil {
+		c.problem(w, r, http.StatusBadRequest, problemMissingBody,
+			"no token provided")
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.problem(w, r, http.StatusBadRequest, problemMalformedBody,
+			"error on decoding token:", err)
+		return
+	}
+
+	secret, token, err := auth.NewToken(req.Scope, req.Databases, time.Now().UTC())
+	if err != nil {
+		c.problem(w, r, http.StatusBadRequest, problemInvalidScope, err)
+		return
+	}
+	c.persist(tokenEntry(token), func() bool {
+		err = c.Auth.Store(token)
+		return err == nil
+	})
+	if err != nil {
+		c.problem(w, r, http.StatusConflict, problemTokenExists, err)
+		return
+	}
+
+	// Do not check error as the token does not
+	// contain types that could cause such case.
+	b, _ := json.Marshal(tokenResponse{Secret: secret, Token: token.Token})
+	c.logRequest(r, http.StatusCreated, token.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(b)
+}
+
+// tokenHandler returns a token given its ID, or revokes it and returns
+// 204.
+func (c *Conn) tokenHandler(tokenID string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		// we override the mux vars to be able to test
+		// an arbitrary token ID
+		if tokenID != "" {
+			vars = map[string]string{
+				"id": tokenID,
+			}
+		}
+
+		if r.Method == "DELETE" {
+			var ok bool
+			entry := aof.Entry{Op: aof.RevokeToken, Database: auth.AUTH, Stack: vars["id"]}
+			c.persist(entry, func() bool {
+				ok = c.Auth.Revoke(vars["id"])
+				return ok
+			})
+			if !ok {
+				c.goneHandler(w, r, problemTokenGone, fmt.Sprintf("token %s is Gone", vars["id"]))
+				return
+			}
+			c.logRequest(r, http.StatusNoContent)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		token, ok := c.Auth.Token(vars["id"])
+		if !ok {
+			c.goneHandler(w, r, problemTokenGone, fmt.Sprintf("token %s is Gone", vars["id"]))
+			return
+		}
+
+		// Do not check error as the token does not
+		// contain types that could cause such case.
+		b, _ := json.Marshal(token)
+		c.logRequest(r, http.StatusOK)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
+
+// tokenEntry returns the append-only file entry of a created token,
+// which is stored as a Stack named by its ID in the _auth database.
+func tokenEntry(token auth.StoredToken) aof.Entry {
+	return aof.Entry{
+		Op:       aof.CreateToken,
+		Database: auth.AUTH,
+		Stack:    token.ID,
+		Element:  token,
+		Time:     token.CreatedAt,
+	}
+}
+
+// storeToken adds a token persisted into a snapshot or the append-only
+// file, given the element that holds it.
+func (c *Conn) storeToken(element interface{}) error {
+	// elements are decoded as maps, so they are
+	// encoded again to decode the token
+	b, err := json.Marshal(element)
+	if err != nil {
+		return err
+	}
+	var token auth.StoredToken
+	if err := json.Unmarshal(b, &token); err != nil {
+		return err
+	}
+	return c.Auth.Store(token)
+}
+
+// tokensSnapshot returns the stored tokens, as they are saved
+// into a snapshot.
+func (c *Conn) tokensSnapshot() []interface{} {
+	stored := c.Auth.Stored()
+	tokens := make([]interface{}, 0, len(stored))
+	for _, token := range stored {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// restoreTokens adds the tokens saved into a snapshot by
+// tokensSnapshot.
+func (c *Conn) restoreTokens(tokens []interface{}) error {
+	for i, token := range tokens {
+		if err := c.storeToken(token); err != nil {
+			return fmt.Errorf("token %d: %v", i, err)
+		}
+	}
+	return nil
+}
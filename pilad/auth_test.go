@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/auth"
+	"github.com/fern4lvarez/piladb/config/vars"
+)
+
+// serveToken serves a request with a token through the Router of a
+// Conn, and returns the response.
+func serveToken(conn *Conn, method, url, token string, body []byte) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest(method, url, bytes.NewReader(body))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response := httptest.NewRecorder()
+	Router(conn).ServeHTTP(response, request)
+	return response
+}
+
+func TestAuthMiddleware_Disabled(t *testing.T) {
+	conn := NewConn()
+
+	if response := serveToken(conn, "PUT", "/databases?name=db", "", nil); response.Code != http.StatusCreated {
+		t.Errorf("response code is %d, expected %d", response.Code, http.StatusCreated)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	conn := NewConn()
+	conn.buildConfig()
+	_, _ = conn.Auth.Add("admin", auth.ScopeAdmin, nil, time.Now())
+	db := serveToken(conn, "PUT", "/databases?name=db", "admin", nil)
+	if db.Code != http.StatusCreated {
+		t.Fatalf("response code is %d, expected %d", db.Code, http.StatusCreated)
+	}
+	serveToken(conn, "PUT", "/databases?name=other", "admin", nil)
+	serveToken(conn, "PUT", "/databases/db/stacks?name=stack", "admin", nil)
+	serveToken(conn, "PUT", "/databases/other/stacks?name=stack", "admin", nil)
+
+	readOnly, _ := conn.Auth.Add("ro", auth.ScopeReadOnly, nil, time.Now())
+	_, _ = conn.Auth.Add("rw", auth.ScopeReadWrite, nil, time.Now())
+	_, _ = conn.Auth.Add("rwdb", auth.ScopeReadWrite, []string{"db"}, time.Now())
+	var dbStatus struct{ ID string }
+	_ = json.Unmarshal(db.Body.Bytes(), &dbStatus)
+
+	for _, tc := range []struct {
+		method, url, token string
+		body               string
+		expected           int
+	}{
+		{"GET", "/_ping", "", "", http.StatusOK},
+		{"GET", "/", "", "", http.StatusOK},
+		{"GET", "/_status", "", "", http.StatusUnauthorized},
+		{"GET", "/_status", "nope", "", http.StatusUnauthorized},
+		{"GET", "/_status", "ro", "", http.StatusOK},
+		{"GET", "/_status", "rwdb", "", http.StatusForbidden},
+		{"GET", "/_metrics", "ro", "", http.StatusOK},
+		{"GET", "/_metrics", "rwdb", "", http.StatusForbidden},
+		{"GET", "/_config", "ro", "", http.StatusOK},
+		{"POST", "/_config/MAX_STACK_SIZE", "rw", `{"element":10}`, http.StatusForbidden},
+		{"POST", "/_config/MAX_STACK_SIZE", "admin", `{"element":10}`, http.StatusOK},
+		{"POST", "/_snapshot", "rw", "", http.StatusForbidden},
+		{"GET", "/_tokens", "rw", "", http.StatusForbidden},
+		{"GET", "/_tokens", "admin", "", http.StatusOK},
+		{"GET", "/databases", "ro", "", http.StatusOK},
+		{"GET", "/databases", "rwdb", "", http.StatusForbidden},
+		{"PUT", "/databases?name=new", "ro", "", http.StatusForbidden},
+		{"PUT", "/databases?name=new", "rwdb", "", http.StatusForbidden},
+		{"PUT", "/databases?name=new", "rw", "", http.StatusCreated},
+		{"GET", "/databases/db", "rwdb", "", http.StatusOK},
+		{"GET", "/databases/" + dbStatus.ID, "rwdb", "", http.StatusOK},
+		{"GET", "/databases/other", "rwdb", "", http.StatusForbidden},
+		{"GET", "/databases/db/stacks/stack", "ro", "", http.StatusOK},
+		{"POST", "/databases/db/stacks/stack", "ro", `{"element":1}`, http.StatusForbidden},
+		{"POST", "/databases/db/stacks/stack", "rwdb", `{"element":1}`, http.StatusOK},
+		{"POST", "/databases/other/stacks/stack", "rwdb", `{"element":1}`, http.StatusForbidden},
+		{"POST", "/databases/" + dbStatus.ID + "/stacks/stack", "rwdb", `{"element":1}`, http.StatusOK},
+		{"POST", "/databases/db/stacks/stack/_move?to=stack&database=other", "rwdb", "", http.StatusForbidden},
+		{"POST", "/databases/db/stacks/stack/_move?to=stack&database=other", "rw", "", http.StatusOK},
+		{"POST", "/databases/db/_tx", "rwdb", `[{"op":"pop","stack":"stack"}]`, http.StatusOK},
+		{"DELETE", "/databases/other", "rwdb", "", http.StatusForbidden},
+		{"DELETE", "/databases/other", "rw", "", http.StatusNoContent},
+		{"GET", "/databases/gone", "rwdb", "", http.StatusForbidden},
+		{"GET", "/databases/gone", "ro", "", http.StatusGone},
+		{"GET", "/nope", "", "", http.StatusNotFound},
+	} {
+		response := serveToken(conn, tc.method, tc.url, tc.token, []byte(tc.body))
+		if response.Code != tc.expected {
+			t.Errorf("%s %s with %q: response code is %d, expected %d", tc.method, tc.url, tc.token, response.Code, tc.expected)
+		}
+		if response.Code == http.StatusUnauthorized && response.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s %s with %q: no WWW-Authenticate header", tc.method, tc.url, tc.token)
+		}
+	}
+
+	// revoked tokens are not authenticated
+	if response := serveToken(conn, "DELETE", "/_tokens/"+readOnly.ID, "admin", nil); response.Code != http.StatusNoContent {
+		t.Errorf("response code is %d, expected %d", response.Code, http.StatusNoContent)
+	}
+	if response := serveToken(conn, "GET", "/_status", "ro", nil); response.Code != http.StatusUnauthorized {
+		t.Errorf("response code is %d, expected %d", response.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthMiddleware_Scheme(t *testing.T) {
+	conn := NewConn()
+	_, _ = conn.Auth.Add("admin", auth.ScopeAdmin, nil, time.Now())
+
+	for _, tc := range []struct {
+		authorization string
+		expected      int
+	}{
+		{"Bearer admin", http.StatusOK},
+		{"bearer admin", http.StatusOK},
+		{"admin", http.StatusUnauthorized},
+		{"Basic admin", http.StatusUnauthorized},
+		{"Bearer ", http.StatusUnauthorized},
+	} {
+		request, _ := http.NewRequest("GET", "/databases", nil)
+		request.Header.Set("Authorization", tc.authorization)
+		response := httptest.NewRecorder()
+		Router(conn).ServeHTTP(response, request)
+
+		if response.Code != tc.expected {
+			t.Errorf("response code for %q is %d, expected %d", tc.authorization, response.Code, tc.expected)
+		}
+	}
+}
+
+func TestTokensHandler(t *testing.T) {
+	conn := NewConn()
+	_, _ = conn.Auth.Add("admin", auth.ScopeAdmin, nil, time.Now())
+
+	response := serveToken(conn, "POST", "/_tokens", "admin", []byte(`{"scope":"read-write","databases":["db"]}`))
+	if response.Code != http.StatusCreated {
+		t.Fatalf("response code is %d, expected %d", response.Code, http.StatusCreated)
+	}
+	var created struct {
+		Token     string   `json:"token"`
+		ID        string   `json:"id"`
+		Scope     string   `json:"scope"`
+		Databases []string `json:"databases"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Token == "" || created.ID == "" || created.Scope != auth.ScopeReadWrite || created.Databases[0] != "db" {
+		t.Errorf("token is %s, unexpected", response.Body.String())
+	}
+
+	// the created token is granted its scope
+	if response := serveToken(conn, "PUT", "/databases?name=db", created.Token, nil); response.Code != http.StatusCreated {
+		t.Errorf("response code is %d, expected %d", response.Code, http.StatusCreated)
+	}
+
+	response = serveToken(conn, "GET", "/_tokens/"+created.ID, "admin", nil)
+	if response.Code != http.StatusOK || bytes.Contains(response.Body.Bytes(), []byte(created.Token)) {
+		t.Errorf("response is %d %s, expected token without secret", response.Code, response.Body.String())
+	}
+
+	response = serveToken(conn, "GET", "/_tokens", "admin", nil)
+	var list struct {
+		Tokens []auth.Token `json:"tokens"`
+	}
+	_ = json.Unmarshal(response.Body.Bytes(), &list)
+	if response.Code != http.StatusOK || len(list.Tokens) != 2 {
+		t.Errorf("response is %d %s, expected 2 tokens", response.Code, response.Body.String())
+	}
+
+	for _, tc := range []struct {
+		method, url, body string
+		expected          int
+	}{
+		{"POST", "/_tokens", `{"scope":"root"}`, http.StatusBadRequest},
+		{"POST", "/_tokens", `{"scope":"admin","databases":["db"]}`, http.StatusBadRequest},
+		{"POST", "/_tokens", `{`, http.StatusBadRequest},
+		{"GET", "/_tokens/nope", "", http.StatusGone},
+		{"DELETE", "/_tokens/nope", "", http.StatusGone},
+		{"DELETE", "/_tokens/" + created.ID, "", http.StatusNoContent},
+	} {
+		if response := serveToken(conn, tc.method, tc.url, "admin", []byte(tc.body)); response.Code != tc.expected {
+			t.Errorf("%s %s: response code is %d, expected %d", tc.method, tc.url, response.Code, tc.expected)
+		}
+	}
+}
+
+func TestTokensHandler_NoBody(t *testing.T) {
+	conn := NewConn()
+	request, _ := http.NewRequest("POST", "/_tokens", nil)
+	response := httptest.NewRecorder()
+	conn.tokensHandler(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("response code is %d, expected %d", response.Code, http.StatusBadRequest)
+	}
+}
+
+func TestConnBuildAuth(t *testing.T) {
+	defer func(token string) { adminTokenFlag = token }(adminTokenFlag)
+	defer os.Unsetenv(vars.Env(auth.AdminToken))
+
+	conn := NewConn()
+	if err := conn.buildAuth(); err != nil {
+		t.Fatal(err)
+	}
+	if conn.Auth.Enabled() {
+		t.Error("auth is enabled, expected not")
+	}
+
+	adminTokenFlag = "flag"
+	if err := conn.buildAuth(); err != nil {
+		t.Fatal(err)
+	}
+	if token, ok := conn.Auth.Authenticate("flag"); !ok || token.Scope != auth.ScopeAdmin {
+		t.Errorf("token is %v, %v, expected admin", token, ok)
+	}
+
+	// the environment variable takes precedence
+	conn = NewConn()
+	os.Setenv(vars.Env(auth.AdminToken), "env")
+	if err := conn.buildAuth(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := conn.Auth.Authenticate("env"); !ok {
+		t.Error("env token is not authenticated, expected authenticated")
+	}
+	if _, ok := conn.Auth.Authenticate("flag"); ok {
+		t.Error("flag token is authenticated, expected not")
+	}
+}
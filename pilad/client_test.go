@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fern4lvarez/piladb/config/vars"
+	"github.com/fern4lvarez/piladb/pkg/client"
+)
+
+func TestClient(t *testing.T) {
+	conn := NewConn()
+	server := httptest.NewServer(Router(conn))
+	defer server.Close()
+
+	ctx := context.Background()
+	c := client.New(server.URL)
+
+	if err := c.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := c.Status(ctx); err != nil || status.Code != "OK" {
+		t.Errorf("status is %v, %v, expected OK", status, err)
+	}
+
+	dbStatus, err := c.CreateDatabase(ctx, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbStatus.Name != "db" {
+		t.Errorf("database is %s, expected %s", dbStatus.Name, "db")
+	}
+	if _, err := c.CreateDatabase(ctx, "db"); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("err is %v, expected %v", err, client.ErrConflict)
+	}
+	if status, err := c.Databases(ctx); err != nil || status.NumberDatabases != 1 {
+		t.Errorf("databases are %v, %v, expected 1", status, err)
+	}
+
+	db := c.Database(dbStatus.ID)
+	stackStatus, err := db.CreateStack(ctx, "stack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateStack(ctx, "stack"); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("err is %v, expected %v", err, client.ErrConflict)
+	}
+	if status, err := db.Stacks(ctx); err != nil || len(status.Stacks) != 1 {
+		t.Errorf("stacks are %v, %v, expected 1", status, err)
+	}
+
+	stack := db.Stack(stackStatus.Name)
+	if element, err := stack.Push(ctx, "foo"); err != nil || element != "foo" {
+		t.Errorf("element is %v, %v, expected %v", element, err, "foo")
+	}
+	if element, err := stack.Push(ctx, map[string]interface{}{"bar": 1.0}); err != nil || element == nil {
+		t.Errorf("element is %v, %v, expected an object", element, err)
+	}
+	if size, err := stack.Size(ctx); err != nil || size != 2 {
+		t.Errorf("size is %d, %v, expected %d", size, err, 2)
+	}
+	if element, err := stack.Pop(ctx); err != nil || element.(map[string]interface{})["bar"] != 1.0 {
+		t.Errorf("element is %v, %v, expected the object", element, err)
+	}
+	if element, err := stack.Peek(ctx); err != nil || element != "foo" {
+		t.Errorf("element is %v, %v, expected %v", element, err, "foo")
+	}
+	if status, err := stack.Status(ctx); err != nil || status.Size != 1 || status.Peek != "foo" {
+		t.Errorf("stack is %v, %v, expected size 1", status, err)
+	}
+	if status, err := stack.Flush(ctx); err != nil || status.Size != 0 {
+		t.Errorf("stack is %v, %v, expected size 0", status, err)
+	}
+	if _, err := stack.Pop(ctx); err != client.ErrEmptyStack {
+		t.Errorf("err is %v, expected %v", err, client.ErrEmptyStack)
+	}
+
+	if err := stack.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stack.Push(ctx, "foo"); !errors.Is(err, client.ErrGone) {
+		t.Errorf("err is %v, expected %v", err, client.ErrGone)
+	}
+
+	if err := db.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Status(ctx); !errors.Is(err, client.ErrGone) {
+		t.Errorf("err is %v, expected %v", err, client.ErrGone)
+	}
+}
+
+func TestClientConfig(t *testing.T) {
+	conn := NewConn()
+	conn.buildConfig()
+	server := httptest.NewServer(Router(conn))
+	defer server.Close()
+
+	ctx := context.Background()
+	c := client.New(server.URL)
+
+	if value, err := c.SetConfigValue(ctx, vars.MaxStackSize, 1); err != nil || value != 1.0 {
+		t.Errorf("value is %v, %v, expected %v", value, err, 1)
+	}
+	if value, err := c.ConfigValue(ctx, vars.MaxStackSize); err != nil || value != 1.0 {
+		t.Errorf("value is %v, %v, expected %v", value, err, 1)
+	}
+	if config, err := c.Config(ctx); err != nil || config[vars.MaxStackSize] != 1.0 {
+		t.Errorf("config is %v, %v, expected %s set", config, err, vars.MaxStackSize)
+	}
+	if _, err := c.ConfigValue(ctx, "NOT_A_KEY"); !errors.Is(err, client.ErrGone) {
+		t.Errorf("err is %v, expected %v", err, client.ErrGone)
+	}
+
+	if _, err := c.CreateDatabase(ctx, "db"); err != nil {
+		t.Fatal(err)
+	}
+	stack := c.Database("db").Stack("stack")
+	if _, err := c.Database("db").CreateStack(ctx, "stack"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stack.Push(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stack.Push(ctx, "bar"); !errors.Is(err, client.ErrNotAcceptable) {
+		t.Errorf("err is %v, expected %v", err, client.ErrNotAcceptable)
+	}
+	if _, err := c.CreateDatabase(ctx, ""); !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("err is %v, expected %v", err, client.ErrBadRequest)
+	}
+}
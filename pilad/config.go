@@ -1,13 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	"github.com/fern4lvarez/piladb/config/vars"
 	"github.com/fern4lvarez/piladb/pila"
 	"github.com/gorilla/mux"
@@ -20,14 +22,50 @@ var (
 	maxStackSizeFlag                  int
 	readTimeoutFlag, writeTimeoutFlag int
 	portFlag                          int
+	snapshotPathFlag                  string
+	snapshotIntervalFlag              int
+	aofPathFlag, aofFsyncFlag         string
+	aofRewriteMinSizeFlag             int
+	diskPathFlag                      string
+	stackEngineFlag                   string
+	respPortFlag                      int
+	grpcPortFlag                      int
+	tlsCertFlag, tlsKeyFlag           string
+	tlsCAFlag                         string
+	metricsMaxStacksFlag              int
+	logLevelFlag, logFormatFlag       string
+	adminTokenFlag                    string
+	configFlag                        string
 	versionFlag                       bool
 )
 
+// setFlags holds the names of the cli flags that were
+// set explicitly, which take precedence over any other
+// source of config values.
+var setFlags = map[string]bool{}
+
 func init() {
 	flag.IntVar(&maxStackSizeFlag, "max-stack-size", vars.MaxStackSizeDefault, "Max size of Stacks")
 	flag.IntVar(&readTimeoutFlag, "read-timeout", vars.ReadTimeoutDefault, "Read request timeout")
 	flag.IntVar(&writeTimeoutFlag, "write-timeout", vars.WriteTimeoutDefault, "Write response timeout")
 	flag.IntVar(&portFlag, "port", vars.PortDefault, "Port number")
+	flag.StringVar(&snapshotPathFlag, "snapshot-path", vars.SnapshotPathDefault, "Path of the snapshot file")
+	flag.IntVar(&snapshotIntervalFlag, "snapshot-interval", vars.SnapshotIntervalDefault, "Seconds between periodic snapshots")
+	flag.StringVar(&aofPathFlag, "aof-path", vars.AOFPathDefault, "Path of the append-only file")
+	flag.StringVar(&aofFsyncFlag, "aof-fsync", vars.AOFFsyncDefault, "Fsync policy of the append-only file: always, everysec or no")
+	flag.IntVar(&aofRewriteMinSizeFlag, "aof-rewrite-min-size", vars.AOFRewriteMinSizeDefault, "Minimum size in bytes to rewrite the append-only file")
+	flag.StringVar(&diskPathFlag, "disk-path", vars.DiskPathDefault, "Path of the directory of disk stacks")
+	flag.StringVar(&stackEngineFlag, "stack-engine", vars.StackEngineDefault, "Default engine of Stacks: memory, slice, disk or ring")
+	flag.IntVar(&respPortFlag, "resp-port", vars.RESPPortDefault, "Port number of the RESP listener, disabled if 0")
+	flag.IntVar(&grpcPortFlag, "grpc-port", vars.GRPCPortDefault, "Port number of the gRPC listener, disabled if 0")
+	flag.StringVar(&tlsCertFlag, "tls-cert", vars.TLSCertDefault, "Path of the TLS certificate, served over HTTPS if set")
+	flag.StringVar(&tlsKeyFlag, "tls-key", vars.TLSKeyDefault, "Path of the private key of the TLS certificate")
+	flag.StringVar(&tlsCAFlag, "tls-ca", vars.TLSCADefault, "Path of the CA certificates of clients, required if set")
+	flag.IntVar(&metricsMaxStacksFlag, "metrics-max-stacks", vars.MetricsMaxStacksDefault, "Max number of Stacks whose sizes are reported as metrics")
+	flag.StringVar(&logLevelFlag, "log-level", vars.LogLevelDefault, "Minimum level of logs: debug, info, warn or error")
+	flag.StringVar(&logFormatFlag, "log-format", vars.LogFormatDefault, "Format of logs: text or json")
+	flag.StringVar(&adminTokenFlag, "admin-token", "", "Token with admin scope, which enables authentication")
+	flag.StringVar(&configFlag, "config", "", "Path of the TOML config file, reloaded on SIGHUP")
 	flag.BoolVar(&versionFlag, "v", false, "Version")
 }
 
@@ -36,97 +74,310 @@ type flagKey struct {
 	key  string
 }
 
-// buildConfig sets non-default config values to the Connection
-// reading from environment variables and cli flags.
-func (c *Conn) buildConfig() {
-	flagKeys := []flagKey{
+// flagKeys returns the cli flags of every config value,
+// along with their keys.
+func flagKeys() []flagKey {
+	return []flagKey{
 		{maxStackSizeFlag, vars.MaxStackSize},
 		{readTimeoutFlag, vars.ReadTimeout},
 		{writeTimeoutFlag, vars.WriteTimeout},
 		{portFlag, vars.Port},
+		{snapshotPathFlag, vars.SnapshotPath},
+		{snapshotIntervalFlag, vars.SnapshotInterval},
+		{aofPathFlag, vars.AOFPath},
+		{aofFsyncFlag, vars.AOFFsync},
+		{aofRewriteMinSizeFlag, vars.AOFRewriteMinSize},
+		{diskPathFlag, vars.DiskPath},
+		{stackEngineFlag, vars.StackEngine},
+		{respPortFlag, vars.RESPPort},
+		{grpcPortFlag, vars.GRPCPort},
+		{tlsCertFlag, vars.TLSCert},
+		{tlsKeyFlag, vars.TLSKey},
+		{tlsCAFlag, vars.TLSCA},
+		{metricsMaxStacksFlag, vars.MetricsMaxStacks},
+		{logLevelFlag, vars.LogLevel},
+		{logFormatFlag, vars.LogFormat},
 	}
+}
 
-	for _, fk := range flagKeys {
-		if e := os.Getenv(vars.Env(fk.key)); e != "" {
-			if i, err := strconv.Atoi(e); err != nil {
-				c.Config.Set(fk.key, vars.DefaultInt(fk.key))
-			} else {
-				c.Config.Set(fk.key, i)
+// flagName returns the name of the cli flag of a config key,
+// e.g. max-stack-size for MAX_STACK_SIZE.
+func flagName(key string) string {
+	return strings.ToLower(strings.Replace(key, "_", "-", -1))
+}
 
-			}
+// visitFlags records the cli flags that were set explicitly.
+func visitFlags() {
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+}
+
+// buildConfig sets non-default config values to the Connection
+// reading from cli flags, environment variables and the config
+// file, in that order of precedence.
+func (c *Conn) buildConfig() error {
+	c.configPath = configFlag
+	c.configFile = map[string]interface{}{}
+	if c.configPath != "" {
+		file, err := readConfigFile(c.configPath)
+		if err != nil {
+			return err
+		}
+		c.configFile = file
+	}
+
+	for _, fk := range flagKeys() {
+		value, ok, err := c.explicitValue(fk)
+		if err != nil {
+			return err
+		}
+		if ok {
+			c.Config.Set(fk.key, value)
 			continue
 		}
 		c.Config.Set(fk.key, fk.flag)
 	}
+	return vars.Check(c.Config.Get)
+}
+
+// explicitValue returns the value of a config key set explicitly,
+// by its cli flag, its environment variable or the config file,
+// in that order of precedence, if any.
+func (c *Conn) explicitValue(fk flagKey) (interface{}, bool, error) {
+	value, ok, err := overriddenValue(fk)
+	if ok || err != nil {
+		return value, ok, err
+	}
+	value, ok = c.configFile[fk.key]
+	return value, ok, nil
+}
+
+// overriddenValue returns the value of a config key given by its cli
+// flag, if set explicitly, or by its environment variable, if any. It
+// returns an error if the value is not accepted by the config value.
+func overriddenValue(fk flagKey) (interface{}, bool, error) {
+	if setFlags[flagName(fk.key)] {
+		value, err := vars.Validate(fk.key, fk.flag)
+		if err != nil {
+			return nil, false, fmt.Errorf("%v in -%s", err, flagName(fk.key))
+		}
+		return value, true, nil
+	}
+
+	e := os.Getenv(vars.Env(fk.key))
+	if e == "" {
+		return nil, false, nil
+	}
+	var value interface{} = e
+	if _, ok := fk.flag.(string); !ok {
+		// strings that are not integers are
+		// rejected by int config values
+		if i, err := strconv.Atoi(e); err == nil {
+			value = i
+		}
+	}
+	value, err := vars.Validate(fk.key, value)
+	if err != nil {
+		return nil, false, fmt.Errorf("%v in %s", err, vars.Env(fk.key))
+	}
+	return value, true, nil
+}
+
+// readConfigFile reads the config values of a TOML file, whose keys
+// are the names of the config values, e.g.:
+//
+//	MAX_STACK_SIZE = 100
+//	AOF_FSYNC = "always"
+//
+// It returns an error if a key is unknown, or if its value is
+// not accepted by the config value.
+func readConfigFile(path string) (map[string]interface{}, error) {
+	var file map[string]interface{}
+	if _, err := toml.DecodeFile(path, &file); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(file))
+	for key, value := range file {
+		v, err := vars.Validate(key, value)
+		if err != nil {
+			return nil, fmt.Errorf("%v in %s", err, path)
+		}
+		values[key] = v
+	}
+	return values, nil
+}
+
+// reloadConfig reads the config file again, and sets the values that
+// changed since it was last read, unless they are set by cli flags or
+// environment variables, or are only read on start-up. Values removed
+// from the file are set back to their defaults, and values not changed
+// in the file keep the ones set at runtime, if any.
+func (c *Conn) reloadConfig() error {
+	file, err := readConfigFile(c.configPath)
+	if err != nil {
+		return err
+	}
+
+	changes := map[string]interface{}{}
+	for _, fk := range flagKeys() {
+		previous, wasSet := c.configFile[fk.key]
+		value, ok := file[fk.key]
+		if ok == wasSet && value == previous {
+			continue
+		}
+		// overridden values were validated on start-up
+		if _, overridden, _ := overriddenValue(fk); overridden {
+			continue
+		}
+		if v, _ := vars.Lookup(fk.key); !v.Mutable {
+			c.Logger.Warn("config value changed, restart required", "key", fk.key)
+			continue
+		}
+		if !ok {
+			value = fk.flag
+		}
+		changes[fk.key] = value
+	}
+
+	// no value is changed unless all of them are accepted together
+	err = vars.Check(func(name string) interface{} {
+		if value, ok := changes[name]; ok {
+			return value
+		}
+		return c.Config.Get(name)
+	})
+	if err != nil {
+		return fmt.Errorf("%v in %s", err, c.configPath)
+	}
+
+	for _, fk := range flagKeys() {
+		value, ok := changes[fk.key]
+		if !ok {
+			continue
+		}
+		current := c.Config.Get(fk.key)
+		c.Config.Set(fk.key, value)
+		c.Logger.Info("config value changed", "key", fk.key, "from", current, "to", value)
+	}
+	c.configFile = file
+	c.applyLogConfig()
+	return nil
+}
+
+// reloadConfigLoop reloads the config file every time
+// a signal is received.
+func (c *Conn) reloadConfigLoop(signals <-chan os.Signal) {
+	for range signals {
+		if err := c.reloadConfig(); err != nil {
+			c.Logger.Error("error on reloading config file", "path", c.configPath, "error", err)
+			continue
+		}
+		c.Logger.Info("config file reloaded", "path", c.configPath)
+	}
 }
 
 // stackHandlerFunc represents a Handler of a Stack.
 type stackHandlerFunc func(w http.ResponseWriter, r *http.Request, stack *pila.Stack)
 
+// configStatus represents the config values, along with
+// the schema of every config value.
+type configStatus struct {
+	pila.StacksKV
+	Schema map[string]vars.Var `json:"schema"`
+}
+
 // configHandler handles a request to the Conn configuration.
 func (c *Conn) configHandler(w http.ResponseWriter, r *http.Request) {
-	res, err := c.Config.Values.StacksKV().ToJSON()
+	status := configStatus{
+		StacksKV: c.Config.Values.StacksKV(),
+		Schema:   vars.Schema(),
+	}
+
+	res, err := json.Marshal(status)
 	if err != nil {
-		log.Println(r.Method, r.URL, http.StatusBadRequest,
+		c.problem(w, r, http.StatusBadRequest, problemSerialization,
 			"error on response serialization:", err)
-		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(res)
-	log.Println(r.Method, r.URL, http.StatusOK)
+	c.logRequest(r, http.StatusOK)
 }
 
-// configKeyHandler handles a config value.
+// configKeyHandler handles a config value. New values are only set
+// if they are accepted by the config value, and it can be changed at
+// runtime.
 func (c *Conn) configKeyHandler(configKey string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
+		params := mux.Vars(r)
 
 		// we override the mux vars to be able to test
 		// an arbitrary configKey
 		if configKey != "" {
-			vars = map[string]string{
+			params = map[string]string{
 				"key": configKey,
 			}
 		}
-		value := c.Config.Get(vars["key"])
+		key := params["key"]
+		value := c.Config.Get(key)
 		if value == nil {
-			c.goneHandler(w, r, fmt.Sprintf("%s is not set", vars["key"]))
+			c.goneHandler(w, r, problemConfigGone, fmt.Sprintf("%s is not set", key))
 			return
 		}
 
 		var element pila.Element
 		if r.Method == "GET" {
-			value := c.Config.Get(vars["key"])
 			element.Value = value
 		}
 		if r.Method == "POST" {
 			if r.Body == nil {
-				log.Println(r.Method, r.URL, http.StatusBadRequest,
+				c.problem(w, r, http.StatusBadRequest, problemMissingBody,
 					"no element provided")
-				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
 			err := element.Decode(r.Body)
 			if err != nil {
-				log.Println(r.Method, r.URL, http.StatusBadRequest,
+				c.problem(w, r, http.StatusBadRequest, problemMalformedBody,
 					"error on decoding element:", err)
-				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
 
-			c.Config.Set(vars["key"], element.Value)
+			v, ok := vars.Lookup(key)
+			if ok && !v.Mutable {
+				c.problem(w, r, http.StatusBadRequest, problemImmutableConfig,
+					key, "is only read on start-up")
+				return
+			}
+			element.Value, err = vars.Validate(key, element.Value)
+			if err != nil {
+				c.problem(w, r, http.StatusBadRequest, problemInvalidConfig, err)
+				return
+			}
+			err = vars.Check(func(name string) interface{} {
+				if name == key {
+					return element.Value
+				}
+				return c.Config.Get(name)
+			})
+			if err != nil {
+				c.problem(w, r, http.StatusBadRequest, problemInvalidConfig, err)
+				return
+			}
+
+			c.Config.Set(key, element.Value)
+			c.applyLogConfig()
 		}
 
-		log.Println(r.Method, r.URL, http.StatusOK, element.Value)
+		c.logRequest(r, http.StatusOK, element.Value)
 		w.Header().Set("Content-Type", "application/json")
 
 		b, err := element.ToJSON()
 		if err != nil {
-			log.Println(r.Method, r.URL, http.StatusBadRequest,
+			c.problem(w, r, http.StatusBadRequest, problemMalformedBody,
 				"error on decoding element:", err)
-			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 		w.Write(b)
@@ -134,12 +385,17 @@ func (c *Conn) configKeyHandler(configKey string) http.Handler {
 }
 
 // checkMaxStackSize checks config value for MaxStackSize and execute the
-// wrapped handler if check is validated.
+// wrapped handler if check is validated. Stacks with the evict overflow
+// policy are not checked, as they discard their bottom element instead.
 func (c *Conn) checkMaxStackSize(handler stackHandlerFunc) stackHandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request, stack *pila.Stack) {
+		if stack.Overflow == pila.OverflowEvict {
+			handler(w, r, stack)
+			return
+		}
+
 		if s := c.Config.MaxStackSize(); stack.Size() >= s && s != -1 {
-			log.Println(r.Method, r.URL, http.StatusNotAcceptable, vars.MaxStackSize, "value reached")
-			w.WriteHeader(http.StatusNotAcceptable)
+			c.problem(w, r, http.StatusNotAcceptable, problemMaxStackSize, vars.MaxStackSize, "value reached")
 			return
 		}
 
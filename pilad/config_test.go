@@ -2,12 +2,17 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -39,13 +44,55 @@ func TestBuildConfig(t *testing.T) {
 		t.Errorf("MaxStackSize is %v, expected %d", s, 42)
 	}
 
-	if err := os.Setenv(vars.Env(vars.MaxStackSize), "foo"); err != nil {
+	if err := os.Unsetenv(vars.Env(vars.MaxStackSize)); err != nil {
 		t.Fatal(err)
 	}
-	conn.buildConfig()
+}
+
+func TestBuildConfig_Invalid(t *testing.T) {
+	for _, io := range []struct {
+		key, value, err string
+	}{
+		{vars.MaxStackSize, "foo", "MAX_STACK_SIZE must be an integer in PILADB_MAX_STACK_SIZE"},
+		{vars.MaxStackSize, "-2", "MAX_STACK_SIZE must be at least -1 in PILADB_MAX_STACK_SIZE"},
+		{vars.AOFFsync, "sometimes", "AOF_FSYNC must be one of always, everysec, no in PILADB_AOF_FSYNC"},
+	} {
+		t.Run(io.key+"="+io.value, func(t *testing.T) {
+			t.Setenv(vars.Env(io.key), io.value)
+
+			err := NewConn().buildConfig()
+			if err == nil || err.Error() != io.err {
+				t.Errorf("err is %v, expected %s", err, io.err)
+			}
+		})
+	}
+}
+
+func TestBuildConfig_RingEngine(t *testing.T) {
+	t.Setenv(vars.Env(vars.StackEngine), RingEngine)
+	t.Setenv(vars.Env(vars.MaxStackSize), "-1")
 
-	if s := conn.Config.Get(vars.MaxStackSize); s != -1 {
-		t.Errorf("MaxStackSize is %v, expected %v", s, -1)
+	expected := "STACK_ENGINE ring requires a positive MAX_STACK_SIZE"
+	if err := NewConn().buildConfig(); err == nil || err.Error() != expected {
+		t.Errorf("err is %v, expected %s", err, expected)
+	}
+
+	t.Setenv(vars.Env(vars.MaxStackSize), "10")
+	if err := NewConn().buildConfig(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBuildConfig_InvalidFlag(t *testing.T) {
+	maxStackSizeFlag = -2
+	setFlags[flagName(vars.MaxStackSize)] = true
+	defer func() {
+		maxStackSizeFlag = vars.MaxStackSizeDefault
+		delete(setFlags, flagName(vars.MaxStackSize))
+	}()
+
+	if err := NewConn().buildConfig(); err == nil {
+		t.Error("err is nil, expected error")
 	}
 }
 
@@ -59,7 +106,7 @@ func TestConfigHandler_GET(t *testing.T) {
 	inputOutput := []struct {
 		input, output string
 	}{
-		{"/_config", `{"stacks":{"PORT":"8080","SIZE":2}}`},
+		{"/_config", `{"stacks":{"PORT":"8080","SIZE":2},"schema":{`},
 	}
 
 	for _, io := range inputOutput {
@@ -84,8 +131,21 @@ func TestConfigHandler_GET(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		if string(config) != io.output {
-			t.Errorf("config is %s, expected %s", string(config), io.output)
+		if !strings.HasPrefix(string(config), io.output) {
+			t.Errorf("config is %s, expected to start with %s", string(config), io.output)
+		}
+
+		var status struct {
+			Schema map[string]vars.Var `json:"schema"`
+		}
+		if err := json.Unmarshal(config, &status); err != nil {
+			t.Fatal(err)
+		}
+		if v := status.Schema[vars.MaxStackSize]; v.Type != vars.TypeInt || !v.Mutable || *v.Min != -1 {
+			t.Errorf("schema of %s is %+v, unexpected", vars.MaxStackSize, v)
+		}
+		if len(status.Schema) != len(vars.Schema()) {
+			t.Errorf("schema has %d values, expected %d", len(status.Schema), len(vars.Schema()))
 		}
 	}
 }
@@ -242,6 +302,65 @@ func TestConfigKeyHandler_BadRequest(t *testing.T) {
 	}
 }
 
+func TestConfigKeyHandler_Invalid(t *testing.T) {
+	conn := NewConn()
+	conn.buildConfig()
+	conn.Config.Set("SIZE", 2)
+
+	inputOutput := []struct {
+		key, payload, code string
+	}{
+		{vars.MaxStackSize, `{"element":-5}`, problemInvalidConfig},
+		{vars.MaxStackSize, `{"element":10.5}`, problemInvalidConfig},
+		{vars.MaxStackSize, `{"element":"10"}`, problemInvalidConfig},
+		{vars.LogLevel, `{"element":"trace"}`, problemInvalidConfig},
+		{vars.StackEngine, `{"element":"ring"}`, problemInvalidConfig},
+		{vars.Port, `{"element":8080}`, problemImmutableConfig},
+		{"SIZE", `{"element":3}`, problemInvalidConfig},
+	}
+
+	for _, io := range inputOutput {
+		before := conn.Config.Get(io.key)
+		request, err := http.NewRequest("POST", "/_config/"+io.key, bytes.NewBufferString(io.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := httptest.NewRecorder()
+
+		conn.configKeyHandler(io.key).ServeHTTP(response, request)
+
+		var problem Problem
+		_ = json.Unmarshal(response.Body.Bytes(), &problem)
+		if response.Code != http.StatusBadRequest || problem.Code != io.code {
+			t.Errorf("response for %s %s is %d %s, expected %d %s", io.key, io.payload,
+				response.Code, problem.Code, http.StatusBadRequest, io.code)
+		}
+		if value := conn.Config.Get(io.key); value != before {
+			t.Errorf("%s is %v, expected %v", io.key, value, before)
+		}
+	}
+}
+
+func TestConfigKeyHandler_Normalized(t *testing.T) {
+	conn := NewConn()
+	conn.buildConfig()
+
+	request, err := http.NewRequest("POST", "/_config/"+vars.MaxStackSize, bytes.NewBufferString(`{"element":10.0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := httptest.NewRecorder()
+
+	conn.configKeyHandler(vars.MaxStackSize).ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("response code is %d, expected %d", response.Code, http.StatusOK)
+	}
+	if value := conn.Config.Get(vars.MaxStackSize); value != 10 {
+		t.Errorf("%s is %#v, expected %#v", vars.MaxStackSize, value, 10)
+	}
+}
+
 func TestCheckMaxStackSize(t *testing.T) {
 	s := pila.NewStack("stack", time.Now())
 	s.Push("foo")
@@ -282,3 +401,188 @@ func TestCheckMaxStackSize(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildConfig_String(t *testing.T) {
+	conn := NewConn()
+
+	if err := os.Unsetenv(vars.Env(vars.SnapshotPath)); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshotPathFlag = "/tmp/flag.snapshot"
+	defer func() { snapshotPathFlag = vars.SnapshotPathDefault }()
+	conn.buildConfig()
+
+	if s := conn.Config.Get(vars.SnapshotPath); s != "/tmp/flag.snapshot" {
+		t.Errorf("SnapshotPath is %v, expected %s", s, "/tmp/flag.snapshot")
+	}
+
+	if err := os.Setenv(vars.Env(vars.SnapshotPath), "/tmp/env.snapshot"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(vars.Env(vars.SnapshotPath))
+	conn.buildConfig()
+
+	if s := conn.Config.Get(vars.SnapshotPath); s != "/tmp/env.snapshot" {
+		t.Errorf("SnapshotPath is %v, expected %s", s, "/tmp/env.snapshot")
+	}
+}
+
+// writeConfigFile writes a config file into a temporary directory,
+// and returns its path.
+func writeConfigFile(t *testing.T, path, content string) string {
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "pilad.toml")
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuildConfig_File(t *testing.T) {
+	configFlag = writeConfigFile(t, "", `
+SNAPSHOT_INTERVAL = 10
+AOF_FSYNC = "always"
+METRICS_MAX_STACKS = 5
+`)
+	defer func() { configFlag = "" }()
+
+	t.Setenv(vars.Env(vars.AOFFsync), "no")
+	t.Setenv(vars.Env(vars.MetricsMaxStacks), "9")
+	metricsMaxStacksFlag = 7
+	setFlags[flagName(vars.MetricsMaxStacks)] = true
+	defer func() {
+		metricsMaxStacksFlag = vars.MetricsMaxStacksDefault
+		delete(setFlags, flagName(vars.MetricsMaxStacks))
+	}()
+
+	conn := NewConn()
+	if err := conn.buildConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	inputOutput := []struct {
+		key      string
+		expected interface{}
+	}{
+		{vars.SnapshotInterval, 10},
+		{vars.AOFFsync, "no"},
+		{vars.MetricsMaxStacks, 7},
+		{vars.StackEngine, vars.StackEngineDefault},
+	}
+
+	for _, io := range inputOutput {
+		if value := conn.Config.Get(io.key); value != io.expected {
+			t.Errorf("%s is %v, expected %v", io.key, value, io.expected)
+		}
+	}
+}
+
+func TestBuildConfig_FileError(t *testing.T) {
+	configFlag = filepath.Join(t.TempDir(), "nope.toml")
+	defer func() { configFlag = "" }()
+
+	if err := NewConn().buildConfig(); err == nil {
+		t.Error("err is nil, expected error")
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	values, err := readConfigFile(writeConfigFile(t, "", `
+PORT = 8080
+STACK_ENGINE = "slice"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values[vars.Port] != 8080 || values[vars.StackEngine] != "slice" {
+		t.Errorf("values are %v, expected PORT and STACK_ENGINE", values)
+	}
+
+	for _, content := range []string{
+		`FOO = 1`,
+		`PORT = "8080"`,
+		`STACK_ENGINE = 1`,
+		`PORT = 1.5`,
+		`PORT = 80`,
+		`LOG_LEVEL = "trace"`,
+		`PORT =`,
+	} {
+		if _, err := readConfigFile(writeConfigFile(t, "", content)); err == nil {
+			t.Errorf("err for %q is nil, expected error", content)
+		}
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	configFlag = writeConfigFile(t, "", `
+SNAPSHOT_INTERVAL = 10
+LOG_LEVEL = "info"
+`)
+	defer func() { configFlag = "" }()
+
+	conn, lb := loggedConn()
+	if err := conn.buildConfig(); err != nil {
+		t.Fatal(err)
+	}
+	conn.Config.Set(vars.SnapshotPath, "/tmp/runtime.snapshot")
+
+	writeConfigFile(t, configFlag, `
+LOG_LEVEL = "debug"
+AOF_REWRITE_MIN_SIZE = 1024
+PORT = 8080
+`)
+	if err := conn.reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	inputOutput := []struct {
+		key      string
+		expected interface{}
+	}{
+		{vars.SnapshotInterval, vars.SnapshotIntervalDefault},
+		{vars.LogLevel, "debug"},
+		{vars.AOFRewriteMinSize, 1024},
+		{vars.SnapshotPath, "/tmp/runtime.snapshot"},
+		{vars.Port, vars.PortDefault},
+	}
+
+	for _, io := range inputOutput {
+		if value := conn.Config.Get(io.key); value != io.expected {
+			t.Errorf("%s is %v, expected %v", io.key, value, io.expected)
+		}
+	}
+	if !conn.Logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("debug level is not enabled, expected it to be")
+	}
+	if !strings.Contains(lb.String(), `msg="config value changed" key=LOG_LEVEL from=info to=debug`) {
+		t.Errorf("log is %q, expected config changes", lb.String())
+	}
+	if !strings.Contains(lb.String(), `msg="config value changed, restart required" key=PORT`) {
+		t.Errorf("log is %q, expected restart required", lb.String())
+	}
+
+	writeConfigFile(t, configFlag, `LOG_LEVEL = 1`)
+	if err := conn.reloadConfig(); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if level := conn.Config.Get(vars.LogLevel); level != "debug" {
+		t.Errorf("LOG_LEVEL is %v, expected %v", level, "debug")
+	}
+
+	// values not accepted together are not changed
+	writeConfigFile(t, configFlag, `
+LOG_LEVEL = "warn"
+STACK_ENGINE = "ring"
+`)
+	if err := conn.reloadConfig(); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if level := conn.Config.Get(vars.LogLevel); level != "debug" {
+		t.Errorf("LOG_LEVEL is %v, expected %v", level, "debug")
+	}
+	if engine := conn.Config.Get(vars.StackEngine); engine != vars.StackEngineDefault {
+		t.Errorf("STACK_ENGINE is %v, expected %v", engine, vars.StackEngineDefault)
+	}
+}
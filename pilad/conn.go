@@ -1,13 +1,20 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/fern4lvarez/piladb/auth"
 	"github.com/fern4lvarez/piladb/config"
+	"github.com/fern4lvarez/piladb/config/vars"
 	"github.com/fern4lvarez/piladb/pila"
+	"github.com/fern4lvarez/piladb/pkg/aof"
+	"github.com/fern4lvarez/piladb/pkg/logger"
 	"github.com/fern4lvarez/piladb/pkg/uuid"
 
 	"github.com/gorilla/mux"
@@ -20,11 +27,40 @@ type Conn struct {
 	Pila *pila.Pila
 	// Config handles the connection configuration.
 	Config *config.Config
+	// Auth handles the tokens that grant access to
+	// the connection.
+	Auth *auth.Auth
 	// Status holds the status of the connection and
 	// resources management.
 	Status *Status
-
-	opDate time.Time
+	// Metrics holds the counters of requests and
+	// operations on Stacks.
+	Metrics *Metrics
+	// Logger logs the requests and the events of
+	// the connection.
+	Logger *logger.Logger
+
+	// snapshotMu serializes snapshots, so periodic and
+	// on-demand snapshots never write the file concurrently.
+	snapshotMu sync.Mutex
+
+	// creatingMu guards creating, which holds the Stacks whose
+	// bases are being created, by their directories, so two
+	// creations of the same Stack never build a base at once.
+	creatingMu sync.Mutex
+	creating   map[string]bool
+
+	// aof is the append-only file where operations are
+	// logged. It is nil if the append-only file is disabled.
+	aof *aof.Log
+	// replayTime is the time of the append-only file entry
+	// being replayed, when the elements of the Stacks expire.
+	replayTime time.Time
+
+	// configPath is the path of the config file, if any,
+	// and configFile holds the values last read from it.
+	configPath string
+	configFile map[string]interface{}
 }
 
 // NewConn creates and returns a new piladb connection.
@@ -32,7 +68,10 @@ func NewConn() *Conn {
 	conn := &Conn{}
 	conn.Pila = pila.NewPila()
 	conn.Config = config.NewConfig()
+	conn.Auth = auth.NewAuth()
 	conn.Status = NewStatus(v(), time.Now().UTC(), MemStats())
+	conn.Metrics = NewMetrics()
+	conn.Logger = logger.New(os.Stderr)
 	return conn
 }
 
@@ -42,13 +81,13 @@ func NewConn() *Conn {
 func (c *Conn) rootHandler(w http.ResponseWriter, r *http.Request) {
 	var links = []byte(`{"thank you":"for using piladb","www":"https://www.piladb.org","code":"https://github.com/fern4lvarez/piladb","docs":"https://docs.piladb.org"}`)
 	w.Header().Set("Content-Type", "application/json")
-	log.Println(r.Method, r.URL, http.StatusOK)
+	c.logRequest(r, http.StatusOK)
 	w.Write(links)
 }
 
 // pingHandler writes pong.
 func (c *Conn) pingHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println(r.Method, r.URL, http.StatusOK)
+	c.logRequest(r, http.StatusOK)
 	w.Write([]byte("pong"))
 }
 
@@ -57,7 +96,7 @@ func (c *Conn) statusHandler(w http.ResponseWriter, r *http.Request) {
 	c.Status.Update(time.Now().UTC(), MemStats())
 
 	w.Header().Set("Content-Type", "application/json")
-	log.Println(r.Method, r.URL, http.StatusOK)
+	c.logRequest(r, http.StatusOK)
 	w.Write(c.Status.ToJSON())
 }
 
@@ -69,7 +108,7 @@ func (c *Conn) databasesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	log.Println(r.Method, r.URL, http.StatusOK)
+	c.logRequest(r, http.StatusOK)
 	w.Write(c.Pila.Status().ToJSON())
 }
 
@@ -78,21 +117,41 @@ func (c *Conn) databasesHandler(w http.ResponseWriter, r *http.Request) {
 func (c *Conn) createDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 	name := r.FormValue("name")
 	if name == "" {
-		log.Println(r.Method, r.URL, http.StatusBadRequest, "missing name")
-		w.WriteHeader(http.StatusBadRequest)
+		c.problem(w, r, http.StatusBadRequest, problemMissingParameter, "missing name")
+		return
+	}
+	if err := checkDatabaseName(name); err != nil {
+		c.problem(w, r, http.StatusBadRequest, problemInvalidParameter, err)
+		return
+	}
+
+	idleTTL, err := parseIdleTTL(r)
+	if err != nil {
+		c.problem(w, r, http.StatusBadRequest, problemInvalidParameter, err)
 		return
 	}
 
+	now := time.Now().UTC()
 	db := pila.NewDatabase(name)
-	err := c.Pila.AddDatabase(db)
+	db.IdleTTL = idleTTL
+	db.Read(now)
+	entry := aof.Entry{
+		Op:       aof.CreateDatabase,
+		Database: name,
+		IdleTTL:  idleTTL.Seconds(),
+		Time:     now,
+	}
+	c.persist(entry, func() bool {
+		err = c.Pila.AddDatabase(db)
+		return err == nil
+	})
 	if err != nil {
-		log.Println(r.Method, r.URL, http.StatusConflict, err)
-		w.WriteHeader(http.StatusConflict)
+		c.problem(w, r, http.StatusConflict, problemDatabaseExists, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	log.Println(r.Method, r.URL, http.StatusCreated)
+	c.logRequest(r, http.StatusCreated)
 	w.WriteHeader(http.StatusCreated)
 	w.Write(db.Status().ToJSON())
 }
@@ -113,19 +172,22 @@ func (c *Conn) databaseHandler(databaseID string) http.Handler {
 
 		db, ok := ResourceDatabase(c, vars["id"])
 		if !ok {
-			c.goneHandler(w, r, fmt.Sprintf("database %s is Gone", vars["id"]))
+			c.goneHandler(w, r, problemDatabaseGone, fmt.Sprintf("database %s is Gone", vars["id"]))
 			return
 		}
+		db.Read(time.Now().UTC())
 
 		if r.Method == "DELETE" {
-			_ = c.Pila.RemoveDatabase(db.ID)
-			log.Println(r.Method, r.URL, http.StatusNoContent)
+			c.persist(aof.Entry{Op: aof.DeleteDatabase, Database: db.Name}, func() bool {
+				return c.Pila.RemoveDatabase(db.ID)
+			})
+			c.logRequest(r, http.StatusNoContent)
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		log.Println(r.Method, r.URL, http.StatusOK)
+		c.logRequest(r, http.StatusOK)
 		w.Write(db.Status().ToJSON())
 	})
 }
@@ -134,7 +196,7 @@ func (c *Conn) databaseHandler(databaseID string) http.Handler {
 // of them, or create a new one.
 func (c *Conn) stacksHandler(databaseID string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		c.opDate = time.Now().UTC()
+		now := time.Now().UTC()
 		vars := mux.Vars(r)
 
 		// we override the mux vars to be able to test
@@ -147,9 +209,10 @@ func (c *Conn) stacksHandler(databaseID string) http.Handler {
 
 		db, ok := ResourceDatabase(c, vars["database_id"])
 		if !ok {
-			c.goneHandler(w, r, fmt.Sprintf("database %s is Gone", vars["database_id"]))
+			c.goneHandler(w, r, problemDatabaseGone, fmt.Sprintf("database %s is Gone", vars["database_id"]))
 			return
 		}
+		db.Read(now)
 
 		if r.Method == "PUT" {
 			c.createStackHandler(w, r, db.ID.String())
@@ -166,15 +229,14 @@ func (c *Conn) stacksHandler(databaseID string) http.Handler {
 
 		res, err := status.ToJSON()
 		if err != nil {
-			log.Println(r.Method, r.URL, http.StatusBadRequest,
+			c.problem(w, r, http.StatusBadRequest, problemSerialization,
 				"error on response serialization:", err)
-			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.Write(res)
-		log.Println(r.Method, r.URL, http.StatusOK)
+		c.logRequest(r, http.StatusOK)
 
 	})
 }
@@ -184,25 +246,68 @@ func (c *Conn) stacksHandler(databaseID string) http.Handler {
 func (c *Conn) createStackHandler(w http.ResponseWriter, r *http.Request, databaseID string) {
 	name := r.FormValue("name")
 	if name == "" {
-		log.Println(r.Method, r.URL, http.StatusBadRequest, "missing name")
-		w.WriteHeader(http.StatusBadRequest)
+		c.problem(w, r, http.StatusBadRequest, problemMissingParameter, "missing name")
 		return
 	}
 
 	db, ok := c.Pila.Database(uuid.UUID(databaseID))
 	if !ok {
-		c.goneHandler(w, r, fmt.Sprintf("database %s is Gone", databaseID))
+		c.goneHandler(w, r, problemDatabaseGone, fmt.Sprintf("database %s is Gone", databaseID))
+		return
+	}
+
+	engine, overflow, capacity, err := c.stackOptions(r)
+	if err != nil {
+		c.problem(w, r, http.StatusBadRequest, problemInvalidParameter, err)
+		return
+	}
+
+	idleTTL, err := parseIdleTTL(r)
+	if err != nil {
+		c.problem(w, r, http.StatusBadRequest, problemInvalidParameter, err)
+		return
+	}
+
+	// reserve the name before creating the base, as it
+	// could take over the resources of an existing Stack.
+	release, err := c.reserveStack(db, name)
+	if err != nil {
+		c.problem(w, r, http.StatusConflict, problemStackExists, err)
 		return
 	}
+	defer release()
 
-	stack := pila.NewStack(name, c.opDate)
-	err := db.AddStack(stack)
+	base, err := c.newBase(engine, db.Name, name, capacity)
 	if err != nil {
-		log.Println(r.Method, r.URL, http.StatusConflict, err)
-		w.WriteHeader(http.StatusConflict)
+		c.problem(w, r, http.StatusBadRequest, problemInvalidParameter, err)
 		return
 	}
-	stack.Update(c.opDate)
+
+	now := time.Now().UTC()
+	stack := pila.NewStackWithBase(name, now, base)
+	stack.Engine = engineName(engine)
+	stack.Overflow = overflow
+	stack.IdleTTL = idleTTL
+	entry := aof.Entry{
+		Op:       aof.CreateStack,
+		Database: db.Name,
+		Stack:    name,
+		Engine:   stack.Engine,
+		Overflow: overflow,
+		Capacity: capacity,
+		IdleTTL:  idleTTL.Seconds(),
+		Time:     now,
+	}
+	c.persist(entry, func() bool {
+		err = db.AddStack(stack)
+		return err == nil
+	})
+	if err != nil {
+		closeBase(base)
+		c.problem(w, r, http.StatusConflict, problemStackExists, err)
+		return
+	}
+	stack.Update(now)
 
 	// Do not check error as the Status of a new stack does
 	// not contain types that could cause such case.
@@ -212,14 +317,100 @@ func (c *Conn) createStackHandler(w http.ResponseWriter, r *http.Request, databa
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	w.Write(res)
-	log.Println(r.Method, r.URL, http.StatusCreated)
+	c.logRequest(r, http.StatusCreated)
+}
+
+// txHandler runs a list of operations on the stacks of a database
+// atomically, and returns 200 and the result of each operation. If any
+// operation fails, all of them are rolled back, and it returns 409 and
+// the result of the operations that ran.
+func (c *Conn) txHandler(databaseID string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().UTC()
+		vars := mux.Vars(r)
+
+		// we override the mux vars to be able to test
+		// an arbitrary database ID
+		if databaseID != "" {
+			vars = map[string]string{
+				"database_id": databaseID,
+			}
+		}
+
+		db, ok := ResourceDatabase(c, vars["database_id"])
+		if !ok {
+			c.goneHandler(w, r, problemDatabaseGone, fmt.Sprintf("database %s is Gone", vars["database_id"]))
+			return
+		}
+		db.Read(now)
+
+		if r.Body == nil {
+			c.problem(w, r, http.StatusBadRequest, problemMissingBody,
+				"no operations provided")
+			return
+		}
+
+		var ops []pila.TxOp
+		if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+			c.problem(w, r, http.StatusBadRequest, problemMalformedBody,
+				"error on decoding operations:", err)
+			return
+		}
+
+		entry := aof.Entry{Op: aof.Tx, Database: db.Name, Time: now}
+		for _, op := range ops {
+			if err := op.Validate(); err != nil {
+				c.problem(w, r, http.StatusBadRequest, problemInvalidOperation, err)
+				return
+			}
+
+			// log the name of the stacks, as their IDs
+			// are not known on replay
+			name := op.Stack
+			if stack, ok := ResourceStack(db, op.Stack); ok {
+				name = stack.Name
+			}
+			entry.Entries = append(entry.Entries, aof.Entry{
+				Op:      op.Op,
+				Stack:   name,
+				Element: op.Element,
+			})
+		}
+
+		var status pila.TxStatus
+		var err error
+		c.persist(entry, func() bool {
+			status.Results, err = db.Transaction(ops, c.Config.MaxStackSize(), now)
+			status.Committed = err == nil
+			return status.Committed
+		})
+
+		if err != nil {
+			if status.Results == nil {
+				status.Results = []pila.TxResult{}
+			}
+			c.logRequest(r, http.StatusConflict, err)
+			writeProblem(w, http.StatusConflict, txProblem{
+				Problem:  newProblem(r, http.StatusConflict, problemTxAborted, err),
+				TxStatus: status,
+			})
+			return
+		}
+
+		// Do not check error as we consider our elements
+		// suitable for a JSON encoding.
+		b, _ := status.ToJSON()
+		c.logRequest(r, http.StatusOK, len(status.Results))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
 }
 
 // stackHandler handles operations on a single stack of a database. It holds
 // the PUSH, POP, PEEK and SIZE methods, and the stack deletion.
 func (c *Conn) stackHandler(params *map[string]string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		c.opDate = time.Now().UTC()
+		now := time.Now().UTC()
 		vars := mux.Vars(r)
 		// we override the mux vars to be able to test
 		// an arbitrary database and stack ID
@@ -229,15 +420,17 @@ func (c *Conn) stackHandler(params *map[string]string) http.Handler {
 
 		db, ok := ResourceDatabase(c, vars["database_id"])
 		if !ok {
-			c.goneHandler(w, r, fmt.Sprintf("database %s is Gone", vars["database_id"]))
+			c.goneHandler(w, r, problemDatabaseGone, fmt.Sprintf("database %s is Gone", vars["database_id"]))
 			return
 		}
+		db.Read(now)
 
 		stack, ok := ResourceStack(db, vars["stack_id"])
 		if !ok {
-			c.goneHandler(w, r, fmt.Sprintf("stack %s is Gone", vars["stack_id"]))
+			c.goneHandler(w, r, problemStackGone, fmt.Sprintf("stack %s is Gone", vars["stack_id"]))
 			return
 		}
+		w = &etagWriter{ResponseWriter: w, stack: stack}
 
 		switch {
 		case r.Method == "GET":
@@ -250,10 +443,18 @@ func (c *Conn) stackHandler(params *map[string]string) http.Handler {
 				c.sizeStackHandler(w, r, stack)
 				return
 			}
+			if _, ok := r.Form["range"]; ok {
+				c.rangeStackHandler(w, r, stack)
+				return
+			}
 			c.statusStackHandler(w, r, stack)
 			return
 
 		case r.Method == "POST":
+			if _, ok := r.URL.Query()["batch"]; ok {
+				c.pushManyStackHandler(w, r, stack)
+				return
+			}
 			c.checkMaxStackSize(c.pushStackHandler)(w, r, stack)
 			return
 
@@ -267,16 +468,109 @@ func (c *Conn) stackHandler(params *map[string]string) http.Handler {
 				c.deleteStackHandler(w, r, db, stack)
 				return
 			}
+			if _, ok := r.Form["pop"]; ok {
+				c.popManyStackHandler(w, r, stack)
+				return
+			}
 			c.popStackHandler(w, r, stack)
 			return
 		}
 	})
 }
 
+// moveStackHandler pops the element on top of a Stack and pushes it on top
+// of the Stack given by the to parameter atomically, and returns 200 and
+// the element. The destination Stack belongs to the same database, unless
+// the database parameter is set.
+func (c *Conn) moveStackHandler(params *map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().UTC()
+		vars := mux.Vars(r)
+		// we override the mux vars to be able to test
+		// an arbitrary database and stack ID
+		if params != nil {
+			vars = *params
+		}
+
+		db, ok := ResourceDatabase(c, vars["database_id"])
+		if !ok {
+			c.goneHandler(w, r, problemDatabaseGone, fmt.Sprintf("database %s is Gone", vars["database_id"]))
+			return
+		}
+		db.Read(now)
+
+		src, ok := ResourceStack(db, vars["stack_id"])
+		if !ok {
+			c.goneHandler(w, r, problemStackGone, fmt.Sprintf("stack %s is Gone", vars["stack_id"]))
+			return
+		}
+
+		to := r.FormValue("to")
+		if to == "" {
+			c.problem(w, r, http.StatusBadRequest, problemMissingParameter,
+				"missing destination stack")
+			return
+		}
+
+		dstDB := db
+		if database := r.FormValue("database"); database != "" {
+			dstDB, ok = ResourceDatabase(c, database)
+			if !ok {
+				c.goneHandler(w, r, problemDatabaseGone, fmt.Sprintf("database %s is Gone", database))
+				return
+			}
+		}
+
+		dst, ok := ResourceStack(dstDB, to)
+		if !ok {
+			c.goneHandler(w, r, problemStackGone, fmt.Sprintf("stack %s is Gone", to))
+			return
+		}
+
+		c.checkMaxStackSize(func(w http.ResponseWriter, r *http.Request, dst *pila.Stack) {
+			c.moveElement(w, r, src, dst)
+		})(w, r, dst)
+	})
+}
+
+// moveElement moves the element on top of src to dst, and returns 200
+// and the element, or 204 if src is empty.
+func (c *Conn) moveElement(w http.ResponseWriter, r *http.Request, src, dst *pila.Stack) {
+	now := time.Now().UTC()
+	var value interface{}
+	var ok bool
+	entry := c.stackEntry(aof.Move, src, now)
+	entry.ToStack = dst.Name
+	if dst.Database != nil {
+		entry.ToDatabase = dst.Database.Name
+	}
+	c.persist(entry, func() bool {
+		value, ok = pila.Move(src, dst)
+		return ok
+	})
+	if !ok {
+		c.logRequest(r, http.StatusNoContent)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	src.Update(now)
+	dst.Update(now)
+
+	element := pila.Element{Value: value}
+
+	c.logRequest(r, http.StatusOK, element.Value)
+	w.Header().Set("Content-Type", "application/json")
+
+	// Do not check error as we consider our element
+	// suitable for a JSON encoding.
+	b, _ := element.ToJSON()
+	w.Write(b)
+}
+
 // statusStackHandler returns the status of the Stack.
 func (c *Conn) statusStackHandler(w http.ResponseWriter, r *http.Request, stack *pila.Stack) {
-	stack.Read(c.opDate)
-	log.Println(r.Method, r.URL, http.StatusOK)
+	stack.Read(time.Now().UTC())
+	c.logRequest(r, http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
 
 	// Do not check error as we consider that a flushed
@@ -289,9 +583,9 @@ func (c *Conn) statusStackHandler(w http.ResponseWriter, r *http.Request, stack
 func (c *Conn) peekStackHandler(w http.ResponseWriter, r *http.Request, stack *pila.Stack) {
 	var element pila.Element
 	element.Value = stack.Peek()
-	stack.Read(c.opDate)
+	stack.Read(time.Now().UTC())
 
-	log.Println(r.Method, r.URL, http.StatusOK, element.Value)
+	c.logRequest(r, http.StatusOK, element.Value)
 	w.Header().Set("Content-Type", "application/json")
 
 	// Do not check error as we consider our element
@@ -302,8 +596,8 @@ func (c *Conn) peekStackHandler(w http.ResponseWriter, r *http.Request, stack *p
 
 // sizeStackHandler returns the size of the Stack.
 func (c *Conn) sizeStackHandler(w http.ResponseWriter, r *http.Request, stack *pila.Stack) {
-	stack.Read(c.opDate)
-	log.Println(r.Method, r.URL, http.StatusOK, stack.Size())
+	stack.Read(time.Now().UTC())
+	c.logRequest(r, http.StatusOK, stack.Size())
 	w.Header().Set("Content-Type", "application/json")
 
 	// Do not check error as we consider the size
@@ -311,28 +605,104 @@ func (c *Conn) sizeStackHandler(w http.ResponseWriter, r *http.Request, stack *p
 	w.Write(stack.SizeToJSON())
 }
 
+// rangeStackHandler returns a page of the elements of the Stack, from
+// top to bottom, without modifying it. The page is set by the offset
+// and limit parameters, 0 and 50 by default.
+func (c *Conn) rangeStackHandler(w http.ResponseWriter, r *http.Request, stack *pila.Stack) {
+	offset, err := formInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		c.problem(w, r, http.StatusBadRequest, problemInvalidParameter,
+			"offset must be a non-negative integer")
+		return
+	}
+
+	limit, err := formInt(r, "limit", 50)
+	if err != nil || limit < 1 {
+		c.problem(w, r, http.StatusBadRequest, problemInvalidParameter,
+			"limit must be a positive integer")
+		return
+	}
+
+	elements, ok := stack.Range(offset, limit)
+	if !ok {
+		c.problem(w, r, http.StatusNotImplemented, problemNotImplemented,
+			"stack does not support range reads")
+		return
+	}
+	stack.Read(time.Now().UTC())
+
+	stackRange := pila.StackRange{
+		Offset:   offset,
+		Limit:    limit,
+		Size:     stack.Size(),
+		Elements: elements,
+	}
+
+	res, err := stackRange.ToJSON()
+	if err != nil {
+		c.problem(w, r, http.StatusBadRequest, problemSerialization,
+			"error on response serialization:", err)
+		return
+	}
+
+	c.logRequest(r, http.StatusOK, len(elements))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
 // pushStackHandler adds an element into a Stack and returns 200 and the element.
+// If the element has a TTL, it expires after that many seconds. If the If-Match
+// header is set, the element is only added if it matches the Stack version.
 func (c *Conn) pushStackHandler(w http.ResponseWriter, r *http.Request, stack *pila.Stack) {
+	now := time.Now().UTC()
 	if r.Body == nil {
-		log.Println(r.Method, r.URL, http.StatusBadRequest,
+		c.problem(w, r, http.StatusBadRequest, problemMissingBody,
 			"no element provided")
-		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
 	var element pila.Element
 	err := element.Decode(r.Body)
 	if err != nil {
-		log.Println(r.Method, r.URL, http.StatusBadRequest,
+		c.problem(w, r, http.StatusBadRequest, problemMalformedBody,
 			"error on decoding element:", err)
-		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	stack.Push(element.Value)
-	stack.Update(c.opDate)
+	version, conditional, err := ifMatch(r)
+	if err != nil {
+		c.problem(w, r, http.StatusPreconditionFailed, problemInvalidIfMatch, err)
+		return
+	}
 
-	log.Println(r.Method, r.URL, http.StatusOK, element.Value)
+	entry := c.stackEntry(aof.Push, stack, now)
+	entry.Element = element.Value
+	expiresAt := element.ExpiresAt(now)
+	if !expiresAt.IsZero() {
+		entry.ExpiresAt = &expiresAt
+	}
+	c.persist(entry, func() bool {
+		switch {
+		case conditional:
+			err = stack.CompareAndPushExpiring(version, element.Value, expiresAt)
+		case !expiresAt.IsZero():
+			err = stack.PushExpiring(element.Value, expiresAt)
+		default:
+			stack.Push(element.Value)
+		}
+		return err == nil
+	})
+	if err == pila.ErrNoExpiration {
+		c.problem(w, r, http.StatusNotImplemented, problemNotImplemented, err)
+		return
+	}
+	if err != nil {
+		c.problem(w, r, http.StatusPreconditionFailed, problemVersionMismatch, err)
+		return
+	}
+	stack.Update(now)
+
+	c.logRequest(r, http.StatusOK, element.Value)
 	w.Header().Set("Content-Type", "application/json")
 
 	// Do not check error as we consider our element
@@ -342,18 +712,70 @@ func (c *Conn) pushStackHandler(w http.ResponseWriter, r *http.Request, stack *p
 }
 
 // popStackHandler extracts the peek element of a Stack, returns 200 and returns it.
+// If the Stack is empty and the wait parameter is set, it waits for an element
+// to be pushed for that long, bounded by WRITE_TIMEOUT. If the If-Match header
+// is set, the element is only extracted if it matches the Stack version, and
+// the wait parameter is not accepted.
 func (c *Conn) popStackHandler(w http.ResponseWriter, r *http.Request, stack *pila.Stack) {
-	value, ok := stack.Pop()
+	wait, err := c.popWait(r)
+	if err != nil {
+		c.problem(w, r, http.StatusBadRequest, problemInvalidParameter, err)
+		return
+	}
+
+	version, conditional, err := ifMatch(r)
+	if err != nil {
+		c.problem(w, r, http.StatusPreconditionFailed, problemInvalidIfMatch, err)
+		return
+	}
+	// the push that ends the wait changes the version of
+	// the Stack, so a conditional pop could never succeed
+	if conditional && wait > 0 {
+		c.problem(w, r, http.StatusBadRequest, problemInvalidParameter,
+			"wait cannot be combined with If-Match")
+		return
+	}
+
+	var value interface{}
+	var ok bool
+	var now time.Time
+	deadline := time.Now().Add(wait)
+	for front := false; ; front = true {
+		// the time is taken on every attempt, as
+		// waiting could have taken a while
+		now = time.Now().UTC()
+		c.persist(c.stackEntry(aof.Pop, stack, now), func() bool {
+			if conditional {
+				value, err = stack.CompareAndPop(version)
+				ok = err == nil
+				return ok
+			}
+			value, ok = stack.Pop()
+			return ok
+		})
+		if ok {
+			break
+		}
+		if err == pila.ErrVersionMismatch {
+			c.problem(w, r, http.StatusPreconditionFailed, problemVersionMismatch, err)
+			return
+		}
+
+		remaining := deadline.Sub(time.Now())
+		if remaining <= 0 || !stack.Wait(remaining, front) {
+			break
+		}
+	}
 	if !ok {
-		log.Println(r.Method, r.URL, http.StatusNoContent)
+		c.logRequest(r, http.StatusNoContent)
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
-	stack.Update(c.opDate)
+	stack.Update(now)
 
 	element := pila.Element{Value: value}
 
-	log.Println(r.Method, r.URL, http.StatusOK, element.Value)
+	c.logRequest(r, http.StatusOK, element.Value)
 	w.Header().Set("Content-Type", "application/json")
 
 	// Do not check error as we consider our element
@@ -362,13 +784,150 @@ func (c *Conn) popStackHandler(w http.ResponseWriter, r *http.Request, stack *pi
 	w.Write(b)
 }
 
+// pushManyStackHandler adds an array of elements into a Stack atomically,
+// in order, and returns 200 and the elements. If the If-Match header is set,
+// the elements are only added if it matches the Stack version.
+func (c *Conn) pushManyStackHandler(w http.ResponseWriter, r *http.Request, stack *pila.Stack) {
+	now := time.Now().UTC()
+	if r.Body == nil {
+		c.problem(w, r, http.StatusBadRequest, problemMissingBody,
+			"no elements provided")
+		return
+	}
+
+	var elements pila.Elements
+	err := elements.Decode(r.Body)
+	if err != nil {
+		c.problem(w, r, http.StatusBadRequest, problemMalformedBody,
+			"error on decoding elements:", err)
+		return
+	}
+
+	version, conditional, err := ifMatch(r)
+	if err != nil {
+		c.problem(w, r, http.StatusPreconditionFailed, problemInvalidIfMatch, err)
+		return
+	}
+
+	entry := c.stackEntry(aof.PushMany, stack, now)
+	entry.Elements = elements
+	maxSize := c.Config.MaxStackSize()
+	c.persist(entry, func() bool {
+		if conditional {
+			err = stack.CompareAndPushMany(version, elements, maxSize)
+		} else {
+			err = stack.PushManyBounded(elements, maxSize)
+		}
+		return err == nil
+	})
+	if err == pila.ErrStackFull {
+		c.problem(w, r, http.StatusNotAcceptable, problemMaxStackSize, vars.MaxStackSize, "value exceeded")
+		return
+	}
+	if err != nil {
+		c.problem(w, r, http.StatusPreconditionFailed, problemVersionMismatch, err)
+		return
+	}
+	stack.Update(now)
+
+	c.logRequest(r, http.StatusOK, len(elements))
+	w.Header().Set("Content-Type", "application/json")
+
+	// Do not check error as we consider our elements
+	// suitable for a JSON encoding.
+	b, _ := elements.ToJSON()
+	w.Write(b)
+}
+
+// popManyStackHandler extracts up to N elements from the top of a Stack
+// atomically, returns 200 and an array with them, from top to bottom. If
+// the If-Match header is set, the elements are only extracted if it matches
+// the Stack version.
+func (c *Conn) popManyStackHandler(w http.ResponseWriter, r *http.Request, stack *pila.Stack) {
+	now := time.Now().UTC()
+	n, err := strconv.Atoi(r.FormValue("pop"))
+	if err != nil || n < 1 {
+		c.problem(w, r, http.StatusBadRequest, problemInvalidParameter,
+			"pop must be a positive integer")
+		return
+	}
+
+	version, conditional, err := ifMatch(r)
+	if err != nil {
+		c.problem(w, r, http.StatusPreconditionFailed, problemInvalidIfMatch, err)
+		return
+	}
+
+	var elements pila.Elements
+	entry := c.stackEntry(aof.PopMany, stack, now)
+	entry.Count = n
+	c.persist(entry, func() bool {
+		if conditional {
+			elements, err = stack.CompareAndPopMany(version, n)
+		} else {
+			elements = stack.PopMany(n)
+		}
+		return len(elements) > 0
+	})
+	if err != nil {
+		c.problem(w, r, http.StatusPreconditionFailed, problemVersionMismatch, err)
+		return
+	}
+	if len(elements) == 0 {
+		c.logRequest(r, http.StatusNoContent)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	stack.Update(now)
+
+	c.logRequest(r, http.StatusOK, len(elements))
+	w.Header().Set("Content-Type", "application/json")
+
+	// Do not check error as we consider our elements
+	// suitable for a JSON encoding.
+	b, _ := elements.ToJSON()
+	w.Write(b)
+}
+
+// popWait returns how long a POP operation waits for an element
+// to be pushed into an empty Stack, given by the wait parameter as
+// a duration, e.g. 30s, or as a number of seconds. The result is
+// bounded by WRITE_TIMEOUT, leaving a second to write the response.
+func (c *Conn) popWait(r *http.Request) (time.Duration, error) {
+	value := r.FormValue("wait")
+	if value == "" {
+		return 0, nil
+	}
+
+	wait, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid wait %s", value)
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+	if wait < 0 {
+		return 0, fmt.Errorf("invalid wait %s", value)
+	}
+
+	if max := c.Config.WriteTimeout()*time.Second - time.Second; wait > max {
+		wait = max
+	}
+	return wait, nil
+}
+
 // flushStackHandler flushes the Stack, setting the size to 0 and emptying all
 // the content.
 func (c *Conn) flushStackHandler(w http.ResponseWriter, r *http.Request, stack *pila.Stack) {
-	stack.Flush()
-	stack.Update(c.opDate)
+	now := time.Now().UTC()
+	c.persist(c.stackEntry(aof.Flush, stack, now), func() bool {
+		stack.Flush()
+		return true
+	})
+	stack.Update(now)
 
-	log.Println(r.Method, r.URL, http.StatusOK)
+	c.logRequest(r, http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
 
 	// Do not check error as we consider that a flushed
@@ -379,27 +938,35 @@ func (c *Conn) flushStackHandler(w http.ResponseWriter, r *http.Request, stack *
 
 // deleteStackHandler deletes the Stack from a database.
 func (c *Conn) deleteStackHandler(w http.ResponseWriter, r *http.Request, database *pila.Database, stack *pila.Stack) {
-	stack.Flush()
+	now := time.Now().UTC()
+	c.persist(c.stackEntry(aof.DeleteStack, stack, now), func() bool {
+		stack.Flush()
+
+		// Do not check output as we validated that
+		// stack always exists.
+		_ = database.RemoveStack(stack.ID)
+		return true
+	})
 
-	// Do not check output as we validated that
-	// stack always exists.
-	_ = database.RemoveStack(stack.ID)
+	c.logRequest(r, http.StatusNoContent)
 
-	log.Println(r.Method, r.URL, http.StatusNoContent)
 	w.WriteHeader(http.StatusNoContent)
 	return
 }
 
 // notFoundHandler logs and returns a 404 NotFound response.
 func (c *Conn) notFoundHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println(r.Method, r.URL, http.StatusNotFound)
-	http.NotFound(w, r)
+	c.problem(w, r, http.StatusNotFound, problemNotFound)
+}
+
+// methodNotAllowedHandler logs and returns a 405 MethodNotAllowed response.
+func (c *Conn) methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	c.problem(w, r, http.StatusMethodNotAllowed, problemMethodNotAllowed,
+		"method", r.Method, "is not allowed")
 }
 
-// goneHandler logs and returns a 410 Gone response with information
-// about the missing resource.
-func (c *Conn) goneHandler(w http.ResponseWriter, r *http.Request, message string) {
-	log.Println(r.Method, r.URL,
-		http.StatusGone, message)
-	w.WriteHeader(http.StatusGone)
+// goneHandler logs and returns a 410 Gone response with the code and
+// information about the missing resource.
+func (c *Conn) goneHandler(w http.ResponseWriter, r *http.Request, code, message string) {
+	c.problem(w, r, http.StatusGone, code, message)
 }
@@ -6,12 +6,17 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/fern4lvarez/piladb/auth"
+	"github.com/fern4lvarez/piladb/config"
+	"github.com/fern4lvarez/piladb/config/vars"
 	"github.com/fern4lvarez/piladb/pila"
 	"github.com/fern4lvarez/piladb/pkg/date"
+	"github.com/fern4lvarez/piladb/pkg/stack"
 	"github.com/fern4lvarez/piladb/pkg/uuid"
 )
 
@@ -241,6 +246,26 @@ func TestCreateDatabaseHandler(t *testing.T) {
 	}
 }
 
+func TestCreateDatabaseHandler_ReservedName(t *testing.T) {
+	for _, name := range []string{config.CONFIG, auth.AUTH, "_foo"} {
+		conn := NewConn()
+		request, err := http.NewRequest("PUT", "/databases?name="+name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := httptest.NewRecorder()
+
+		conn.createDatabaseHandler(response, request)
+
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("response code is %v, expected %v", response.Code, http.StatusBadRequest)
+		}
+		if n := len(conn.Pila.Databases); n != 0 {
+			t.Errorf("Pila has %d databases, expected %d", n, 0)
+		}
+	}
+}
+
 func TestCreateDatabaseHandler_NoName(t *testing.T) {
 	conn := NewConn()
 	request, err := http.NewRequest("PUT", "/databases", nil)
@@ -487,7 +512,7 @@ func TestStacksHandler_GET(t *testing.T) {
 	inputOutput := []struct {
 		input, output string
 	}{
-		{"/databases/db/stacks", fmt.Sprintf(`{"stacks":[{"id":"f0306fec639bd57fc2929c8b897b9b37","name":"stack1","peek":"foo","size":1,"created_at":"%v","updated_at":"%v","read_at":"%v"},{"id":"dde8f895aea2ffa5546336146b9384e7","name":"stack2","peek":8,"size":2,"created_at":"%v","updated_at":"%v","read_at":"%v"}]}`,
+		{"/databases/db/stacks", fmt.Sprintf(`{"stacks":[{"id":"f0306fec639bd57fc2929c8b897b9b37","name":"stack1","peek":"foo","size":1,"created_at":"%v","updated_at":"%v","read_at":"%v","version":1},{"id":"dde8f895aea2ffa5546336146b9384e7","name":"stack2","peek":8,"size":2,"created_at":"%v","updated_at":"%v","read_at":"%v","version":2}]}`,
 			date.Format(now1.Local()), date.Format(after1.Local()), date.Format(after1.Local()),
 			date.Format(now2.Local()), date.Format(after2.Local()), date.Format(after2.Local()))},
 		{"/databases/db/stacks?kv", `{"stacks":{"stack1":"foo","stack2":8}}`},
@@ -568,7 +593,7 @@ func TestStacksHandler_GET_Name(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if expected := fmt.Sprintf(`{"stacks":[{"id":"f0306fec639bd57fc2929c8b897b9b37","name":"stack1","peek":"bar","size":1,"created_at":"%v","updated_at":"%v","read_at":"%v"},{"id":"dde8f895aea2ffa5546336146b9384e7","name":"stack2","peek":"{\"a\":\"b\"}","size":1,"created_at":"%v","updated_at":"%v","read_at":"%v"}]}`,
+	if expected := fmt.Sprintf(`{"stacks":[{"id":"f0306fec639bd57fc2929c8b897b9b37","name":"stack1","peek":"bar","size":1,"created_at":"%v","updated_at":"%v","read_at":"%v","version":1},{"id":"dde8f895aea2ffa5546336146b9384e7","name":"stack2","peek":"{\"a\":\"b\"}","size":1,"created_at":"%v","updated_at":"%v","read_at":"%v","version":1}]}`,
 		date.Format(now1.Local()), date.Format(after1.Local()), date.Format(after1.Local()),
 		date.Format(now2.Local()), date.Format(after2.Local()), date.Format(after2.Local())); string(stacks) != expected {
 		t.Errorf("stacks are %s, expected %s", string(stacks), expected)
@@ -635,7 +660,6 @@ func TestStacksHandler_PUT(t *testing.T) {
 
 	conn := NewConn()
 	conn.Pila = p
-	conn.opDate = time.Now().UTC()
 
 	path := fmt.Sprintf("/databases/%s/stacks/?name=test-stack", db.ID.String())
 	request, err := http.NewRequest("PUT", path, nil)
@@ -660,8 +684,9 @@ func TestStacksHandler_PUT(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	created, _ := ResourceStack(db, "test-stack")
 	expectedStack := fmt.Sprintf(`{"id":"bb4dabeeaa6e90108583ddbf49649427","name":"test-stack","peek":null,"size":0,"created_at":"%v","updated_at":"%v","read_at":"%v"}`,
-		date.Format(conn.opDate.Local()), date.Format(conn.opDate.Local()), date.Format(conn.opDate.Local()))
+		date.Format(created.CreatedAt.Local()), date.Format(created.CreatedAt.Local()), date.Format(created.CreatedAt.Local()))
 
 	if string(stack) != expectedStack {
 		t.Errorf("stack is %s, expected %s", string(stack), expectedStack)
@@ -676,7 +701,6 @@ func TestStacksHandler_PUT_Name(t *testing.T) {
 
 	conn := NewConn()
 	conn.Pila = p
-	conn.opDate = time.Now().UTC()
 
 	path := fmt.Sprintf("/databases/%s/stacks/?name=test-stack", db.Name)
 	request, err := http.NewRequest("PUT", path, nil)
@@ -701,8 +725,9 @@ func TestStacksHandler_PUT_Name(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	created, _ := ResourceStack(db, "test-stack")
 	expectedStack := fmt.Sprintf(`{"id":"bb4dabeeaa6e90108583ddbf49649427","name":"test-stack","peek":null,"size":0,"created_at":"%v","updated_at":"%v","read_at":"%v"}`,
-		date.Format(conn.opDate.Local()), date.Format(conn.opDate.Local()), date.Format(conn.opDate.Local()))
+		date.Format(created.CreatedAt.Local()), date.Format(created.CreatedAt.Local()), date.Format(created.CreatedAt.Local()))
 
 	if string(stack) != expectedStack {
 		t.Errorf("stack is %s, expected %s", string(stack), expectedStack)
@@ -717,7 +742,6 @@ func TestCreateStackHandler(t *testing.T) {
 
 	conn := NewConn()
 	conn.Pila = p
-	conn.opDate = time.Now().UTC()
 
 	path := fmt.Sprintf("/databases/%s/stacks/?name=test-stack", db.ID.String())
 	request, err := http.NewRequest("PUT", path, nil)
@@ -741,8 +765,9 @@ func TestCreateStackHandler(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	created, _ := ResourceStack(db, "test-stack")
 	expectedStack := fmt.Sprintf(`{"id":"bb4dabeeaa6e90108583ddbf49649427","name":"test-stack","peek":null,"size":0,"created_at":"%v","updated_at":"%v","read_at":"%v"}`,
-		date.Format(conn.opDate.Local()), date.Format(conn.opDate.Local()), date.Format(conn.opDate.Local()))
+		date.Format(created.CreatedAt.Local()), date.Format(created.CreatedAt.Local()), date.Format(created.CreatedAt.Local()))
 	if string(stack) != expectedStack {
 		t.Errorf("stack is %s, expected %s", string(stack), expectedStack)
 	}
@@ -833,7 +858,6 @@ func TestStackHandler_GET(t *testing.T) {
 
 	conn := NewConn()
 	conn.Pila = p
-	conn.opDate = time.Now().UTC()
 
 	s.Push(element.Value)
 
@@ -942,7 +966,6 @@ func TestStackHandler_POST(t *testing.T) {
 
 	conn := NewConn()
 	conn.Pila = p
-	conn.opDate = time.Now().UTC()
 
 	element := pila.Element{Value: "test-element"}
 	expectedElementJSON, _ := element.ToJSON()
@@ -1084,7 +1107,6 @@ func TestStackHandler_DELETE(t *testing.T) {
 		}
 
 		if io.input.op == "flush" {
-			s.Update(conn.opDate)
 			stackStatus := s.Status()
 
 			expectedStackStatusJSON, err := stackStatus.ToJSON()
@@ -1129,7 +1151,6 @@ func TestStackHandler_DatabaseGone(t *testing.T) {
 
 	conn := NewConn()
 	conn.Pila = p
-	conn.opDate = now
 
 	request, err := http.NewRequest("GET",
 		fmt.Sprintf("/databases/%s/stacks/%s",
@@ -1168,7 +1189,6 @@ func TestStackHandler_StackGone(t *testing.T) {
 
 	conn := NewConn()
 	conn.Pila = p
-	conn.opDate = now
 
 	request, err := http.NewRequest("GET",
 		fmt.Sprintf("/databases/%s/stacks/%s",
@@ -1209,10 +1229,6 @@ func TestStatusStackHandler(t *testing.T) {
 
 	s.Push("one")
 
-	expectedStackStatusJSON, err := s.Status().ToJSON()
-	if err != nil {
-		t.Fatal(err)
-	}
 
 	varss := []map[string]string{
 		{
@@ -1255,6 +1271,11 @@ func TestStatusStackHandler(t *testing.T) {
 			t.Fatal(err)
 		}
 
+		// the stack is read by the request
+		expectedStackStatusJSON, err := s.Status().ToJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
 		if string(stackStatusJSON) != string(expectedStackStatusJSON) {
 			t.Errorf("stack status is %s, expected %s", string(stackStatusJSON), string(expectedStackStatusJSON))
 		}
@@ -1362,6 +1383,81 @@ func TestSizeStackHandler(t *testing.T) {
 	}
 }
 
+func TestRangeStackHandler(t *testing.T) {
+	s := pila.NewStack("stack", time.Now().UTC())
+	s.PushMany([]interface{}{"a", "b", "c"})
+
+	db := pila.NewDatabase("db")
+	_ = db.AddStack(s)
+
+	p := pila.NewPila()
+	_ = p.AddDatabase(db)
+
+	conn := NewConn()
+	conn.Pila = p
+
+	params := map[string]string{
+		"database_id": db.Name,
+		"stack_id":    s.Name,
+	}
+
+	inputOutput := []struct {
+		input    string
+		response string
+		code     int
+	}{
+		{"", `{"offset":0,"limit":50,"size":3,"elements":["c","b","a"]}`, http.StatusOK},
+		{"&offset=1&limit=1", `{"offset":1,"limit":1,"size":3,"elements":["b"]}`, http.StatusOK},
+		{"&offset=3", `{"offset":3,"limit":50,"size":3,"elements":[]}`, http.StatusOK},
+		{"&offset=-1", `{"type":"about:blank","title":"Bad Request","status":400,"code":"invalid_parameter","detail":"offset must be a non-negative integer","instance":"/databases/db/stacks/stack"}`, http.StatusBadRequest},
+		{"&offset=foo", `{"type":"about:blank","title":"Bad Request","status":400,"code":"invalid_parameter","detail":"offset must be a non-negative integer","instance":"/databases/db/stacks/stack"}`, http.StatusBadRequest},
+		{"&limit=0", `{"type":"about:blank","title":"Bad Request","status":400,"code":"invalid_parameter","detail":"limit must be a positive integer","instance":"/databases/db/stacks/stack"}`, http.StatusBadRequest},
+		{"&limit=foo", `{"type":"about:blank","title":"Bad Request","status":400,"code":"invalid_parameter","detail":"limit must be a positive integer","instance":"/databases/db/stacks/stack"}`, http.StatusBadRequest},
+	}
+
+	for _, io := range inputOutput {
+		request, err := http.NewRequest("GET",
+			fmt.Sprintf("/databases/%s/stacks/%s?range%s", db.Name, s.Name, io.input), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response := httptest.NewRecorder()
+
+		conn.stackHandler(&params).ServeHTTP(response, request)
+
+		if response.Code != io.code {
+			t.Errorf("response code for %q is %v, expected %v", io.input, response.Code, io.code)
+		}
+		if body := response.Body.String(); body != io.response {
+			t.Errorf("range for %q is %s, expected %s", io.input, body, io.response)
+		}
+	}
+
+	if s.Size() != 3 {
+		t.Errorf("stack size is %d, expected %d", s.Size(), 3)
+	}
+}
+
+func TestRangeStackHandler_NotImplemented(t *testing.T) {
+	s := pila.NewStackWithBase("stack", time.Now().UTC(), struct{ stack.Stacker }{stack.NewStack()})
+
+	conn := NewConn()
+
+	request, err := http.NewRequest("GET", "/databases/db/stacks/stack?range", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+
+	conn.rangeStackHandler(response, request, s)
+
+	if response.Code != http.StatusNotImplemented {
+		t.Errorf("response code is %v, expected %v", response.Code, http.StatusNotImplemented)
+	}
+}
+
 func TestPushStackHandler(t *testing.T) {
 	s := pila.NewStack("stack", time.Now().UTC())
 
@@ -1574,6 +1670,33 @@ func TestPushStackHandler_NoElement(t *testing.T) {
 	}
 }
 
+func TestPushStackHandler_TTL(t *testing.T) {
+	conn := NewConn()
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=stack", nil)
+
+	if code := serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"foo"}`)); code != http.StatusOK {
+		t.Errorf("response code is %v, expected %v", code, http.StatusOK)
+	}
+	if code := serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"bar","ttl":0.001}`)); code != http.StatusOK {
+		t.Errorf("response code is %v, expected %v", code, http.StatusOK)
+	}
+	if code := serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"baz","ttl":-1}`)); code != http.StatusBadRequest {
+		t.Errorf("response code is %v, expected %v", code, http.StatusBadRequest)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	db, _ := ResourceDatabase(conn, "db")
+	s, _ := ResourceStack(db, "stack")
+	if s.Size() != 1 || s.Peek() != "foo" {
+		t.Errorf("size is %d and peek %v, expected %d and %v", s.Size(), s.Peek(), 1, "foo")
+	}
+	if n := conn.Pila.Expire(); n != 0 {
+		t.Errorf("expired %d elements, expected %d", n, 0)
+	}
+}
+
 func TestPopStackHandler(t *testing.T) {
 	element := pila.Element{Value: "test-element"}
 	expectedElementJSON, _ := element.ToJSON()
@@ -1671,8 +1794,139 @@ func TestPopStackHandler_EmptyStack(t *testing.T) {
 	}
 }
 
-func TestFlushStackHandler(t *testing.T) {
+func TestPopStackHandler_Wait(t *testing.T) {
+	s := pila.NewStack("stack", time.Now().UTC())
+
+	conn := NewConn()
+
+	request, err := http.NewRequest("DELETE", "/databases/db/stacks/stack?wait=10s", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		conn.popStackHandler(response, request, s)
+		close(done)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	pushedAt := time.Now().UTC()
+	s.Push("foo")
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pop did not return after push")
+	}
+
+	// the stack is updated once the element is popped
+	if s.UpdatedAt.Before(pushedAt) {
+		t.Errorf("stack UpdatedAt is %v, expected after %v", s.UpdatedAt, pushedAt)
+	}
+
+	if response.Code != http.StatusOK {
+		t.Errorf("response code is %v, expected %v", response.Code, http.StatusOK)
+	}
+	if body := response.Body.String(); body != `{"element":"foo"}` {
+		t.Errorf("popped element is %s, expected %s", body, `{"element":"foo"}`)
+	}
+	if s.Size() != 0 {
+		t.Errorf("stack size is %d, expected %d", s.Size(), 0)
+	}
+}
+
+func TestPopStackHandler_WaitTimeout(t *testing.T) {
+	s := pila.NewStack("stack", time.Now().UTC())
+
+	conn := NewConn()
+
+	request, err := http.NewRequest("DELETE", "/databases/db/stacks/stack?wait=50ms", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+
+	start := time.Now()
+	conn.popStackHandler(response, request, s)
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("pop returned after %v, expected at least %v", elapsed, 50*time.Millisecond)
+	}
+	if response.Code != http.StatusNoContent {
+		t.Errorf("response code is %v, expected %v", response.Code, http.StatusNoContent)
+	}
+}
+
+func TestPopStackHandler_WaitFairness(t *testing.T) {
+	s := pila.NewStack("stack", time.Now().UTC())
+
+	conn := NewConn()
+
+	elements := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			request, _ := http.NewRequest("DELETE", "/databases/db/stacks/stack?wait=10s", nil)
+			response := httptest.NewRecorder()
+			conn.popStackHandler(response, request, s)
+			elements <- response.Body.String()
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	s.PushMany([]interface{}{"foo", "bar", "baz"})
+
+	popped := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		select {
+		case element := <-elements:
+			popped[element] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("pop did not return after push")
+		}
+	}
+	if len(popped) != 3 {
+		t.Errorf("popped elements are %v, expected 3 different elements", popped)
+	}
+}
+
+func TestPopWait(t *testing.T) {
+	conn := NewConn()
+	conn.Config.Set(vars.WriteTimeout, 10)
+
+	inputOutput := []struct {
+		input  string
+		output time.Duration
+	}{
+		{"", 0},
+		{"0", 0},
+		{"5", 5 * time.Second},
+		{"500ms", 500 * time.Millisecond},
+		{"1m", 9 * time.Second},
+	}
+
+	for _, io := range inputOutput {
+		request, _ := http.NewRequest("DELETE", "/databases/db/stacks/stack?wait="+io.input, nil)
+		if wait, err := conn.popWait(request); err != nil {
+			t.Errorf("wait=%s: %v", io.input, err)
+		} else if wait != io.output {
+			t.Errorf("wait=%s is %v, expected %v", io.input, wait, io.output)
+		}
+	}
+
+	for _, input := range []string{"foo", "-1s", "-3"} {
+		request, _ := http.NewRequest("DELETE", "/databases/db/stacks/stack?wait="+input, nil)
+		if _, err := conn.popWait(request); err == nil {
+			t.Errorf("wait=%s: err is nil", input)
+		}
+	}
+}
+
+func TestPushManyStackHandler(t *testing.T) {
 	s := pila.NewStack("stack", time.Now().UTC())
+	s.Push("foo")
 
 	db := pila.NewDatabase("db")
 	_ = db.AddStack(s)
@@ -1683,11 +1937,409 @@ func TestFlushStackHandler(t *testing.T) {
 	conn := NewConn()
 	conn.Pila = p
 
-	expectedStackStatusJSON, err := s.Status().ToJSON()
+	params := map[string]string{
+		"database_id": db.Name,
+		"stack_id":    s.Name,
+	}
+
+	elements := `["bar",8,{"baz":true}]`
+	request, err := http.NewRequest("POST",
+		fmt.Sprintf("/databases/%s/stacks/%s?batch", db.Name, s.Name),
+		bytes.NewBuffer([]byte(elements)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+
+	conn.stackHandler(&params).ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("response code is %v, expected %v", response.Code, http.StatusOK)
+	}
+	if contentType := response.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type is %v, expected %v", contentType, "application/json")
+	}
+	if body := response.Body.String(); body != elements {
+		t.Errorf("pushed elements are %s, expected %s", body, elements)
+	}
+
+	if s.Size() != 4 {
+		t.Errorf("stack size is %d, expected %d", s.Size(), 4)
+	}
+	if peek := s.Peek(); !reflect.DeepEqual(peek, map[string]interface{}{"baz": true}) {
+		t.Errorf("stack peek is %v, expected %v", peek, map[string]interface{}{"baz": true})
+	}
+}
+
+func TestPushManyStackHandler_BadDecoding(t *testing.T) {
+	s := pila.NewStack("stack", time.Now().UTC())
+
+	conn := NewConn()
+
+	for _, body := range []string{`{"element":"foo"}`, `["foo"`, ``} {
+		request, err := http.NewRequest("POST", "/databases/db/stacks/stack?batch",
+			bytes.NewBuffer([]byte(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response := httptest.NewRecorder()
+
+		conn.pushManyStackHandler(response, request, s)
+
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("response code for %q is %v, expected %v", body, response.Code, http.StatusBadRequest)
+		}
+	}
+
+	request, err := http.NewRequest("POST", "/databases/db/stacks/stack?batch", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := httptest.NewRecorder()
+
+	conn.pushManyStackHandler(response, request, s)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("response code is %v, expected %v", response.Code, http.StatusBadRequest)
+	}
+	if s.Size() != 0 {
+		t.Errorf("stack size is %d, expected %d", s.Size(), 0)
+	}
+}
+
+func TestPushManyStackHandler_MaxStackSize(t *testing.T) {
+	s := pila.NewStack("stack", time.Now().UTC())
+	s.Push("foo")
+
+	conn := NewConn()
+	conn.Config.Set(vars.MaxStackSize, 3)
+
+	inputOutput := []struct {
+		input string
+		code  int
+		size  int
+	}{
+		{`["bar","baz","qux"]`, http.StatusNotAcceptable, 1},
+		{`["bar","baz"]`, http.StatusOK, 3},
+		{`[]`, http.StatusOK, 3},
+		{`["qux"]`, http.StatusNotAcceptable, 3},
+	}
+
+	for _, io := range inputOutput {
+		request, err := http.NewRequest("POST", "/databases/db/stacks/stack?batch",
+			bytes.NewBuffer([]byte(io.input)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response := httptest.NewRecorder()
+
+		conn.pushManyStackHandler(response, request, s)
+
+		if response.Code != io.code {
+			t.Errorf("response code for %s is %v, expected %v", io.input, response.Code, io.code)
+		}
+		if s.Size() != io.size {
+			t.Errorf("stack size after %s is %d, expected %d", io.input, s.Size(), io.size)
+		}
+	}
+}
+
+func TestPopManyStackHandler(t *testing.T) {
+	s := pila.NewStack("stack", time.Now().UTC())
+	s.PushMany([]interface{}{"foo", "bar", "baz"})
+
+	db := pila.NewDatabase("db")
+	_ = db.AddStack(s)
+
+	p := pila.NewPila()
+	_ = p.AddDatabase(db)
+
+	conn := NewConn()
+	conn.Pila = p
+
+	params := map[string]string{
+		"database_id": db.Name,
+		"stack_id":    s.Name,
+	}
+
+	inputOutput := []struct {
+		input    string
+		response string
+		code     int
+	}{
+		{"2", `["baz","bar"]`, http.StatusOK},
+		{"5", `["foo"]`, http.StatusOK},
+		{"1", "", http.StatusNoContent},
+	}
+
+	for _, io := range inputOutput {
+		request, err := http.NewRequest("DELETE",
+			fmt.Sprintf("/databases/%s/stacks/%s?pop=%s", db.Name, s.Name, io.input), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response := httptest.NewRecorder()
+
+		conn.stackHandler(&params).ServeHTTP(response, request)
+
+		if response.Code != io.code {
+			t.Errorf("response code for pop=%s is %v, expected %v", io.input, response.Code, io.code)
+		}
+		if body := response.Body.String(); body != io.response {
+			t.Errorf("popped elements for pop=%s are %s, expected %s", io.input, body, io.response)
+		}
+	}
+}
+
+func TestPopManyStackHandler_BadCount(t *testing.T) {
+	s := pila.NewStack("stack", time.Now().UTC())
+	s.Push("foo")
+
+	conn := NewConn()
+
+	for _, n := range []string{"", "0", "-1", "foo"} {
+		request, err := http.NewRequest("DELETE", "/databases/db/stacks/stack?pop="+n, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response := httptest.NewRecorder()
+
+		conn.popManyStackHandler(response, request, s)
+
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("response code for pop=%s is %v, expected %v", n, response.Code, http.StatusBadRequest)
+		}
+	}
+
+	if s.Size() != 1 {
+		t.Errorf("stack size is %d, expected %d", s.Size(), 1)
+	}
+}
+
+func TestMoveStackHandler(t *testing.T) {
+	p := pila.NewPila()
+	db := pila.NewDatabase("db")
+	db2 := pila.NewDatabase("db2")
+	_ = p.AddDatabase(db)
+	_ = p.AddDatabase(db2)
+
+	src := pila.NewStack("src", time.Now().UTC())
+	src.PushMany([]interface{}{"foo", "bar"})
+	dst := pila.NewStack("dst", time.Now().UTC())
+	other := pila.NewStack("other", time.Now().UTC())
+	_ = db.AddStack(src)
+	_ = db.AddStack(dst)
+	_ = db2.AddStack(other)
+
+	conn := NewConn()
+	conn.Pila = p
+
+	params := map[string]string{
+		"database_id": db.Name,
+		"stack_id":    src.Name,
+	}
+
+	inputOutput := []struct {
+		query    string
+		response string
+		code     int
+	}{
+		{"to=dst", `{"element":"bar"}`, http.StatusOK},
+		{"to=" + other.ID.String() + "&database=db2", `{"element":"foo"}`, http.StatusOK},
+		{"to=dst", "", http.StatusNoContent},
+		{"", `{"type":"about:blank","title":"Bad Request","status":400,"code":"missing_parameter","detail":"missing destination stack","instance":"/databases/db/stacks/src/_move"}`, http.StatusBadRequest},
+		{"to=nostack", `{"type":"about:blank","title":"Gone","status":410,"code":"stack_gone","detail":"stack nostack is Gone","instance":"/databases/db/stacks/src/_move"}`, http.StatusGone},
+		{"to=dst&database=nodb", `{"type":"about:blank","title":"Gone","status":410,"code":"database_gone","detail":"database nodb is Gone","instance":"/databases/db/stacks/src/_move"}`, http.StatusGone},
+	}
+
+	for _, io := range inputOutput {
+		request, err := http.NewRequest("POST",
+			fmt.Sprintf("/databases/%s/stacks/%s/_move?%s", db.Name, src.Name, io.query), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response := httptest.NewRecorder()
+
+		conn.moveStackHandler(&params).ServeHTTP(response, request)
+
+		if response.Code != io.code {
+			t.Errorf("response code for %s is %v, expected %v", io.query, response.Code, io.code)
+		}
+		if body := response.Body.String(); body != io.response {
+			t.Errorf("moved element for %s is %s, expected %s", io.query, body, io.response)
+		}
+	}
+
+	if dst.Size() != 1 || dst.Peek() != "bar" {
+		t.Errorf("dst size is %d and peek %v, expected %d and %v", dst.Size(), dst.Peek(), 1, "bar")
+	}
+	if other.Size() != 1 || other.Peek() != "foo" {
+		t.Errorf("other size is %d and peek %v, expected %d and %v", other.Size(), other.Peek(), 1, "foo")
+	}
+}
+
+func TestMoveStackHandler_Gone(t *testing.T) {
+	db := pila.NewDatabase("db")
+	p := pila.NewPila()
+	_ = p.AddDatabase(db)
+
+	conn := NewConn()
+	conn.Pila = p
+
+	for _, params := range []map[string]string{
+		{"database_id": "nodb", "stack_id": "src"},
+		{"database_id": "db", "stack_id": "nostack"},
+	} {
+		request, err := http.NewRequest("POST", "/databases/db/stacks/src/_move?to=dst", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response := httptest.NewRecorder()
+
+		conn.moveStackHandler(&params).ServeHTTP(response, request)
+
+		if response.Code != http.StatusGone {
+			t.Errorf("response code is %v, expected %v", response.Code, http.StatusGone)
+		}
+	}
+}
+
+func TestMoveStackHandler_MaxStackSize(t *testing.T) {
+	src := pila.NewStack("src", time.Now().UTC())
+	src.Push("foo")
+	dst := pila.NewStack("dst", time.Now().UTC())
+	dst.PushMany([]interface{}{"one", "two"})
+
+	db := pila.NewDatabase("db")
+	_ = db.AddStack(src)
+	_ = db.AddStack(dst)
+	p := pila.NewPila()
+	_ = p.AddDatabase(db)
+
+	conn := NewConn()
+	conn.Pila = p
+	conn.Config.Set(vars.MaxStackSize, 2)
+
+	params := map[string]string{
+		"database_id": db.Name,
+		"stack_id":    src.Name,
+	}
+	request, err := http.NewRequest("POST", "/databases/db/stacks/src/_move?to=dst", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := httptest.NewRecorder()
+
+	conn.moveStackHandler(&params).ServeHTTP(response, request)
+
+	if response.Code != http.StatusNotAcceptable {
+		t.Errorf("response code is %v, expected %v", response.Code, http.StatusNotAcceptable)
+	}
+	if src.Size() != 1 || dst.Size() != 2 {
+		t.Errorf("sizes are %d and %d, expected %d and %d", src.Size(), dst.Size(), 1, 2)
+	}
+}
+
+func TestTxHandler(t *testing.T) {
+	a := pila.NewStack("a", time.Now().UTC())
+	a.PushMany([]interface{}{"foo", "bar"})
+	b := pila.NewStack("b", time.Now().UTC())
+
+	db := pila.NewDatabase("db")
+	_ = db.AddStack(a)
+	_ = db.AddStack(b)
+	p := pila.NewPila()
+	_ = p.AddDatabase(db)
+
+	conn := NewConn()
+	conn.Pila = p
+	conn.Config.Set(vars.MaxStackSize, 2)
+
+	inputOutput := []struct {
+		input    string
+		response string
+		code     int
+	}{
+		{`[{"op":"pop","stack":"a"},{"op":"push","stack":"b","element":"x"},{"op":"push","stack":"b","element":"y"}]`,
+			`{"committed":true,"results":[{"op":"pop","stack":"a","element":"bar","size":1},{"op":"push","stack":"b","element":"x","size":1},{"op":"push","stack":"b","element":"y","size":2}]}`,
+			http.StatusOK},
+		{`[{"op":"flush","stack":"a"},{"op":"push","stack":"b","element":"z"}]`,
+			`{"type":"about:blank","title":"Conflict","status":409,"code":"transaction_aborted","detail":"stack is full","instance":"/databases/db/_tx","committed":false,"results":[{"op":"flush","stack":"a","size":0},{"op":"push","stack":"b","size":2,"error":"stack is full"}]}`,
+			http.StatusConflict},
+		{`[{"op":"pop","stack":"nostack"}]`,
+			`{"type":"about:blank","title":"Conflict","status":409,"code":"transaction_aborted","detail":"database db does not contain stack nostack","instance":"/databases/db/_tx","committed":false,"results":[{"op":"pop","stack":"nostack","size":0,"error":"database db does not contain stack nostack"}]}`,
+			http.StatusConflict},
+		{`[]`, `{"committed":true,"results":[]}`, http.StatusOK},
+		{`[{"op":"peek","stack":"a"}]`,
+			`{"type":"about:blank","title":"Bad Request","status":400,"code":"invalid_operation","detail":"unknown operation \"peek\"","instance":"/databases/db/_tx"}`,
+			http.StatusBadRequest},
+		{`[{"op":"pop"}]`,
+			`{"type":"about:blank","title":"Bad Request","status":400,"code":"invalid_operation","detail":"operation pop has no stack","instance":"/databases/db/_tx"}`,
+			http.StatusBadRequest},
+		{`{"op":"pop","stack":"a"}`,
+			`{"type":"about:blank","title":"Bad Request","status":400,"code":"malformed_body","detail":"error on decoding operations: json: cannot unmarshal object into Go value of type []pila.TxOp","instance":"/databases/db/_tx"}`,
+			http.StatusBadRequest},
+	}
+
+	for _, io := range inputOutput {
+		request, err := http.NewRequest("POST", "/databases/db/_tx", bytes.NewBuffer([]byte(io.input)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response := httptest.NewRecorder()
+
+		conn.txHandler(db.Name).ServeHTTP(response, request)
+
+		if response.Code != io.code {
+			t.Errorf("response code for %s is %v, expected %v", io.input, response.Code, io.code)
+		}
+		if body := response.Body.String(); body != io.response {
+			t.Errorf("response for %s is %s, expected %s", io.input, body, io.response)
+		}
+	}
+
+	if a.Size() != 1 || b.Size() != 2 {
+		t.Errorf("sizes are %d and %d, expected %d and %d", a.Size(), b.Size(), 1, 2)
+	}
+}
+
+func TestTxHandler_Gone(t *testing.T) {
+	conn := NewConn()
+	request, err := http.NewRequest("POST", "/databases/nodb/_tx", bytes.NewBuffer([]byte(`[]`)))
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	response := httptest.NewRecorder()
+
+	conn.txHandler("nodb").ServeHTTP(response, request)
+
+	if response.Code != http.StatusGone {
+		t.Errorf("response code is %v, expected %v", response.Code, http.StatusGone)
+	}
+}
+
+func TestFlushStackHandler(t *testing.T) {
+	s := pila.NewStack("stack", time.Now().UTC())
+
+	db := pila.NewDatabase("db")
+	_ = db.AddStack(s)
+
+	p := pila.NewPila()
+	_ = p.AddDatabase(db)
+
+	conn := NewConn()
+	conn.Pila = p
+
 	s.Push("one")
 	s.Push("two")
 	s.Push("three")
@@ -1738,6 +2390,11 @@ func TestFlushStackHandler(t *testing.T) {
 			t.Fatal(err)
 		}
 
+		expectedStackStatusJSON, err := s.Status().ToJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+
 		if string(stackStatusJSON) != string(expectedStackStatusJSON) {
 			t.Errorf("stack status is %s, expected %s", string(stackStatusJSON), string(expectedStackStatusJSON))
 		}
@@ -1841,7 +2498,7 @@ func TestGoneHandler(t *testing.T) {
 	}
 	response := httptest.NewRecorder()
 
-	conn.goneHandler(response, request, "database nodb is Gone")
+	conn.goneHandler(response, request, problemDatabaseGone, "database nodb is Gone")
 
 	if response.Code != http.StatusGone {
 		t.Errorf("response code is %v, expected %v", response.Code, http.StatusNotFound)
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fern4lvarez/piladb/config/vars"
+	"github.com/fern4lvarez/piladb/pila"
+	"github.com/fern4lvarez/piladb/pkg/stack"
+	"github.com/fern4lvarez/piladb/pkg/uuid"
+)
+
+// Engines are the stack.Stacker implementations that can be
+// chosen as base of a Stack at creation time. Stacks created
+// without an engine use the one set in STACK_ENGINE.
+const (
+	// MemoryEngine stores elements in memory, in a linked list.
+	MemoryEngine = "memory"
+	// SliceEngine stores elements in memory, in a growable slice.
+	// It avoids an allocation per element, which suits stacks
+	// receiving large amounts of small elements.
+	SliceEngine = "slice"
+	// DiskEngine stores elements in segment files on disk,
+	// under the directory set in DISK_PATH.
+	DiskEngine = "disk"
+	// RingEngine stores up to a fixed number of elements in
+	// memory, in a ring buffer, discarding the bottom element
+	// on overflow. It is the engine of the evict overflow policy.
+	RingEngine = "ring"
+)
+
+// newBase returns a new stack.Stacker base given the name of an engine,
+// the Database name, the Stack name and its capacity, which is only used
+// by bounded engines. An empty engine stands for the memory engine, which
+// was the only one available in older snapshots and append-only files.
+func (c *Conn) newBase(engine, databaseName, stackName string, capacity int) (stack.Stacker, error) {
+	switch engine {
+	case "", MemoryEngine:
+		return stack.NewStack(), nil
+	case SliceEngine:
+		return stack.NewSliceStack(stack.DefaultChunkSize), nil
+	case DiskEngine:
+		dir, err := c.diskDir(databaseName, stackName)
+		if err != nil {
+			return nil, err
+		}
+		return stack.NewDiskStack(dir, stack.DefaultSegmentSize)
+	case RingEngine:
+		return stack.NewRingStack(capacity)
+	}
+
+	return nil, fmt.Errorf("unknown engine %s", engine)
+}
+
+// reserveStack reserves the name of a Stack being created in a
+// Database, so no other Stack with the same name is created until the
+// returned function releases it. It returns an error if the Database
+// already contains such Stack, or if it is being created.
+func (c *Conn) reserveStack(db *pila.Database, name string) (func(), error) {
+	dir := stackDir(db.Name, name)
+
+	c.creatingMu.Lock()
+	defer c.creatingMu.Unlock()
+
+	if _, ok := ResourceStack(db, name); ok || c.creating[dir] {
+		return nil, fmt.Errorf("database %v already contains stack %v", db.Name, name)
+	}
+	if c.creating == nil {
+		c.creating = make(map[string]bool)
+	}
+	c.creating[dir] = true
+
+	return func() {
+		c.creatingMu.Lock()
+		defer c.creatingMu.Unlock()
+		delete(c.creating, dir)
+	}, nil
+}
+
+// closeBase releases the resources of a base whose Stack could not
+// be created, if it implements io.Closer.
+func closeBase(base stack.Stacker) {
+	if closer, ok := base.(io.Closer); ok {
+		// Do not check error as the base
+		// is not used anyway.
+		_ = closer.Close()
+	}
+}
+
+// openBase returns the base of a Stack restored from the checkpoint
+// directory where its elements were saved, or a new base given by
+// newBase if checkpoint is empty. Only the disk engine supports
+// checkpoints.
+func (c *Conn) openBase(engine, databaseName, stackName string, capacity int, checkpoint string) (stack.Stacker, error) {
+	if checkpoint == "" {
+		return c.newBase(engine, databaseName, stackName, capacity)
+	}
+	if engine != DiskEngine {
+		return nil, fmt.Errorf("engine %s does not support checkpoints", engineName(engine))
+	}
+
+	dir, err := c.diskDir(databaseName, stackName)
+	if err != nil {
+		return nil, err
+	}
+	return stack.OpenDiskStack(dir, stack.DefaultSegmentSize, checkpoint)
+}
+
+// diskDir returns the directory of a disk Stack given the
+// Database name and the Stack name, under DISK_PATH.
+func (c *Conn) diskDir(databaseName, stackName string) (string, error) {
+	path := c.Config.DiskPath()
+	if path == "" {
+		return "", errors.New(vars.DiskPath + " is not set")
+	}
+	return filepath.Join(path, stackDir(databaseName, stackName)), nil
+}
+
+// stackDir returns the relative directory of a disk Stack, nested
+// under the one of its Database, so Stacks whose names concatenated
+// to the ones of their Databases are equal do not share it.
+func stackDir(databaseName, stackName string) string {
+	return filepath.Join(uuid.New(databaseName).String(), uuid.New(stackName).String())
+}
+
+// checkpointsDir is the directory under DISK_PATH where the
+// elements of disk Stacks are saved on snapshots and rewrites
+// of the append-only file.
+const checkpointsDir = "_checkpoints"
+
+// checkpointName returns a unique name for the checkpoints
+// of disk Stacks taken for a kind of persistence, e.g. aof.
+func checkpointName(kind string) string {
+	return fmt.Sprintf("%s-%d", kind, time.Now().UnixNano())
+}
+
+// checkpoint returns the pila.CheckpointFunc that saves the elements
+// of disk Stacks under the checkpoints given by name, or nil if
+// DISK_PATH is not set.
+func (c *Conn) checkpoint(name string) pila.CheckpointFunc {
+	path := c.Config.DiskPath()
+	if path == "" {
+		return nil
+	}
+	return func(databaseName, stackName string) string {
+		return filepath.Join(path, checkpointsDir, name, stackDir(databaseName, stackName))
+	}
+}
+
+// removeCheckpoints removes the checkpoints of disk Stacks whose names
+// start by prefix, except the one given by keep, once they are not
+// referenced anymore.
+func (c *Conn) removeCheckpoints(prefix, keep string) {
+	path := c.Config.DiskPath()
+	if path == "" {
+		return
+	}
+
+	dirs, err := ioutil.ReadDir(filepath.Join(path, checkpointsDir))
+	if err != nil && !os.IsNotExist(err) {
+		c.Logger.Error("error on removing checkpoints", "error", err)
+		return
+	}
+	for _, dir := range dirs {
+		if !strings.HasPrefix(dir.Name(), prefix) || dir.Name() == keep {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(path, checkpointsDir, dir.Name())); err != nil {
+			c.Logger.Error("error on removing checkpoints", "checkpoint", dir.Name(), "error", err)
+		}
+	}
+}
+
+// removeCheckpoint removes the checkpoints of disk Stacks given
+// by name, e.g. once the persistence they were taken for failed.
+func (c *Conn) removeCheckpoint(name string) {
+	path := c.Config.DiskPath()
+	if path == "" {
+		return
+	}
+	if err := os.RemoveAll(filepath.Join(path, checkpointsDir, name)); err != nil {
+		c.Logger.Error("error on removing checkpoints", "checkpoint", name, "error", err)
+	}
+}
+
+// engineName returns the name of an engine, resolving an
+// empty one to the memory engine.
+func engineName(engine string) string {
+	if engine == "" {
+		return MemoryEngine
+	}
+	return engine
+}
+
+// restoreBase returns the base of a Stack being restored from a
+// snapshot, using the same engine it had when the snapshot was taken.
+func (c *Conn) restoreBase(database string, ss pila.StackSnapshot) (stack.Stacker, error) {
+	return c.openBase(ss.Engine, database, ss.Name, ss.Capacity, ss.Checkpoint)
+}
+
+// stackOptions returns the engine, the overflow policy and the capacity
+// of a Stack being created, given the engine, overflow and capacity
+// parameters of the request.
+func (c *Conn) stackOptions(r *http.Request) (engine, overflow string, capacity int, err error) {
+	return c.parseStackOptions(r.FormValue("engine"), r.FormValue("overflow"), r.FormValue("capacity"))
+}
+
+// parseStackOptions returns the engine, the overflow policy and the
+// capacity of a Stack being created, given their values, empty if
+// unset. The evict overflow policy is only provided by the ring engine,
+// so setting either one implies the other.
+func (c *Conn) parseStackOptions(engine, overflow, capacityValue string) (string, string, int, error) {
+	var capacity int
+	var err error
+	if engine == "" {
+		engine = c.Config.StackEngine()
+		if overflow == pila.OverflowEvict {
+			engine = RingEngine
+		}
+	}
+
+	switch overflow {
+	case "":
+		if engine == RingEngine {
+			overflow = pila.OverflowEvict
+		}
+	case pila.OverflowReject:
+		if engine == RingEngine {
+			return "", "", 0, fmt.Errorf("engine %s requires overflow policy %s", RingEngine, pila.OverflowEvict)
+		}
+	case pila.OverflowEvict:
+		if engine != RingEngine {
+			return "", "", 0, fmt.Errorf("overflow policy %s requires engine %s", pila.OverflowEvict, RingEngine)
+		}
+	default:
+		return "", "", 0, fmt.Errorf("unknown overflow policy %s", overflow)
+	}
+
+	if engine != RingEngine {
+		return engine, overflow, 0, nil
+	}
+
+	// capacity defaults to MAX_STACK_SIZE, and cannot exceed it
+	maxSize := c.Config.MaxStackSize()
+	capacity = maxSize
+	if capacityValue != "" {
+		if capacity, err = strconv.Atoi(capacityValue); err != nil {
+			return "", "", 0, fmt.Errorf("invalid capacity %s", capacityValue)
+		}
+	}
+	if maxSize != -1 && capacity > maxSize {
+		return "", "", 0, fmt.Errorf("capacity %d exceeds %s", capacity, vars.MaxStackSize)
+	}
+	if capacity < 1 {
+		return "", "", 0, fmt.Errorf("engine %s requires a positive capacity", RingEngine)
+	}
+
+	return engine, overflow, capacity, nil
+}
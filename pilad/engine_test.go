@@ -0,0 +1,486 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/config/vars"
+	"github.com/fern4lvarez/piladb/pila"
+	"github.com/fern4lvarez/piladb/pkg/stack"
+	"github.com/fern4lvarez/piladb/pkg/uuid"
+)
+
+func diskTestConn(t *testing.T) (*Conn, string) {
+	dir, err := ioutil.TempDir("", "piladb-engine")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := NewConn()
+	conn.Config.Set(vars.DiskPath, filepath.Join(dir, "disk"))
+	return conn, dir
+}
+
+func TestNewBase(t *testing.T) {
+	conn, dir := diskTestConn(t)
+	defer os.RemoveAll(dir)
+
+	for _, engine := range []string{"", MemoryEngine} {
+		base, err := conn.newBase(engine, "db", "stack", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := base.(*stack.Stack); !ok {
+			t.Errorf("base of engine %q is %T, expected %T", engine, base, &stack.Stack{})
+		}
+	}
+
+	base, err := conn.newBase(SliceEngine, "db", "stack", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := base.(*stack.SliceStack); !ok {
+		t.Errorf("base of engine %q is %T, expected %T", SliceEngine, base, &stack.SliceStack{})
+	}
+
+	base, err = conn.newBase(DiskEngine, "db", "stack", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := base.(*stack.DiskStack); !ok {
+		t.Errorf("base of engine %q is %T, expected %T", DiskEngine, base, &stack.DiskStack{})
+	}
+}
+
+func TestDiskDir(t *testing.T) {
+	conn, dir := diskTestConn(t)
+	defer os.RemoveAll(dir)
+
+	// db "ab" with stack "c" and db "a" with stack "bc" do
+	// not share the directory of their segments
+	abc, err := conn.diskDir("ab", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := conn.diskDir("a", "bc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if abc == bc {
+		t.Errorf("directories are both %s", abc)
+	}
+	if expected := filepath.Join(dir, "disk", uuid.New("ab").String()); filepath.Dir(abc) != expected {
+		t.Errorf("directory is %s, expected it under %s", abc, expected)
+	}
+}
+
+func TestReserveStack(t *testing.T) {
+	conn := NewConn()
+	db := pila.NewDatabase("db")
+	_ = conn.Pila.AddDatabase(db)
+	_ = db.AddStack(pila.NewStack("stack", time.Now()))
+
+	if _, err := conn.reserveStack(db, "stack"); err == nil {
+		t.Error("err is nil, expected error on existing stack")
+	}
+
+	release, err := conn.reserveStack(db, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.reserveStack(db, "new"); err == nil {
+		t.Error("err is nil, expected error on reserved stack")
+	}
+	release()
+	release, err = conn.reserveStack(db, "new")
+	if err != nil {
+		t.Errorf("err is %v, expected nil once released", err)
+	}
+	release()
+}
+
+func TestNewBase_Error(t *testing.T) {
+	conn := NewConn()
+
+	if _, err := conn.newBase(DiskEngine, "db", "stack", 0); err == nil {
+		t.Error("err is nil")
+	}
+	if _, err := conn.newBase("foo", "db", "stack", 0); err == nil {
+		t.Error("err is nil")
+	}
+}
+
+func TestEngineName(t *testing.T) {
+	inputOutput := []struct {
+		input, output string
+	}{
+		{"", MemoryEngine},
+		{MemoryEngine, MemoryEngine},
+		{SliceEngine, SliceEngine},
+		{DiskEngine, DiskEngine},
+	}
+
+	for _, io := range inputOutput {
+		if name := engineName(io.input); name != io.output {
+			t.Errorf("engineName(%q) is %s, expected %s", io.input, name, io.output)
+		}
+	}
+}
+
+func TestCreateStackHandler_Engine(t *testing.T) {
+	conn, dir := diskTestConn(t)
+	defer os.RemoveAll(dir)
+
+	requests := []struct {
+		method, url string
+		code        int
+	}{
+		{"PUT", "/databases?name=db", http.StatusCreated},
+		{"PUT", "/databases/db/stacks?name=memory&engine=memory", http.StatusCreated},
+		{"PUT", "/databases/db/stacks?name=disk&engine=disk", http.StatusCreated},
+		{"PUT", "/databases/db/stacks?name=disk&engine=disk", http.StatusConflict},
+		{"PUT", "/databases/db/stacks?name=foo&engine=foo", http.StatusBadRequest},
+	}
+
+	for _, r := range requests {
+		if code := serve(t, conn, r.method, r.url, nil); code != r.code {
+			t.Errorf("%s %s response code is %d, expected %d", r.method, r.url, code, r.code)
+		}
+	}
+
+	db, _ := ResourceDatabase(conn, "db")
+	for _, name := range []string{"memory", "disk"} {
+		s, ok := ResourceStack(db, name)
+		if !ok {
+			t.Fatalf("stack %s was not created", name)
+		}
+		if s.Engine != name {
+			t.Errorf("stack engine is %s, expected %s", s.Engine, name)
+		}
+	}
+	if _, ok := ResourceStack(db, "foo"); ok {
+		t.Error("stack foo was created")
+	}
+
+	// the conflicting request did not take over the existing stack
+	serve(t, conn, "POST", "/databases/db/stacks/disk", []byte(`{"element":"foo"}`))
+	serve(t, conn, "PUT", "/databases/db/stacks?name=disk&engine=disk", nil)
+	disk, _ := ResourceStack(db, "disk")
+	if disk.Peek() != "foo" {
+		t.Errorf("stack peek is %v, expected %v", disk.Peek(), "foo")
+	}
+}
+
+func TestCreateStackHandler_DefaultEngine(t *testing.T) {
+	conn := NewConn()
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=memory", nil)
+
+	conn.Config.Set(vars.StackEngine, SliceEngine)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=slice", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=explicit&engine=memory", nil)
+
+	db, _ := ResourceDatabase(conn, "db")
+	for name, engine := range map[string]string{
+		"memory":   MemoryEngine,
+		"slice":    SliceEngine,
+		"explicit": MemoryEngine,
+	} {
+		s, ok := ResourceStack(db, name)
+		if !ok {
+			t.Fatalf("stack %s was not created", name)
+		}
+		if s.Engine != engine {
+			t.Errorf("stack %s engine is %s, expected %s", name, s.Engine, engine)
+		}
+	}
+
+	// an unknown default engine falls back to the default one
+	conn.Config.Set(vars.StackEngine, "foo")
+	url := "/databases/db/stacks?name=foo"
+	if code := serve(t, conn, "PUT", url, nil); code != http.StatusCreated {
+		t.Errorf("response code is %d, expected %d", code, http.StatusCreated)
+	}
+	if s, _ := ResourceStack(db, "foo"); s == nil || s.Engine != vars.StackEngineDefault {
+		t.Errorf("stack foo is %v, expected engine %s", s, vars.StackEngineDefault)
+	}
+}
+
+func TestCreateStackHandler_DiskPathNotSet(t *testing.T) {
+	conn := NewConn()
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+
+	url := "/databases/db/stacks?name=disk&engine=disk"
+	if code := serve(t, conn, "PUT", url, nil); code != http.StatusBadRequest {
+		t.Errorf("response code is %d, expected %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestSnapshotRestore_Engine(t *testing.T) {
+	conn, dir := diskTestConn(t)
+	defer os.RemoveAll(dir)
+	conn.Config.Set(vars.SnapshotPath, filepath.Join(dir, "piladb.snapshot"))
+
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=disk&engine=disk", nil)
+	serve(t, conn, "POST", "/databases/db/stacks/disk", []byte(`{"element":"foo"}`))
+
+	db, _ := ResourceDatabase(conn, "db")
+	s, _ := ResourceStack(db, "disk")
+	elements := make([]interface{}, 3*stack.DefaultSegmentSize)
+	for i := range elements {
+		elements[i] = "bar"
+	}
+	s.PushMany(elements)
+	s.Push("foo")
+
+	// the previous checkpoint is removed
+	for i := 0; i < 2; i++ {
+		if _, err := conn.snapshot(time.Now().UTC()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := checkpoints(t, conn); n != 1 {
+		t.Errorf("there are %d checkpoints, expected %d", n, 1)
+	}
+	b, err := ioutil.ReadFile(conn.Config.SnapshotPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("bar")) || !bytes.Contains(b, []byte(`"checkpoint":"`)) {
+		t.Errorf("snapshot is %s, expected disk stack to be checkpointed", b)
+	}
+
+	// the checkpoint does not change with the stack
+	serve(t, conn, "DELETE", "/databases/db/stacks/disk?full", nil)
+
+	restored := NewConn()
+	restored.Config.Set(vars.SnapshotPath, conn.Config.SnapshotPath())
+	restored.Config.Set(vars.DiskPath, filepath.Join(dir, "restored"))
+	if err := restored.restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertDiskStack(t, restored)
+	db, _ = ResourceDatabase(restored, "db")
+	s, _ = ResourceStack(db, "disk")
+	if size := s.Size(); size != len(elements)+2 {
+		t.Errorf("stack size is %d, expected %d", size, len(elements)+2)
+	}
+}
+
+func checkpoints(t *testing.T, conn *Conn) int {
+	dirs, err := ioutil.ReadDir(filepath.Join(conn.Config.DiskPath(), checkpointsDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(dirs)
+}
+
+func TestAOF_Engine(t *testing.T) {
+	conn, dir := diskTestConn(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+	conn.Config.Set(vars.AOFPath, path)
+	if err := conn.openAOF(); err != nil {
+		t.Fatal(err)
+	}
+
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=disk&engine=disk", nil)
+	serve(t, conn, "POST", "/databases/db/stacks/disk", []byte(`{"element":"foo"}`))
+	for i := 0; i < 2; i++ {
+		if err := conn.rewriteAOF(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := checkpoints(t, conn); n != 1 {
+		t.Errorf("there are %d checkpoints, expected %d", n, 1)
+	}
+	serve(t, conn, "POST", "/databases/db/stacks/disk", []byte(`{"element":"bar"}`))
+	serve(t, conn, "DELETE", "/databases/db/stacks/disk", nil)
+	conn.aof.Close()
+
+	replayed := NewConn()
+	replayed.Config.Set(vars.AOFPath, path)
+	replayed.Config.Set(vars.DiskPath, filepath.Join(dir, "replayed"))
+	if err := replayed.openAOF(); err != nil {
+		t.Fatal(err)
+	}
+	defer replayed.aof.Close()
+
+	assertDiskStack(t, replayed)
+}
+
+func assertDiskStack(t *testing.T, conn *Conn) {
+	db, ok := ResourceDatabase(conn, "db")
+	if !ok {
+		t.Fatal("database db was not restored")
+	}
+	s, ok := ResourceStack(db, "disk")
+	if !ok {
+		t.Fatal("stack disk was not restored")
+	}
+	if s.Engine != DiskEngine {
+		t.Errorf("stack engine is %s, expected %s", s.Engine, DiskEngine)
+	}
+	if s.Peek() != "foo" {
+		t.Errorf("stack peek is %v, expected %v", s.Peek(), "foo")
+	}
+}
+
+func TestPushStackHandler_TTLNotImplemented(t *testing.T) {
+	conn, dir := diskTestConn(t)
+	defer os.RemoveAll(dir)
+
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=disk&engine=disk", nil)
+	if code := serve(t, conn, "POST", "/databases/db/stacks/disk", []byte(`{"element":"foo","ttl":60}`)); code != http.StatusNotImplemented {
+		t.Errorf("response code is %v, expected %v", code, http.StatusNotImplemented)
+	}
+
+	db, _ := ResourceDatabase(conn, "db")
+	s, _ := ResourceStack(db, "disk")
+	if s.Size() != 0 {
+		t.Errorf("size is %d, expected %d", s.Size(), 0)
+	}
+}
+
+func TestStackOptions(t *testing.T) {
+	conn := NewConn()
+	conn.Config.Set(vars.MaxStackSize, 10)
+
+	inputOutput := []struct {
+		query    string
+		engine   string
+		overflow string
+		capacity int
+	}{
+		{"", MemoryEngine, "", 0},
+		{"engine=slice", SliceEngine, "", 0},
+		{"overflow=reject", MemoryEngine, pila.OverflowReject, 0},
+		{"overflow=evict", RingEngine, pila.OverflowEvict, 10},
+		{"overflow=evict&capacity=5", RingEngine, pila.OverflowEvict, 5},
+		{"engine=ring&capacity=3", RingEngine, pila.OverflowEvict, 3},
+		{"engine=ring&overflow=evict", RingEngine, pila.OverflowEvict, 10},
+	}
+
+	for _, io := range inputOutput {
+		request, _ := http.NewRequest("PUT", "/databases/db/stacks?name=s&"+io.query, nil)
+		engine, overflow, capacity, err := conn.stackOptions(request)
+		if err != nil {
+			t.Errorf("%s: %v", io.query, err)
+			continue
+		}
+		if engine != io.engine || overflow != io.overflow || capacity != io.capacity {
+			t.Errorf("%s: options are %s, %s and %d, expected %s, %s and %d", io.query,
+				engine, overflow, capacity, io.engine, io.overflow, io.capacity)
+		}
+	}
+}
+
+func TestStackOptions_Error(t *testing.T) {
+	conn := NewConn()
+	conn.Config.Set(vars.MaxStackSize, 10)
+
+	for _, query := range []string{
+		"overflow=foo",
+		"overflow=evict&engine=memory",
+		"overflow=reject&engine=ring",
+		"overflow=evict&capacity=foo",
+		"overflow=evict&capacity=11",
+		"overflow=evict&capacity=0",
+	} {
+		request, _ := http.NewRequest("PUT", "/databases/db/stacks?name=s&"+query, nil)
+		if _, _, _, err := conn.stackOptions(request); err == nil {
+			t.Errorf("%s: err is nil", query)
+		}
+	}
+
+	// ring stacks have no default capacity without MAX_STACK_SIZE
+	conn.Config.Set(vars.MaxStackSize, -1)
+	conn.Config.Set(vars.StackEngine, RingEngine)
+	request, _ := http.NewRequest("PUT", "/databases/db/stacks?name=s", nil)
+	if _, _, _, err := conn.stackOptions(request); err == nil {
+		t.Error("err is nil, expected error")
+	}
+}
+
+func TestCreateStackHandler_Overflow(t *testing.T) {
+	conn := NewConn()
+	conn.Config.Set(vars.MaxStackSize, 2)
+
+	requests := []struct {
+		method, url string
+		body        string
+		code        int
+	}{
+		{"PUT", "/databases?name=db", "", http.StatusCreated},
+		{"PUT", "/databases/db/stacks?name=reject", "", http.StatusCreated},
+		{"PUT", "/databases/db/stacks?name=evict&overflow=evict", "", http.StatusCreated},
+		{"PUT", "/databases/db/stacks?name=default-capacity&overflow=evict", "", http.StatusCreated},
+		{"PUT", "/databases/db/stacks?name=foo&overflow=foo", "", http.StatusBadRequest},
+		{"POST", "/databases/db/stacks/reject", `{"element":1}`, http.StatusOK},
+		{"POST", "/databases/db/stacks/reject", `{"element":2}`, http.StatusOK},
+		{"POST", "/databases/db/stacks/reject", `{"element":3}`, http.StatusNotAcceptable},
+		{"POST", "/databases/db/stacks/evict", `{"element":1}`, http.StatusOK},
+		{"POST", "/databases/db/stacks/evict", `{"element":2}`, http.StatusOK},
+		{"POST", "/databases/db/stacks/evict", `{"element":3}`, http.StatusOK},
+	}
+
+	for _, r := range requests {
+		if code := serve(t, conn, r.method, r.url, []byte(r.body)); code != r.code {
+			t.Errorf("%s %s response code is %d, expected %d", r.method, r.url, code, r.code)
+		}
+	}
+
+	db, _ := ResourceDatabase(conn, "db")
+	evict, _ := ResourceStack(db, "evict")
+	if evict.Size() != 2 || evict.Peek() != 3.0 {
+		t.Errorf("stack size is %d and peek %v, expected %d and %v", evict.Size(), evict.Peek(), 2, 3)
+	}
+
+	status := evict.Status()
+	if status.Overflow != pila.OverflowEvict || status.Capacity != 2 {
+		t.Errorf("status overflow and capacity are %s and %d, expected %s and %d",
+			status.Overflow, status.Capacity, pila.OverflowEvict, 2)
+	}
+}
+
+func TestAOF_Overflow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-engine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	conn := aofTestConn(t, path)
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=evict&overflow=evict&capacity=2", nil)
+	for _, element := range []string{"foo", "bar", "baz"} {
+		serve(t, conn, "POST", "/databases/db/stacks/evict", []byte(`{"element":"`+element+`"}`))
+	}
+	conn.aof.Close()
+
+	replayed := aofTestConn(t, path)
+	defer replayed.aof.Close()
+
+	db, _ := ResourceDatabase(replayed, "db")
+	s, ok := ResourceStack(db, "evict")
+	if !ok {
+		t.Fatal("stack evict was not replayed")
+	}
+	if s.Overflow != pila.OverflowEvict || s.Capacity() != 2 {
+		t.Errorf("stack overflow and capacity are %s and %d, expected %s and %d",
+			s.Overflow, s.Capacity(), pila.OverflowEvict, 2)
+	}
+	if s.Size() != 2 || s.Peek() != "baz" {
+		t.Errorf("stack size is %d and peek %v, expected %d and %v", s.Size(), s.Peek(), 2, "baz")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fern4lvarez/piladb/pila"
+)
+
+// etag returns the entity tag of a Stack, given by its version.
+func etag(stack *pila.Stack) string {
+	return fmt.Sprintf(`"%d"`, stack.Version())
+}
+
+// ifMatch returns the Stack version required by the If-Match header
+// of a request, and true if the operation is conditional. It returns
+// false if the header is not set or matches any version, i.e. `*`,
+// and an error if it does not contain a single strong entity tag, as
+// such a header cannot match any version.
+func ifMatch(r *http.Request) (uint64, bool, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, false, nil
+	}
+
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return 0, true, errors.New("invalid If-Match " + value)
+	}
+
+	version, err := strconv.ParseUint(value[1:len(value)-1], 10, 64)
+	if err != nil {
+		return 0, true, errors.New("invalid If-Match " + value)
+	}
+	return version, true, nil
+}
+
+// etagWriter is an http.ResponseWriter that sets the ETag header
+// of a Stack right before the response is written, so it reflects
+// the version of the Stack after the operation.
+type etagWriter struct {
+	http.ResponseWriter
+	stack       *pila.Stack
+	wroteHeader bool
+}
+
+// WriteHeader sets the ETag header and sends the response header
+// with the status code.
+func (w *etagWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set("ETag", etag(w.stack))
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes data as part of the response, setting the ETag
+// header first if it was not sent yet.
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
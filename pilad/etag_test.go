@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pila"
+)
+
+func TestIfMatch(t *testing.T) {
+	inputOutput := []struct {
+		header      string
+		version     uint64
+		conditional bool
+		err         bool
+	}{
+		{"", 0, false, false},
+		{"*", 0, false, false},
+		{`"0"`, 0, true, false},
+		{` "42" `, 42, true, false},
+		{"42", 0, true, true},
+		{`W/"42"`, 0, true, true},
+		{`"42", "43"`, 0, true, true},
+		{`"foo"`, 0, true, true},
+		{`"-1"`, 0, true, true},
+		{`"`, 0, true, true},
+	}
+
+	for _, io := range inputOutput {
+		request, err := http.NewRequest("POST", "/databases/db/stacks/stack", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("If-Match", io.header)
+
+		version, conditional, err := ifMatch(request)
+		if version != io.version || conditional != io.conditional || (err != nil) != io.err {
+			t.Errorf("ifMatch(%q) is %d, %v, %v, expected %d, %v and error %v",
+				io.header, version, conditional, err, io.version, io.conditional, io.err)
+		}
+	}
+}
+
+func etagTestConn() (*Conn, *pila.Stack, map[string]string) {
+	s := pila.NewStack("stack", time.Now().UTC())
+	db := pila.NewDatabase("db")
+	_ = db.AddStack(s)
+	p := pila.NewPila()
+	_ = p.AddDatabase(db)
+
+	conn := NewConn()
+	conn.Pila = p
+
+	params := map[string]string{
+		"database_id": db.Name,
+		"stack_id":    s.Name,
+	}
+	return conn, s, params
+}
+
+func TestStackHandler_ETag(t *testing.T) {
+	conn, s, params := etagTestConn()
+
+	requests := []struct {
+		method, url string
+		body        []byte
+		etag        string
+	}{
+		{"GET", "/databases/db/stacks/stack", nil, `"0"`},
+		{"POST", "/databases/db/stacks/stack", []byte(`{"element":"foo"}`), `"1"`},
+		{"GET", "/databases/db/stacks/stack?peek", nil, `"1"`},
+		{"POST", "/databases/db/stacks/stack?batch", []byte(`["bar","baz"]`), `"2"`},
+		{"GET", "/databases/db/stacks/stack?size", nil, `"2"`},
+		{"DELETE", "/databases/db/stacks/stack", nil, `"3"`},
+		{"DELETE", "/databases/db/stacks/stack?pop=5", nil, `"4"`},
+		{"DELETE", "/databases/db/stacks/stack", nil, `"4"`},
+		{"DELETE", "/databases/db/stacks/stack?flush", nil, `"5"`},
+	}
+
+	for _, req := range requests {
+		request, err := http.NewRequest(req.method, req.url, bytes.NewBuffer(req.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := httptest.NewRecorder()
+
+		conn.stackHandler(&params).ServeHTTP(response, request)
+
+		if etag := response.Header().Get("ETag"); etag != req.etag {
+			t.Errorf("ETag of %s %s is %s, expected %s", req.method, req.url, etag, req.etag)
+		}
+	}
+
+	if v := s.Version(); v != 5 {
+		t.Errorf("version is %d, expected %d", v, 5)
+	}
+}
+
+func TestPushStackHandler_IfMatch(t *testing.T) {
+	conn, s, params := etagTestConn()
+
+	inputOutput := []struct {
+		ifMatch string
+		code    int
+		etag    string
+	}{
+		{`"0"`, http.StatusOK, `"1"`},
+		{`"0"`, http.StatusPreconditionFailed, `"1"`},
+		{"1", http.StatusPreconditionFailed, `"1"`},
+		{`"1"`, http.StatusOK, `"2"`},
+		{"*", http.StatusOK, `"3"`},
+	}
+
+	for _, io := range inputOutput {
+		request, err := http.NewRequest("POST", "/databases/db/stacks/stack",
+			bytes.NewBuffer([]byte(`{"element":"foo"}`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("If-Match", io.ifMatch)
+		response := httptest.NewRecorder()
+
+		conn.stackHandler(&params).ServeHTTP(response, request)
+
+		if response.Code != io.code {
+			t.Errorf("response code for If-Match %s is %v, expected %v", io.ifMatch, response.Code, io.code)
+		}
+		if etag := response.Header().Get("ETag"); etag != io.etag {
+			t.Errorf("ETag for If-Match %s is %s, expected %s", io.ifMatch, etag, io.etag)
+		}
+	}
+
+	if s.Size() != 3 {
+		t.Errorf("stack size is %d, expected %d", s.Size(), 3)
+	}
+}
+
+func TestPopStackHandler_IfMatch(t *testing.T) {
+	conn, s, params := etagTestConn()
+	s.PushMany([]interface{}{"foo", "bar"})
+
+	inputOutput := []struct {
+		ifMatch  string
+		code     int
+		response string
+	}{
+		{`"0"`, http.StatusPreconditionFailed, `{"type":"about:blank","title":"Precondition Failed","status":412,"code":"version_mismatch","detail":"stack version mismatch","instance":"/databases/db/stacks/stack"}`},
+		{`"1"`, http.StatusOK, `{"element":"bar"}`},
+		{`"1"`, http.StatusPreconditionFailed, `{"type":"about:blank","title":"Precondition Failed","status":412,"code":"version_mismatch","detail":"stack version mismatch","instance":"/databases/db/stacks/stack"}`},
+		{`"2"`, http.StatusOK, `{"element":"foo"}`},
+		{`"3"`, http.StatusNoContent, ""},
+	}
+
+	for _, io := range inputOutput {
+		request, err := http.NewRequest("DELETE", "/databases/db/stacks/stack", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("If-Match", io.ifMatch)
+		response := httptest.NewRecorder()
+
+		conn.stackHandler(&params).ServeHTTP(response, request)
+
+		if response.Code != io.code {
+			t.Errorf("response code for If-Match %s is %v, expected %v", io.ifMatch, response.Code, io.code)
+		}
+		if body := response.Body.String(); body != io.response {
+			t.Errorf("response for If-Match %s is %s, expected %s", io.ifMatch, body, io.response)
+		}
+	}
+}
+
+func TestPopStackHandler_IfMatchWait(t *testing.T) {
+	conn, s, params := etagTestConn()
+
+	request, err := http.NewRequest("DELETE", "/databases/db/stacks/stack?wait=5s", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("If-Match", `"0"`)
+	response := httptest.NewRecorder()
+
+	// the push that would end the wait changes the
+	// version, so the request is rejected right away
+	start := time.Now()
+	conn.stackHandler(&params).ServeHTTP(response, request)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("pop returned after %v, expected it not to wait", elapsed)
+	}
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("response code is %v, expected %v", response.Code, http.StatusBadRequest)
+	}
+	if s.Size() != 0 {
+		t.Errorf("stack size is %d, expected %d", s.Size(), 0)
+	}
+}
+
+func TestPushManyStackHandler_IfMatch(t *testing.T) {
+	conn, s, params := etagTestConn()
+
+	inputOutput := []struct {
+		ifMatch string
+		code    int
+		etag    string
+	}{
+		{`"0"`, http.StatusOK, `"1"`},
+		{`"0"`, http.StatusPreconditionFailed, `"1"`},
+		{"1", http.StatusPreconditionFailed, `"1"`},
+		{`"1"`, http.StatusOK, `"2"`},
+	}
+
+	for _, io := range inputOutput {
+		request, err := http.NewRequest("POST", "/databases/db/stacks/stack?batch",
+			bytes.NewBuffer([]byte(`["foo","bar"]`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("If-Match", io.ifMatch)
+		response := httptest.NewRecorder()
+
+		conn.stackHandler(&params).ServeHTTP(response, request)
+
+		if response.Code != io.code {
+			t.Errorf("response code for If-Match %s is %v, expected %v", io.ifMatch, response.Code, io.code)
+		}
+		if etag := response.Header().Get("ETag"); etag != io.etag {
+			t.Errorf("ETag for If-Match %s is %s, expected %s", io.ifMatch, etag, io.etag)
+		}
+	}
+
+	if s.Size() != 4 {
+		t.Errorf("stack size is %d, expected %d", s.Size(), 4)
+	}
+}
+
+func TestPopManyStackHandler_IfMatch(t *testing.T) {
+	conn, s, params := etagTestConn()
+	s.PushMany([]interface{}{"foo", "bar", "baz"})
+
+	inputOutput := []struct {
+		ifMatch  string
+		code     int
+		response string
+	}{
+		{`"0"`, http.StatusPreconditionFailed, `{"type":"about:blank","title":"Precondition Failed","status":412,"code":"version_mismatch","detail":"stack version mismatch","instance":"/databases/db/stacks/stack"}`},
+		{`"1"`, http.StatusOK, `["baz","bar"]`},
+		{`"2"`, http.StatusOK, `["foo"]`},
+		{`"3"`, http.StatusNoContent, ""},
+	}
+
+	for _, io := range inputOutput {
+		request, err := http.NewRequest("DELETE", "/databases/db/stacks/stack?pop=2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("If-Match", io.ifMatch)
+		response := httptest.NewRecorder()
+
+		conn.stackHandler(&params).ServeHTTP(response, request)
+
+		if response.Code != io.code {
+			t.Errorf("response code for If-Match %s is %v, expected %v", io.ifMatch, response.Code, io.code)
+		}
+		if body := response.Body.String(); body != io.response {
+			t.Errorf("response for If-Match %s is %s, expected %s", io.ifMatch, body, io.response)
+		}
+	}
+}
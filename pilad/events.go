@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pila"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/websocket"
+)
+
+// eventsHandler streams the events of the stacks of a database, or of
+// a single stack if its ID is given, as they change. Events are sent
+// through a WebSocket if the request asks for an upgrade, and as
+// Server-Sent Events otherwise. The stream ends once the stack or the
+// database is deleted.
+func (c *Conn) eventsHandler(params *map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		// we override the mux vars to be able to test
+		// an arbitrary database and stack ID
+		if params != nil {
+			vars = *params
+		}
+
+		db, ok := ResourceDatabase(c, vars["database_id"])
+		if !ok {
+			c.goneHandler(w, r, problemDatabaseGone, fmt.Sprintf("database %s is Gone", vars["database_id"]))
+			return
+		}
+		db.Read(time.Now().UTC())
+
+		var sub *pila.Subscription
+		if stackID, ok := vars["stack_id"]; ok {
+			stack, ok := ResourceStack(db, stackID)
+			if !ok {
+				c.goneHandler(w, r, problemStackGone, fmt.Sprintf("stack %s is Gone", stackID))
+				return
+			}
+			sub = db.Subscribe(stack.UUID())
+
+			// the stack could be deleted before subscribing,
+			// so the subscription would never be closed
+			if _, ok := db.Stack(stack.UUID()); !ok {
+				sub.Close()
+				c.goneHandler(w, r, problemStackGone, fmt.Sprintf("stack %s is Gone", stackID))
+				return
+			}
+		} else {
+			sub = db.Subscribe(nil)
+		}
+		defer sub.Close()
+
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			websocket.Server{
+				Handshake: func(config *websocket.Config, r *http.Request) error {
+					err := checkOrigin(config, r)
+					if err != nil {
+						c.logRequest(r, http.StatusForbidden, err)
+					}
+					return err
+				},
+				Handler: func(ws *websocket.Conn) {
+					c.logRequest(r, http.StatusOK)
+					streamWebSocketEvents(ws, sub)
+				},
+			}.ServeHTTP(w, r)
+			return
+		}
+		c.logRequest(r, http.StatusOK)
+		streamServerSentEvents(w, r, sub)
+	})
+}
+
+// checkOrigin accepts the WebSocket handshakes of clients that are not
+// browsers, which send no Origin header, and of pages served by the
+// same host as pilad, as the HTTP API allows no cross-origin requests
+// either. Otherwise, any page could read the events of a database.
+func checkOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin != nil && !strings.EqualFold(origin.Host, r.Host) {
+		return fmt.Errorf("origin %s is not allowed", origin)
+	}
+	config.Origin = origin
+	return nil
+}
+
+// streamServerSentEvents writes the events received by a Subscription
+// as Server-Sent Events, until it is closed or the client disconnects.
+func streamServerSentEvents(w http.ResponseWriter, r *http.Request, sub *pila.Subscription) {
+	// the stream is not bounded by WRITE_TIMEOUT
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+
+			// Do not check error as we consider our event
+			// suitable for a JSON encoding.
+			b, _ := event.ToJSON()
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, b); err != nil {
+				return
+			}
+			_ = rc.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// streamWebSocketEvents sends the events received by a Subscription
+// as JSON messages through a WebSocket, until it is closed or the
+// client disconnects.
+func streamWebSocketEvents(ws *websocket.Conn, sub *pila.Subscription) {
+	// the stream is not bounded by WRITE_TIMEOUT
+	_ = ws.SetDeadline(time.Time{})
+
+	// messages from the client are discarded, and
+	// only read to know when it disconnects
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var message []byte
+		for websocket.Message.Receive(ws, &message) == nil {
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if err := websocket.JSON.Send(ws, event); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
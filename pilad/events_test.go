@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pila"
+	"github.com/fern4lvarez/piladb/pkg/uuid"
+
+	"golang.org/x/net/websocket"
+)
+
+// sseTestClient reads the Server-Sent Events streamed by a
+// response.
+type sseTestClient struct {
+	r *bufio.Reader
+}
+
+// next returns the type and data of the next event, or empty
+// strings if the stream ended.
+func (c *sseTestClient) next() (string, string) {
+	var eventType, data string
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return "", ""
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return eventType, data
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func eventsTestServer(t *testing.T) (*Conn, *httptest.Server) {
+	conn := NewConn()
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=stack", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=other", nil)
+	return conn, httptest.NewServer(Router(conn))
+}
+
+func TestEventsHandler_SSE(t *testing.T) {
+	conn, server := eventsTestServer(t)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/databases/db/stacks/stack/_events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("response code is %d, expected %d", response.StatusCode, http.StatusOK)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Content-Type is %v, expected %v", contentType, "text/event-stream")
+	}
+
+	serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"foo"}`))
+	serve(t, conn, "POST", "/databases/db/stacks/other", []byte(`{"element":"bar"}`))
+	serve(t, conn, "POST", "/databases/db/stacks/stack?batch", []byte(`[1,2]`))
+	serve(t, conn, "DELETE", "/databases/db/stacks/stack", nil)
+	serve(t, conn, "DELETE", "/databases/db/stacks/stack?flush", nil)
+	serve(t, conn, "DELETE", "/databases/db/stacks/stack?full", nil)
+
+	client := &sseTestClient{r: bufio.NewReader(response.Body)}
+	for _, expected := range []struct {
+		eventType, data string
+	}{
+		{"push", `"element":"foo","size":1`},
+		{"push", `"element":1,"size":2`},
+		{"push", `"element":2,"size":3`},
+		{"pop", `"element":2,"size":2`},
+		{"flush", `"size":0`},
+		{"flush", `"size":0`},
+		{"delete", `"size":0`},
+	} {
+		eventType, data := client.next()
+		if eventType != expected.eventType || !strings.Contains(data, expected.data) {
+			t.Errorf("event is %s %s, expected %s with %s", eventType, data, expected.eventType, expected.data)
+		}
+		if !strings.Contains(data, `"database":"db","stack_id":"`+uuid.New("dbstack").String()) {
+			t.Errorf("event %s has not the database and ID of the stack", data)
+		}
+	}
+
+	// the stream ends once the stack is deleted
+	if eventType, _ := client.next(); eventType != "" {
+		t.Errorf("event is %s, expected end of stream", eventType)
+	}
+}
+
+func TestEventsHandler_Database(t *testing.T) {
+	conn, server := eventsTestServer(t)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/databases/db/_events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"foo"}`))
+	serve(t, conn, "POST", "/databases/db/stacks/other", []byte(`{"element":"bar"}`))
+	serve(t, conn, "DELETE", "/databases/db/stacks/stack?full", nil)
+	serve(t, conn, "DELETE", "/databases/db", nil)
+
+	client := &sseTestClient{r: bufio.NewReader(response.Body)}
+	for _, expected := range []string{
+		`"type":"push","database":"db","stack_id":"` + uuid.New("dbstack").String() + `","stack":"stack","element":"foo"`,
+		`"type":"push","database":"db","stack_id":"` + uuid.New("dbother").String() + `","stack":"other","element":"bar"`,
+		`"type":"flush","database":"db","stack_id":"` + uuid.New("dbstack").String() + `","stack":"stack"`,
+		`"type":"delete","database":"db","stack_id":"` + uuid.New("dbstack").String() + `","stack":"stack"`,
+		`"type":"delete","database":"db","stack_id":"` + uuid.New("dbother").String() + `","stack":"other"`,
+	} {
+		if _, data := client.next(); !strings.Contains(data, expected) {
+			t.Errorf("event is %s, expected %s", data, expected)
+		}
+	}
+
+	// the stream ends once the database is deleted, along
+	// with its stacks
+	if eventType, _ := client.next(); eventType != "" {
+		t.Errorf("event is %s, expected end of stream", eventType)
+	}
+}
+
+func TestEventsHandler_WebSocket(t *testing.T) {
+	conn, server := eventsTestServer(t)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/databases/db/stacks/stack/_events"
+	ws, err := websocket.Dial(url, "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	// the subscription is made before the handshake completes
+	serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"foo"}`))
+	serve(t, conn, "DELETE", "/databases/db/stacks/stack", nil)
+
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, expected := range []pila.Event{
+		{Type: pila.EventPush, Database: "db", Stack: "stack", Element: "foo", Size: 1},
+		{Type: pila.EventPop, Database: "db", Stack: "stack", Element: "foo", Size: 0},
+	} {
+		var event pila.Event
+		if err := websocket.JSON.Receive(ws, &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != expected.Type || event.Database != expected.Database || event.Stack != expected.Stack ||
+			event.Element != expected.Element || event.Size != expected.Size {
+			t.Errorf("event is %v, expected %v", event, expected)
+		}
+	}
+}
+
+func TestEventsHandler_WebSocketOrigin(t *testing.T) {
+	_, server := eventsTestServer(t)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/databases/db/stacks/stack/_events"
+	if ws, err := websocket.Dial(url, "", "http://example.com"); err == nil {
+		ws.Close()
+		t.Error("err is nil, expected the handshake to be rejected")
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	for _, io := range []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"http://localhost:1205", true},
+		{"https://LOCALHOST:1205", true},
+		{"http://localhost:8080", false},
+		{"http://example.com", false},
+		{"null", false},
+	} {
+		request, _ := http.NewRequest("GET", "http://localhost:1205/databases/db/_events", nil)
+		if io.origin != "" {
+			request.Header.Set("Origin", io.origin)
+		}
+
+		config := &websocket.Config{Version: websocket.ProtocolVersionHybi13}
+		if err := checkOrigin(config, request); (err == nil) != io.ok {
+			t.Errorf("err for origin %q is %v, expected ok to be %v", io.origin, err, io.ok)
+		}
+	}
+}
+
+func TestEventsHandler_Gone(t *testing.T) {
+	conn, _ := eventsTestServer(t)
+
+	for _, params := range []map[string]string{
+		{"database_id": "nodb"},
+		{"database_id": "nodb", "stack_id": "stack"},
+		{"database_id": "db", "stack_id": "nostack"},
+	} {
+		params := params
+		request, _ := http.NewRequest("GET", "/_events", nil)
+		response := httptest.NewRecorder()
+		conn.eventsHandler(&params).ServeHTTP(response, request)
+
+		if response.Code != http.StatusGone {
+			t.Errorf("response code for %v is %d, expected %d", params, response.Code, http.StatusGone)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import "time"
+
+// expireLoop removes the expired elements of the stacks every
+// second. Expired elements are not visible anyway, so it only
+// releases the resources they hold.
+func (c *Conn) expireLoop() {
+	for range time.Tick(time.Second) {
+		if n := c.Pila.Expire(); n > 0 {
+			c.Logger.Debug("expired elements removed", "elements", n)
+		}
+	}
+}
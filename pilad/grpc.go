@@ -0,0 +1,670 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/fern4lvarez/piladb/auth"
+	"github.com/fern4lvarez/piladb/config/vars"
+	"github.com/fern4lvarez/piladb/pila"
+	"github.com/fern4lvarez/piladb/pkg/aof"
+	"github.com/fern4lvarez/piladb/pkg/pilapb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcPollInterval is how often a waiting pop checks whether its
+// call was cancelled or its Stack removed.
+const grpcPollInterval = 100 * time.Millisecond
+
+// serveGRPC serves the gRPC API to the clients connecting to l,
+// until l is closed.
+func (c *Conn) serveGRPC(l net.Listener) error {
+	return c.grpcServer().Serve(l)
+}
+
+// grpcServer returns a gRPC server with the Pila service registered,
+// which logs and authenticates every call.
+func (c *Conn) grpcServer() *grpc.Server {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(c.logUnaryGRPC, c.authUnaryGRPC),
+		grpc.ChainStreamInterceptor(c.logStreamGRPC, c.authStreamGRPC),
+	)
+	pilapb.RegisterPilaServer(s, &pilaServer{c: c})
+	return s
+}
+
+// logUnaryGRPC logs a unary call given its method and its status.
+func (c *Conn) logUnaryGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	id := grpcRequestID(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, id))
+
+	res, err := handler(ctx, req)
+	c.logGRPC(id, info.FullMethod, err)
+	return res, err
+}
+
+// logStreamGRPC logs a streaming call given its method and its status,
+// once it finishes.
+func (c *Conn) logStreamGRPC(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	id := grpcRequestID(ss.Context())
+	_ = ss.SetHeader(metadata.Pairs(requestIDHeader, id))
+
+	err := handler(srv, ss)
+	c.logGRPC(id, info.FullMethod, err)
+	return err
+}
+
+// grpcRequestID returns the request ID given in the x-request-id
+// metadata of a call, or a new one if it has none or it is not valid.
+func grpcRequestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get(requestIDHeader); len(ids) > 0 && validRequestID(ids[0]) {
+		return ids[0]
+	}
+	return newRequestID()
+}
+
+// logGRPC logs a finished call given its request ID, its method and
+// its error, nil if it succeeded.
+func (c *Conn) logGRPC(id, method string, err error) {
+	l := c.Logger.With("request_id", id, "method", method)
+	if err != nil {
+		l.Warn("gRPC call failed", "code", status.Code(err).String(), "detail", status.Convert(err).Message())
+		return
+	}
+	l.Info("gRPC call", "code", codes.OK.String())
+}
+
+// grpcScopes contains the scope of a token required by each method
+// of the Pila service. Methods not listed require the admin scope.
+var grpcScopes = map[string]string{
+	"Status":         auth.ScopeReadOnly,
+	"ListDatabases":  auth.ScopeReadOnly,
+	"CreateDatabase": auth.ScopeReadWrite,
+	"GetDatabase":    auth.ScopeReadOnly,
+	"DeleteDatabase": auth.ScopeReadWrite,
+	"ListStacks":     auth.ScopeReadOnly,
+	"CreateStack":    auth.ScopeReadWrite,
+	"GetStack":       auth.ScopeReadOnly,
+	"DeleteStack":    auth.ScopeReadWrite,
+	"Push":           auth.ScopeReadWrite,
+	"Pop":            auth.ScopeReadWrite,
+	"Peek":           auth.ScopeReadOnly,
+	"Size":           auth.ScopeReadOnly,
+	"Flush":          auth.ScopeReadWrite,
+	"StreamPops":     auth.ScopeReadWrite,
+}
+
+// authUnaryGRPC authenticates a unary call by the token in its
+// authorization metadata, and checks that its scope grants access
+// to the requested database.
+func (c *Conn) authUnaryGRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := c.authorizeGRPC(ctx, info.FullMethod, req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authStreamGRPC authenticates a streaming call as authUnaryGRPC,
+// once its request is received.
+func (c *Conn) authStreamGRPC(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &authServerStream{ServerStream: ss, c: c, method: info.FullMethod})
+}
+
+// authServerStream is a grpc.ServerStream that authorizes the
+// requests it receives.
+type authServerStream struct {
+	grpc.ServerStream
+	c      *Conn
+	method string
+}
+
+// RecvMsg receives a request, and checks that the call is
+// authorized to make it.
+func (ss *authServerStream) RecvMsg(m interface{}) error {
+	if err := ss.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return ss.c.authorizeGRPC(ss.Context(), ss.method, m)
+}
+
+// authorizeGRPC authenticates a call given its method and its request
+// by the token in its authorization metadata, failing with
+// Unauthenticated if it is not valid, or PermissionDenied if its scope
+// does not grant access to the requested database. Calls are not
+// authenticated if there are no tokens.
+func (c *Conn) authorizeGRPC(ctx context.Context, method string, req interface{}) error {
+	if !c.Auth.Enabled() {
+		return nil
+	}
+
+	scope, ok := grpcScopes[path.Base(method)]
+	if !ok {
+		scope = auth.ScopeAdmin
+	}
+	var database string
+	switch req := req.(type) {
+	case *pilapb.CreateDatabaseRequest:
+		database = req.GetName()
+	case interface{ GetDatabase() string }:
+		database = c.databaseName(req.GetDatabase())
+	}
+
+	var secret string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		secret = bearerToken(values[0])
+	}
+
+	err := c.authorize(secret, scope, []string{database})
+	if err == errInvalidToken {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
+
+// pilaServer implements the Pila gRPC service on top of a Conn, sharing
+// its data with the HTTP API.
+type pilaServer struct {
+	pilapb.UnimplementedPilaServer
+	c *Conn
+}
+
+// Status returns the status of the piladb instance.
+func (s *pilaServer) Status(ctx context.Context, _ *emptypb.Empty) (*pilapb.StatusResponse, error) {
+	st := s.c.Status
+	st.Update(time.Now().UTC(), MemStats())
+
+	st.reapMu.Lock()
+	defer st.reapMu.Unlock()
+	return &pilapb.StatusResponse{
+		Status:           st.Code,
+		Version:          st.Version,
+		GoVersion:        st.GoVersion,
+		Host:             st.Host,
+		Pid:              int64(st.PID),
+		StartedAt:        timestamppb.New(st.StartedAt),
+		RunningFor:       st.RunningFor,
+		NumberGoroutines: int64(st.NumberGoroutines),
+		MemoryAlloc:      st.MemoryAlloc,
+		ReapedDatabases:  int64(st.ReapedDatabases),
+		ReapedStacks:     int64(st.ReapedStacks),
+	}, nil
+}
+
+// ListDatabases returns the status of all databases.
+func (s *pilaServer) ListDatabases(ctx context.Context, _ *emptypb.Empty) (*pilapb.ListDatabasesResponse, error) {
+	res := &pilapb.ListDatabasesResponse{}
+	for _, dbs := range s.c.Pila.Status().Databases {
+		res.Databases = append(res.Databases, grpcDatabase(dbs))
+	}
+	return res, nil
+}
+
+// CreateDatabase creates a database, failing with AlreadyExists if
+// there is one with the same name.
+func (s *pilaServer) CreateDatabase(ctx context.Context, req *pilapb.CreateDatabaseRequest) (*pilapb.Database, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing name")
+	}
+	if err := checkDatabaseName(req.Name); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.IdleTtl < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid idle_ttl %v", req.IdleTtl)
+	}
+
+	now := time.Now().UTC()
+	db := pila.NewDatabase(req.Name)
+	db.IdleTTL = pila.Seconds(req.IdleTtl)
+	db.Read(now)
+	entry := aof.Entry{
+		Op:       aof.CreateDatabase,
+		Database: req.Name,
+		IdleTTL:  req.IdleTtl,
+		Time:     now,
+	}
+	var err error
+	s.c.persist(entry, func() bool {
+		err = s.c.Pila.AddDatabase(db)
+		return err == nil
+	})
+	if err != nil {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+
+	return grpcDatabase(db.Status()), nil
+}
+
+// GetDatabase returns the status of a database.
+func (s *pilaServer) GetDatabase(ctx context.Context, req *pilapb.DatabaseRequest) (*pilapb.Database, error) {
+	db, err := s.database(req.Database, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return grpcDatabase(db.Status()), nil
+}
+
+// DeleteDatabase deletes a database and all its stacks.
+func (s *pilaServer) DeleteDatabase(ctx context.Context, req *pilapb.DatabaseRequest) (*emptypb.Empty, error) {
+	db, err := s.database(req.Database, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	s.c.persist(aof.Entry{Op: aof.DeleteDatabase, Database: db.Name}, func() bool {
+		return s.c.Pila.RemoveDatabase(db.ID)
+	})
+	return &emptypb.Empty{}, nil
+}
+
+// ListStacks returns the status of the stacks of a database.
+func (s *pilaServer) ListStacks(ctx context.Context, req *pilapb.DatabaseRequest) (*pilapb.ListStacksResponse, error) {
+	db, err := s.database(req.Database, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pilapb.ListStacksResponse{}
+	for _, ss := range db.StacksStatus().Stacks {
+		stack, err := grpcStack(ss)
+		if err != nil {
+			return nil, err
+		}
+		res.Stacks = append(res.Stacks, stack)
+	}
+	return res, nil
+}
+
+// CreateStack creates a stack in a database, failing with AlreadyExists
+// if it already contains one with the same name.
+func (s *pilaServer) CreateStack(ctx context.Context, req *pilapb.CreateStackRequest) (*pilapb.Stack, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing name")
+	}
+
+	now := time.Now().UTC()
+	db, err := s.database(req.Database, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var capacityValue string
+	if req.Capacity != 0 {
+		capacityValue = strconv.FormatInt(req.Capacity, 10)
+	}
+	engine, overflow, capacity, err := s.c.parseStackOptions(req.Engine, req.Overflow, capacityValue)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.IdleTtl < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid idle_ttl %v", req.IdleTtl)
+	}
+
+	// reserve the name before creating the base, as it
+	// could take over the resources of an existing Stack.
+	release, err := s.c.reserveStack(db, req.Name)
+	if err != nil {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	defer release()
+
+	base, err := s.c.newBase(engine, db.Name, req.Name, capacity)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	stack := pila.NewStackWithBase(req.Name, now, base)
+	stack.Engine = engineName(engine)
+	stack.Overflow = overflow
+	stack.IdleTTL = pila.Seconds(req.IdleTtl)
+	entry := aof.Entry{
+		Op:       aof.CreateStack,
+		Database: db.Name,
+		Stack:    req.Name,
+		Engine:   stack.Engine,
+		Overflow: overflow,
+		Capacity: capacity,
+		IdleTTL:  req.IdleTtl,
+		Time:     now,
+	}
+	s.c.persist(entry, func() bool {
+		err = db.AddStack(stack)
+		return err == nil
+	})
+	if err != nil {
+		closeBase(base)
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	stack.Update(now)
+
+	return grpcStack(stack.Status())
+}
+
+// GetStack returns the status of a stack.
+func (s *pilaServer) GetStack(ctx context.Context, req *pilapb.StackRequest) (*pilapb.Stack, error) {
+	now := time.Now().UTC()
+	_, stack, err := s.stack(req.Database, req.Stack, now)
+	if err != nil {
+		return nil, err
+	}
+
+	stack.Read(now)
+	return grpcStack(stack.Status())
+}
+
+// DeleteStack deletes a stack.
+func (s *pilaServer) DeleteStack(ctx context.Context, req *pilapb.StackRequest) (*emptypb.Empty, error) {
+	now := time.Now().UTC()
+	db, stack, err := s.stack(req.Database, req.Stack, now)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := aof.Entry{Op: aof.DeleteStack, Database: db.Name, Stack: stack.Name, Time: now}
+	s.c.persist(entry, func() bool {
+		stack.Flush()
+
+		// Do not check output as we validated that
+		// stack always exists.
+		_ = db.RemoveStack(stack.ID)
+		return true
+	})
+	return &emptypb.Empty{}, nil
+}
+
+// Push adds an element on top of a stack, and returns it. If the element
+// has a TTL, it expires after that many seconds. It fails with
+// ResourceExhausted if the stack reached MAX_STACK_SIZE.
+func (s *pilaServer) Push(ctx context.Context, req *pilapb.PushRequest) (*pilapb.Element, error) {
+	if req.Element == nil {
+		return nil, status.Error(codes.InvalidArgument, "no element provided")
+	}
+	if req.Ttl < 0 {
+		return nil, status.Error(codes.InvalidArgument, "negative ttl")
+	}
+
+	now := time.Now().UTC()
+	db, stack, err := s.stack(req.Database, req.Stack, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if max := s.c.Config.MaxStackSize(); max != -1 && stack.Overflow != pila.OverflowEvict &&
+		stack.Size() >= max {
+		return nil, status.Error(codes.ResourceExhausted, vars.MaxStackSize+" value reached")
+	}
+
+	element := pila.Element{Value: req.Element.AsInterface(), TTL: req.Ttl}
+	entry := aof.Entry{Op: aof.Push, Database: db.Name, Stack: stack.Name, Element: element.Value, Time: now}
+	expiresAt := element.ExpiresAt(now)
+	if !expiresAt.IsZero() {
+		entry.ExpiresAt = &expiresAt
+	}
+	s.c.persist(entry, func() bool {
+		if expiresAt.IsZero() {
+			stack.Push(element.Value)
+			return true
+		}
+		err = stack.PushExpiring(element.Value, expiresAt)
+		return err == nil
+	})
+	if err != nil {
+		return nil, status.Error(codes.Unimplemented, err.Error())
+	}
+	stack.Update(now)
+
+	return &pilapb.Element{Value: req.Element}, nil
+}
+
+// Pop extracts the element on top of a stack and returns it. If the
+// stack is empty and wait is set, it waits for an element to be pushed
+// for that long, bounded by the deadline of the call. The element of
+// the response is unset if no element was extracted.
+func (s *pilaServer) Pop(ctx context.Context, req *pilapb.PopRequest) (*pilapb.PopResponse, error) {
+	var wait time.Duration
+	if req.Wait != nil {
+		if err := req.Wait.CheckValid(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if wait = req.Wait.AsDuration(); wait < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid wait %v", wait)
+		}
+	}
+
+	now := time.Now().UTC()
+	db, stack, err := s.stack(req.Database, req.Stack, now)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := s.pop(ctx, db, stack, time.Now().Add(wait), now)
+	if !ok {
+		return &pilapb.PopResponse{}, nil
+	}
+
+	element, err := pilapb.NewElement(value)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pilapb.PopResponse{Element: element}, nil
+}
+
+// Peek returns the element on top of a stack without extracting it. Its
+// value is null if the stack is empty.
+func (s *pilaServer) Peek(ctx context.Context, req *pilapb.StackRequest) (*pilapb.Element, error) {
+	now := time.Now().UTC()
+	_, stack, err := s.stack(req.Database, req.Stack, now)
+	if err != nil {
+		return nil, err
+	}
+
+	element, err := pilapb.NewElement(stack.Peek())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	stack.Read(now)
+	return element, nil
+}
+
+// Size returns the number of elements of a stack.
+func (s *pilaServer) Size(ctx context.Context, req *pilapb.StackRequest) (*pilapb.SizeResponse, error) {
+	now := time.Now().UTC()
+	_, stack, err := s.stack(req.Database, req.Stack, now)
+	if err != nil {
+		return nil, err
+	}
+
+	stack.Read(now)
+	return &pilapb.SizeResponse{Size: int64(stack.Size())}, nil
+}
+
+// Flush empties a stack, and returns its status.
+func (s *pilaServer) Flush(ctx context.Context, req *pilapb.StackRequest) (*pilapb.Stack, error) {
+	now := time.Now().UTC()
+	db, stack, err := s.stack(req.Database, req.Stack, now)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := aof.Entry{Op: aof.Flush, Database: db.Name, Stack: stack.Name, Time: now}
+	s.c.persist(entry, func() bool {
+		stack.Flush()
+		return true
+	})
+	stack.Update(now)
+
+	return grpcStack(stack.Status())
+}
+
+// StreamPops pops the elements of a stack as they are pushed, and sends
+// them until the call is cancelled or the stack is removed.
+func (s *pilaServer) StreamPops(req *pilapb.StackRequest, stream pilapb.Pila_StreamPopsServer) error {
+	db, stack, err := s.stack(req.Database, req.Stack, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	for {
+		// there is no deadline other than the one of the call
+		value, ok := s.pop(ctx, db, stack, time.Time{}, time.Now().UTC())
+		if !ok {
+			if err := ctx.Err(); err != nil {
+				return status.FromContextError(err).Err()
+			}
+			return nil
+		}
+
+		element, err := pilapb.NewElement(value)
+		if err != nil {
+			s.pushBack(db, stack, value)
+			return status.Error(codes.Internal, err.Error())
+		}
+		if err := stream.Send(element); err != nil {
+			s.pushBack(db, stack, value)
+			return err
+		}
+	}
+}
+
+// pushBack pushes an element popped from the Stack back on top of it,
+// once it could not be sent, so it is not lost.
+func (s *pilaServer) pushBack(db *pila.Database, stack *pila.Stack, value interface{}) {
+	now := time.Now().UTC()
+	entry := aof.Entry{Op: aof.Push, Database: db.Name, Stack: stack.Name, Element: value, Time: now}
+	s.c.persist(entry, func() bool {
+		stack.Push(value)
+		return true
+	})
+	stack.Update(now)
+}
+
+// pop extracts the element on top of the Stack at now. If it is empty,
+// it waits for an element to be pushed until the deadline, or forever
+// if it is zero, as long as the call is not cancelled and the Stack is
+// not removed. It returns false if no element was extracted.
+func (s *pilaServer) pop(ctx context.Context, db *pila.Database, stack *pila.Stack, deadline, now time.Time) (interface{}, bool) {
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+
+	var value interface{}
+	var ok bool
+	entry := aof.Entry{Op: aof.Pop, Database: db.Name, Stack: stack.Name, Time: now}
+	// a caller notified of a push that lost the element to
+	// another consumer waits again first in line
+	var front bool
+	for {
+		s.c.persist(entry, func() bool {
+			value, ok = stack.Pop()
+			return ok
+		})
+		if ok {
+			stack.Update(now)
+			return value, true
+		}
+
+		timeout := grpcPollInterval
+		if !deadline.IsZero() {
+			remaining := deadline.Sub(time.Now())
+			if remaining <= 0 {
+				return nil, false
+			}
+			if remaining < timeout {
+				timeout = remaining
+			}
+		}
+		if ctx.Err() != nil {
+			return nil, false
+		}
+		front = stack.Wait(timeout, front)
+		if !front && s.removed(db, stack) {
+			return nil, false
+		}
+		entry.Time = time.Now().UTC()
+		now = entry.Time
+	}
+}
+
+// removed returns true if the Stack was removed from the Database, or
+// the Database from the Pila.
+func (s *pilaServer) removed(db *pila.Database, stack *pila.Stack) bool {
+	if _, ok := s.c.Pila.Database(db.ID); !ok {
+		return true
+	}
+	_, ok := db.Stack(stack.ID)
+	return !ok
+}
+
+// database returns the Database given by its ID or name, failing with
+// NotFound if it does not exist, and marks it as read at now.
+func (s *pilaServer) database(database string, now time.Time) (*pila.Database, error) {
+	db, ok := ResourceDatabase(s.c, database)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "database %s is Gone", database)
+	}
+	db.Read(now)
+	return db, nil
+}
+
+// stack returns the Stack given by its ID or name, and its Database,
+// failing with NotFound if any of them does not exist.
+func (s *pilaServer) stack(database, stack string, now time.Time) (*pila.Database, *pila.Stack, error) {
+	db, err := s.database(database, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	st, ok := ResourceStack(db, stack)
+	if !ok {
+		return nil, nil, status.Errorf(codes.NotFound, "stack %s is Gone", stack)
+	}
+	return db, st, nil
+}
+
+// grpcDatabase converts the status of a Database into its protocol
+// buffer.
+func grpcDatabase(dbs pila.DatabaseStatus) *pilapb.Database {
+	return &pilapb.Database{
+		Id:             dbs.ID,
+		Name:           dbs.Name,
+		NumberOfStacks: int64(dbs.NumberStacks),
+		Stacks:         dbs.Stacks,
+		IdleTtl:        dbs.IdleTTL,
+	}
+}
+
+// grpcStack converts the status of a Stack into its protocol buffer.
+func grpcStack(ss pila.StackStatus) (*pilapb.Stack, error) {
+	peek, err := pilapb.NewValue(ss.Peek)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pilapb.Stack{
+		Id:        ss.ID,
+		Name:      ss.Name,
+		Peek:      peek,
+		Size:      int64(ss.Size),
+		CreatedAt: timestamppb.New(ss.CreatedAt),
+		UpdatedAt: timestamppb.New(ss.UpdatedAt),
+		ReadAt:    timestamppb.New(ss.ReadAt),
+		Overflow:  ss.Overflow,
+		Capacity:  int64(ss.Capacity),
+		Version:   ss.Version,
+		IdleTtl:   ss.IdleTTL,
+	}, nil
+}
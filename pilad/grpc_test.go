@@ -0,0 +1,554 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/auth"
+	"github.com/fern4lvarez/piladb/config/vars"
+	"github.com/fern4lvarez/piladb/pkg/pilapb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func grpcTestConn(t *testing.T, conn *Conn) (pilapb.PilaClient, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := conn.grpcServer()
+	go s.Serve(l)
+
+	cc, err := grpc.NewClient(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pilapb.NewPilaClient(cc), func() {
+		cc.Close()
+		s.Stop()
+	}
+}
+
+func grpcTestElement(t *testing.T, element interface{}) *pilapb.Element {
+	e, err := pilapb.NewElement(element)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestGRPC(t *testing.T) {
+	conn := NewConn()
+	client, closeClient := grpcTestConn(t, conn)
+	defer closeClient()
+	ctx := context.Background()
+
+	db, err := client.CreateDatabase(ctx, &pilapb.CreateDatabaseRequest{Name: "db", IdleTtl: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Name != "db" || db.IdleTtl != 60 || db.NumberOfStacks != 0 {
+		t.Errorf("database is %v, expected db with no stacks", db)
+	}
+
+	stack, err := client.CreateStack(ctx, &pilapb.CreateStackRequest{Database: db.Id, Name: "stack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stack.Name != "stack" || stack.Size != 0 {
+		t.Errorf("stack is %v, expected empty stack", stack)
+	}
+
+	for _, element := range []interface{}{"foo", map[string]interface{}{"bar": 1.0}} {
+		e, err := client.Push(ctx, &pilapb.PushRequest{Database: "db", Stack: "stack", Element: grpcTestElement(t, element).Value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := e.Interface(); !reflect.DeepEqual(v, element) {
+			t.Errorf("pushed element is %v, expected %v", v, element)
+		}
+	}
+
+	// the data is shared with the HTTP API
+	if code := serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":8}`)); code != 200 {
+		t.Fatalf("response code is %d, expected %d", code, 200)
+	}
+
+	size, err := client.Size(ctx, &pilapb.StackRequest{Database: "db", Stack: stack.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size.Size != 3 {
+		t.Errorf("size is %d, expected %d", size.Size, 3)
+	}
+
+	peek, err := client.Peek(ctx, &pilapb.StackRequest{Database: "db", Stack: "stack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := peek.Interface(); v != 8.0 {
+		t.Errorf("peek is %v, expected %v", v, 8.0)
+	}
+
+	popped, err := client.Pop(ctx, &pilapb.PopRequest{Database: "db", Stack: "stack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := popped.Element.Interface(); v != 8.0 {
+		t.Errorf("popped element is %v, expected %v", v, 8.0)
+	}
+
+	stack, err = client.GetStack(ctx, &pilapb.StackRequest{Database: "db", Stack: "stack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := stack.Peek.AsInterface(); !reflect.DeepEqual(v, map[string]interface{}{"bar": 1.0}) || stack.Size != 2 {
+		t.Errorf("stack is %v, expected peek {bar: 1} and size 2", stack)
+	}
+
+	stacks, err := client.ListStacks(ctx, &pilapb.DatabaseRequest{Database: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stacks.Stacks) != 1 || stacks.Stacks[0].Id != stack.Id {
+		t.Errorf("stacks are %v, expected %v", stacks.Stacks, stack)
+	}
+
+	stack, err = client.Flush(ctx, &pilapb.StackRequest{Database: "db", Stack: "stack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stack.Size != 0 {
+		t.Errorf("stack size is %d, expected %d", stack.Size, 0)
+	}
+
+	popped, err = client.Pop(ctx, &pilapb.PopRequest{Database: "db", Stack: "stack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if popped.Element != nil {
+		t.Errorf("popped element is %v, expected none", popped.Element)
+	}
+
+	databases, err := client.ListDatabases(ctx, &emptypb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(databases.Databases) != 1 || databases.Databases[0].NumberOfStacks != 1 {
+		t.Errorf("databases are %v, expected db with 1 stack", databases.Databases)
+	}
+
+	if _, err := client.DeleteStack(ctx, &pilapb.StackRequest{Database: "db", Stack: "stack"}); err != nil {
+		t.Fatal(err)
+	}
+	if db, err = client.GetDatabase(ctx, &pilapb.DatabaseRequest{Database: "db"}); err != nil {
+		t.Fatal(err)
+	}
+	if db.NumberOfStacks != 0 {
+		t.Errorf("database has %d stacks, expected %d", db.NumberOfStacks, 0)
+	}
+
+	if _, err := client.DeleteDatabase(ctx, &pilapb.DatabaseRequest{Database: "db"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(conn.Pila.Databases); n != 0 {
+		t.Errorf("pila has %d databases, expected %d", n, 0)
+	}
+
+	st, err := client.Status(ctx, &emptypb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != "OK" || st.Version != v() {
+		t.Errorf("status is %v, expected OK and version %s", st, v())
+	}
+}
+
+func TestGRPC_Errors(t *testing.T) {
+	conn := NewConn()
+	conn.Config.Set(vars.MaxStackSize, 1)
+	client, closeClient := grpcTestConn(t, conn)
+	defer closeClient()
+	ctx := context.Background()
+
+	if _, err := client.CreateDatabase(ctx, &pilapb.CreateDatabaseRequest{Name: "db"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateStack(ctx, &pilapb.CreateStackRequest{Database: "db", Name: "stack"}); err != nil {
+		t.Fatal(err)
+	}
+	element := grpcTestElement(t, "foo").Value
+
+	calls := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{"database without name", func() error {
+			_, err := client.CreateDatabase(ctx, &pilapb.CreateDatabaseRequest{})
+			return err
+		}, codes.InvalidArgument},
+		{"database with reserved name", func() error {
+			_, err := client.CreateDatabase(ctx, &pilapb.CreateDatabaseRequest{Name: "_auth"})
+			return err
+		}, codes.InvalidArgument},
+		{"database with negative idle TTL", func() error {
+			_, err := client.CreateDatabase(ctx, &pilapb.CreateDatabaseRequest{Name: "foo", IdleTtl: -1})
+			return err
+		}, codes.InvalidArgument},
+		{"existing database", func() error {
+			_, err := client.CreateDatabase(ctx, &pilapb.CreateDatabaseRequest{Name: "db"})
+			return err
+		}, codes.AlreadyExists},
+		{"missing database", func() error {
+			_, err := client.GetDatabase(ctx, &pilapb.DatabaseRequest{Database: "nodb"})
+			return err
+		}, codes.NotFound},
+		{"existing stack", func() error {
+			_, err := client.CreateStack(ctx, &pilapb.CreateStackRequest{Database: "db", Name: "stack"})
+			return err
+		}, codes.AlreadyExists},
+		{"stack with unknown engine", func() error {
+			_, err := client.CreateStack(ctx, &pilapb.CreateStackRequest{Database: "db", Name: "foo", Engine: "foo"})
+			return err
+		}, codes.InvalidArgument},
+		{"stack in missing database", func() error {
+			_, err := client.CreateStack(ctx, &pilapb.CreateStackRequest{Database: "nodb", Name: "foo"})
+			return err
+		}, codes.NotFound},
+		{"missing stack", func() error {
+			_, err := client.Peek(ctx, &pilapb.StackRequest{Database: "db", Stack: "nostack"})
+			return err
+		}, codes.NotFound},
+		{"push without element", func() error {
+			_, err := client.Push(ctx, &pilapb.PushRequest{Database: "db", Stack: "stack"})
+			return err
+		}, codes.InvalidArgument},
+		{"push with negative TTL", func() error {
+			_, err := client.Push(ctx, &pilapb.PushRequest{Database: "db", Stack: "stack", Element: element, Ttl: -1})
+			return err
+		}, codes.InvalidArgument},
+		{"push beyond MAX_STACK_SIZE", func() error {
+			if _, err := client.Push(ctx, &pilapb.PushRequest{Database: "db", Stack: "stack", Element: element}); err != nil {
+				return err
+			}
+			_, err := client.Push(ctx, &pilapb.PushRequest{Database: "db", Stack: "stack", Element: element})
+			return err
+		}, codes.ResourceExhausted},
+		{"pop with negative wait", func() error {
+			_, err := client.Pop(ctx, &pilapb.PopRequest{Database: "db", Stack: "stack", Wait: durationpb.New(-time.Second)})
+			return err
+		}, codes.InvalidArgument},
+	}
+
+	for _, c := range calls {
+		if code := status.Code(c.call()); code != c.code {
+			t.Errorf("%s code is %v, expected %v", c.name, code, c.code)
+		}
+	}
+}
+
+func TestGRPC_Auth(t *testing.T) {
+	conn := NewConn()
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=stack", nil)
+	_, _ = conn.Auth.Add("ro", auth.ScopeReadOnly, nil, time.Now())
+	_, _ = conn.Auth.Add("rwdb", auth.ScopeReadWrite, []string{"db"}, time.Now())
+	client, closeClient := grpcTestConn(t, conn)
+	defer closeClient()
+
+	withToken := func(secret string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+secret)
+	}
+	stack := &pilapb.StackRequest{Database: "db", Stack: "stack"}
+	push := &pilapb.PushRequest{Database: "db", Stack: "stack", Element: grpcTestElement(t, "foo").Value}
+
+	calls := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{"call without token", func() error {
+			_, err := client.Size(context.Background(), stack)
+			return err
+		}, codes.Unauthenticated},
+		{"call with invalid token", func() error {
+			_, err := client.Size(withToken("foo"), stack)
+			return err
+		}, codes.Unauthenticated},
+		{"call without scheme", func() error {
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "ro")
+			_, err := client.Size(ctx, stack)
+			return err
+		}, codes.Unauthenticated},
+		{"read with read-only token", func() error {
+			_, err := client.Size(withToken("ro"), stack)
+			return err
+		}, codes.OK},
+		{"push with read-only token", func() error {
+			_, err := client.Push(withToken("ro"), push)
+			return err
+		}, codes.PermissionDenied},
+		{"push with read-write token", func() error {
+			_, err := client.Push(withToken("rwdb"), push)
+			return err
+		}, codes.OK},
+		{"status with database token", func() error {
+			_, err := client.Status(withToken("rwdb"), &emptypb.Empty{})
+			return err
+		}, codes.PermissionDenied},
+		{"database with database token", func() error {
+			_, err := client.CreateDatabase(withToken("rwdb"), &pilapb.CreateDatabaseRequest{Name: "other"})
+			return err
+		}, codes.PermissionDenied},
+		{"stream with read-only token", func() error {
+			stream, err := client.StreamPops(withToken("ro"), stack)
+			if err != nil {
+				return err
+			}
+			_, err = stream.Recv()
+			return err
+		}, codes.PermissionDenied},
+	}
+
+	for _, c := range calls {
+		if code := status.Code(c.call()); code != c.code {
+			t.Errorf("%s code is %v, expected %v", c.name, code, c.code)
+		}
+	}
+}
+
+func TestGRPCPop_Wait(t *testing.T) {
+	conn := NewConn()
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=stack", nil)
+	client, closeClient := grpcTestConn(t, conn)
+	defer closeClient()
+	ctx := context.Background()
+
+	popped, err := client.Pop(ctx, &pilapb.PopRequest{Database: "db", Stack: "stack", Wait: durationpb.New(50 * time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if popped.Element != nil {
+		t.Errorf("popped element is %v, expected none", popped.Element)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"foo"}`))
+	}()
+	popped, err = client.Pop(ctx, &pilapb.PopRequest{Database: "db", Stack: "stack", Wait: durationpb.New(5 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := popped.Element.Interface(); v != "foo" {
+		t.Errorf("popped element is %v, expected %v", v, "foo")
+	}
+
+	// the deadline of the call bounds the wait
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = client.Pop(ctx, &pilapb.PopRequest{Database: "db", Stack: "stack", Wait: durationpb.New(5 * time.Second)})
+	if code := status.Code(err); code != codes.DeadlineExceeded && code != codes.OK {
+		t.Errorf("pop code is %v, expected %v", code, codes.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("pop waited for %v, expected less than %v", d, 2*time.Second)
+	}
+}
+
+func TestGRPCPop_WaitOrder(t *testing.T) {
+	conn := NewConn()
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=stack", nil)
+	client, closeClient := grpcTestConn(t, conn)
+	defer closeClient()
+
+	// an HTTP caller waits first
+	codes := make(chan int, 1)
+	go func() {
+		codes <- serve(t, conn, "DELETE", "/databases/db/stacks/stack?wait=5s", nil)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	popped := make(chan interface{}, 1)
+	go func() {
+		res, err := client.Pop(context.Background(), &pilapb.PopRequest{Database: "db", Stack: "stack", Wait: durationpb.New(5 * time.Second)})
+		if err != nil || res.Element == nil {
+			popped <- err
+			return
+		}
+		popped <- res.Element.Interface()
+	}()
+
+	// the gRPC caller does not overtake it when polling, and
+	// the element is pushed halfway through one of its polls
+	time.Sleep(3*grpcPollInterval + grpcPollInterval/2)
+	serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"foo"}`))
+	select {
+	case code := <-codes:
+		if code != http.StatusOK {
+			t.Errorf("response code is %v, expected %v", code, http.StatusOK)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("HTTP caller did not pop the element")
+	}
+
+	serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"bar"}`))
+	if v := <-popped; v != "bar" {
+		t.Errorf("popped element is %v, expected %v", v, "bar")
+	}
+}
+
+func TestGRPCStreamPops(t *testing.T) {
+	conn := NewConn()
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=stack", nil)
+	serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"foo"}`))
+	client, closeClient := grpcTestConn(t, conn)
+	defer closeClient()
+
+	stream, err := client.StreamPops(context.Background(), &pilapb.StackRequest{Database: "db", Stack: "stack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"foo", "bar", "baz"} {
+		if expected != "foo" {
+			serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"`+expected+`"}`))
+		}
+		element, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := element.Interface(); v != expected {
+			t.Errorf("streamed element is %v, expected %v", v, expected)
+		}
+	}
+
+	// the stream ends once the Stack is removed
+	serve(t, conn, "DELETE", "/databases/db/stacks/stack?full", nil)
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("err is %v, expected %v", err, io.EOF)
+	}
+}
+
+func TestGRPCStreamPops_Cancel(t *testing.T) {
+	conn := NewConn()
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=stack", nil)
+	client, closeClient := grpcTestConn(t, conn)
+	defer closeClient()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.StreamPops(ctx, &pilapb.StackRequest{Database: "db", Stack: "stack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Errorf("err is %v, expected code %v", err, codes.Canceled)
+	}
+
+	// elements pushed after the cancellation are not popped
+	time.Sleep(2 * grpcPollInterval)
+	serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"foo"}`))
+	time.Sleep(2 * grpcPollInterval)
+	db, _ := ResourceDatabase(conn, "db")
+	stack, _ := ResourceStack(db, "stack")
+	if stack.Size() != 1 {
+		t.Errorf("stack size is %d, expected %d", stack.Size(), 1)
+	}
+}
+
+// failingStream is a stream of pops whose sends fail.
+type failingStream struct {
+	grpc.ServerStream
+}
+
+func (failingStream) Context() context.Context { return context.Background() }
+
+func (failingStream) Send(*pilapb.Element) error { return io.ErrClosedPipe }
+
+func TestGRPCStreamPops_SendError(t *testing.T) {
+	conn := NewConn()
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=stack", nil)
+	serve(t, conn, "POST", "/databases/db/stacks/stack", []byte(`{"element":"foo"}`))
+
+	server := &pilaServer{c: conn}
+	if err := server.StreamPops(&pilapb.StackRequest{Database: "db", Stack: "stack"}, failingStream{}); err != io.ErrClosedPipe {
+		t.Errorf("err is %v, expected %v", err, io.ErrClosedPipe)
+	}
+
+	// the element that could not be sent is pushed back
+	db, _ := ResourceDatabase(conn, "db")
+	stack, _ := ResourceStack(db, "stack")
+	if stack.Size() != 1 || stack.Peek() != "foo" {
+		t.Errorf("stack has size %d and peek %v, expected %d and %v", stack.Size(), stack.Peek(), 1, "foo")
+	}
+}
+
+func TestGRPC_AOF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	conn := aofTestConn(t, path)
+	client, closeClient := grpcTestConn(t, conn)
+	ctx := context.Background()
+	if _, err := client.CreateDatabase(ctx, &pilapb.CreateDatabaseRequest{Name: "db"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateStack(ctx, &pilapb.CreateStackRequest{Database: "db", Name: "stack"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, element := range []string{"foo", "bar", "baz"} {
+		if _, err := client.Push(ctx, &pilapb.PushRequest{Database: "db", Stack: "stack", Element: grpcTestElement(t, element).Value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := client.Pop(ctx, &pilapb.PopRequest{Database: "db", Stack: "stack"}); err != nil {
+		t.Fatal(err)
+	}
+	closeClient()
+	conn.aof.Close()
+
+	replayed := aofTestConn(t, path)
+	defer replayed.aof.Close()
+
+	db, ok := ResourceDatabase(replayed, "db")
+	if !ok {
+		t.Fatal("database db was not replayed")
+	}
+	stack, ok := ResourceStack(db, "stack")
+	if !ok {
+		t.Fatal("stack stack was not replayed")
+	}
+	if stack.Size() != 2 {
+		t.Errorf("stack size is %d, expected %d", stack.Size(), 2)
+	}
+	if stack.Peek() != "bar" {
+		t.Errorf("stack peek is %v, expected %v", stack.Peek(), "bar")
+	}
+}
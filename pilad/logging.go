@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/fern4lvarez/piladb/pkg/logger"
+)
+
+// requestIDHeader is the header that carries the ID of a request,
+// both in the request and in its response.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the maximum length of the request IDs
+// taken from requests.
+const maxRequestIDLength = 128
+
+// requestIDKey is the key of the ID of a request in its context.
+type requestIDKey struct{}
+
+// requestIDMiddleware identifies requests by the ID given in their
+// X-Request-ID header, or by a new one if they have none or it is not
+// valid. The ID is returned in the X-Request-ID header of the response,
+// and is logged along with the request in every line logged for it.
+func (c *Conn) requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		l := c.Logger.With("request_id", id, "method", r.Method, "url", r.URL.String())
+		if identity := tlsIdentity(r.TLS); identity != "" {
+			l = l.With("client", identity)
+		}
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = logger.NewContext(ctx, l)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestID returns the ID of a request, or an empty ID if it was not
+// identified.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID returns a random request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	// crypto/rand.Read never returns an error.
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID returns true if a request ID given by a client is not
+// empty nor too long, and contains only letters, digits, dashes, dots,
+// underscores or colons, so it can be logged and returned as is.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '.', r == '_', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// logRequest logs a request responded with a status code, at warn level
+// for client errors and at error level for server errors, along with
+// details given by args, formatted like log.Println.
+func (c *Conn) logRequest(r *http.Request, code int, args ...interface{}) {
+	l, ok := logger.FromContext(r.Context())
+	if !ok {
+		l = c.Logger.With("method", r.Method, "url", r.URL.String())
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case code >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case code >= http.StatusBadRequest:
+		level = slog.LevelWarn
+	}
+
+	attrs := []interface{}{"status", code}
+	if len(args) > 0 {
+		attrs = append(attrs, "detail", strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+	}
+	l.Log(r.Context(), level, http.StatusText(code), attrs...)
+}
+
+// applyLogConfig sets the level and format of the Logger given by
+// the config values.
+func (c *Conn) applyLogConfig() {
+	// Config values are always valid levels and
+	// formats, so there cannot be any error.
+	_ = c.Logger.SetLevel(c.Config.LogLevel())
+	_ = c.Logger.SetFormat(c.Config.LogFormat())
+}
+
+// fatal logs an error and exits.
+func (c *Conn) fatal(msg string, err error) {
+	c.Logger.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fern4lvarez/piladb/pkg/logger"
+	"github.com/fern4lvarez/piladb/pkg/pilapb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// logBuffer is a bytes.Buffer safe for concurrent use, where
+// the lines of a Conn are logged in tests.
+type logBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (lb *logBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.b.Write(p)
+}
+
+func (lb *logBuffer) String() string {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.b.String()
+}
+
+func (lb *logBuffer) Reset() {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.b.Reset()
+}
+
+// loggedConn returns a new Conn whose lines are logged into a
+// logBuffer.
+func loggedConn() (*Conn, *logBuffer) {
+	conn := NewConn()
+	conn.buildConfig()
+	lb := &logBuffer{}
+	conn.Logger = logger.New(lb)
+	return conn, lb
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	conn, lb := loggedConn()
+
+	for _, tc := range []struct {
+		requestID string
+		generated bool
+	}{
+		{"", true},
+		{"my-request.1_a:b", false},
+		{"with spaces", true},
+		{strings.Repeat("a", maxRequestIDLength+1), true},
+	} {
+		lb.Reset()
+		request, _ := http.NewRequest("GET", "/_ping", nil)
+		if tc.requestID != "" {
+			request.Header.Set(requestIDHeader, tc.requestID)
+		}
+		response := httptest.NewRecorder()
+		Router(conn).ServeHTTP(response, request)
+
+		id := response.Header().Get(requestIDHeader)
+		if tc.generated && (id == tc.requestID || len(id) != 32) {
+			t.Errorf("request ID is %q, expected a generated one", id)
+		}
+		if !tc.generated && id != tc.requestID {
+			t.Errorf("request ID is %q, expected %q", id, tc.requestID)
+		}
+		if !strings.Contains(lb.String(), "request_id="+id+" ") {
+			t.Errorf("log is %q, expected request ID %s", lb.String(), id)
+		}
+	}
+
+	// requests to unknown routes are identified too
+	lb.Reset()
+	request, _ := http.NewRequest("GET", "/nope", nil)
+	request.Header.Set(requestIDHeader, "unknown")
+	response := httptest.NewRecorder()
+	Router(conn).ServeHTTP(response, request)
+	if id := response.Header().Get(requestIDHeader); id != "unknown" {
+		t.Errorf("request ID is %q, expected %q", id, "unknown")
+	}
+	if !strings.Contains(lb.String(), "level=WARN msg=\"Not Found\" request_id=unknown method=GET url=/nope status=404") {
+		t.Errorf("log is %q, unexpected", lb.String())
+	}
+}
+
+func TestLogRequest(t *testing.T) {
+	conn, lb := loggedConn()
+
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	if expected := `level=INFO msg=Created request_id=`; !strings.Contains(lb.String(), expected) {
+		t.Errorf("log is %q, expected %q", lb.String(), expected)
+	}
+	if expected := `method=PUT url="/databases?name=db" status=201`; !strings.Contains(lb.String(), expected) {
+		t.Errorf("log is %q, expected %q", lb.String(), expected)
+	}
+
+	lb.Reset()
+	serve(t, conn, "GET", "/databases/nope", nil)
+	if expected := `level=WARN msg=Gone`; !strings.Contains(lb.String(), expected) {
+		t.Errorf("log is %q, expected %q", lb.String(), expected)
+	}
+	if expected := `status=410 detail="database nope is Gone"`; !strings.Contains(lb.String(), expected) {
+		t.Errorf("log is %q, expected %q", lb.String(), expected)
+	}
+
+	// requests not identified are logged without ID
+	lb.Reset()
+	request, _ := http.NewRequest("GET", "/_ping", nil)
+	conn.pingHandler(httptest.NewRecorder(), request)
+	if expected := "level=INFO msg=OK method=GET url=/_ping status=200\n"; !strings.HasSuffix(lb.String(), expected) {
+		t.Errorf("log is %q, expected %q", lb.String(), expected)
+	}
+}
+
+func TestLogConfig(t *testing.T) {
+	conn, lb := loggedConn()
+
+	serve(t, conn, "POST", "/_config/LOG_LEVEL", []byte(`{"element":"warn"}`))
+	lb.Reset()
+	serve(t, conn, "GET", "/_ping", nil)
+	if lb.String() != "" {
+		t.Errorf("log is %q, expected none", lb.String())
+	}
+	serve(t, conn, "GET", "/nope", nil)
+	if !strings.Contains(lb.String(), "level=WARN") {
+		t.Errorf("log is %q, expected warning", lb.String())
+	}
+
+	serve(t, conn, "POST", "/_config/LOG_LEVEL", []byte(`{"element":"debug"}`))
+	serve(t, conn, "POST", "/_config/LOG_FORMAT", []byte(`{"element":"json"}`))
+	lb.Reset()
+	request, _ := http.NewRequest("GET", "/_ping", nil)
+	request.Header.Set(requestIDHeader, "42")
+	Router(conn).ServeHTTP(httptest.NewRecorder(), request)
+
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(lb.String()), &line); err != nil {
+		t.Fatalf("log %q is not JSON: %v", lb.String(), err)
+	}
+	if line["request_id"] != "42" || line["status"] != float64(http.StatusOK) || line["level"] != "INFO" {
+		t.Errorf("log is %v, unexpected", line)
+	}
+
+	// unknown values are rejected
+	if code := serve(t, conn, "POST", "/_config/LOG_FORMAT", []byte(`{"element":"xml"}`)); code != http.StatusBadRequest {
+		t.Errorf("response code is %d, expected %d", code, http.StatusBadRequest)
+	}
+	if f := conn.Logger.Format(); f != logger.FormatJSON {
+		t.Errorf("format is %s, expected %s", f, logger.FormatJSON)
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	for _, tc := range []struct {
+		id       string
+		expected bool
+	}{
+		{"", false},
+		{"abc-DEF_0.1:2", true},
+		{strings.Repeat("a", maxRequestIDLength), true},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+		{"a b", false},
+		{"a\nb", false},
+		{`a"b`, false},
+	} {
+		if ok := validRequestID(tc.id); ok != tc.expected {
+			t.Errorf("validRequestID(%q) is %v, expected %v", tc.id, ok, tc.expected)
+		}
+	}
+}
+
+func TestGRPCRequestID(t *testing.T) {
+	conn, lb := loggedConn()
+	client, closeClient := grpcTestConn(t, conn)
+	defer closeClient()
+
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "grpc-42")
+	if _, err := client.CreateDatabase(ctx, &pilapb.CreateDatabaseRequest{Name: "db"}, grpc.Header(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if ids := header.Get(requestIDHeader); len(ids) != 1 || ids[0] != "grpc-42" {
+		t.Errorf("request IDs are %v, expected %s", ids, "grpc-42")
+	}
+	if expected := "level=INFO msg=\"gRPC call\" request_id=grpc-42 method=/pila.Pila/CreateDatabase code=OK"; !strings.Contains(lb.String(), expected) {
+		t.Errorf("log is %q, expected %q", lb.String(), expected)
+	}
+}
@@ -1,8 +1,30 @@
 package main
 
-import "log"
+import (
+	"log"
+
+	"github.com/fern4lvarez/piladb/pkg/logger"
+)
 
 func logo(conn *Conn) {
+	attrs := []interface{}{
+		"version", conn.Status.Version,
+		"go_version", conn.Status.GoVersion,
+		"host", conn.Status.Host,
+		"port", conn.Config.Port(),
+		"pid", conn.Status.PID,
+	}
+	if port := conn.Config.RESPPort(); port != 0 {
+		attrs = append(attrs, "resp_port", port)
+	}
+
+	// The logo would break the lines
+	// of logs in JSON format.
+	if conn.Logger.Format() == logger.FormatJSON {
+		conn.Logger.Info("pilad started", attrs...)
+		return
+	}
+
 	log.Println()
 	log.Println("         d8b 888               888 888      ")
 	log.Println("         Y8P 888               888 888      ")
@@ -20,6 +42,9 @@ func logo(conn *Conn) {
 	log.Printf("Go Version:   %s", conn.Status.GoVersion)
 	log.Printf("Host:         %s", conn.Status.Host)
 	log.Printf("Port:         %d", conn.Config.Port())
+	if port := conn.Config.RESPPort(); port != 0 {
+		log.Printf("RESP Port:    %d", port)
+	}
 	log.Printf("PID:          %d", conn.Status.PID)
 	log.Println()
 }
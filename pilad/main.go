@@ -4,8 +4,12 @@ package main
 import (
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -15,16 +19,86 @@ func main() {
 		fmt.Println(v())
 		return
 	}
+	visitFlags()
 
 	conn := NewConn()
-	conn.buildConfig()
+	if err := conn.buildConfig(); err != nil {
+		conn.fatal("error on reading config file", err)
+	}
+	conn.applyLogConfig()
+	if err := conn.buildAuth(); err != nil {
+		conn.fatal("error on adding admin token", err)
+	}
 	logo(conn)
+	// Lines logged by other packages are
+	// written by the Logger as well.
+	slog.SetDefault(conn.Logger.Logger)
+
+	// The append-only file, if enabled, always contains
+	// newer data than the snapshot file, which is only
+	// restored if the append-only file does not exist.
+	restore := conn.restore
+	if conn.Config.AOFPath() != "" {
+		restore = conn.openAOF
+	}
+	if err := restore(); err != nil {
+		conn.fatal("error on restoring data", err)
+	}
+	// Operations are observed once data is restored,
+	// so replayed ones are not counted.
+	conn.Pila.SetHook(conn.Metrics.ObserveOp)
+	go conn.snapshotLoop()
+	go conn.aofRewriteLoop()
+	go conn.expireLoop()
+	go conn.reapLoop()
+
+	// The config file, if any, is read again on SIGHUP.
+	if conn.configPath != "" {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGHUP)
+		go conn.reloadConfigLoop(signals)
+	}
+
+	if port := conn.Config.RESPPort(); port != 0 {
+		l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			conn.fatal("error on listening to RESP clients", err)
+		}
+		go func() { conn.fatal("error on serving RESP clients", conn.serveRESP(l)) }()
+	}
+
+	if port := conn.Config.GRPCPort(); port != 0 {
+		l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			conn.fatal("error on listening to gRPC clients", err)
+		}
+		go func() { conn.fatal("error on serving gRPC clients", conn.serveGRPC(l)) }()
+	}
 
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", conn.Config.Port()),
 		Handler:      Router(conn),
 		ReadTimeout:  conn.Config.ReadTimeout() * time.Second,
 		WriteTimeout: conn.Config.WriteTimeout() * time.Second,
+		ErrorLog:     slog.NewLogLogger(conn.Logger.Handler(), slog.LevelWarn),
+	}
+
+	cert, key, ca := conn.Config.TLSCert(), conn.Config.TLSKey(), conn.Config.TLSCA()
+	if cert == "" && key == "" && ca == "" {
+		conn.fatal("error on serving HTTP", srv.ListenAndServe())
 	}
-	log.Fatal(srv.ListenAndServe())
+
+	reloader, err := newTLSReloader(cert, key, ca, conn.Logger.Logger)
+	if err != nil {
+		conn.fatal("error on loading TLS certificates", err)
+	}
+	srv.TLSConfig = reloader.config()
+
+	// Certificates are reloaded on SIGHUP, without
+	// closing established connections.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go reloadTLSLoop(reloader, signals)
+
+	conn.fatal("error on serving HTTPS", srv.ListenAndServeTLS("", ""))
 }
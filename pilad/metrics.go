@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// durationBuckets are the upper bounds in seconds of the buckets
+// of the histograms of the duration of requests.
+var durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics holds the counters of the requests to piladb and of the
+// operations on its Stacks, which are exposed along with the size
+// of its data in the Prometheus text format.
+type Metrics struct {
+	// mu guards requests and operations, as they are
+	// increased concurrently.
+	mu         sync.Mutex
+	requests   map[requestLabels]*histogram
+	operations map[operationLabels]int
+}
+
+// requestLabels identifies the requests to a route with a method
+// that returned a status code.
+type requestLabels struct {
+	route, method, code string
+}
+
+// operationLabels identifies the operations of a type on the Stacks
+// of a Database.
+type operationLabels struct {
+	database, op string
+}
+
+// histogram counts observations in durationBuckets.
+type histogram struct {
+	buckets []int
+	count   int
+	sum     float64
+}
+
+// NewMetrics returns new Metrics without observations.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:   make(map[requestLabels]*histogram),
+		operations: make(map[operationLabels]int),
+	}
+}
+
+// ObserveRequest counts a request to a route, given by its path
+// template, with a method, and the status code and duration of the
+// response.
+func (m *Metrics) ObserveRequest(route, method string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels := requestLabels{route, method, strconv.Itoa(code)}
+	h, ok := m.requests[labels]
+	if !ok {
+		h = &histogram{buckets: make([]int, len(durationBuckets))}
+		m.requests[labels] = h
+	}
+
+	seconds := d.Seconds()
+	for i, le := range durationBuckets {
+		if seconds <= le {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// ObserveOp counts an operation on n elements of a Stack of a
+// Database. It is a pila.Hook.
+func (m *Metrics) ObserveOp(database, stack, op string, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.operations[operationLabels{database, op}] += n
+}
+
+// WriteText writes the metrics in the Prometheus text format, along
+// with the sizes of the Stacks mapped by Database and Stack names,
+// and the status of piladb. Sizes of single Stacks are written only
+// if there are no more than maxStacks Stacks.
+func (m *Metrics) WriteText(w io.Writer, sizes map[string]map[string]int, maxStacks int, status *Status, mem *runtime.MemStats) error {
+	var b bytes.Buffer
+
+	m.mu.Lock()
+	requests := make([]requestLabels, 0, len(m.requests))
+	for labels := range m.requests {
+		requests = append(requests, labels)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+
+	writeHeader(&b, "piladb_http_requests_total", "counter", "Number of HTTP requests by route, method and status code.")
+	for _, labels := range requests {
+		fmt.Fprintf(&b, "piladb_http_requests_total{%s} %d\n", labels, m.requests[labels].count)
+	}
+
+	writeHeader(&b, "piladb_http_request_duration_seconds", "histogram", "Duration of HTTP requests by route, method and status code.")
+	for _, labels := range requests {
+		h := m.requests[labels]
+		for i, le := range durationBuckets {
+			fmt.Fprintf(&b, "piladb_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels, strconv.FormatFloat(le, 'g', -1, 64), h.buckets[i])
+		}
+		fmt.Fprintf(&b, "piladb_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(&b, "piladb_http_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "piladb_http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	operations := make([]operationLabels, 0, len(m.operations))
+	for labels := range m.operations {
+		operations = append(operations, labels)
+	}
+	sort.Slice(operations, func(i, j int) bool {
+		a, b := operations[i], operations[j]
+		if a.database != b.database {
+			return a.database < b.database
+		}
+		return a.op < b.op
+	})
+
+	writeHeader(&b, "piladb_stack_operations_total", "counter", "Number of elements pushed, popped and peeked, and number of flushes, by database.")
+	for _, labels := range operations {
+		fmt.Fprintf(&b, "piladb_stack_operations_total{database=\"%s\",op=\"%s\"} %d\n",
+			escapeLabel(labels.database), labels.op, m.operations[labels])
+	}
+	m.mu.Unlock()
+
+	databases := make([]string, 0, len(sizes))
+	var numberStacks int
+	for database, stacks := range sizes {
+		databases = append(databases, database)
+		numberStacks += len(stacks)
+	}
+	sort.Strings(databases)
+
+	writeHeader(&b, "piladb_databases", "gauge", "Number of databases.")
+	fmt.Fprintf(&b, "piladb_databases %d\n", len(databases))
+
+	writeHeader(&b, "piladb_stacks", "gauge", "Number of stacks by database.")
+	for _, database := range databases {
+		fmt.Fprintf(&b, "piladb_stacks{database=\"%s\"} %d\n", escapeLabel(database), len(sizes[database]))
+	}
+
+	writeHeader(&b, "piladb_database_elements", "gauge", "Number of elements in the stacks of a database.")
+	for _, database := range databases {
+		var elements int
+		for _, size := range sizes[database] {
+			elements += size
+		}
+		fmt.Fprintf(&b, "piladb_database_elements{database=\"%s\"} %d\n", escapeLabel(database), elements)
+	}
+
+	if maxStacks > 0 && numberStacks <= maxStacks {
+		writeHeader(&b, "piladb_stack_size", "gauge", "Number of elements in a stack.")
+		for _, database := range databases {
+			stacks := make([]string, 0, len(sizes[database]))
+			for stack := range sizes[database] {
+				stacks = append(stacks, stack)
+			}
+			sort.Strings(stacks)
+
+			for _, stack := range stacks {
+				fmt.Fprintf(&b, "piladb_stack_size{database=\"%s\",stack=\"%s\"} %d\n",
+					escapeLabel(database), escapeLabel(stack), sizes[database][stack])
+			}
+		}
+	}
+
+	writeHeader(&b, "piladb_goroutines", "gauge", "Number of goroutines.")
+	fmt.Fprintf(&b, "piladb_goroutines %d\n", runtime.NumGoroutine())
+
+	writeHeader(&b, "piladb_memory_alloc_bytes", "gauge", "Bytes of allocated heap objects.")
+	fmt.Fprintf(&b, "piladb_memory_alloc_bytes %d\n", mem.Alloc)
+
+	writeHeader(&b, "piladb_uptime_seconds", "gauge", "Seconds since piladb started.")
+	fmt.Fprintf(&b, "piladb_uptime_seconds %s\n",
+		strconv.FormatFloat(time.Since(status.StartedAt).Seconds(), 'f', 3, 64))
+
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// String returns the labels of a request in the Prometheus text
+// format, without braces.
+func (labels requestLabels) String() string {
+	return fmt.Sprintf("route=\"%s\",method=\"%s\",code=\"%s\"",
+		escapeLabel(labels.route), escapeLabel(labels.method), labels.code)
+}
+
+// writeHeader writes the help and type lines of a metric.
+func writeHeader(b *bytes.Buffer, name, metricType, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// labelEscaper escapes the values of labels in the Prometheus
+// text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes the value of a label.
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// metricsHandler writes the metrics of piladb in the Prometheus
+// text format.
+func (c *Conn) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.logRequest(r, http.StatusOK)
+	if err := c.Metrics.WriteText(w, c.Pila.Sizes(), c.Config.MetricsMaxStacks(), c.Status, MemStats()); err != nil {
+		c.Logger.Warn("error on writing metrics", "error", err)
+	}
+}
+
+// metricsMiddleware observes the route, method, status code and
+// duration of requests.
+func (c *Conn) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var route string
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		c.Metrics.ObserveRequest(route, r.Method, recorder.code, time.Since(start))
+	})
+}
+
+// statusRecorder is an http.ResponseWriter that records the status
+// code of the response. It can be flushed and hijacked if the
+// underlying http.ResponseWriter can.
+type statusRecorder struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+// WriteHeader records the status code and writes it.
+func (rec *statusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.code = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes data, with status code 200 if none was written.
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client.
+func (rec *statusRecorder) Flush() {
+	rec.wroteHeader = true
+	_ = http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+// Hijack takes over the connection, which switches protocols.
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("connection cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && !rec.wroteHeader {
+		rec.code = http.StatusSwitchingProtocols
+		rec.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter, so it can be
+// controlled by an http.ResponseController.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/config/vars"
+)
+
+func TestMetricsWriteText(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveRequest("/_ping", "GET", http.StatusOK, 20*time.Millisecond)
+	m.ObserveRequest("/_ping", "GET", http.StatusOK, 2*time.Second)
+	m.ObserveRequest("/databases/{id}", "GET", http.StatusGone, time.Millisecond)
+	m.ObserveOp("db", "stack", "push", 3)
+	m.ObserveOp("db", "other", "push", 1)
+	m.ObserveOp(`d"b`, "stack", "pop", 1)
+
+	sizes := map[string]map[string]int{
+		"db":  {"stack": 2, "other": 1},
+		`d"b`: {"stack": 0},
+	}
+	status := NewStatus("v", time.Now().Add(-time.Minute), nil)
+
+	var b bytes.Buffer
+	if err := m.WriteText(&b, sizes, 3, status, MemStats()); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"# TYPE piladb_http_requests_total counter\n",
+		`piladb_http_requests_total{route="/_ping",method="GET",code="200"} 2` + "\n",
+		`piladb_http_requests_total{route="/databases/{id}",method="GET",code="410"} 1` + "\n",
+		"# TYPE piladb_http_request_duration_seconds histogram\n",
+		`piladb_http_request_duration_seconds_bucket{route="/_ping",method="GET",code="200",le="0.01"} 0` + "\n",
+		`piladb_http_request_duration_seconds_bucket{route="/_ping",method="GET",code="200",le="0.025"} 1` + "\n",
+		`piladb_http_request_duration_seconds_bucket{route="/_ping",method="GET",code="200",le="2.5"} 2` + "\n",
+		`piladb_http_request_duration_seconds_bucket{route="/_ping",method="GET",code="200",le="+Inf"} 2` + "\n",
+		`piladb_http_request_duration_seconds_sum{route="/_ping",method="GET",code="200"} 2.02` + "\n",
+		`piladb_http_request_duration_seconds_count{route="/_ping",method="GET",code="200"} 2` + "\n",
+		`piladb_stack_operations_total{database="db",op="push"} 4` + "\n",
+		`piladb_stack_operations_total{database="d\"b",op="pop"} 1` + "\n",
+		"piladb_databases 2\n",
+		`piladb_stacks{database="db"} 2` + "\n",
+		`piladb_database_elements{database="db"} 3` + "\n",
+		`piladb_database_elements{database="d\"b"} 0` + "\n",
+		`piladb_stack_size{database="db",stack="other"} 1` + "\n",
+		`piladb_stack_size{database="db",stack="stack"} 2` + "\n",
+		"piladb_uptime_seconds 60.",
+		"piladb_goroutines ",
+		"piladb_memory_alloc_bytes ",
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("metrics do not contain %q:\n%s", expected, b.String())
+		}
+	}
+
+	// sizes of stacks are not written over the limit
+	for _, maxStacks := range []int{0, 2} {
+		b.Reset()
+		if err := m.WriteText(&b, sizes, maxStacks, status, MemStats()); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(b.String(), "piladb_stack_size") {
+			t.Errorf("metrics with %d max stacks contain stack sizes:\n%s", maxStacks, b.String())
+		}
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	conn := NewConn()
+	conn.buildConfig()
+	conn.Config.Set(vars.MetricsMaxStacks, 10)
+	conn.Pila.SetHook(conn.Metrics.ObserveOp)
+
+	for _, r := range []struct {
+		method, url, body string
+	}{
+		{"PUT", "/databases?name=db", ""},
+		{"PUT", "/databases/db/stacks?name=stack", ""},
+		{"POST", "/databases/db/stacks/stack", `{"element":1}`},
+		{"POST", "/databases/db/stacks/stack?batch", `[2,3]`},
+		{"GET", "/databases/db/stacks/stack?peek", ""},
+		{"DELETE", "/databases/db/stacks/stack", ""},
+		{"GET", "/databases/nope", ""},
+	} {
+		serve(t, conn, r.method, r.url, []byte(r.body))
+	}
+
+	request, _ := http.NewRequest("GET", "/_metrics", nil)
+	response := httptest.NewRecorder()
+	Router(conn).ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("response code is %d, expected %d", response.Code, http.StatusOK)
+	}
+	if contentType := response.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type is %s, expected text/plain", contentType)
+	}
+	for _, expected := range []string{
+		`piladb_http_requests_total{route="/databases",method="PUT",code="201"} 1`,
+		`piladb_http_requests_total{route="/databases/{database_id}/stacks/{stack_id}",method="POST",code="200"} 2`,
+		`piladb_http_requests_total{route="/databases/{id}",method="GET",code="410"} 1`,
+		`piladb_stack_operations_total{database="db",op="push"} 3`,
+		`piladb_stack_operations_total{database="db",op="peek"} 1`,
+		`piladb_stack_operations_total{database="db",op="pop"} 1`,
+		`piladb_database_elements{database="db"} 2`,
+		`piladb_stack_size{database="db",stack="stack"} 2`,
+	} {
+		if !strings.Contains(response.Body.String(), expected) {
+			t.Errorf("metrics do not contain %q:\n%s", expected, response.Body.String())
+		}
+	}
+}
+
+func TestStatusRecorder(t *testing.T) {
+	response := httptest.NewRecorder()
+	recorder := &statusRecorder{ResponseWriter: response, code: http.StatusOK}
+
+	recorder.WriteHeader(http.StatusCreated)
+	recorder.WriteHeader(http.StatusConflict)
+	if recorder.code != http.StatusCreated {
+		t.Errorf("code is %d, expected %d", recorder.code, http.StatusCreated)
+	}
+
+	recorder.Flush()
+	if !response.Flushed {
+		t.Error("response is not flushed, expected flushed")
+	}
+	if recorder.Unwrap() != response {
+		t.Error("unwrapped response writer is not the recorded one")
+	}
+	if _, _, err := recorder.Hijack(); err == nil {
+		t.Error("err is nil, expected error")
+	}
+}
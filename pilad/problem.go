@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fern4lvarez/piladb/pila"
+)
+
+// problemContentType is the media type of the bodies of the error
+// responses, as defined by RFC 9457.
+const problemContentType = "application/problem+json"
+
+// Codes of the problems, which identify the cause of an error response
+// in a machine-readable way, as the same status code may be returned
+// for different causes.
+const (
+	problemMissingParameter = "missing_parameter"
+	problemMissingBody      = "missing_body"
+	problemMalformedBody    = "malformed_body"
+	problemInvalidParameter = "invalid_parameter"
+	problemInvalidIfMatch   = "invalid_if_match"
+	problemInvalidOperation = "invalid_operation"
+	problemInvalidScope     = "invalid_scope"
+	problemDatabaseExists   = "database_exists"
+	problemStackExists      = "stack_exists"
+	problemTokenExists      = "token_exists"
+	problemDatabaseGone     = "database_gone"
+	problemStackGone        = "stack_gone"
+	problemTokenGone        = "token_gone"
+	problemConfigGone       = "config_gone"
+	problemInvalidConfig    = "invalid_config_value"
+	problemImmutableConfig  = "immutable_config_value"
+	problemMaxStackSize     = "max_stack_size"
+	problemVersionMismatch  = "version_mismatch"
+	problemTxAborted        = "transaction_aborted"
+	problemNotImplemented   = "not_implemented"
+	problemUnauthorized     = "unauthorized"
+	problemForbidden        = "forbidden"
+	problemNotFound         = "not_found"
+	problemMethodNotAllowed = "method_not_allowed"
+	problemSnapshotPath     = "snapshot_path_not_set"
+	problemSnapshot         = "snapshot_failed"
+	problemSerialization    = "serialization_failed"
+)
+
+// Problem represents the body of an error response, following
+// the application/problem+json format of RFC 9457.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// newProblem returns the Problem of a request responded with a status
+// code, given its code and details given by args, formatted like
+// log.Println. The instance is the path of the resource involved.
+func newProblem(r *http.Request, status int, code string, args ...interface{}) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Code:      code,
+		Detail:    strings.TrimSuffix(fmt.Sprintln(args...), "\n"),
+		Instance:  r.URL.Path,
+		RequestID: requestID(r),
+	}
+}
+
+// problem logs a request and responds to it with a status code
+// and a Problem body.
+func (c *Conn) problem(w http.ResponseWriter, r *http.Request, status int, code string, args ...interface{}) {
+	c.logRequest(r, status, args...)
+	writeProblem(w, status, newProblem(r, status, code, args...))
+}
+
+// writeProblem writes a problem, or any value extending it, as the
+// body of a response with a status code.
+func writeProblem(w http.ResponseWriter, status int, problem interface{}) {
+	// Do not check error as problems do not contain
+	// types that could cause such case.
+	b, _ := json.Marshal(problem)
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// txProblem is the Problem of an aborted transaction, extended with
+// the status of the transaction, so clients know which operation
+// aborted it.
+type txProblem struct {
+	Problem
+	pila.TxStatus
+}
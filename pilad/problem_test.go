@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/auth"
+)
+
+func TestProblem(t *testing.T) {
+	conn, lb := loggedConn()
+	request, err := http.NewRequest("GET", "/databases/nodb?peek", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set(requestIDHeader, "abc")
+	response := httptest.NewRecorder()
+
+	conn.requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn.problem(w, r, http.StatusGone, problemDatabaseGone, "database", "nodb", "is Gone")
+	})).ServeHTTP(response, request)
+
+	if response.Code != http.StatusGone {
+		t.Errorf("response code is %d, expected %d", response.Code, http.StatusGone)
+	}
+	if contentType := response.Header().Get("Content-Type"); contentType != problemContentType {
+		t.Errorf("content type is %s, expected %s", contentType, problemContentType)
+	}
+
+	expected := `{"type":"about:blank","title":"Gone","status":410,"code":"database_gone","detail":"database nodb is Gone","instance":"/databases/nodb","request_id":"abc"}`
+	if body := response.Body.String(); body != expected {
+		t.Errorf("body is %s, expected %s", body, expected)
+	}
+	for _, expected := range []string{`msg=Gone`, `status=410`, `detail="database nodb is Gone"`} {
+		if !strings.Contains(lb.String(), expected) {
+			t.Errorf("log is %q, expected to contain %q", lb.String(), expected)
+		}
+	}
+}
+
+func TestProblem_Router(t *testing.T) {
+	conn := NewConn()
+	conn.buildConfig()
+	_, _ = conn.Auth.Add("ro", auth.ScopeReadOnly, nil, time.Now())
+
+	for _, tc := range []struct {
+		method, url, token string
+		status             int
+		code               string
+	}{
+		{"GET", "/nope", "ro", http.StatusNotFound, problemNotFound},
+		{"PATCH", "/databases", "ro", http.StatusMethodNotAllowed, problemMethodNotAllowed},
+		{"GET", "/databases", "", http.StatusUnauthorized, problemUnauthorized},
+		{"PUT", "/databases?name=db", "ro", http.StatusForbidden, problemForbidden},
+		{"GET", "/databases/nodb", "ro", http.StatusGone, problemDatabaseGone},
+	} {
+		response := serveToken(conn, tc.method, tc.url, tc.token, nil)
+
+		var problem Problem
+		if err := json.Unmarshal(response.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("body of %s %s is %q, expected a problem: %v", tc.method, tc.url, response.Body.String(), err)
+		}
+		if response.Code != tc.status || problem.Status != tc.status || problem.Code != tc.code {
+			t.Errorf("%s %s is %d %+v, expected %d %s", tc.method, tc.url, response.Code, problem, tc.status, tc.code)
+		}
+		if problem.RequestID == "" || problem.RequestID != response.Header().Get(requestIDHeader) {
+			t.Errorf("request ID of %s %s is %q, expected %q", tc.method, tc.url,
+				problem.RequestID, response.Header().Get(requestIDHeader))
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pila"
+	"github.com/fern4lvarez/piladb/pkg/aof"
+)
+
+// parseIdleTTL returns the idle TTL of a Database or Stack being
+// created, given in seconds by the idle_ttl parameter of the request.
+// It is zero if the parameter is not set.
+func parseIdleTTL(r *http.Request) (time.Duration, error) {
+	value := r.FormValue("idle_ttl")
+	if value == "" {
+		return 0, nil
+	}
+
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid idle_ttl %s", value)
+	}
+	return pila.Seconds(seconds), nil
+}
+
+// reapLoop removes the databases and stacks that are idle every
+// second, and counts them in the status of the connection.
+func (c *Conn) reapLoop() {
+	for range time.Tick(time.Second) {
+		c.reap(time.Now().UTC())
+	}
+}
+
+// reap removes the databases and stacks that are idle at time t,
+// and returns how many of each were removed. Databases are removed
+// first, so their stacks are not counted.
+func (c *Conn) reap(t time.Time) (databases, stacks int) {
+	for _, db := range c.Pila.IdleDatabases(t) {
+		if c.reapDatabase(db, t) {
+			c.Logger.Info("idle database removed", "database", db.Name, "idle_ttl", db.IdleTTL.String())
+			databases++
+		}
+	}
+
+	for _, stack := range c.Pila.IdleStacks(t) {
+		if db := stack.Database; db != nil && c.reapStack(db, stack, t) {
+			c.Logger.Info("idle stack removed", "database", db.Name, "stack", stack.Name, "idle_ttl", stack.IdleTTL.String())
+			stacks++
+		}
+	}
+
+	c.Status.Reap(databases, stacks)
+	return databases, stacks
+}
+
+// reapDatabase removes a Database found idle at time t, returning
+// true if it succeeded. Its idleness is checked again while the
+// operation is persisted, as it could have been read, removed or
+// replaced since it was found idle.
+func (c *Conn) reapDatabase(db *pila.Database, t time.Time) bool {
+	var ok bool
+	c.persist(aof.Entry{Op: aof.DeleteDatabase, Database: db.Name, Time: t}, func() bool {
+		if current, exists := c.Pila.Database(db.ID); !exists || current != db || !db.Idle(t) {
+			return false
+		}
+		ok = c.Pila.RemoveDatabase(db.ID)
+		return ok
+	})
+	return ok
+}
+
+// reapStack removes a Stack of a Database found idle at time t,
+// returning true if it succeeded. Its idleness is checked again
+// while the operation is persisted, as it could have been read,
+// removed or replaced since it was found idle.
+func (c *Conn) reapStack(db *pila.Database, stack *pila.Stack, t time.Time) bool {
+	var ok bool
+	entry := aof.Entry{Op: aof.DeleteStack, Database: db.Name, Stack: stack.Name, Time: t}
+	c.persist(entry, func() bool {
+		if current, exists := db.Stack(stack.UUID()); !exists || current != stack || !stack.Idle(t) {
+			return false
+		}
+		stack.Flush()
+		ok = db.RemoveStack(stack.UUID())
+		return ok
+	})
+	return ok
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pkg/aof"
+)
+
+func TestParseIdleTTL(t *testing.T) {
+	inputOutput := []struct {
+		input  string
+		output time.Duration
+	}{
+		{"", 0},
+		{"idle_ttl=60", time.Minute},
+		{"idle_ttl=0.5", 500 * time.Millisecond},
+	}
+
+	for _, io := range inputOutput {
+		r, _ := http.NewRequest("PUT", "/databases?"+io.input, nil)
+		if ttl, err := parseIdleTTL(r); err != nil {
+			t.Errorf("%s: err is %v, expected nil", io.input, err)
+		} else if ttl != io.output {
+			t.Errorf("%s: idle TTL is %v, expected %v", io.input, ttl, io.output)
+		}
+	}
+
+	for _, input := range []string{"idle_ttl=-1", "idle_ttl=1m"} {
+		r, _ := http.NewRequest("PUT", "/databases?"+input, nil)
+		if _, err := parseIdleTTL(r); err == nil {
+			t.Errorf("%s: err is nil, expected error", input)
+		}
+	}
+}
+
+func TestCreateHandlers_IdleTTL(t *testing.T) {
+	conn := NewConn()
+	if code := serve(t, conn, "PUT", "/databases?name=db&idle_ttl=60", nil); code != http.StatusCreated {
+		t.Errorf("response code is %v, expected %v", code, http.StatusCreated)
+	}
+	if code := serve(t, conn, "PUT", "/databases/db/stacks?name=stack&idle_ttl=1.5", nil); code != http.StatusCreated {
+		t.Errorf("response code is %v, expected %v", code, http.StatusCreated)
+	}
+	if code := serve(t, conn, "PUT", "/databases?name=bad&idle_ttl=-1", nil); code != http.StatusBadRequest {
+		t.Errorf("response code is %v, expected %v", code, http.StatusBadRequest)
+	}
+	if code := serve(t, conn, "PUT", "/databases/db/stacks?name=bad&idle_ttl=foo", nil); code != http.StatusBadRequest {
+		t.Errorf("response code is %v, expected %v", code, http.StatusBadRequest)
+	}
+
+	db, _ := ResourceDatabase(conn, "db")
+	if db.IdleTTL != time.Minute || db.ReadAt.IsZero() {
+		t.Errorf("database idle TTL is %v and read at %v, expected %v and not zero", db.IdleTTL, db.ReadAt, time.Minute)
+	}
+	s, _ := ResourceStack(db, "stack")
+	if s.IdleTTL != 1500*time.Millisecond {
+		t.Errorf("stack idle TTL is %v, expected %v", s.IdleTTL, 1500*time.Millisecond)
+	}
+	if _, ok := ResourceDatabase(conn, "bad"); ok {
+		t.Error("database bad was created")
+	}
+}
+
+func TestReap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	conn := aofTestConn(t, path)
+	serve(t, conn, "PUT", "/databases?name=idle&idle_ttl=60", nil)
+	serve(t, conn, "PUT", "/databases/idle/stacks?name=stack&idle_ttl=1", nil)
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=idle&idle_ttl=60", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=stack", nil)
+
+	if databases, stacks := conn.reap(time.Now().UTC()); databases != 0 || stacks != 0 {
+		t.Errorf("reaped %d databases and %d stacks, expected none", databases, stacks)
+	}
+	if databases, stacks := conn.reap(time.Now().UTC().Add(time.Minute)); databases != 1 || stacks != 1 {
+		t.Errorf("reaped %d databases and %d stacks, expected %d and %d", databases, stacks, 1, 1)
+	}
+	if _, ok := ResourceDatabase(conn, "idle"); ok {
+		t.Error("database idle was not reaped")
+	}
+	db, _ := ResourceDatabase(conn, "db")
+	if _, ok := ResourceStack(db, "idle"); ok {
+		t.Error("stack idle was not reaped")
+	}
+	if _, ok := ResourceStack(db, "stack"); !ok {
+		t.Error("stack without idle TTL was reaped")
+	}
+	if conn.Status.ReapedDatabases != 1 || conn.Status.ReapedStacks != 1 {
+		t.Errorf("status reaped %d databases and %d stacks, expected %d and %d",
+			conn.Status.ReapedDatabases, conn.Status.ReapedStacks, 1, 1)
+	}
+	conn.aof.Close()
+
+	var entries []aof.Entry
+	_ = aof.Replay(path, func(e aof.Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if n := len(entries); n != 7 {
+		t.Fatalf("file has %d entries, expected %d", n, 7)
+	}
+	if entries[5].Op != aof.DeleteDatabase || entries[6].Op != aof.DeleteStack {
+		t.Errorf("entries are %s and %s, expected %s and %s", entries[5].Op, entries[6].Op, aof.DeleteDatabase, aof.DeleteStack)
+	}
+
+	replayed := aofTestConn(t, path)
+	defer replayed.aof.Close()
+	if _, ok := ResourceDatabase(replayed, "idle"); ok {
+		t.Error("database idle was replayed")
+	}
+	db, _ = ResourceDatabase(replayed, "db")
+	if s, ok := ResourceStack(db, "stack"); !ok || s.IdleTTL != 0 {
+		t.Errorf("stack is %v, expected to be replayed without idle TTL", s)
+	}
+}
+
+func TestReap_NotIdle(t *testing.T) {
+	conn := NewConn()
+	serve(t, conn, "PUT", "/databases?name=idle&idle_ttl=60", nil)
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=idle&idle_ttl=60", nil)
+	idleDB, _ := ResourceDatabase(conn, "idle")
+	db, _ := ResourceDatabase(conn, "db")
+	idle, _ := ResourceStack(db, "idle")
+	now := time.Now().UTC().Add(time.Minute)
+
+	// read after being found idle
+	idleDB.Read(now)
+	idle.Read(now)
+	if conn.reapDatabase(idleDB, now) {
+		t.Error("database idle was reaped, expected not to")
+	}
+	if conn.reapStack(db, idle, now) {
+		t.Error("stack idle was reaped, expected not to")
+	}
+
+	// replaced after being found idle
+	serve(t, conn, "DELETE", "/databases/db/stacks/idle?full", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=idle", nil)
+	if conn.reapStack(db, idle, now.Add(time.Minute)) {
+		t.Error("replaced stack idle was reaped, expected not to")
+	}
+	if _, ok := ResourceStack(db, "idle"); !ok {
+		t.Error("new stack idle was removed")
+	}
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fern4lvarez/piladb/auth"
+	"github.com/fern4lvarez/piladb/config/vars"
+	"github.com/fern4lvarez/piladb/pila"
+	"github.com/fern4lvarez/piladb/pkg/aof"
+	"github.com/fern4lvarez/piladb/pkg/resp"
+	"github.com/fern4lvarez/piladb/pkg/uuid"
+)
+
+// serveRESP accepts connections from Redis clients on l, and serves
+// the commands they send until l is closed. Lists are mapped onto
+// Stacks, and databases are selected by name.
+func (c *Conn) serveRESP(l net.Listener) error {
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go c.handleRESP(nc)
+	}
+}
+
+// respArity contains the supported commands, and their minimum and
+// maximum number of arguments, including the command name. A maximum
+// of -1 means any number.
+var respArity = map[string]struct{ min, max int }{
+	"PING":   {1, 2},
+	"QUIT":   {1, 1},
+	"AUTH":   {2, 3},
+	"SELECT": {2, 2},
+	"LPUSH":  {3, -1},
+	"LPOP":   {2, 2},
+	"LLEN":   {2, 2},
+	"LINDEX": {3, 3},
+	"DEL":    {2, -1},
+}
+
+// respScopes contains the scope of a token required by each command
+// accessing a database.
+var respScopes = map[string]string{
+	"SELECT": auth.ScopeReadOnly,
+	"LPUSH":  auth.ScopeReadWrite,
+	"LPOP":   auth.ScopeReadWrite,
+	"LLEN":   auth.ScopeReadOnly,
+	"LINDEX": auth.ScopeReadOnly,
+	"DEL":    auth.ScopeReadWrite,
+}
+
+// respSession holds the state of a connection from a Redis client.
+type respSession struct {
+	c *Conn
+	w *resp.Writer
+	// database is the name of the Database selected with
+	// SELECT, empty if none was selected yet.
+	database string
+	// secret is the secret of the token given with AUTH,
+	// empty if the session was not authenticated yet.
+	secret string
+}
+
+// handleRESP serves the commands sent through a connection from a
+// Redis client, until it is closed or a protocol error happens.
+func (c *Conn) handleRESP(nc net.Conn) {
+	defer nc.Close()
+
+	r := resp.NewReader(nc)
+	s := &respSession{c: c, w: resp.NewWriter(nc)}
+	for {
+		args, err := r.ReadCommand()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			c.Logger.Warn("error on reading RESP command", "remote_addr", nc.RemoteAddr().String(), "error", err)
+			_ = s.w.WriteError("ERR " + err.Error())
+			_ = s.w.Flush()
+			return
+		}
+
+		quit := s.exec(args)
+		if err := s.w.Flush(); err != nil || quit {
+			return
+		}
+	}
+}
+
+// exec runs a command and writes its reply. It returns true if
+// the connection must be closed.
+func (s *respSession) exec(args []string) bool {
+	name := strings.ToUpper(args[0])
+	now := time.Now().UTC()
+
+	a, ok := respArity[name]
+	if !ok {
+		s.fail(args, fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return false
+	}
+	if len(args) < a.min || (a.max != -1 && len(args) > a.max) {
+		s.fail(args, fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		return false
+	}
+
+	switch name {
+	case "PING":
+		if len(args) == 2 {
+			_ = s.w.WriteBulkString(args[1])
+			return false
+		}
+		_ = s.w.WriteSimpleString("PONG")
+		return false
+	case "QUIT":
+		_ = s.w.WriteSimpleString("OK")
+		return true
+	case "AUTH":
+		s.auth(args)
+		return false
+	case "SELECT":
+		if !s.authorize(args, respScopes[name], s.c.databaseName(args[1])) {
+			return false
+		}
+		db, ok := ResourceDatabase(s.c, args[1])
+		if !ok {
+			s.fail(args, fmt.Sprintf("ERR database %s does not exist", args[1]))
+			return false
+		}
+		s.database = db.Name
+		db.Read(now)
+		s.log(args, "OK")
+		_ = s.w.WriteSimpleString("OK")
+		return false
+	}
+
+	db, ok := s.c.Pila.Database(uuid.New(s.database))
+	if !ok {
+		if s.database == "" {
+			s.fail(args, "ERR no database selected, use SELECT")
+		} else {
+			s.fail(args, fmt.Sprintf("ERR database %s does not exist", s.database))
+		}
+		return false
+	}
+	if !s.authorize(args, respScopes[name], db.Name) {
+		return false
+	}
+	db.Read(now)
+
+	switch name {
+	case "LPUSH":
+		s.lpush(args, db, now)
+	case "LPOP":
+		s.lpop(args, db, now)
+	case "LLEN":
+		s.llen(args, db, now)
+	case "LINDEX":
+		s.lindex(args, db, now)
+	case "DEL":
+		s.del(args, db, now)
+	}
+	return false
+}
+
+// lpush pushes the elements on top of the Stack given by the key,
+// creating it if it does not exist, and replies its new size.
+func (s *respSession) lpush(args []string, db *pila.Database, now time.Time) {
+	var err error
+	stack, ok := db.Stack(uuid.New(db.Name + args[1]))
+	if !ok {
+		if stack, err = s.c.respCreateStack(db, args[1], now); err != nil {
+			s.fail(args, "ERR "+err.Error())
+			return
+		}
+	}
+
+	elements := make([]interface{}, len(args)-2)
+	for i, arg := range args[2:] {
+		elements[i] = arg
+	}
+
+	entry := aof.Entry{Database: db.Name, Stack: stack.Name, Time: now}
+	if len(elements) == 1 {
+		entry.Op = aof.Push
+		entry.Element = elements[0]
+	} else {
+		entry.Op = aof.PushMany
+		entry.Elements = elements
+	}
+	maxSize := s.c.Config.MaxStackSize()
+	s.c.persist(entry, func() bool {
+		err = stack.PushManyBounded(elements, maxSize)
+		return err == nil
+	})
+	if err != nil {
+		s.fail(args, "ERR "+vars.MaxStackSize+" value exceeded")
+		return
+	}
+	stack.Update(now)
+
+	size := stack.Size()
+	s.log(args, size)
+	_ = s.w.WriteInteger(int64(size))
+}
+
+// lpop pops the element on top of the Stack given by the key and
+// replies it, or null if the Stack is empty or does not exist.
+func (s *respSession) lpop(args []string, db *pila.Database, now time.Time) {
+	stack, ok := db.Stack(uuid.New(db.Name + args[1]))
+	if !ok {
+		s.log(args, nil)
+		_ = s.w.WriteNull()
+		return
+	}
+
+	var element interface{}
+	entry := aof.Entry{Op: aof.Pop, Database: db.Name, Stack: stack.Name, Time: now}
+	s.c.persist(entry, func() bool {
+		element, ok = stack.Pop()
+		return ok
+	})
+	stack.Update(now)
+	if !ok {
+		s.log(args, nil)
+		_ = s.w.WriteNull()
+		return
+	}
+
+	s.log(args, element)
+	s.element(element)
+}
+
+// llen replies the size of the Stack given by the key, or 0 if
+// it does not exist.
+func (s *respSession) llen(args []string, db *pila.Database, now time.Time) {
+	var size int
+	if stack, ok := db.Stack(uuid.New(db.Name + args[1])); ok {
+		stack.Read(now)
+		size = stack.Size()
+	}
+
+	s.log(args, size)
+	_ = s.w.WriteInteger(int64(size))
+}
+
+// lindex replies the element of the Stack given by the key at the
+// given index, 0 being the top and -1 the bottom, or null if the
+// index is out of range or the Stack does not exist.
+func (s *respSession) lindex(args []string, db *pila.Database, now time.Time) {
+	index, err := strconv.Atoi(args[2])
+	if err != nil {
+		s.fail(args, "ERR value is not an integer or out of range")
+		return
+	}
+
+	stack, ok := db.Stack(uuid.New(db.Name + args[1]))
+	if !ok {
+		s.log(args, nil)
+		_ = s.w.WriteNull()
+		return
+	}
+	stack.Read(now)
+
+	// the top element is peeked, so it can be read from
+	// Stacks that do not support range reads
+	size := stack.Size()
+	if index < 0 {
+		index += size
+	}
+	if index < 0 || index >= size {
+		s.log(args, nil)
+		_ = s.w.WriteNull()
+		return
+	}
+	if index == 0 {
+		element := stack.Peek()
+		s.log(args, element)
+		s.element(element)
+		return
+	}
+
+	elements, ok := stack.Range(index, 1)
+	if !ok {
+		s.fail(args, "ERR stack does not support range reads")
+		return
+	}
+	if len(elements) == 0 {
+		s.log(args, nil)
+		_ = s.w.WriteNull()
+		return
+	}
+	s.log(args, elements[0])
+	s.element(elements[0])
+}
+
+// del deletes the Stacks given by the keys, and replies how many
+// of them existed.
+func (s *respSession) del(args []string, db *pila.Database, now time.Time) {
+	var n int
+	for _, key := range args[1:] {
+		stack, ok := db.Stack(uuid.New(db.Name + key))
+		if !ok {
+			continue
+		}
+
+		entry := aof.Entry{Op: aof.DeleteStack, Database: db.Name, Stack: stack.Name, Time: now}
+		s.c.persist(entry, func() bool {
+			stack.Flush()
+			ok = db.RemoveStack(stack.ID)
+			return ok
+		})
+		if ok {
+			n++
+		}
+	}
+
+	s.log(args, n)
+	_ = s.w.WriteInteger(int64(n))
+}
+
+// auth authenticates the session with the secret of a token, given as
+// the last argument, so Redis clients sending a username are supported.
+func (s *respSession) auth(args []string) {
+	// the secret is never logged
+	command := args[:1]
+	if !s.c.Auth.Enabled() {
+		s.fail(command, "ERR AUTH called without any token configured")
+		return
+	}
+
+	secret := args[len(args)-1]
+	if _, ok := s.c.Auth.Authenticate(secret); !ok {
+		s.fail(command, "WRONGPASS "+errInvalidToken.Error())
+		return
+	}
+	s.secret = secret
+	s.log(command, "OK")
+	_ = s.w.WriteSimpleString("OK")
+}
+
+// authorize checks that the session is authenticated by a token that
+// grants a scope on a database, and writes the error reply otherwise.
+// Sessions are not authenticated if there are no tokens.
+func (s *respSession) authorize(args []string, scope, database string) bool {
+	if !s.c.Auth.Enabled() {
+		return true
+	}
+
+	err := s.c.authorize(s.secret, scope, []string{database})
+	if err == errInvalidToken {
+		s.fail(args, "NOAUTH Authentication required.")
+		return false
+	}
+	if err != nil {
+		s.fail(args, "NOPERM "+err.Error())
+		return false
+	}
+	return true
+}
+
+// element writes an element as a bulk string. Elements that are not
+// strings, pushed through the HTTP API, are encoded in JSON.
+func (s *respSession) element(element interface{}) {
+	if str, ok := element.(string); ok {
+		_ = s.w.WriteBulkString(str)
+		return
+	}
+
+	// Do not check error as we consider our element
+	// suitable for a JSON encoding.
+	b, _ := json.Marshal(element)
+	_ = s.w.WriteBulkString(string(b))
+}
+
+// fail logs a command that failed, and writes the error reply.
+func (s *respSession) fail(args []string, message string) {
+	s.log(args, message)
+	_ = s.w.WriteError(message)
+}
+
+// log logs a command given its arguments, without the elements,
+// and its result.
+func (s *respSession) log(args []string, result interface{}) {
+	command := strings.ToUpper(args[0])
+	if len(args) > 1 {
+		command += " " + args[1]
+	}
+	s.c.Logger.Info("RESP command", "command", command, "database", s.database, "result", fmt.Sprint(result))
+}
+
+// respCreateStack creates a Stack pushed into by a Redis client, as
+// lists are created on their first push, using the default options
+// of the Stacks created through the HTTP API.
+func (c *Conn) respCreateStack(db *pila.Database, name string, now time.Time) (*pila.Stack, error) {
+	engine := c.Config.StackEngine()
+	var overflow string
+	var capacity int
+	if engine == RingEngine {
+		overflow = pila.OverflowEvict
+		capacity = c.Config.MaxStackSize()
+		if capacity < 1 {
+			return nil, fmt.Errorf("engine %s requires a positive %s", RingEngine, vars.MaxStackSize)
+		}
+	}
+
+	// reserve the name before creating the base, as it
+	// could take over the resources of an existing Stack,
+	// e.g. one created meanwhile
+	release, err := c.reserveStack(db, name)
+	if err != nil {
+		if existing, ok := db.Stack(uuid.New(db.Name + name)); ok {
+			return existing, nil
+		}
+		return nil, err
+	}
+	defer release()
+
+	base, err := c.newBase(engine, db.Name, name, capacity)
+	if err != nil {
+		return nil, err
+	}
+
+	stack := pila.NewStackWithBase(name, now, base)
+	stack.Engine = engineName(engine)
+	stack.Overflow = overflow
+	entry := aof.Entry{
+		Op:       aof.CreateStack,
+		Database: db.Name,
+		Stack:    name,
+		Engine:   stack.Engine,
+		Overflow: overflow,
+		Capacity: capacity,
+		Time:     now,
+	}
+	c.persist(entry, func() bool {
+		err = db.AddStack(stack)
+		return err == nil
+	})
+	if err != nil {
+		closeBase(base)
+		return nil, err
+	}
+	stack.Update(now)
+	return stack, nil
+}
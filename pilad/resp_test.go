@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/auth"
+	"github.com/fern4lvarez/piladb/config/vars"
+)
+
+// respTestClient sends commands to a RESP listener of a Conn, and
+// reads its replies.
+type respTestClient struct {
+	t  *testing.T
+	nc net.Conn
+	r  *bufio.Reader
+}
+
+func respTestConn(t *testing.T, conn *Conn) (*respTestClient, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go conn.serveRESP(l)
+
+	nc, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &respTestClient{t: t, nc: nc, r: bufio.NewReader(nc)}
+	return client, func() {
+		nc.Close()
+		l.Close()
+	}
+}
+
+// do sends a command, and returns its reply, with bulk strings
+// joined to their header by a space.
+func (c *respTestClient) do(command string) string {
+	if _, err := c.nc.Write([]byte(command + "\r\n")); err != nil {
+		c.t.Fatal(err)
+	}
+
+	line := c.readLine()
+	if strings.HasPrefix(line, "$") && line != "$-1" {
+		line += " " + c.readLine()
+	}
+	return line
+}
+
+func (c *respTestClient) readLine() string {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return strings.TrimSuffix(line, "\r\n")
+}
+
+func TestRESP(t *testing.T) {
+	conn := NewConn()
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases/db/stacks?name=json", nil)
+	serve(t, conn, "POST", "/databases/db/stacks/json", []byte(`{"element":{"foo":1}}`))
+
+	client, closeClient := respTestConn(t, conn)
+	defer closeClient()
+
+	for _, io := range []struct {
+		command, reply string
+	}{
+		{"PING", "+PONG"},
+		{"ping hello", "$5 hello"},
+		{"LLEN stack", "-ERR no database selected, use SELECT"},
+		{"SELECT nodb", "-ERR database nodb does not exist"},
+		{"SELECT db", "+OK"},
+		{"LLEN stack", ":0"},
+		{"LPOP stack", "$-1"},
+		{"LPUSH stack foo", ":1"},
+		{"*4\r\n$5\r\nLPUSH\r\n$5\r\nstack\r\n$3\r\nbar\r\n$7\r\nbaz qux", ":3"},
+		{"LLEN stack", ":3"},
+		{"LINDEX stack 0", "$7 baz qux"},
+		{"LINDEX stack 2", "$3 foo"},
+		{"LINDEX stack -1", "$3 foo"},
+		{"LINDEX stack 3", "$-1"},
+		{"LINDEX stack foo", "-ERR value is not an integer or out of range"},
+		{"LPOP stack", "$7 baz qux"},
+		{"LPOP json", `$9 {"foo":1}`},
+		{"DEL stack json nostack", ":2"},
+		{"LLEN stack", ":0"},
+		{"LPUSH stack", "-ERR wrong number of arguments for 'lpush' command"},
+		{"GET stack", "-ERR unknown command 'GET'"},
+		{"QUIT", "+OK"},
+	} {
+		if reply := client.do(io.command); reply != io.reply {
+			t.Errorf("%q: reply is %q, expected %q", io.command, reply, io.reply)
+		}
+	}
+
+	db, _ := ResourceDatabase(conn, "db")
+	if _, ok := ResourceStack(db, "stack"); ok {
+		t.Error("stack was not deleted")
+	}
+}
+
+func TestRESP_MaxStackSize(t *testing.T) {
+	conn := NewConn()
+	conn.Config.Set(vars.MaxStackSize, 2)
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+
+	client, closeClient := respTestConn(t, conn)
+	defer closeClient()
+
+	client.do("SELECT db")
+	if reply := client.do("LPUSH stack foo bar baz"); reply != "-ERR MAX_STACK_SIZE value exceeded" {
+		t.Errorf("reply is %q, expected an error", reply)
+	}
+	if reply := client.do("LPUSH stack foo bar"); reply != ":2" {
+		t.Errorf("reply is %q, expected %q", reply, ":2")
+	}
+}
+
+func TestRESP_RingEngine(t *testing.T) {
+	conn := NewConn()
+	conn.Config.Set(vars.StackEngine, RingEngine)
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+
+	client, closeClient := respTestConn(t, conn)
+	defer closeClient()
+
+	client.do("SELECT db")
+	if reply := client.do("LPUSH stack foo"); reply != "-ERR engine ring requires a positive MAX_STACK_SIZE" {
+		t.Errorf("reply is %q, expected an error", reply)
+	}
+
+	conn.Config.Set(vars.MaxStackSize, 2)
+	if reply := client.do("LPUSH stack foo bar baz"); reply != ":2" {
+		t.Errorf("reply is %q, expected %q", reply, ":2")
+	}
+}
+
+func TestRESP_Auth(t *testing.T) {
+	conn := NewConn()
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	serve(t, conn, "PUT", "/databases?name=other", nil)
+
+	client, closeClient := respTestConn(t, conn)
+	defer closeClient()
+
+	if reply := client.do("AUTH secret"); reply != "-ERR AUTH called without any token configured" {
+		t.Errorf("reply is %q, expected an error", reply)
+	}
+
+	ro, _ := conn.Auth.Add("ro", auth.ScopeReadOnly, nil, time.Now())
+	rwdb, _ := conn.Auth.Add("rwdb", auth.ScopeReadWrite, []string{"db"}, time.Now())
+
+	for _, io := range []struct {
+		command, reply string
+	}{
+		{"PING", "+PONG"},
+		{"SELECT db", "-NOAUTH Authentication required."},
+		{"AUTH foo", "-WRONGPASS invalid token"},
+		{"AUTH ro", "+OK"},
+		{"SELECT db", "+OK"},
+		{"LLEN stack", ":0"},
+		{"LPUSH stack foo", "-NOPERM token " + ro.ID + " is not granted read-write on db"},
+		{"AUTH default rwdb", "+OK"},
+		{"LPUSH stack foo", ":1"},
+		{"SELECT other", "-NOPERM token " + rwdb.ID + " is not granted read-only on other"},
+	} {
+		if reply := client.do(io.command); reply != io.reply {
+			t.Errorf("%q: reply is %q, expected %q", io.command, reply, io.reply)
+		}
+	}
+
+	conn.Auth.Revoke(rwdb.ID)
+	if reply := client.do("LLEN stack"); reply != "-NOAUTH Authentication required." {
+		t.Errorf("reply is %q, expected an error", reply)
+	}
+}
+
+func TestRESP_ProtocolError(t *testing.T) {
+	client, closeClient := respTestConn(t, NewConn())
+	defer closeClient()
+
+	if reply := client.do("*1\r\n:1"); !strings.HasPrefix(reply, "-ERR protocol error") {
+		t.Errorf("reply is %q, expected a protocol error", reply)
+	}
+	if _, err := client.r.ReadString('\n'); err == nil {
+		t.Error("connection was not closed")
+	}
+}
+
+func TestRESP_AOF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "piladb.aof")
+
+	conn := aofTestConn(t, path)
+	serve(t, conn, "PUT", "/databases?name=db", nil)
+	client, closeClient := respTestConn(t, conn)
+	client.do("SELECT db")
+	client.do("LPUSH stack foo")
+	client.do("LPUSH stack bar baz")
+	client.do("LPOP stack")
+	client.do("LPUSH deleted foo")
+	client.do("DEL deleted")
+	closeClient()
+	conn.aof.Close()
+
+	replayed := aofTestConn(t, path)
+	defer replayed.aof.Close()
+
+	db, _ := ResourceDatabase(replayed, "db")
+	s, ok := ResourceStack(db, "stack")
+	if !ok {
+		t.Fatal("stack was not replayed")
+	}
+	if s.Size() != 2 || s.Peek() != "bar" {
+		t.Errorf("size is %d and peek %v, expected %d and %v", s.Size(), s.Peek(), 2, "bar")
+	}
+	if _, ok := ResourceStack(db, "deleted"); ok {
+		t.Error("deleted stack was replayed")
+	}
+}
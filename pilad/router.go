@@ -24,6 +24,14 @@ func Router(conn *Conn) *mux.Router {
 	r.HandleFunc("/_status", conn.statusHandler).
 		Methods("GET")
 
+	// GET /_metrics
+	r.HandleFunc("/_metrics", conn.metricsHandler).
+		Methods("GET")
+
+	// POST /_snapshot
+	r.HandleFunc("/_snapshot", conn.snapshotHandler).
+		Methods("POST")
+
 	// GET /_config
 	r.HandleFunc("/_config", conn.configHandler).
 		Methods("GET")
@@ -32,6 +40,15 @@ func Router(conn *Conn) *mux.Router {
 	r.Handle("/_config/{key}", conn.configKeyHandler("")).
 		Methods("GET", "POST")
 
+	// GET /_tokens
+	// POST /_tokens + {scope: admin|read-write|read-only, databases: [DATABASE_NAME, ...]}
+	r.HandleFunc("/_tokens", conn.tokensHandler).
+		Methods("GET", "POST")
+	// GET /_tokens/$TOKEN_ID
+	// DELETE /_tokens/$TOKEN_ID
+	r.Handle("/_tokens/{id}", conn.tokenHandler("")).
+		Methods("GET", "DELETE")
+
 	// GET /databases
 	// PUT /databases?name=DATABASE_NAME
 	r.HandleFunc("/databases", conn.databasesHandler).
@@ -41,6 +58,14 @@ func Router(conn *Conn) *mux.Router {
 	r.Handle("/databases/{id}", conn.databaseHandler("")).
 		Methods("GET", "DELETE")
 
+	// POST /databases/$DATABASE_ID/_tx + [{op: push|pop|flush, stack: STACK_ID, element: value}, ...]
+	r.Handle("/databases/{database_id}/_tx", conn.txHandler("")).
+		Methods("POST")
+
+	// GET /databases/$DATABASE_ID/_events
+	r.Handle("/databases/{database_id}/_events", conn.eventsHandler(nil)).
+		Methods("GET")
+
 	// GET /databases/$DATABASE_ID/stacks
 	// GET /databases/$DATABASE_ID/stacks?kv
 	// PUT /databases/$DATABASE_ID/stacks?name=STACK_NAME
@@ -50,13 +75,30 @@ func Router(conn *Conn) *mux.Router {
 	// GET /databases/$DATABASE_ID/stacks/$STACK_ID
 	// GET /databases/$DATABASE_ID/stacks/$STACK_ID?peek
 	// GET /databases/$DATABASE_ID/stacks/$STACK_ID?size
+	// GET /databases/$DATABASE_ID/stacks/$STACK_ID?range&offset=N&limit=M
 	// POST /databases/$DATABASE_ID/stacks/$STACK_ID + {element: value}
+	// POST /databases/$DATABASE_ID/stacks/$STACK_ID?batch + [value, ...]
 	// DELETE /databases/$DATABASE_ID/stacks/$STACK_ID
+	// DELETE /databases/$DATABASE_ID/stacks/$STACK_ID?wait=DURATION
+	// DELETE /databases/$DATABASE_ID/stacks/$STACK_ID?pop=N
 	// DELETE /databases/$DATABASE_ID/stacks/$STACK_ID?flush
 	// DELETE /databases/$DATABASE_ID/stacks/$STACK_ID?full
 	r.Handle("/databases/{database_id}/stacks/{stack_id}", conn.stackHandler(nil)).
 		Methods("GET", "POST", "DELETE")
 
-	r.NotFoundHandler = http.HandlerFunc(conn.notFoundHandler)
+	// POST /databases/$DATABASE_ID/stacks/$STACK_ID/_move?to=STACK_ID
+	// POST /databases/$DATABASE_ID/stacks/$STACK_ID/_move?to=STACK_ID&database=DATABASE_ID
+	r.Handle("/databases/{database_id}/stacks/{stack_id}/_move", conn.moveStackHandler(nil)).
+		Methods("POST")
+
+	// GET /databases/$DATABASE_ID/stacks/$STACK_ID/_events
+	r.Handle("/databases/{database_id}/stacks/{stack_id}/_events", conn.eventsHandler(nil)).
+		Methods("GET")
+
+	r.NotFoundHandler = conn.requestIDMiddleware(http.HandlerFunc(conn.notFoundHandler))
+	r.MethodNotAllowedHandler = conn.requestIDMiddleware(http.HandlerFunc(conn.methodNotAllowedHandler))
+	r.Use(conn.requestIDMiddleware)
+	r.Use(conn.metricsMiddleware)
+	r.Use(conn.authMiddleware)
 	return r
 }
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fern4lvarez/piladb/config/vars"
+	"github.com/fern4lvarez/piladb/pila"
+)
+
+// SnapshotStatus represents the result of a snapshot.
+type SnapshotStatus struct {
+	Path            string    `json:"path"`
+	CreatedAt       time.Time `json:"created_at"`
+	NumberDatabases int       `json:"number_of_databases"`
+}
+
+// ToJSON returns the SnapshotStatus into a JSON file in []byte
+// format.
+func (s SnapshotStatus) ToJSON() []byte {
+	// Do not check error as the SnapshotStatus type does
+	// not contain types that could cause such case.
+	// See http://golang.org/src/encoding/json/encode.go?s=5438:5481#L125
+	b, _ := json.Marshal(s)
+	return b
+}
+
+// snapshot writes the Snapshot of the Pila, its Config and the stored
+// tokens into the file
+// set in SNAPSHOT_PATH. The file is replaced atomically, so a failure
+// never leaves a partially written snapshot behind.
+func (c *Conn) snapshot(t time.Time) (SnapshotStatus, error) {
+	path := c.Config.SnapshotPath()
+	if path == "" {
+		return SnapshotStatus{}, errors.New(vars.SnapshotPath + " is not set")
+	}
+
+	c.snapshotMu.Lock()
+	defer c.snapshotMu.Unlock()
+
+	// disk Stacks are saved in checkpoints, which replace
+	// the previous ones once the snapshot is written
+	checkpoint := checkpointName("snapshot")
+	status, err := c.writeSnapshot(path, t, checkpoint)
+	if err != nil {
+		c.removeCheckpoint(checkpoint)
+		return SnapshotStatus{}, err
+	}
+	c.removeCheckpoints("snapshot-", checkpoint)
+	return status, nil
+}
+
+// writeSnapshot writes the Snapshot of the Pila, its Config and the
+// stored tokens into path, saving the elements of disk Stacks in
+// checkpoint.
+func (c *Conn) writeSnapshot(path string, t time.Time, checkpoint string) (SnapshotStatus, error) {
+	snapshot, err := c.Pila.Snapshot(t, c.checkpoint(checkpoint))
+	if err != nil {
+		return SnapshotStatus{}, err
+	}
+
+	configSnapshot, err := c.Config.Values.Snapshot()
+	if err != nil {
+		return SnapshotStatus{}, err
+	}
+	snapshot.Config = &configSnapshot
+	snapshot.Tokens = c.tokensSnapshot()
+
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return SnapshotStatus{}, err
+	}
+
+	if err := snapshot.Encode(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return SnapshotStatus{}, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return SnapshotStatus{}, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return SnapshotStatus{}, err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return SnapshotStatus{}, err
+	}
+
+	return SnapshotStatus{
+		Path:            path,
+		CreatedAt:       t,
+		NumberDatabases: len(snapshot.Databases),
+	}, nil
+}
+
+// restore loads the snapshot stored in SNAPSHOT_PATH, if any, into the
+// Pila, the Config and the tokens of the Conn. Config values set explicitly, by cli
+// flags, environment variables or the config file, are pushed on top of
+// the restored ones, so they take precedence, while config values
+// missing from the snapshot keep their current value.
+func (c *Conn) restore() error {
+	path := c.Config.SnapshotPath()
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	snapshot, err := pila.DecodeSnapshot(f)
+	if err != nil {
+		return err
+	}
+
+	for _, ds := range snapshot.Databases {
+		if err := checkDatabaseName(ds.Name); err != nil {
+			return fmt.Errorf("%v in %s", err, path)
+		}
+	}
+	if err := c.restoreTokens(snapshot.Tokens); err != nil {
+		return err
+	}
+
+	if configSnapshot := snapshot.Config; configSnapshot != nil {
+		values, err := configSnapshot.Restore(nil)
+		if err != nil {
+			return err
+		}
+
+		// restored values are validated as the ones read
+		// from the config file, and unknown ones are kept
+		for _, s := range values.Stacks {
+			v, ok := vars.Lookup(s.Name)
+			if !ok {
+				continue
+			}
+			value, err := v.Validate(s.Peek())
+			if err != nil {
+				return fmt.Errorf("%v in %s", err, path)
+			}
+			s.Push(value)
+		}
+
+		current := c.Config.Values
+		c.Config.Values = values
+		for _, s := range current.Stacks {
+			if c.Config.Get(s.Name) == nil {
+				c.Config.Set(s.Name, s.Peek())
+			}
+		}
+		for _, fk := range flagKeys() {
+			value, ok, err := c.explicitValue(fk)
+			if err != nil {
+				return err
+			}
+			if ok {
+				c.Config.Set(fk.key, value)
+			}
+		}
+		if err := vars.Check(c.Config.Get); err != nil {
+			return fmt.Errorf("%v in %s", err, path)
+		}
+	}
+
+	if err := c.Pila.Restore(snapshot, c.restoreBase); err != nil {
+		return err
+	}
+
+	c.Logger.Info("snapshot restored", "databases", len(snapshot.Databases), "path", path)
+	return nil
+}
+
+// snapshotLoop takes a snapshot every SNAPSHOT_INTERVAL seconds. Config
+// values are checked every second, so periodic snapshots can be enabled,
+// disabled or rescheduled at runtime.
+func (c *Conn) snapshotLoop() {
+	last := time.Now()
+	for now := range time.Tick(time.Second) {
+		interval := c.Config.SnapshotInterval() * time.Second
+		if interval <= 0 || c.Config.SnapshotPath() == "" || now.Sub(last) < interval {
+			continue
+		}
+		last = now
+
+		status, err := c.snapshot(now.UTC())
+		if err != nil {
+			c.Logger.Error("error on periodic snapshot", "error", err)
+			continue
+		}
+		c.Logger.Info("snapshot written", "path", status.Path)
+	}
+}
+
+// snapshotHandler takes a snapshot on demand and returns 200 and
+// the status of the snapshot.
+func (c *Conn) snapshotHandler(w http.ResponseWriter, r *http.Request) {
+	if c.Config.SnapshotPath() == "" {
+		c.problem(w, r, http.StatusBadRequest, problemSnapshotPath, vars.SnapshotPath, "is not set")
+		return
+	}
+
+	status, err := c.snapshot(time.Now().UTC())
+	if err != nil {
+		c.problem(w, r, http.StatusInternalServerError, problemSnapshot,
+			"error on snapshot:", err)
+		return
+	}
+
+	c.logRequest(r, http.StatusOK, status.Path)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(status.ToJSON())
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/auth"
+	"github.com/fern4lvarez/piladb/config/vars"
+	"github.com/fern4lvarez/piladb/pila"
+)
+
+func snapshotTestConn(t *testing.T) (*Conn, string) {
+	dir, err := ioutil.TempDir("", "piladb-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := NewConn()
+	conn.Config.Set(vars.SnapshotPath, filepath.Join(dir, "piladb.snapshot"))
+	return conn, dir
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	conn, dir := snapshotTestConn(t)
+	defer os.RemoveAll(dir)
+
+	now := time.Now().UTC()
+	db := pila.NewDatabase("db")
+	_ = conn.Pila.AddDatabase(db)
+	s := pila.NewStack("stack", now)
+	_ = db.AddStack(s)
+	s.Push("foo")
+	s.Push("bar")
+	s.Update(now)
+	conn.Config.Set(vars.MaxStackSize, 10)
+	conn.Config.Set(vars.MetricsMaxStacks, 5)
+	conn.Config.Set("CUSTOM", "value")
+
+	status, err := conn.snapshot(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.NumberDatabases != 1 {
+		t.Errorf("status.NumberDatabases is %d, expected %d", status.NumberDatabases, 1)
+	}
+
+	// MAX_STACK_SIZE is set explicitly, and METRICS_MAX_STACKS
+	// keeps the value changed at runtime over its default
+	t.Setenv(vars.Env(vars.MaxStackSize), "20")
+	t.Setenv(vars.Env(vars.SnapshotPath), conn.Config.SnapshotPath())
+	restored := NewConn()
+	if err := restored.buildConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.restore(); err != nil {
+		t.Fatal(err)
+	}
+
+	rdb, ok := ResourceDatabase(restored, "db")
+	if !ok {
+		t.Fatal("database db was not restored")
+	}
+	rs, ok := ResourceStack(rdb, "stack")
+	if !ok {
+		t.Fatal("stack stack was not restored")
+	}
+	if rs.Size() != 2 {
+		t.Errorf("stack size is %d, expected %d", rs.Size(), 2)
+	}
+	if rs.Peek() != "bar" {
+		t.Errorf("stack peek is %v, expected %v", rs.Peek(), "bar")
+	}
+	if !rs.UpdatedAt.Equal(now) {
+		t.Errorf("stack UpdatedAt is %v, expected %v", rs.UpdatedAt, now)
+	}
+
+	if v := restored.Config.MaxStackSize(); v != 20 {
+		t.Errorf("MaxStackSize is %d, expected %d", v, 20)
+	}
+	if v := restored.Config.MetricsMaxStacks(); v != 5 {
+		t.Errorf("MetricsMaxStacks is %d, expected %d", v, 5)
+	}
+	if v := restored.Config.AOFFsync(); v != vars.AOFFsyncDefault {
+		t.Errorf("AOFFsync is %s, expected %s", v, vars.AOFFsyncDefault)
+	}
+	if v := restored.Config.Get("CUSTOM"); v != "value" {
+		t.Errorf("CUSTOM is %v, expected %v", v, "value")
+	}
+}
+
+func TestSnapshotRestore_Tokens(t *testing.T) {
+	conn, dir := snapshotTestConn(t)
+	defer os.RemoveAll(dir)
+
+	_, _ = conn.Auth.Add("admin", auth.ScopeAdmin, nil, time.Now())
+	secret, _ := createToken(t, conn, `{"scope":"read-write"}`)
+	status, err := conn.snapshot(time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.NumberDatabases != 0 {
+		t.Errorf("snapshot has %d databases, expected %d", status.NumberDatabases, 0)
+	}
+
+	restored := NewConn()
+	restored.Config.Set(vars.SnapshotPath, conn.Config.SnapshotPath())
+	if err := restored.restore(); err != nil {
+		t.Fatal(err)
+	}
+	if token, ok := restored.Auth.Authenticate(secret); !ok || token.Scope != auth.ScopeReadWrite {
+		t.Errorf("token is %v, %v, expected it to be restored", token, ok)
+	}
+	if _, ok := restored.Auth.Authenticate("admin"); ok {
+		t.Error("admin token was restored")
+	}
+	if n := len(restored.Pila.Databases); n != 0 {
+		t.Errorf("Pila has %d databases, expected %d", n, 0)
+	}
+}
+
+func TestRestore_InvalidConfig(t *testing.T) {
+	conn, dir := snapshotTestConn(t)
+	defer os.RemoveAll(dir)
+
+	conn.Config.Set(vars.AOFFsync, "sometimes")
+	if _, err := conn.snapshot(time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewConn()
+	restored.Config.Set(vars.SnapshotPath, conn.Config.SnapshotPath())
+	if err := restored.restore(); err == nil {
+		t.Error("err is nil, expected error")
+	}
+}
+
+func TestRestore_ReservedDatabase(t *testing.T) {
+	conn, dir := snapshotTestConn(t)
+	defer os.RemoveAll(dir)
+
+	// a database named as the tokens is not restored as such
+	db := pila.NewDatabase(auth.AUTH)
+	_ = conn.Pila.AddDatabase(db)
+	s := pila.NewStack("token", time.Now())
+	_ = db.AddStack(s)
+	s.Push(map[string]interface{}{"id": "token", "scope": auth.ScopeAdmin})
+	if _, err := conn.snapshot(time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewConn()
+	restored.Config.Set(vars.SnapshotPath, conn.Config.SnapshotPath())
+	if err := restored.restore(); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if tokens := restored.Auth.Stored(); len(tokens) != 0 {
+		t.Errorf("tokens are %v, expected none", tokens)
+	}
+}
+
+func TestSnapshot_NoPath(t *testing.T) {
+	conn := NewConn()
+	if _, err := conn.snapshot(time.Now()); err == nil {
+		t.Error("err is nil")
+	}
+}
+
+func TestSnapshot_WrongPath(t *testing.T) {
+	conn := NewConn()
+	conn.Config.Set(vars.SnapshotPath, "/no/exist/piladb.snapshot")
+	if _, err := conn.snapshot(time.Now()); err == nil {
+		t.Error("err is nil")
+	}
+}
+
+func TestRestore_NoFile(t *testing.T) {
+	conn, dir := snapshotTestConn(t)
+	defer os.RemoveAll(dir)
+
+	if err := conn.restore(); err != nil {
+		t.Error(err)
+	}
+	if n := len(conn.Pila.Databases); n != 0 {
+		t.Errorf("Pila has %d databases, expected %d", n, 0)
+	}
+}
+
+func TestRestore_NoPath(t *testing.T) {
+	conn := NewConn()
+	if err := conn.restore(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRestore_Malformed(t *testing.T) {
+	conn, dir := snapshotTestConn(t)
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(conn.Config.SnapshotPath(), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.restore(); err == nil {
+		t.Error("err is nil")
+	}
+}
+
+func TestSnapshotHandler(t *testing.T) {
+	conn, dir := snapshotTestConn(t)
+	defer os.RemoveAll(dir)
+	conn.Pila.CreateDatabase("db")
+
+	request, err := http.NewRequest("POST", "/_snapshot", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := httptest.NewRecorder()
+
+	conn.snapshotHandler(response, request)
+
+	if contentType := response.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type is %v, expected %v", contentType, "application/json")
+	}
+
+	if response.Code != http.StatusOK {
+		t.Errorf("response code is %v, expected %v", response.Code, http.StatusOK)
+	}
+
+	var status SnapshotStatus
+	if err := json.NewDecoder(response.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Path != conn.Config.SnapshotPath() {
+		t.Errorf("status.Path is %s, expected %s", status.Path, conn.Config.SnapshotPath())
+	}
+	if status.NumberDatabases != 1 {
+		t.Errorf("status.NumberDatabases is %d, expected %d", status.NumberDatabases, 1)
+	}
+
+	if _, err := os.Stat(status.Path); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSnapshotHandler_BadRequest(t *testing.T) {
+	conn := NewConn()
+
+	request, err := http.NewRequest("POST", "/_snapshot", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := httptest.NewRecorder()
+
+	conn.snapshotHandler(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("response code is %v, expected %v", response.Code, http.StatusBadRequest)
+	}
+}
+
+func TestSnapshotHandler_InternalServerError(t *testing.T) {
+	conn := NewConn()
+	conn.Config.Set(vars.SnapshotPath, "/no/exist/piladb.snapshot")
+
+	request, err := http.NewRequest("POST", "/_snapshot", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := httptest.NewRecorder()
+
+	conn.snapshotHandler(response, request)
+
+	if response.Code != http.StatusInternalServerError {
+		t.Errorf("response code is %v, expected %v", response.Code, http.StatusInternalServerError)
+	}
+}
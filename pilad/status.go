@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 )
 
@@ -20,6 +21,12 @@ type Status struct {
 	RunningFor       float64   `json:"running_for"`
 	NumberGoroutines int       `json:"number_goroutines"`
 	MemoryAlloc      string    `json:"memory_alloc"`
+	ReapedDatabases  int       `json:"reaped_databases"`
+	ReapedStacks     int       `json:"reaped_stacks"`
+
+	// reapMu guards the number of reaped resources, as
+	// they are increased in the background.
+	reapMu sync.Mutex
 }
 
 // NewStatus returns a new piladb status.
@@ -47,12 +54,25 @@ func (s *Status) Update(now time.Time, mem *runtime.MemStats) {
 	s.MemoryAlloc = MemOutput(mem.Alloc)
 }
 
+// Reap adds a number of databases and stacks removed for
+// being idle to the Status.
+func (s *Status) Reap(databases, stacks int) {
+	s.reapMu.Lock()
+	defer s.reapMu.Unlock()
+
+	s.ReapedDatabases += databases
+	s.ReapedStacks += stacks
+}
+
 // ToJSON returns the Status into a JSON file in []byte
 // format.
 func (s *Status) ToJSON() []byte {
 	// Store date in UTC, show it in Local.
 	s.StartedAt = s.StartedAt.Local()
 
+	s.reapMu.Lock()
+	defer s.reapMu.Unlock()
+
 	// Do not check error as the Status type does
 	// not contain types that could cause such case.
 	// See http://golang.org/src/encoding/json/encode.go?s=5438:5481#L125
@@ -61,12 +61,22 @@ func TestStatusUpdate(t *testing.T) {
 	}
 }
 
+func TestStatusReap(t *testing.T) {
+	status := NewStatus("v1", time.Now(), nil)
+	status.Reap(1, 2)
+	status.Reap(0, 3)
+
+	if status.ReapedDatabases != 1 || status.ReapedStacks != 5 {
+		t.Errorf("reaped %d databases and %d stacks, expected %d and %d", status.ReapedDatabases, status.ReapedStacks, 1, 5)
+	}
+}
+
 func TestStatusToJSON(t *testing.T) {
 	now := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
 	status := NewStatus("v1", now, nil)
 	oneHourLater := now.Add(60 * time.Minute)
 	mem := runtime.MemStats{Alloc: 0}
-	expectedJSON := fmt.Sprintf(`{"status":"OK","version":"v1","go_version":"%s","host":"%s_%s","pid":%d,"started_at":"%s","running_for":3600,"number_goroutines":%d,"memory_alloc":"0B"}`, runtime.Version(), runtime.GOOS, runtime.GOARCH, os.Getpid(), date.Format(now.Local()), runtime.NumGoroutine())
+	expectedJSON := fmt.Sprintf(`{"status":"OK","version":"v1","go_version":"%s","host":"%s_%s","pid":%d,"started_at":"%s","running_for":3600,"number_goroutines":%d,"memory_alloc":"0B","reaped_databases":0,"reaped_stacks":0}`, runtime.Version(), runtime.GOOS, runtime.GOARCH, os.Getpid(), date.Format(now.Local()), runtime.NumGoroutine())
 
 	status.Update(oneHourLater, &mem)
 	json := status.ToJSON()
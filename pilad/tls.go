@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// tlsReloader holds the certificate of pilad and the certificates of the
+// authorities of its clients, read from files. They can be reloaded while
+// serving, so new connections use the new certificates, and established
+// ones are kept.
+type tlsReloader struct {
+	certPath, keyPath, caPath string
+	logger                    *slog.Logger
+
+	// mu guards cert and clientCAs, as they are read
+	// on every handshake.
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// newTLSReloader returns a tlsReloader with the certificates read from
+// the given paths, which logs to l. Client certificates are required
+// only if the path of the certificates of their authorities is set.
+func newTLSReloader(certPath, keyPath, caPath string, l *slog.Logger) (*tlsReloader, error) {
+	if certPath == "" || keyPath == "" {
+		return nil, errors.New("both certificate and key must be set")
+	}
+
+	r := &tlsReloader{certPath: certPath, keyPath: keyPath, caPath: caPath, logger: l}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload reads the certificates again. If any of them cannot be read,
+// the current ones are kept.
+func (r *tlsReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+	if r.caPath != "" {
+		b, err := os.ReadFile(r.caPath)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(b) {
+			return fmt.Errorf("no certificates found in %s", r.caPath)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	return nil
+}
+
+// config returns a tls.Config that serves the current certificates on
+// every handshake, and logs the identity of the clients presenting
+// one.
+func (r *tlsReloader) config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+				NextProtos:   []string{"h2", "http/1.1"},
+				VerifyConnection: func(state tls.ConnectionState) error {
+					if identity := tlsIdentity(&state); identity != "" {
+						r.logger.Info("TLS client connected", "client", identity, "remote_addr", hello.Conn.RemoteAddr().String())
+					}
+					return nil
+				},
+			}
+			if r.clientCAs != nil {
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+				config.ClientCAs = r.clientCAs
+			}
+			return config, nil
+		},
+	}
+}
+
+// tlsIdentity returns the identity of the client of a TLS connection
+// given by the subject of its certificate: its common name, or the
+// whole subject if it has none. It returns an empty identity if the
+// client presented no certificate.
+func tlsIdentity(state *tls.ConnectionState) string {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return ""
+	}
+
+	subject := state.PeerCertificates[0].Subject
+	if subject.CommonName != "" {
+		return subject.CommonName
+	}
+	return subject.String()
+}
+
+// reloadTLSLoop reloads the certificates every time a signal is
+// received, until the channel is closed.
+func reloadTLSLoop(r *tlsReloader, signals <-chan os.Signal) {
+	for range signals {
+		if err := r.reload(); err != nil {
+			r.logger.Error("error on reloading TLS certificates", "error", err)
+			continue
+		}
+		r.logger.Info("TLS certificates reloaded")
+	}
+}
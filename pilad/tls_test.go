@@ -0,0 +1,258 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pkg/logger"
+)
+
+// testCert is a certificate along with its key, signed by a parent
+// certificate, or self-signed if it has none.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, subject pkix.Name, parent *testCert, isCA bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               subject,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// write writes the certificate and its key as PEM files into dir, and
+// returns their paths.
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+// serveTLS serves the Router of a new Conn over TLS with the
+// certificates of a tlsReloader, and returns its address.
+func serveTLS(t *testing.T, reloader *tlsReloader) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: Router(NewConn()), TLSConfig: reloader.config()}
+	go srv.ServeTLS(l, "", "")
+	t.Cleanup(func() { srv.Close() })
+	return "https://" + l.Addr().String()
+}
+
+// testLogger discards the lines logged in tests.
+var testLogger = logger.New(io.Discard).Logger
+
+func tlsClient(ca *testCert, certs ...tls.Certificate) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+	}}
+}
+
+func TestNewTLSReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, pkix.Name{CommonName: "ca"}, nil, true)
+	certPath, keyPath := newTestCert(t, pkix.Name{CommonName: "pilad"}, ca, false).write(t, dir, "pilad")
+	caPath, _ := ca.write(t, dir, "ca")
+
+	if _, err := newTLSReloader(certPath, keyPath, caPath, testLogger); err != nil {
+		t.Errorf("err is %v, expected nil", err)
+	}
+
+	for _, tc := range []struct {
+		cert, key, ca string
+	}{
+		{certPath, "", ""},
+		{"", keyPath, caPath},
+		{certPath, caPath, ""},
+		{certPath, keyPath, filepath.Join(dir, "nope")},
+		{certPath, keyPath, keyPath},
+	} {
+		if _, err := newTLSReloader(tc.cert, tc.key, tc.ca, testLogger); err == nil {
+			t.Errorf("%v: err is nil, expected error", tc)
+		}
+	}
+}
+
+func TestTLSReloader_ClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, pkix.Name{CommonName: "ca"}, nil, true)
+	certPath, keyPath := newTestCert(t, pkix.Name{CommonName: "pilad"}, ca, false).write(t, dir, "pilad")
+	caPath, _ := ca.write(t, dir, "ca")
+	client := newTestCert(t, pkix.Name{CommonName: "client"}, ca, false)
+	other := newTestCert(t, pkix.Name{CommonName: "other"}, newTestCert(t, pkix.Name{CommonName: "other ca"}, nil, true), false)
+
+	reloader, err := newTLSReloader(certPath, keyPath, caPath, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serveTLS(t, reloader)
+
+	response, err := tlsClient(ca, client.tlsCertificate()).Get(url + "/_ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("response code is %d, expected %d", response.StatusCode, http.StatusOK)
+	}
+	if identity := tlsIdentity(response.TLS); identity != "pilad" {
+		t.Errorf("server identity is %q, expected %q", identity, "pilad")
+	}
+
+	if _, err := tlsClient(ca).Get(url + "/_ping"); err == nil {
+		t.Error("err is nil, expected error without client certificate")
+	}
+	if _, err := tlsClient(ca, other.tlsCertificate()).Get(url + "/_ping"); err == nil {
+		t.Error("err is nil, expected error with unknown client certificate")
+	}
+}
+
+func TestTLSReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, pkix.Name{CommonName: "ca"}, nil, true)
+	certPath, keyPath := newTestCert(t, pkix.Name{CommonName: "pilad"}, ca, false).write(t, dir, "pilad")
+
+	reloader, err := newTLSReloader(certPath, keyPath, "", testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serveTLS(t, reloader)
+
+	client := tlsClient(ca)
+	response, err := client.Get(url + "/_ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	newTestCert(t, pkix.Name{CommonName: "renewed"}, ca, false).write(t, dir, "pilad")
+	if err := reloader.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	// established connections are kept
+	response, err = client.Get(url + "/_ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if identity := tlsIdentity(response.TLS); identity != "pilad" {
+		t.Errorf("server identity is %q, expected %q", identity, "pilad")
+	}
+
+	// new connections use the reloaded certificate
+	response, err = tlsClient(ca).Get(url + "/_ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if identity := tlsIdentity(response.TLS); identity != "renewed" {
+		t.Errorf("server identity is %q, expected %q", identity, "renewed")
+	}
+
+	// current certificates are kept on errors
+	os.WriteFile(keyPath, []byte("nope"), 0600)
+	if err := reloader.reload(); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if cn := reloader.cert.Leaf.Subject.CommonName; cn != "renewed" {
+		t.Errorf("certificate is %s, expected renewed", cn)
+	}
+}
+
+func TestTLSIdentity(t *testing.T) {
+	for _, tc := range []struct {
+		state    *tls.ConnectionState
+		expected string
+	}{
+		{nil, ""},
+		{&tls.ConnectionState{}, ""},
+		{&tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "client"}}}}, "client"},
+		{&tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{Organization: []string{"piladb"}}}}}, "O=piladb"},
+	} {
+		if identity := tlsIdentity(tc.state); identity != tc.expected {
+			t.Errorf("identity is %q, expected %q", identity, tc.expected)
+		}
+	}
+}
+
+func TestReloadTLSLoop(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, pkix.Name{CommonName: "ca"}, nil, true)
+	certPath, keyPath := newTestCert(t, pkix.Name{CommonName: "pilad"}, ca, false).write(t, dir, "pilad")
+
+	reloader, err := newTLSReloader(certPath, keyPath, "", testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTestCert(t, pkix.Name{CommonName: "renewed"}, ca, false).write(t, dir, "pilad")
+
+	signals := make(chan os.Signal)
+	done := make(chan struct{})
+	go func() {
+		reloadTLSLoop(reloader, signals)
+		close(done)
+	}()
+	signals <- os.Interrupt
+	close(signals)
+	<-done
+
+	if cn := reloader.cert.Leaf.Subject.CommonName; cn != "renewed" {
+		t.Errorf("certificate is %s, expected renewed", cn)
+	}
+}
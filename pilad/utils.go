@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/fern4lvarez/piladb/pila"
 	"github.com/fern4lvarez/piladb/pkg/uuid"
@@ -24,15 +27,25 @@ func ResourceDatabase(conn *Conn, databaseInput string) (*pila.Database, bool) {
 // ResourceStack will return the right Stack resource
 // given a Database and a Stack ID or Name.
 func ResourceStack(db *pila.Database, stackInput string) (*pila.Stack, bool) {
-	stack, ok := db.Stacks[uuid.UUID(stackInput)]
+	stack, ok := db.Stack(uuid.UUID(stackInput))
 	if !ok {
 		// Fallback to find by stack name
-		stack, ok = db.Stacks[uuid.New(db.Name+stackInput)]
+		stack, ok = db.Stack(uuid.New(db.Name + stackInput))
 	}
 
 	return stack, ok
 }
 
+// checkDatabaseName returns an error if the name of a Database is
+// reserved, i.e. it starts with an underscore like the names of the
+// databases holding the config values and the tokens.
+func checkDatabaseName(name string) error {
+	if strings.HasPrefix(name, "_") {
+		return fmt.Errorf("database name %s is reserved", name)
+	}
+	return nil
+}
+
 // MemStats fetches the memory statistics provided
 // by the Go stdlib.
 func MemStats() *runtime.MemStats {
@@ -62,6 +75,16 @@ func MemOutput(mem uint64) string {
 	}
 }
 
+// formInt returns the value of the key parameter of a request
+// as an integer, or defaultValue if it is not set.
+func formInt(r *http.Request, key string, defaultValue int) (int, error) {
+	value := r.FormValue(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
 // v returns the version using pkg/version
 func v() string {
 	return version.Version(version.VERSION)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"net/http"
 	"reflect"
 	"testing"
 	"time"
@@ -117,3 +118,32 @@ func TestMemOutput(t *testing.T) {
 		}
 	}
 }
+
+func TestFormInt(t *testing.T) {
+	request, err := http.NewRequest("GET", "/?limit=10&offset=&page=foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputOutput := []struct {
+		key    string
+		output int
+		ok     bool
+	}{
+		{"limit", 10, true},
+		{"offset", 5, true},
+		{"missing", 5, true},
+		{"page", 0, false},
+	}
+
+	for _, io := range inputOutput {
+		i, err := formInt(request, io.key, 5)
+		if (err == nil) != io.ok {
+			t.Errorf("formInt(%s) error is %v", io.key, err)
+			continue
+		}
+		if io.ok && i != io.output {
+			t.Errorf("formInt(%s) is %d, expected %d", io.key, i, io.output)
+		}
+	}
+}
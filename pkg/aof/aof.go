@@ -0,0 +1,419 @@
+// Package aof provides an append-only file that logs every operation
+// modifying piladb data, so it can be replayed to rebuild it.
+package aof
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Operations that can be logged.
+const (
+	CreateDatabase = "create_database"
+	DeleteDatabase = "delete_database"
+	CreateStack    = "create_stack"
+	DeleteStack    = "delete_stack"
+	Push           = "push"
+	Pop            = "pop"
+	PushMany       = "push_many"
+	PopMany        = "pop_many"
+	Move           = "move"
+	Tx             = "tx"
+	Flush          = "flush"
+	CreateToken    = "create_token"
+	RevokeToken    = "revoke_token"
+)
+
+// Policy represents how often the file is synced to disk.
+type Policy string
+
+// Available fsync policies.
+const (
+	// Always syncs the file after every append. It is the
+	// safest and slowest policy.
+	Always Policy = "always"
+	// EverySecond syncs the file once per second, so at most
+	// one second of operations can be lost on a crash.
+	EverySecond Policy = "everysec"
+	// Never leaves syncing to the operating system.
+	Never Policy = "no"
+)
+
+// ErrRewriteInProgress is returned when a rewrite is requested
+// while another one is still running.
+var ErrRewriteInProgress = errors.New("aof rewrite already in progress")
+
+// Entry represents a logged operation.
+type Entry struct {
+	Op         string        `json:"op"`
+	Database   string        `json:"database"`
+	Stack      string        `json:"stack,omitempty"`
+	Engine     string        `json:"engine,omitempty"`
+	Overflow   string        `json:"overflow,omitempty"`
+	Capacity   int           `json:"capacity,omitempty"`
+	IdleTTL    float64       `json:"idle_ttl,omitempty"`
+	Element    interface{}   `json:"element,omitempty"`
+	Elements   []interface{} `json:"elements,omitempty"`
+	Count      int           `json:"count,omitempty"`
+	ToDatabase string        `json:"to_database,omitempty"`
+	ToStack    string        `json:"to_stack,omitempty"`
+	Version    uint64        `json:"version,omitempty"`
+	Checkpoint string        `json:"checkpoint,omitempty"`
+	Entries    []Entry       `json:"entries,omitempty"`
+	ExpiresAt  *time.Time    `json:"expires_at,omitempty"`
+	Time       time.Time     `json:"time"`
+}
+
+// Log represents an append-only file.
+type Log struct {
+	path   string
+	policy Policy
+
+	// mu serializes appends, so the order of the entries
+	// in the file is the order in which they were applied.
+	mu       sync.Mutex
+	f        *os.File
+	size     int64
+	baseSize int64
+	dirty    bool
+
+	// rewriting is true while a rewrite is running, and buffer
+	// holds the entries appended meanwhile.
+	rewriting bool
+	buffer    bytes.Buffer
+
+	// err is set once an applied operation could not be logged,
+	// and fails every later append.
+	err error
+
+	done chan struct{}
+}
+
+// Open opens the append-only file located at path, creating it if
+// it does not exist, and syncing it following policy. A trailing
+// partial entry, left by an interrupted write, is discarded.
+func Open(path string, policy Policy) (*Log, error) {
+	switch policy {
+	case Always, EverySecond, Never:
+	default:
+		return nil, fmt.Errorf("unknown fsync policy %q", policy)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := truncatePartial(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	l := &Log{
+		path:     path,
+		policy:   policy,
+		f:        f,
+		size:     size,
+		baseSize: size,
+		done:     make(chan struct{}),
+	}
+
+	if policy == EverySecond {
+		go l.syncLoop()
+	}
+
+	return l, nil
+}
+
+// Append applies an operation calling apply, and logs its entry if
+// apply returns true. No other entry is appended in between, so
+// operations are logged in the same order they are applied.
+//
+// If the entry of an applied operation cannot be written, the part
+// of it that was written is discarded, and no other operation is
+// applied, as the file would not be able to rebuild the data anymore.
+func (l *Log) Append(entry Entry, apply func() bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return l.err
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if !apply() {
+		return nil
+	}
+
+	n, err := l.f.Write(b)
+	if err == nil && n < len(b) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		if n > 0 {
+			l.truncate()
+		}
+		l.err = fmt.Errorf("aof append failed, no more operations are applied: %v", err)
+		return l.err
+	}
+	l.size += int64(n)
+
+	if l.rewriting {
+		l.buffer.Write(b)
+	}
+
+	if l.policy == Always {
+		return l.f.Sync()
+	}
+	l.dirty = true
+	return nil
+}
+
+// truncate discards the bytes written after the last complete
+// entry. It must be called holding the mutex.
+func (l *Log) truncate() {
+	if err := l.f.Truncate(l.size); err != nil {
+		return
+	}
+	l.f.Seek(l.size, io.SeekStart)
+}
+
+// Size returns the current size of the file in bytes.
+func (l *Log) Size() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.size
+}
+
+// ShouldRewrite returns true if the file is at least minSize bytes, and
+// has doubled its size since it was opened or rewritten for the last time.
+func (l *Log) ShouldRewrite(minSize int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return !l.rewriting && l.size >= minSize && l.size >= 2*l.baseSize
+}
+
+// Rewrite compacts the file, replacing it with the entries returned
+// by state, which must represent the current state of the data. state
+// is called while appends are blocked, so it must be fast; the new file
+// is written without blocking appends, and the entries appended
+// meanwhile are added to it before it replaces the old one.
+func (l *Log) Rewrite(state func() ([]Entry, error)) error {
+	l.mu.Lock()
+	if l.rewriting {
+		l.mu.Unlock()
+		return ErrRewriteInProgress
+	}
+	entries, err := state()
+	if err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	l.rewriting = true
+	l.mu.Unlock()
+
+	tmp, err := l.writeTemp(entries)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer func() {
+		l.rewriting = false
+		l.buffer.Reset()
+	}()
+
+	if err != nil {
+		return err
+	}
+
+	if err := l.swap(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// Close syncs and closes the file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	close(l.done)
+	if err := l.f.Sync(); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+// writeTemp writes entries into a new temporary file, next to the
+// current one.
+func (l *Log) writeTemp(entries []Entry) (*os.File, error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(l.path), filepath.Base(l.path)+".rewrite")
+	if err != nil {
+		return nil, err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return nil, err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return tmp, nil
+}
+
+// swap appends the buffered entries to tmp and replaces the current
+// file with it. It must be called holding the mutex.
+func (l *Log) swap(tmp *os.File) error {
+	if _, err := tmp.Write(l.buffer.Bytes()); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+
+	info, err := tmp.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return err
+	}
+
+	l.f.Close()
+	l.f = tmp
+	l.size = info.Size()
+	l.baseSize = info.Size()
+	l.dirty = false
+	return nil
+}
+
+// syncLoop syncs the file every second if there are new
+// entries, until the Log is closed.
+func (l *Log) syncLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			if l.dirty {
+				l.f.Sync()
+				l.dirty = false
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// Replay reads the append-only file located at path and calls fn for
+// each of its entries, in order. A missing file is considered empty,
+// and a trailing partial entry is ignored.
+func Replay(path string, fn func(Entry) error) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// a line without a trailing newline was
+			// interrupted while being written.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+		if err := fn(entry); err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+	}
+}
+
+// truncatePartial removes a trailing partial entry of f, returning
+// the resulting size and leaving the offset at the end of the file.
+func truncatePartial(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	size := info.Size()
+	buf := make([]byte, 4096)
+	end := size
+	for end > 0 {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil {
+			return 0, err
+		}
+
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			end = start + int64(i) + 1
+			break
+		}
+		end = start
+	}
+
+	if end != size {
+		if err := f.Truncate(end); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = f.Seek(end, io.SeekStart)
+	return end, err
+}
@@ -0,0 +1,375 @@
+package aof
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testLog(t *testing.T, policy Policy) (*Log, string) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Open(filepath.Join(dir, "piladb.aof"), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, dir
+}
+
+func replayAll(t *testing.T, path string) []Entry {
+	var entries []Entry
+	err := Replay(path, func(e Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func yes() bool { return true }
+
+func TestOpen(t *testing.T) {
+	for _, policy := range []Policy{Always, EverySecond, Never} {
+		l, dir := testLog(t, policy)
+		if l.policy != policy {
+			t.Errorf("l.policy is %v, expected %v", l.policy, policy)
+		}
+		if l.Size() != 0 {
+			t.Errorf("l.Size() is %d, expected %d", l.Size(), 0)
+		}
+		if err := l.Close(); err != nil {
+			t.Error(err)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func TestOpen_Error(t *testing.T) {
+	if _, err := Open("/no/exist/piladb.aof", Always); err == nil {
+		t.Error("err is nil")
+	}
+	if _, err := Open("piladb.aof", Policy("sometimes")); err == nil {
+		t.Error("err is nil")
+	}
+}
+
+func TestAppendReplay(t *testing.T) {
+	l, dir := testLog(t, Always)
+	defer os.RemoveAll(dir)
+
+	now := time.Now().UTC()
+	entries := []Entry{
+		{Op: CreateDatabase, Database: "db", Time: now},
+		{Op: CreateStack, Database: "db", Stack: "stack", Time: now},
+		{Op: Push, Database: "db", Stack: "stack", Element: "foo", Time: now},
+		{Op: Push, Database: "db", Stack: "stack", Element: map[string]interface{}{"a": 1.0}, Time: now},
+		{Op: Pop, Database: "db", Stack: "stack", Time: now},
+	}
+
+	var applied int
+	for _, entry := range entries {
+		err := l.Append(entry, func() bool {
+			applied++
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if applied != len(entries) {
+		t.Errorf("applied %d entries, expected %d", applied, len(entries))
+	}
+
+	// not applied operations are not logged
+	if err := l.Append(Entry{Op: Pop}, func() bool { return false }); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed := replayAll(t, l.path)
+	if len(replayed) != len(entries) {
+		t.Fatalf("replayed %d entries, expected %d", len(replayed), len(entries))
+	}
+	for i := range entries {
+		if !replayed[i].Time.Equal(entries[i].Time) {
+			t.Errorf("entry %d time is %v, expected %v", i, replayed[i].Time, entries[i].Time)
+		}
+		replayed[i].Time = entries[i].Time
+		if !reflect.DeepEqual(replayed[i], entries[i]) {
+			t.Errorf("entry %d is %v, expected %v", i, replayed[i], entries[i])
+		}
+	}
+}
+
+func TestAppend_WriteError(t *testing.T) {
+	l, dir := testLog(t, Always)
+	defer os.RemoveAll(dir)
+
+	now := time.Now().UTC()
+	if err := l.Append(Entry{Op: CreateDatabase, Database: "db", Time: now}, yes); err != nil {
+		t.Fatal(err)
+	}
+	size := l.Size()
+
+	// writes fail once the file is closed
+	l.f.Close()
+
+	var applied int
+	apply := func() bool {
+		applied++
+		return true
+	}
+	if err := l.Append(Entry{Op: DeleteDatabase, Database: "db", Time: now}, apply); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if applied != 1 {
+		t.Errorf("applied %d entries, expected %d", applied, 1)
+	}
+	if l.Size() != size {
+		t.Errorf("l.Size() is %d, expected %d", l.Size(), size)
+	}
+
+	// the log fails closed
+	if err := l.Append(Entry{Op: CreateDatabase, Database: "db", Time: now}, apply); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if applied != 1 {
+		t.Errorf("applied %d entries, expected %d", applied, 1)
+	}
+
+	if replayed := replayAll(t, l.path); len(replayed) != 1 {
+		t.Errorf("replayed %d entries, expected %d", len(replayed), 1)
+	}
+}
+
+func TestAppend_Truncate(t *testing.T) {
+	l, dir := testLog(t, Always)
+	defer os.RemoveAll(dir)
+
+	if err := l.Append(Entry{Op: CreateDatabase, Database: "db"}, yes); err != nil {
+		t.Fatal(err)
+	}
+	size := l.Size()
+
+	// a partial entry is discarded
+	if _, err := l.f.Write([]byte(`{"op":"pu`)); err != nil {
+		t.Fatal(err)
+	}
+	l.truncate()
+	if err := l.Append(Entry{Op: DeleteDatabase, Database: "db"}, yes); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(l.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != l.size || l.size <= size {
+		t.Errorf("file size is %d, expected %d", info.Size(), l.size)
+	}
+	if replayed := replayAll(t, l.path); len(replayed) != 2 {
+		t.Errorf("replayed %d entries, expected %d", len(replayed), 2)
+	}
+}
+
+func TestAppend_EverySecond(t *testing.T) {
+	l, dir := testLog(t, EverySecond)
+	defer os.RemoveAll(dir)
+	defer l.Close()
+
+	if err := l.Append(Entry{Op: CreateDatabase, Database: "db"}, yes); err != nil {
+		t.Fatal(err)
+	}
+
+	l.mu.Lock()
+	dirty := l.dirty
+	l.mu.Unlock()
+	if !dirty {
+		t.Error("l.dirty is false")
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	l.mu.Lock()
+	dirty = l.dirty
+	l.mu.Unlock()
+	if dirty {
+		t.Error("l.dirty is true")
+	}
+}
+
+func TestReplay_NoFile(t *testing.T) {
+	if entries := replayAll(t, "/no/exist/piladb.aof"); len(entries) != 0 {
+		t.Errorf("replayed %d entries, expected %d", len(entries), 0)
+	}
+}
+
+func TestReplay_Error(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "piladb.aof")
+	if err := ioutil.WriteFile(path, []byte("{\"op\":\"push\"}\n{\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Replay(path, func(Entry) error { return nil }); err == nil {
+		t.Error("err is nil")
+	}
+
+	if err := Replay(path, func(Entry) error { return errors.New("fail") }); err == nil {
+		t.Error("err is nil")
+	}
+}
+
+func TestPartialEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piladb-aof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "piladb.aof")
+	content := "{\"op\":\"create_database\",\"database\":\"db\"}\n{\"op\":\"pu"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries := replayAll(t, path); len(entries) != 1 {
+		t.Fatalf("replayed %d entries, expected %d", len(entries), 1)
+	}
+
+	l, err := Open(path, Never)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(Entry{Op: Push, Database: "db"}, yes); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	entries := replayAll(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("replayed %d entries, expected %d", len(entries), 2)
+	}
+	if entries[1].Op != Push {
+		t.Errorf("entries[1].Op is %s, expected %s", entries[1].Op, Push)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	l, dir := testLog(t, Always)
+	defer os.RemoveAll(dir)
+	defer l.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := l.Append(Entry{Op: Push, Database: "db", Stack: "stack", Element: float64(i)}, yes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := l.Size()
+
+	state := []Entry{
+		{Op: CreateDatabase, Database: "db"},
+	}
+	err := l.Rewrite(func() ([]Entry, error) {
+		// simulate an entry appended while the file is rewritten
+		go l.Append(Entry{Op: Flush, Database: "db", Stack: "stack"}, yes)
+		return state, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wait for the concurrent append
+	for i := 0; i < 100 && len(replayAll(t, l.path)) < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	entries := replayAll(t, l.path)
+	if len(entries) != 2 {
+		t.Fatalf("replayed %d entries, expected %d", len(entries), 2)
+	}
+	if entries[0].Op != CreateDatabase || entries[1].Op != Flush {
+		t.Errorf("entries are %v", entries)
+	}
+	if l.Size() >= before {
+		t.Errorf("l.Size() is %d, expected less than %d", l.Size(), before)
+	}
+
+	// appends go to the new file
+	if err := l.Append(Entry{Op: Pop, Database: "db", Stack: "stack"}, yes); err != nil {
+		t.Fatal(err)
+	}
+	if entries := replayAll(t, l.path); len(entries) != 3 {
+		t.Errorf("replayed %d entries, expected %d", len(entries), 3)
+	}
+}
+
+func TestRewrite_InProgress(t *testing.T) {
+	l, dir := testLog(t, Never)
+	defer os.RemoveAll(dir)
+	defer l.Close()
+
+	l.rewriting = true
+	if err := l.Rewrite(func() ([]Entry, error) { return nil, nil }); err != ErrRewriteInProgress {
+		t.Errorf("err is %v, expected %v", err, ErrRewriteInProgress)
+	}
+}
+
+func TestShouldRewrite(t *testing.T) {
+	l, dir := testLog(t, Never)
+	defer os.RemoveAll(dir)
+	defer l.Close()
+
+	if !l.ShouldRewrite(0) {
+		t.Error("empty log should be rewritten with minSize 0")
+	}
+	if l.ShouldRewrite(1) {
+		t.Error("empty log should not be rewritten with minSize 1")
+	}
+
+	_ = l.Append(Entry{Op: CreateDatabase, Database: "db"}, yes)
+	if err := l.Rewrite(func() ([]Entry, error) { return []Entry{{Op: CreateDatabase, Database: "db"}}, nil }); err != nil {
+		t.Fatal(err)
+	}
+	if l.ShouldRewrite(1) {
+		t.Error("log should not be rewritten right after a rewrite")
+	}
+
+	_ = l.Append(Entry{Op: CreateDatabase, Database: "db"}, yes)
+	if !l.ShouldRewrite(1) {
+		t.Error("log should be rewritten after doubling its size")
+	}
+}
+
+func TestRewrite_StateError(t *testing.T) {
+	l, dir := testLog(t, Never)
+	defer os.RemoveAll(dir)
+	defer l.Close()
+
+	if err := l.Rewrite(func() ([]Entry, error) { return nil, errors.New("fail") }); err == nil {
+		t.Error("err is nil")
+	}
+	if l.rewriting {
+		t.Error("l.rewriting is true")
+	}
+}
@@ -0,0 +1,416 @@
+// Package client implements a client of the piladb HTTP API, so Go
+// programs can manage databases, stacks and configuration of a pilad
+// server with typed requests and responses.
+//
+//	c := client.New("http://localhost:1205")
+//	stack := c.Database("db").Stack("stack")
+//	element, err := stack.Push(ctx, "foo")
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fern4lvarez/piladb/pila"
+)
+
+// Default retry policy of a Client.
+const (
+	// DefaultMaxRetries is the number of times a request is
+	// retried after a network error.
+	DefaultMaxRetries = 3
+	// DefaultRetryInterval is the time to wait before the first
+	// retry, doubled on every following one.
+	DefaultRetryInterval = 100 * time.Millisecond
+)
+
+// problemContentType is the media type of the bodies of the error
+// responses of pilad.
+const problemContentType = "application/problem+json"
+
+// Errors matched by the Error returned for each response status code
+// of pilad, to be checked with errors.Is.
+var (
+	// ErrBadRequest is returned when pilad rejects a malformed
+	// request or parameter.
+	ErrBadRequest = errors.New("bad request")
+	// ErrConflict is returned when a database or stack with the
+	// same name already exists, or a transaction is rolled back.
+	ErrConflict = errors.New("conflict")
+	// ErrNotAcceptable is returned when a stack reached
+	// MAX_STACK_SIZE.
+	ErrNotAcceptable = errors.New("not acceptable")
+	// ErrGone is returned when a database, stack or config
+	// value does not exist.
+	ErrGone = errors.New("gone")
+	// ErrUnauthorized is returned when pilad requires a token,
+	// and the Client has none or an invalid one.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when the token of the Client is
+	// not granted access to a resource.
+	ErrForbidden = errors.New("forbidden")
+	// ErrEmptyStack is returned when popping an empty stack.
+	ErrEmptyStack = errors.New("stack is empty")
+)
+
+// Error represents a response of pilad with an unexpected status code.
+// Code and Detail are taken from the application/problem+json body of
+// the response, if any.
+type Error struct {
+	Method     string
+	URL        string
+	StatusCode int
+	// Code identifies the cause of the error, e.g. stack_gone.
+	Code string
+	// Detail is a human-readable explanation of the error.
+	Detail string
+}
+
+// Error returns the request, the status code and the detail of the
+// response.
+func (e *Error) Error() string {
+	s := fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	return s
+}
+
+// Unwrap returns the error matching the status code of the response,
+// or nil if there is none.
+func (e *Error) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusNotAcceptable:
+		return ErrNotAcceptable
+	case http.StatusGone:
+		return ErrGone
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Status represents the status of a pilad server.
+type Status struct {
+	Code             string    `json:"status"`
+	Version          string    `json:"version"`
+	GoVersion        string    `json:"go_version"`
+	Host             string    `json:"host"`
+	PID              int       `json:"pid"`
+	StartedAt        time.Time `json:"started_at"`
+	RunningFor       float64   `json:"running_for"`
+	NumberGoroutines int       `json:"number_goroutines"`
+	MemoryAlloc      string    `json:"memory_alloc"`
+	ReapedDatabases  int       `json:"reaped_databases"`
+	ReapedStacks     int       `json:"reaped_stacks"`
+}
+
+// Client is a client of a pilad server. It is safe for concurrent use.
+type Client struct {
+	// Address is the base URL of the pilad server.
+	Address string
+	// HTTPClient sends the requests, http.DefaultClient by default.
+	HTTPClient *http.Client
+	// Token is sent to authenticate the requests, if set.
+	Token string
+	// MaxRetries is the number of times a request is retried after
+	// a network error. Requests that could modify data are only
+	// retried if the connection could not be established, so they
+	// are never applied twice.
+	MaxRetries int
+	// RetryInterval is the time to wait before the first retry,
+	// doubled on every following one.
+	RetryInterval time.Duration
+}
+
+// New returns a Client of the pilad server listening on an address,
+// such as "http://localhost:1205", with the default retry policy.
+// The scheme is http if it is missing.
+func New(address string) *Client {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	return &Client{
+		Address:       strings.TrimSuffix(address, "/"),
+		HTTPClient:    http.DefaultClient,
+		MaxRetries:    DefaultMaxRetries,
+		RetryInterval: DefaultRetryInterval,
+	}
+}
+
+// Ping returns nil if the pilad server is reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.do(ctx, "GET", "/_ping", nil, nil, http.StatusOK)
+}
+
+// Status returns the status of the pilad server.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var status Status
+	err := c.do(ctx, "GET", "/_status", nil, &status, http.StatusOK)
+	return status, err
+}
+
+// Databases returns the status of all databases.
+func (c *Client) Databases(ctx context.Context) (pila.Status, error) {
+	var status pila.Status
+	err := c.do(ctx, "GET", "/databases", nil, &status, http.StatusOK)
+	return status, err
+}
+
+// CreateDatabase creates a database given its name, and returns its
+// status. It returns ErrConflict if it already exists.
+func (c *Client) CreateDatabase(ctx context.Context, name string) (pila.DatabaseStatus, error) {
+	var status pila.DatabaseStatus
+	path := "/databases?name=" + url.QueryEscape(name)
+	err := c.do(ctx, "PUT", path, nil, &status, http.StatusCreated)
+	return status, err
+}
+
+// Database returns a Database given its ID or name. No request is
+// made until one of its methods is called.
+func (c *Client) Database(idOrName string) *Database {
+	return &Database{client: c, path: "/databases/" + url.PathEscape(idOrName)}
+}
+
+// Config returns the configuration values of the pilad server.
+func (c *Client) Config(ctx context.Context) (map[string]interface{}, error) {
+	var kv pila.StacksKV
+	err := c.do(ctx, "GET", "/_config", nil, &kv, http.StatusOK)
+	return kv.Stacks, err
+}
+
+// ConfigValue returns a configuration value given its key. It returns
+// ErrGone if the key is unknown.
+func (c *Client) ConfigValue(ctx context.Context, key string) (interface{}, error) {
+	var element pila.Element
+	err := c.do(ctx, "GET", "/_config/"+url.PathEscape(key), nil, &element, http.StatusOK)
+	return element.Value, err
+}
+
+// SetConfigValue sets a configuration value given its key, and
+// returns it. It returns ErrGone if the key is unknown.
+func (c *Client) SetConfigValue(ctx context.Context, key string, value interface{}) (interface{}, error) {
+	var element pila.Element
+	err := c.do(ctx, "POST", "/_config/"+url.PathEscape(key), pila.Element{Value: value}, &element, http.StatusOK)
+	return element.Value, err
+}
+
+// Database represents a database of a pilad server.
+type Database struct {
+	client *Client
+	path   string
+}
+
+// Status returns the status of the Database.
+func (db *Database) Status(ctx context.Context) (pila.DatabaseStatus, error) {
+	var status pila.DatabaseStatus
+	err := db.client.do(ctx, "GET", db.path, nil, &status, http.StatusOK)
+	return status, err
+}
+
+// Delete deletes the Database and all its stacks.
+func (db *Database) Delete(ctx context.Context) error {
+	return db.client.do(ctx, "DELETE", db.path, nil, nil, http.StatusNoContent)
+}
+
+// Stacks returns the status of the stacks of the Database.
+func (db *Database) Stacks(ctx context.Context) (pila.StacksStatus, error) {
+	var status pila.StacksStatus
+	err := db.client.do(ctx, "GET", db.path+"/stacks", nil, &status, http.StatusOK)
+	return status, err
+}
+
+// CreateStack creates a stack in the Database given its name, and
+// returns its status. It returns ErrConflict if it already exists.
+func (db *Database) CreateStack(ctx context.Context, name string) (pila.StackStatus, error) {
+	var status pila.StackStatus
+	path := db.path + "/stacks?name=" + url.QueryEscape(name)
+	err := db.client.do(ctx, "PUT", path, nil, &status, http.StatusCreated)
+	return status, err
+}
+
+// Stack returns a Stack of the Database given its ID or name. No
+// request is made until one of its methods is called.
+func (db *Database) Stack(idOrName string) *Stack {
+	return &Stack{client: db.client, path: db.path + "/stacks/" + url.PathEscape(idOrName)}
+}
+
+// Stack represents a stack of a pilad server.
+type Stack struct {
+	client *Client
+	path   string
+}
+
+// Status returns the status of the Stack.
+func (s *Stack) Status(ctx context.Context) (pila.StackStatus, error) {
+	var status pila.StackStatus
+	err := s.client.do(ctx, "GET", s.path, nil, &status, http.StatusOK)
+	return status, err
+}
+
+// Push adds an element on top of the Stack, and returns it. It
+// returns ErrNotAcceptable if the Stack reached MAX_STACK_SIZE.
+func (s *Stack) Push(ctx context.Context, element interface{}) (interface{}, error) {
+	var pushed pila.Element
+	err := s.client.do(ctx, "POST", s.path, pila.Element{Value: element}, &pushed, http.StatusOK)
+	return pushed.Value, err
+}
+
+// Pop extracts the element on top of the Stack, and returns it. It
+// returns ErrEmptyStack if the Stack is empty.
+func (s *Stack) Pop(ctx context.Context) (interface{}, error) {
+	var popped *pila.Element
+	err := s.client.do(ctx, "DELETE", s.path, nil, &popped, http.StatusOK, http.StatusNoContent)
+	if err != nil {
+		return nil, err
+	}
+	if popped == nil {
+		return nil, ErrEmptyStack
+	}
+	return popped.Value, nil
+}
+
+// Peek returns the element on top of the Stack without extracting
+// it, or nil if the Stack is empty.
+func (s *Stack) Peek(ctx context.Context) (interface{}, error) {
+	var element pila.Element
+	err := s.client.do(ctx, "GET", s.path+"?peek", nil, &element, http.StatusOK)
+	return element.Value, err
+}
+
+// Size returns the number of elements of the Stack.
+func (s *Stack) Size(ctx context.Context) (int, error) {
+	var size int
+	err := s.client.do(ctx, "GET", s.path+"?size", nil, &size, http.StatusOK)
+	return size, err
+}
+
+// Flush removes all elements of the Stack, and returns its status.
+func (s *Stack) Flush(ctx context.Context) (pila.StackStatus, error) {
+	var status pila.StackStatus
+	err := s.client.do(ctx, "DELETE", s.path+"?flush", nil, &status, http.StatusOK)
+	return status, err
+}
+
+// Delete deletes the Stack from its database.
+func (s *Stack) Delete(ctx context.Context) error {
+	return s.client.do(ctx, "DELETE", s.path+"?full", nil, nil, http.StatusNoContent)
+}
+
+// do sends a request with a body encoded into JSON, if any, and
+// decodes the response into out, if any. It returns an Error if the
+// status code of the response is not one of the expected ones. A
+// response with no content leaves out unchanged.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}, expected ...int) error {
+	var payload []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = b
+	}
+
+	response, err := c.send(ctx, method, c.Address+path, payload)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	ok := false
+	for _, code := range expected {
+		ok = ok || response.StatusCode == code
+	}
+	if !ok {
+		e := &Error{Method: method, URL: c.Address + path, StatusCode: response.StatusCode}
+		if strings.HasPrefix(response.Header.Get("Content-Type"), problemContentType) {
+			var problem struct {
+				Code   string `json:"code"`
+				Detail string `json:"detail"`
+			}
+			if err := json.NewDecoder(response.Body).Decode(&problem); err == nil {
+				e.Code, e.Detail = problem.Code, problem.Detail
+			}
+		}
+		// drain the body so the connection can be reused
+		_, _ = io.Copy(io.Discard, response.Body)
+		return e
+	}
+
+	if out == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// send sends a request, retrying it after network errors as long
+// as it is safe to do so, and the context is not done.
+func (c *Client) send(ctx context.Context, method, url string, payload []byte) (*http.Response, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	interval := c.RetryInterval
+	for retry := 0; ; retry++ {
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+		request, err := http.NewRequestWithContext(ctx, method, url, body)
+		if err != nil {
+			return nil, err
+		}
+		if payload != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		if c.Token != "" {
+			request.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+
+		response, err := httpClient.Do(request)
+		if err == nil || retry >= c.MaxRetries || !retriable(method, err) {
+			return response, err
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		interval *= 2
+	}
+}
+
+// retriable returns true if a request that failed with an error can
+// be sent again: it is a network error, and either the request only
+// reads data or it was never sent because the connection failed.
+func retriable(method string, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	if method != "GET" && method != "HEAD" {
+		return false
+	}
+	return opErr != nil || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		address, expected string
+	}{
+		{"http://localhost:1205", "http://localhost:1205"},
+		{"http://localhost:1205/", "http://localhost:1205"},
+		{"localhost:1205", "http://localhost:1205"},
+		{"https://pila.example.com", "https://pila.example.com"},
+	} {
+		c := New(tc.address)
+		if c.Address != tc.expected {
+			t.Errorf("address is %s, expected %s", c.Address, tc.expected)
+		}
+		if c.MaxRetries != DefaultMaxRetries {
+			t.Errorf("max retries is %d, expected %d", c.MaxRetries, DefaultMaxRetries)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	for _, tc := range []struct {
+		code     int
+		expected error
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusNotAcceptable, ErrNotAcceptable},
+		{http.StatusGone, ErrGone},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.code)
+		}))
+
+		_, err := New(server.URL).Database("db").Stack("stack").Push(context.Background(), "foo")
+		if !errors.Is(err, tc.expected) {
+			t.Errorf("err is %v, expected %v", err, tc.expected)
+		}
+
+		var e *Error
+		if !errors.As(err, &e) || e.StatusCode != tc.code || e.Method != "POST" ||
+			e.URL != server.URL+"/databases/db/stacks/stack" {
+			t.Errorf("err is %#v, expected Error with status code %d", err, tc.code)
+		}
+		server.Close()
+	}
+
+	if err := (&Error{StatusCode: http.StatusTeapot}).Unwrap(); err != nil {
+		t.Errorf("err is %v, expected nil", err)
+	}
+}
+
+func TestError_Problem(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusGone)
+		w.Write([]byte(`{"type":"about:blank","title":"Gone","status":410,"code":"stack_gone","detail":"stack stack is Gone","instance":"/databases/db/stacks/stack"}`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL).Database("db").Stack("stack").Peek(context.Background())
+	var e *Error
+	if !errors.As(err, &e) || e.Code != "stack_gone" || e.Detail != "stack stack is Gone" {
+		t.Fatalf("err is %#v, expected Error with problem", err)
+	}
+	if !errors.Is(err, ErrGone) {
+		t.Errorf("err is %v, expected %v", err, ErrGone)
+	}
+	expected := "GET " + server.URL + "/databases/db/stacks/stack?peek: 410 Gone: stack stack is Gone"
+	if err.Error() != expected {
+		t.Errorf("err is %q, expected %q", err.Error(), expected)
+	}
+}
+
+func TestStackPop_Empty(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	if _, err := New(server.URL).Database("db").Stack("stack").Pop(context.Background()); err != ErrEmptyStack {
+		t.Errorf("err is %v, expected %v", err, ErrEmptyStack)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// close the connection of the first requests
+		// without a response
+		if atomic.AddInt32(&requests, 1) < 3 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte("3"))
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	c.RetryInterval = time.Millisecond
+
+	size, err := c.Database("db").Stack("stack").Size(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 3 {
+		t.Errorf("size is %d, expected %d", size, 3)
+	}
+	if requests := atomic.LoadInt32(&requests); requests != 3 {
+		t.Errorf("requests are %d, expected %d", requests, 3)
+	}
+
+	// requests that modify data are not sent twice
+	atomic.StoreInt32(&requests, 0)
+	if _, err := c.Database("db").Stack("stack").Pop(context.Background()); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if requests := atomic.LoadInt32(&requests); requests != 1 {
+		t.Errorf("requests are %d, expected %d", requests, 1)
+	}
+}
+
+func TestClientRetries_Dial(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	c := New(address)
+	c.MaxRetries = 2
+	c.RetryInterval = time.Millisecond
+
+	_, err = c.Database("db").Stack("stack").Push(context.Background(), "foo")
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "dial" {
+		t.Errorf("err is %v, expected dial error", err)
+	}
+	if !retriable("POST", err) {
+		t.Error("dial error is not retriable, expected retriable")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Ping(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err is %v, expected %v", err, context.Canceled)
+	}
+}
+
+func TestClientToken(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "" {
+		t.Errorf("authorization is %s, expected none", authorization)
+	}
+
+	c.Token = "secret"
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Bearer secret" {
+		t.Errorf("authorization is %s, expected %s", authorization, "Bearer secret")
+	}
+}
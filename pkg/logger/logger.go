@@ -0,0 +1,179 @@
+// Package logger provides a leveled logger writing structured lines
+// in text or JSON format, which can both be changed while logging.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Levels of the log lines, from the most to the least verbose.
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// Formats of the log lines.
+const (
+	// FormatText writes lines of key=value pairs.
+	FormatText = "text"
+	// FormatJSON writes lines of JSON objects.
+	FormatJSON = "json"
+)
+
+// levels maps the names of the levels to their slog.Level.
+var levels = map[string]slog.Level{
+	LevelDebug: slog.LevelDebug,
+	LevelInfo:  slog.LevelInfo,
+	LevelWarn:  slog.LevelWarn,
+	LevelError: slog.LevelError,
+}
+
+// Logger is a slog.Logger whose level and format can be changed
+// at any time, including for the loggers derived from it with With.
+type Logger struct {
+	*slog.Logger
+
+	level *slog.LevelVar
+	json  *atomic.Bool
+}
+
+// New returns a Logger writing text lines to w from the info level.
+func New(w io.Writer) *Logger {
+	level := &slog.LevelVar{}
+	json := &atomic.Bool{}
+	options := &slog.HandlerOptions{Level: level}
+	// Both handlers write to w, so lines
+	// are not interleaved on format changes.
+	w = &lockedWriter{w: w}
+
+	return &Logger{
+		Logger: slog.New(&handler{
+			text:   slog.NewTextHandler(w, options),
+			json:   slog.NewJSONHandler(w, options),
+			isJSON: json,
+		}),
+		level: level,
+		json:  json,
+	}
+}
+
+// SetLevel sets the level of the Logger given its name. It returns
+// an error if the level is unknown.
+func (l *Logger) SetLevel(level string) error {
+	lv, ok := levels[level]
+	if !ok {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	l.level.Set(lv)
+	return nil
+}
+
+// SetFormat sets the format of the Logger. It returns an error if the
+// format is unknown.
+func (l *Logger) SetFormat(format string) error {
+	switch format {
+	case FormatText:
+		l.json.Store(false)
+	case FormatJSON:
+		l.json.Store(true)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	return nil
+}
+
+// Format returns the current format of the Logger.
+func (l *Logger) Format() string {
+	if l.json.Load() {
+		return FormatJSON
+	}
+	return FormatText
+}
+
+// ValidLevel returns true if a level is known.
+func ValidLevel(level string) bool {
+	_, ok := levels[level]
+	return ok
+}
+
+// ValidFormat returns true if a format is known.
+func ValidFormat(format string) bool {
+	return format == FormatText || format == FormatJSON
+}
+
+// handler is a slog.Handler that writes through its text or its JSON
+// handler, given the current format.
+type handler struct {
+	text, json slog.Handler
+	isJSON     *atomic.Bool
+}
+
+// current returns the handler of the current format.
+func (h *handler) current() slog.Handler {
+	if h.isJSON.Load() {
+		return h.json
+	}
+	return h.text
+}
+
+// Enabled reports whether the handler handles records at a level.
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.current().Enabled(ctx, level)
+}
+
+// Handle writes a record in the current format.
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	return h.current().Handle(ctx, r)
+}
+
+// WithAttrs returns a handler with attributes in both formats.
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{
+		text:   h.text.WithAttrs(attrs),
+		json:   h.json.WithAttrs(attrs),
+		isJSON: h.isJSON,
+	}
+}
+
+// WithGroup returns a handler with a group in both formats.
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{
+		text:   h.text.WithGroup(name),
+		json:   h.json.WithGroup(name),
+		isJSON: h.isJSON,
+	}
+}
+
+// lockedWriter serializes the writes to an io.Writer.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(b []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	return lw.w.Write(b)
+}
+
+// contextKey is the key of the logger of a context.
+type contextKey struct{}
+
+// NewContext returns a copy of a context carrying a logger.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by a context, if any.
+func FromContext(ctx context.Context) (*slog.Logger, bool) {
+	l, ok := ctx.Value(contextKey{}).(*slog.Logger)
+	return l, ok
+}
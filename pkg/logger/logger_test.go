@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b)
+
+	l.Debug("hidden")
+	l.Info("shown", "key", "value")
+	if out := b.String(); strings.Contains(out, "hidden") || !strings.Contains(out, `level=INFO msg=shown key=value`) {
+		t.Errorf("output is %q, expected only the info line", out)
+	}
+
+	if err := l.SetLevel(LevelDebug); err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	l.Debug("shown")
+	if !strings.Contains(b.String(), "level=DEBUG msg=shown") {
+		t.Errorf("output is %q, expected the debug line", b.String())
+	}
+
+	if err := l.SetLevel(LevelError); err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	l.Warn("hidden")
+	if b.Len() != 0 {
+		t.Errorf("output is %q, expected none", b.String())
+	}
+}
+
+func TestLogger_SetFormat(t *testing.T) {
+	var b bytes.Buffer
+	l := New(&b)
+	derived := l.With("request_id", "42")
+
+	if err := l.SetFormat(FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	if f := l.Format(); f != FormatJSON {
+		t.Errorf("format is %s, expected %s", f, FormatJSON)
+	}
+
+	// derived loggers follow the format
+	derived.Info("json")
+	var line map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &line); err != nil {
+		t.Fatalf("output %q is not JSON: %v", b.String(), err)
+	}
+	if line["msg"] != "json" || line["request_id"] != "42" || line["level"] != "INFO" {
+		t.Errorf("line is %v, unexpected", line)
+	}
+
+	if err := l.SetFormat(FormatText); err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	derived.WithGroup("g").Info("text", "k", 1)
+	if out := b.String(); !strings.Contains(out, "msg=text request_id=42 g.k=1") {
+		t.Errorf("output is %q, expected text", out)
+	}
+}
+
+func TestLogger_Errors(t *testing.T) {
+	l := New(&bytes.Buffer{})
+	if err := l.SetLevel("verbose"); err == nil {
+		t.Error("err is nil, expected error")
+	}
+	if err := l.SetFormat("xml"); err == nil {
+		t.Error("err is nil, expected error")
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, tc := range []struct {
+		value         string
+		level, format bool
+	}{
+		{LevelDebug, true, false},
+		{LevelError, true, false},
+		{FormatText, false, true},
+		{FormatJSON, false, true},
+		{"", false, false},
+	} {
+		if ok := ValidLevel(tc.value); ok != tc.level {
+			t.Errorf("ValidLevel(%q) is %v, expected %v", tc.value, ok, tc.level)
+		}
+		if ok := ValidFormat(tc.value); ok != tc.format {
+			t.Errorf("ValidFormat(%q) is %v, expected %v", tc.value, ok, tc.format)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("context carries a logger, expected none")
+	}
+
+	l := New(&bytes.Buffer{}).With("k", "v")
+	if got, ok := FromContext(NewContext(context.Background(), l)); !ok || got != l {
+		t.Errorf("logger is %v, expected %v", got, l)
+	}
+}
@@ -0,0 +1,1154 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: pila.proto
+
+package pilapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// StatusResponse represents the status of the piladb instance.
+type StatusResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Status           string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Version          string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	GoVersion        string                 `protobuf:"bytes,3,opt,name=go_version,json=goVersion,proto3" json:"go_version,omitempty"`
+	Host             string                 `protobuf:"bytes,4,opt,name=host,proto3" json:"host,omitempty"`
+	Pid              int64                  `protobuf:"varint,5,opt,name=pid,proto3" json:"pid,omitempty"`
+	StartedAt        *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	RunningFor       float64                `protobuf:"fixed64,7,opt,name=running_for,json=runningFor,proto3" json:"running_for,omitempty"`
+	NumberGoroutines int64                  `protobuf:"varint,8,opt,name=number_goroutines,json=numberGoroutines,proto3" json:"number_goroutines,omitempty"`
+	MemoryAlloc      string                 `protobuf:"bytes,9,opt,name=memory_alloc,json=memoryAlloc,proto3" json:"memory_alloc,omitempty"`
+	ReapedDatabases  int64                  `protobuf:"varint,10,opt,name=reaped_databases,json=reapedDatabases,proto3" json:"reaped_databases,omitempty"`
+	ReapedStacks     int64                  `protobuf:"varint,11,opt,name=reaped_stacks,json=reapedStacks,proto3" json:"reaped_stacks,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *StatusResponse) Reset() {
+	*x = StatusResponse{}
+	mi := &file_pila_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusResponse) ProtoMessage() {}
+
+func (x *StatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
+func (*StatusResponse) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StatusResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetGoVersion() string {
+	if x != nil {
+		return x.GoVersion
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetPid() int64 {
+	if x != nil {
+		return x.Pid
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *StatusResponse) GetRunningFor() float64 {
+	if x != nil {
+		return x.RunningFor
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetNumberGoroutines() int64 {
+	if x != nil {
+		return x.NumberGoroutines
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetMemoryAlloc() string {
+	if x != nil {
+		return x.MemoryAlloc
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetReapedDatabases() int64 {
+	if x != nil {
+		return x.ReapedDatabases
+	}
+	return 0
+}
+
+func (x *StatusResponse) GetReapedStacks() int64 {
+	if x != nil {
+		return x.ReapedStacks
+	}
+	return 0
+}
+
+// Database represents the status of a database.
+type Database struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name           string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	NumberOfStacks int64                  `protobuf:"varint,3,opt,name=number_of_stacks,json=numberOfStacks,proto3" json:"number_of_stacks,omitempty"`
+	// stacks contains the IDs of the stacks.
+	Stacks []string `protobuf:"bytes,4,rep,name=stacks,proto3" json:"stacks,omitempty"`
+	// idle_ttl is the number of seconds without access after which
+	// the database is removed, or 0 if it is never removed.
+	IdleTtl       float64 `protobuf:"fixed64,5,opt,name=idle_ttl,json=idleTtl,proto3" json:"idle_ttl,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Database) Reset() {
+	*x = Database{}
+	mi := &file_pila_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Database) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Database) ProtoMessage() {}
+
+func (x *Database) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Database.ProtoReflect.Descriptor instead.
+func (*Database) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Database) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Database) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Database) GetNumberOfStacks() int64 {
+	if x != nil {
+		return x.NumberOfStacks
+	}
+	return 0
+}
+
+func (x *Database) GetStacks() []string {
+	if x != nil {
+		return x.Stacks
+	}
+	return nil
+}
+
+func (x *Database) GetIdleTtl() float64 {
+	if x != nil {
+		return x.IdleTtl
+	}
+	return 0
+}
+
+// Stack represents the status of a stack.
+type Stack struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name      string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Peek      *structpb.Value        `protobuf:"bytes,3,opt,name=peek,proto3" json:"peek,omitempty"`
+	Size      int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	ReadAt    *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=read_at,json=readAt,proto3" json:"read_at,omitempty"`
+	Overflow  string                 `protobuf:"bytes,8,opt,name=overflow,proto3" json:"overflow,omitempty"`
+	Capacity  int64                  `protobuf:"varint,9,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	Version   uint64                 `protobuf:"varint,10,opt,name=version,proto3" json:"version,omitempty"`
+	// idle_ttl is the number of seconds without access after which
+	// the stack is removed, or 0 if it is never removed.
+	IdleTtl       float64 `protobuf:"fixed64,11,opt,name=idle_ttl,json=idleTtl,proto3" json:"idle_ttl,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stack) Reset() {
+	*x = Stack{}
+	mi := &file_pila_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stack) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stack) ProtoMessage() {}
+
+func (x *Stack) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stack.ProtoReflect.Descriptor instead.
+func (*Stack) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Stack) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Stack) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Stack) GetPeek() *structpb.Value {
+	if x != nil {
+		return x.Peek
+	}
+	return nil
+}
+
+func (x *Stack) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Stack) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Stack) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Stack) GetReadAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ReadAt
+	}
+	return nil
+}
+
+func (x *Stack) GetOverflow() string {
+	if x != nil {
+		return x.Overflow
+	}
+	return ""
+}
+
+func (x *Stack) GetCapacity() int64 {
+	if x != nil {
+		return x.Capacity
+	}
+	return 0
+}
+
+func (x *Stack) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Stack) GetIdleTtl() float64 {
+	if x != nil {
+		return x.IdleTtl
+	}
+	return 0
+}
+
+// Element represents an element of a stack.
+type Element struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         *structpb.Value        `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Element) Reset() {
+	*x = Element{}
+	mi := &file_pila_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Element) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Element) ProtoMessage() {}
+
+func (x *Element) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Element.ProtoReflect.Descriptor instead.
+func (*Element) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Element) GetValue() *structpb.Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+type ListDatabasesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Databases     []*Database            `protobuf:"bytes,1,rep,name=databases,proto3" json:"databases,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDatabasesResponse) Reset() {
+	*x = ListDatabasesResponse{}
+	mi := &file_pila_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDatabasesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDatabasesResponse) ProtoMessage() {}
+
+func (x *ListDatabasesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDatabasesResponse.ProtoReflect.Descriptor instead.
+func (*ListDatabasesResponse) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListDatabasesResponse) GetDatabases() []*Database {
+	if x != nil {
+		return x.Databases
+	}
+	return nil
+}
+
+type CreateDatabaseRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// idle_ttl is the number of seconds without access after which
+	// the database is removed, never if 0.
+	IdleTtl       float64 `protobuf:"fixed64,2,opt,name=idle_ttl,json=idleTtl,proto3" json:"idle_ttl,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateDatabaseRequest) Reset() {
+	*x = CreateDatabaseRequest{}
+	mi := &file_pila_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateDatabaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateDatabaseRequest) ProtoMessage() {}
+
+func (x *CreateDatabaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateDatabaseRequest.ProtoReflect.Descriptor instead.
+func (*CreateDatabaseRequest) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateDatabaseRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateDatabaseRequest) GetIdleTtl() float64 {
+	if x != nil {
+		return x.IdleTtl
+	}
+	return 0
+}
+
+type DatabaseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DatabaseRequest) Reset() {
+	*x = DatabaseRequest{}
+	mi := &file_pila_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DatabaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DatabaseRequest) ProtoMessage() {}
+
+func (x *DatabaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DatabaseRequest.ProtoReflect.Descriptor instead.
+func (*DatabaseRequest) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DatabaseRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+type ListStacksResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stacks        []*Stack               `protobuf:"bytes,1,rep,name=stacks,proto3" json:"stacks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStacksResponse) Reset() {
+	*x = ListStacksResponse{}
+	mi := &file_pila_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStacksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStacksResponse) ProtoMessage() {}
+
+func (x *ListStacksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStacksResponse.ProtoReflect.Descriptor instead.
+func (*ListStacksResponse) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListStacksResponse) GetStacks() []*Stack {
+	if x != nil {
+		return x.Stacks
+	}
+	return nil
+}
+
+type CreateStackRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Database string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Name     string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// engine is the engine of the stack, STACK_ENGINE if empty.
+	Engine string `protobuf:"bytes,3,opt,name=engine,proto3" json:"engine,omitempty"`
+	// overflow is the overflow policy of the stack: reject or evict.
+	Overflow string `protobuf:"bytes,4,opt,name=overflow,proto3" json:"overflow,omitempty"`
+	// capacity is the number of elements of a ring stack.
+	Capacity int64 `protobuf:"varint,5,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	// idle_ttl is the number of seconds without access after which
+	// the stack is removed, never if 0.
+	IdleTtl       float64 `protobuf:"fixed64,6,opt,name=idle_ttl,json=idleTtl,proto3" json:"idle_ttl,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateStackRequest) Reset() {
+	*x = CreateStackRequest{}
+	mi := &file_pila_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateStackRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateStackRequest) ProtoMessage() {}
+
+func (x *CreateStackRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateStackRequest.ProtoReflect.Descriptor instead.
+func (*CreateStackRequest) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CreateStackRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *CreateStackRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateStackRequest) GetEngine() string {
+	if x != nil {
+		return x.Engine
+	}
+	return ""
+}
+
+func (x *CreateStackRequest) GetOverflow() string {
+	if x != nil {
+		return x.Overflow
+	}
+	return ""
+}
+
+func (x *CreateStackRequest) GetCapacity() int64 {
+	if x != nil {
+		return x.Capacity
+	}
+	return 0
+}
+
+func (x *CreateStackRequest) GetIdleTtl() float64 {
+	if x != nil {
+		return x.IdleTtl
+	}
+	return 0
+}
+
+type StackRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Stack         string                 `protobuf:"bytes,2,opt,name=stack,proto3" json:"stack,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StackRequest) Reset() {
+	*x = StackRequest{}
+	mi := &file_pila_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StackRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StackRequest) ProtoMessage() {}
+
+func (x *StackRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StackRequest.ProtoReflect.Descriptor instead.
+func (*StackRequest) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *StackRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *StackRequest) GetStack() string {
+	if x != nil {
+		return x.Stack
+	}
+	return ""
+}
+
+type PushRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Database string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Stack    string                 `protobuf:"bytes,2,opt,name=stack,proto3" json:"stack,omitempty"`
+	Element  *structpb.Value        `protobuf:"bytes,3,opt,name=element,proto3" json:"element,omitempty"`
+	// ttl is the number of seconds after which the element
+	// expires, never if 0.
+	Ttl           float64 `protobuf:"fixed64,4,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PushRequest) Reset() {
+	*x = PushRequest{}
+	mi := &file_pila_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PushRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PushRequest) ProtoMessage() {}
+
+func (x *PushRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PushRequest.ProtoReflect.Descriptor instead.
+func (*PushRequest) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *PushRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *PushRequest) GetStack() string {
+	if x != nil {
+		return x.Stack
+	}
+	return ""
+}
+
+func (x *PushRequest) GetElement() *structpb.Value {
+	if x != nil {
+		return x.Element
+	}
+	return nil
+}
+
+func (x *PushRequest) GetTtl() float64 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+type PopRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Database string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Stack    string                 `protobuf:"bytes,2,opt,name=stack,proto3" json:"stack,omitempty"`
+	// wait is how long to wait for an element to be pushed if the
+	// stack is empty, bounded by the deadline of the call.
+	Wait          *durationpb.Duration `protobuf:"bytes,3,opt,name=wait,proto3" json:"wait,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PopRequest) Reset() {
+	*x = PopRequest{}
+	mi := &file_pila_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PopRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PopRequest) ProtoMessage() {}
+
+func (x *PopRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PopRequest.ProtoReflect.Descriptor instead.
+func (*PopRequest) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PopRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *PopRequest) GetStack() string {
+	if x != nil {
+		return x.Stack
+	}
+	return ""
+}
+
+func (x *PopRequest) GetWait() *durationpb.Duration {
+	if x != nil {
+		return x.Wait
+	}
+	return nil
+}
+
+type PopResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// element is the popped element, unset if the stack was empty.
+	Element       *Element `protobuf:"bytes,1,opt,name=element,proto3" json:"element,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PopResponse) Reset() {
+	*x = PopResponse{}
+	mi := &file_pila_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PopResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PopResponse) ProtoMessage() {}
+
+func (x *PopResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PopResponse.ProtoReflect.Descriptor instead.
+func (*PopResponse) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PopResponse) GetElement() *Element {
+	if x != nil {
+		return x.Element
+	}
+	return nil
+}
+
+type SizeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Size          int64                  `protobuf:"varint,1,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SizeResponse) Reset() {
+	*x = SizeResponse{}
+	mi := &file_pila_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SizeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SizeResponse) ProtoMessage() {}
+
+func (x *SizeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pila_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SizeResponse.ProtoReflect.Descriptor instead.
+func (*SizeResponse) Descriptor() ([]byte, []int) {
+	return file_pila_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *SizeResponse) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+var File_pila_proto protoreflect.FileDescriptor
+
+const file_pila_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"pila.proto\x12\x04pila\x1a\x1egoogle/protobuf/duration.proto\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x83\x03\n" +
+	"\x0eStatusResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12\x1d\n" +
+	"\n" +
+	"go_version\x18\x03 \x01(\tR\tgoVersion\x12\x12\n" +
+	"\x04host\x18\x04 \x01(\tR\x04host\x12\x10\n" +
+	"\x03pid\x18\x05 \x01(\x03R\x03pid\x129\n" +
+	"\n" +
+	"started_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x12\x1f\n" +
+	"\vrunning_for\x18\a \x01(\x01R\n" +
+	"runningFor\x12+\n" +
+	"\x11number_goroutines\x18\b \x01(\x03R\x10numberGoroutines\x12!\n" +
+	"\fmemory_alloc\x18\t \x01(\tR\vmemoryAlloc\x12)\n" +
+	"\x10reaped_databases\x18\n" +
+	" \x01(\x03R\x0freapedDatabases\x12#\n" +
+	"\rreaped_stacks\x18\v \x01(\x03R\freapedStacks\"\x8b\x01\n" +
+	"\bDatabase\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12(\n" +
+	"\x10number_of_stacks\x18\x03 \x01(\x03R\x0enumberOfStacks\x12\x16\n" +
+	"\x06stacks\x18\x04 \x03(\tR\x06stacks\x12\x19\n" +
+	"\bidle_ttl\x18\x05 \x01(\x01R\aidleTtl\"\x83\x03\n" +
+	"\x05Stack\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12*\n" +
+	"\x04peek\x18\x03 \x01(\v2\x16.google.protobuf.ValueR\x04peek\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x129\n" +
+	"\n" +
+	"created_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x123\n" +
+	"\aread_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x06readAt\x12\x1a\n" +
+	"\boverflow\x18\b \x01(\tR\boverflow\x12\x1a\n" +
+	"\bcapacity\x18\t \x01(\x03R\bcapacity\x12\x18\n" +
+	"\aversion\x18\n" +
+	" \x01(\x04R\aversion\x12\x19\n" +
+	"\bidle_ttl\x18\v \x01(\x01R\aidleTtl\"7\n" +
+	"\aElement\x12,\n" +
+	"\x05value\x18\x01 \x01(\v2\x16.google.protobuf.ValueR\x05value\"E\n" +
+	"\x15ListDatabasesResponse\x12,\n" +
+	"\tdatabases\x18\x01 \x03(\v2\x0e.pila.DatabaseR\tdatabases\"F\n" +
+	"\x15CreateDatabaseRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x19\n" +
+	"\bidle_ttl\x18\x02 \x01(\x01R\aidleTtl\"-\n" +
+	"\x0fDatabaseRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\"9\n" +
+	"\x12ListStacksResponse\x12#\n" +
+	"\x06stacks\x18\x01 \x03(\v2\v.pila.StackR\x06stacks\"\xaf\x01\n" +
+	"\x12CreateStackRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
+	"\x06engine\x18\x03 \x01(\tR\x06engine\x12\x1a\n" +
+	"\boverflow\x18\x04 \x01(\tR\boverflow\x12\x1a\n" +
+	"\bcapacity\x18\x05 \x01(\x03R\bcapacity\x12\x19\n" +
+	"\bidle_ttl\x18\x06 \x01(\x01R\aidleTtl\"@\n" +
+	"\fStackRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x14\n" +
+	"\x05stack\x18\x02 \x01(\tR\x05stack\"\x83\x01\n" +
+	"\vPushRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x14\n" +
+	"\x05stack\x18\x02 \x01(\tR\x05stack\x120\n" +
+	"\aelement\x18\x03 \x01(\v2\x16.google.protobuf.ValueR\aelement\x12\x10\n" +
+	"\x03ttl\x18\x04 \x01(\x01R\x03ttl\"m\n" +
+	"\n" +
+	"PopRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x14\n" +
+	"\x05stack\x18\x02 \x01(\tR\x05stack\x12-\n" +
+	"\x04wait\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\x04wait\"6\n" +
+	"\vPopResponse\x12'\n" +
+	"\aelement\x18\x01 \x01(\v2\r.pila.ElementR\aelement\"\"\n" +
+	"\fSizeResponse\x12\x12\n" +
+	"\x04size\x18\x01 \x01(\x03R\x04size2\xa5\x06\n" +
+	"\x04Pila\x126\n" +
+	"\x06Status\x12\x16.google.protobuf.Empty\x1a\x14.pila.StatusResponse\x12D\n" +
+	"\rListDatabases\x12\x16.google.protobuf.Empty\x1a\x1b.pila.ListDatabasesResponse\x12=\n" +
+	"\x0eCreateDatabase\x12\x1b.pila.CreateDatabaseRequest\x1a\x0e.pila.Database\x124\n" +
+	"\vGetDatabase\x12\x15.pila.DatabaseRequest\x1a\x0e.pila.Database\x12?\n" +
+	"\x0eDeleteDatabase\x12\x15.pila.DatabaseRequest\x1a\x16.google.protobuf.Empty\x12=\n" +
+	"\n" +
+	"ListStacks\x12\x15.pila.DatabaseRequest\x1a\x18.pila.ListStacksResponse\x124\n" +
+	"\vCreateStack\x12\x18.pila.CreateStackRequest\x1a\v.pila.Stack\x12+\n" +
+	"\bGetStack\x12\x12.pila.StackRequest\x1a\v.pila.Stack\x129\n" +
+	"\vDeleteStack\x12\x12.pila.StackRequest\x1a\x16.google.protobuf.Empty\x12(\n" +
+	"\x04Push\x12\x11.pila.PushRequest\x1a\r.pila.Element\x12*\n" +
+	"\x03Pop\x12\x10.pila.PopRequest\x1a\x11.pila.PopResponse\x12)\n" +
+	"\x04Peek\x12\x12.pila.StackRequest\x1a\r.pila.Element\x12.\n" +
+	"\x04Size\x12\x12.pila.StackRequest\x1a\x12.pila.SizeResponse\x12(\n" +
+	"\x05Flush\x12\x12.pila.StackRequest\x1a\v.pila.Stack\x121\n" +
+	"\n" +
+	"StreamPops\x12\x12.pila.StackRequest\x1a\r.pila.Element0\x01B*Z(github.com/fern4lvarez/piladb/pkg/pilapbb\x06proto3"
+
+var (
+	file_pila_proto_rawDescOnce sync.Once
+	file_pila_proto_rawDescData []byte
+)
+
+func file_pila_proto_rawDescGZIP() []byte {
+	file_pila_proto_rawDescOnce.Do(func() {
+		file_pila_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pila_proto_rawDesc), len(file_pila_proto_rawDesc)))
+	})
+	return file_pila_proto_rawDescData
+}
+
+var file_pila_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_pila_proto_goTypes = []any{
+	(*StatusResponse)(nil),        // 0: pila.StatusResponse
+	(*Database)(nil),              // 1: pila.Database
+	(*Stack)(nil),                 // 2: pila.Stack
+	(*Element)(nil),               // 3: pila.Element
+	(*ListDatabasesResponse)(nil), // 4: pila.ListDatabasesResponse
+	(*CreateDatabaseRequest)(nil), // 5: pila.CreateDatabaseRequest
+	(*DatabaseRequest)(nil),       // 6: pila.DatabaseRequest
+	(*ListStacksResponse)(nil),    // 7: pila.ListStacksResponse
+	(*CreateStackRequest)(nil),    // 8: pila.CreateStackRequest
+	(*StackRequest)(nil),          // 9: pila.StackRequest
+	(*PushRequest)(nil),           // 10: pila.PushRequest
+	(*PopRequest)(nil),            // 11: pila.PopRequest
+	(*PopResponse)(nil),           // 12: pila.PopResponse
+	(*SizeResponse)(nil),          // 13: pila.SizeResponse
+	(*timestamppb.Timestamp)(nil), // 14: google.protobuf.Timestamp
+	(*structpb.Value)(nil),        // 15: google.protobuf.Value
+	(*durationpb.Duration)(nil),   // 16: google.protobuf.Duration
+	(*emptypb.Empty)(nil),         // 17: google.protobuf.Empty
+}
+var file_pila_proto_depIdxs = []int32{
+	14, // 0: pila.StatusResponse.started_at:type_name -> google.protobuf.Timestamp
+	15, // 1: pila.Stack.peek:type_name -> google.protobuf.Value
+	14, // 2: pila.Stack.created_at:type_name -> google.protobuf.Timestamp
+	14, // 3: pila.Stack.updated_at:type_name -> google.protobuf.Timestamp
+	14, // 4: pila.Stack.read_at:type_name -> google.protobuf.Timestamp
+	15, // 5: pila.Element.value:type_name -> google.protobuf.Value
+	1,  // 6: pila.ListDatabasesResponse.databases:type_name -> pila.Database
+	2,  // 7: pila.ListStacksResponse.stacks:type_name -> pila.Stack
+	15, // 8: pila.PushRequest.element:type_name -> google.protobuf.Value
+	16, // 9: pila.PopRequest.wait:type_name -> google.protobuf.Duration
+	3,  // 10: pila.PopResponse.element:type_name -> pila.Element
+	17, // 11: pila.Pila.Status:input_type -> google.protobuf.Empty
+	17, // 12: pila.Pila.ListDatabases:input_type -> google.protobuf.Empty
+	5,  // 13: pila.Pila.CreateDatabase:input_type -> pila.CreateDatabaseRequest
+	6,  // 14: pila.Pila.GetDatabase:input_type -> pila.DatabaseRequest
+	6,  // 15: pila.Pila.DeleteDatabase:input_type -> pila.DatabaseRequest
+	6,  // 16: pila.Pila.ListStacks:input_type -> pila.DatabaseRequest
+	8,  // 17: pila.Pila.CreateStack:input_type -> pila.CreateStackRequest
+	9,  // 18: pila.Pila.GetStack:input_type -> pila.StackRequest
+	9,  // 19: pila.Pila.DeleteStack:input_type -> pila.StackRequest
+	10, // 20: pila.Pila.Push:input_type -> pila.PushRequest
+	11, // 21: pila.Pila.Pop:input_type -> pila.PopRequest
+	9,  // 22: pila.Pila.Peek:input_type -> pila.StackRequest
+	9,  // 23: pila.Pila.Size:input_type -> pila.StackRequest
+	9,  // 24: pila.Pila.Flush:input_type -> pila.StackRequest
+	9,  // 25: pila.Pila.StreamPops:input_type -> pila.StackRequest
+	0,  // 26: pila.Pila.Status:output_type -> pila.StatusResponse
+	4,  // 27: pila.Pila.ListDatabases:output_type -> pila.ListDatabasesResponse
+	1,  // 28: pila.Pila.CreateDatabase:output_type -> pila.Database
+	1,  // 29: pila.Pila.GetDatabase:output_type -> pila.Database
+	17, // 30: pila.Pila.DeleteDatabase:output_type -> google.protobuf.Empty
+	7,  // 31: pila.Pila.ListStacks:output_type -> pila.ListStacksResponse
+	2,  // 32: pila.Pila.CreateStack:output_type -> pila.Stack
+	2,  // 33: pila.Pila.GetStack:output_type -> pila.Stack
+	17, // 34: pila.Pila.DeleteStack:output_type -> google.protobuf.Empty
+	3,  // 35: pila.Pila.Push:output_type -> pila.Element
+	12, // 36: pila.Pila.Pop:output_type -> pila.PopResponse
+	3,  // 37: pila.Pila.Peek:output_type -> pila.Element
+	13, // 38: pila.Pila.Size:output_type -> pila.SizeResponse
+	2,  // 39: pila.Pila.Flush:output_type -> pila.Stack
+	3,  // 40: pila.Pila.StreamPops:output_type -> pila.Element
+	26, // [26:41] is the sub-list for method output_type
+	11, // [11:26] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_pila_proto_init() }
+func file_pila_proto_init() {
+	if File_pila_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pila_proto_rawDesc), len(file_pila_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   14,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pila_proto_goTypes,
+		DependencyIndexes: file_pila_proto_depIdxs,
+		MessageInfos:      file_pila_proto_msgTypes,
+	}.Build()
+	File_pila_proto = out.File
+	file_pila_proto_goTypes = nil
+	file_pila_proto_depIdxs = nil
+}
@@ -0,0 +1,696 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: pila.proto
+
+package pilapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Pila_Status_FullMethodName         = "/pila.Pila/Status"
+	Pila_ListDatabases_FullMethodName  = "/pila.Pila/ListDatabases"
+	Pila_CreateDatabase_FullMethodName = "/pila.Pila/CreateDatabase"
+	Pila_GetDatabase_FullMethodName    = "/pila.Pila/GetDatabase"
+	Pila_DeleteDatabase_FullMethodName = "/pila.Pila/DeleteDatabase"
+	Pila_ListStacks_FullMethodName     = "/pila.Pila/ListStacks"
+	Pila_CreateStack_FullMethodName    = "/pila.Pila/CreateStack"
+	Pila_GetStack_FullMethodName       = "/pila.Pila/GetStack"
+	Pila_DeleteStack_FullMethodName    = "/pila.Pila/DeleteStack"
+	Pila_Push_FullMethodName           = "/pila.Pila/Push"
+	Pila_Pop_FullMethodName            = "/pila.Pila/Pop"
+	Pila_Peek_FullMethodName           = "/pila.Pila/Peek"
+	Pila_Size_FullMethodName           = "/pila.Pila/Size"
+	Pila_Flush_FullMethodName          = "/pila.Pila/Flush"
+	Pila_StreamPops_FullMethodName     = "/pila.Pila/StreamPops"
+)
+
+// PilaClient is the client API for Pila service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Pila manages the databases and stacks of a piladb instance. Databases
+// and stacks are referred to by ID or by name.
+type PilaClient interface {
+	// Status returns the status of the piladb instance.
+	Status(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*StatusResponse, error)
+	// ListDatabases returns the status of all databases.
+	ListDatabases(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListDatabasesResponse, error)
+	// CreateDatabase creates a database.
+	CreateDatabase(ctx context.Context, in *CreateDatabaseRequest, opts ...grpc.CallOption) (*Database, error)
+	// GetDatabase returns the status of a database.
+	GetDatabase(ctx context.Context, in *DatabaseRequest, opts ...grpc.CallOption) (*Database, error)
+	// DeleteDatabase deletes a database and all its stacks.
+	DeleteDatabase(ctx context.Context, in *DatabaseRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ListStacks returns the status of the stacks of a database.
+	ListStacks(ctx context.Context, in *DatabaseRequest, opts ...grpc.CallOption) (*ListStacksResponse, error)
+	// CreateStack creates a stack in a database.
+	CreateStack(ctx context.Context, in *CreateStackRequest, opts ...grpc.CallOption) (*Stack, error)
+	// GetStack returns the status of a stack.
+	GetStack(ctx context.Context, in *StackRequest, opts ...grpc.CallOption) (*Stack, error)
+	// DeleteStack deletes a stack.
+	DeleteStack(ctx context.Context, in *StackRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Push adds an element on top of a stack.
+	Push(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (*Element, error)
+	// Pop extracts the element on top of a stack.
+	Pop(ctx context.Context, in *PopRequest, opts ...grpc.CallOption) (*PopResponse, error)
+	// Peek returns the element on top of a stack without extracting it.
+	Peek(ctx context.Context, in *StackRequest, opts ...grpc.CallOption) (*Element, error)
+	// Size returns the number of elements of a stack.
+	Size(ctx context.Context, in *StackRequest, opts ...grpc.CallOption) (*SizeResponse, error)
+	// Flush empties a stack.
+	Flush(ctx context.Context, in *StackRequest, opts ...grpc.CallOption) (*Stack, error)
+	// StreamPops pops the elements of a stack as they are pushed, and
+	// sends them until the stream is cancelled or the stack is deleted.
+	StreamPops(ctx context.Context, in *StackRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Element], error)
+}
+
+type pilaClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPilaClient(cc grpc.ClientConnInterface) PilaClient {
+	return &pilaClient{cc}
+}
+
+func (c *pilaClient) Status(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*StatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, Pila_Status_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) ListDatabases(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListDatabasesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDatabasesResponse)
+	err := c.cc.Invoke(ctx, Pila_ListDatabases_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) CreateDatabase(ctx context.Context, in *CreateDatabaseRequest, opts ...grpc.CallOption) (*Database, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Database)
+	err := c.cc.Invoke(ctx, Pila_CreateDatabase_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) GetDatabase(ctx context.Context, in *DatabaseRequest, opts ...grpc.CallOption) (*Database, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Database)
+	err := c.cc.Invoke(ctx, Pila_GetDatabase_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) DeleteDatabase(ctx context.Context, in *DatabaseRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Pila_DeleteDatabase_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) ListStacks(ctx context.Context, in *DatabaseRequest, opts ...grpc.CallOption) (*ListStacksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListStacksResponse)
+	err := c.cc.Invoke(ctx, Pila_ListStacks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) CreateStack(ctx context.Context, in *CreateStackRequest, opts ...grpc.CallOption) (*Stack, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Stack)
+	err := c.cc.Invoke(ctx, Pila_CreateStack_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) GetStack(ctx context.Context, in *StackRequest, opts ...grpc.CallOption) (*Stack, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Stack)
+	err := c.cc.Invoke(ctx, Pila_GetStack_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) DeleteStack(ctx context.Context, in *StackRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Pila_DeleteStack_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) Push(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (*Element, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Element)
+	err := c.cc.Invoke(ctx, Pila_Push_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) Pop(ctx context.Context, in *PopRequest, opts ...grpc.CallOption) (*PopResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PopResponse)
+	err := c.cc.Invoke(ctx, Pila_Pop_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) Peek(ctx context.Context, in *StackRequest, opts ...grpc.CallOption) (*Element, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Element)
+	err := c.cc.Invoke(ctx, Pila_Peek_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) Size(ctx context.Context, in *StackRequest, opts ...grpc.CallOption) (*SizeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SizeResponse)
+	err := c.cc.Invoke(ctx, Pila_Size_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) Flush(ctx context.Context, in *StackRequest, opts ...grpc.CallOption) (*Stack, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Stack)
+	err := c.cc.Invoke(ctx, Pila_Flush_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pilaClient) StreamPops(ctx context.Context, in *StackRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Element], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Pila_ServiceDesc.Streams[0], Pila_StreamPops_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StackRequest, Element]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pila_StreamPopsClient = grpc.ServerStreamingClient[Element]
+
+// PilaServer is the server API for Pila service.
+// All implementations must embed UnimplementedPilaServer
+// for forward compatibility.
+//
+// Pila manages the databases and stacks of a piladb instance. Databases
+// and stacks are referred to by ID or by name.
+type PilaServer interface {
+	// Status returns the status of the piladb instance.
+	Status(context.Context, *emptypb.Empty) (*StatusResponse, error)
+	// ListDatabases returns the status of all databases.
+	ListDatabases(context.Context, *emptypb.Empty) (*ListDatabasesResponse, error)
+	// CreateDatabase creates a database.
+	CreateDatabase(context.Context, *CreateDatabaseRequest) (*Database, error)
+	// GetDatabase returns the status of a database.
+	GetDatabase(context.Context, *DatabaseRequest) (*Database, error)
+	// DeleteDatabase deletes a database and all its stacks.
+	DeleteDatabase(context.Context, *DatabaseRequest) (*emptypb.Empty, error)
+	// ListStacks returns the status of the stacks of a database.
+	ListStacks(context.Context, *DatabaseRequest) (*ListStacksResponse, error)
+	// CreateStack creates a stack in a database.
+	CreateStack(context.Context, *CreateStackRequest) (*Stack, error)
+	// GetStack returns the status of a stack.
+	GetStack(context.Context, *StackRequest) (*Stack, error)
+	// DeleteStack deletes a stack.
+	DeleteStack(context.Context, *StackRequest) (*emptypb.Empty, error)
+	// Push adds an element on top of a stack.
+	Push(context.Context, *PushRequest) (*Element, error)
+	// Pop extracts the element on top of a stack.
+	Pop(context.Context, *PopRequest) (*PopResponse, error)
+	// Peek returns the element on top of a stack without extracting it.
+	Peek(context.Context, *StackRequest) (*Element, error)
+	// Size returns the number of elements of a stack.
+	Size(context.Context, *StackRequest) (*SizeResponse, error)
+	// Flush empties a stack.
+	Flush(context.Context, *StackRequest) (*Stack, error)
+	// StreamPops pops the elements of a stack as they are pushed, and
+	// sends them until the stream is cancelled or the stack is deleted.
+	StreamPops(*StackRequest, grpc.ServerStreamingServer[Element]) error
+	mustEmbedUnimplementedPilaServer()
+}
+
+// UnimplementedPilaServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPilaServer struct{}
+
+func (UnimplementedPilaServer) Status(context.Context, *emptypb.Empty) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedPilaServer) ListDatabases(context.Context, *emptypb.Empty) (*ListDatabasesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDatabases not implemented")
+}
+func (UnimplementedPilaServer) CreateDatabase(context.Context, *CreateDatabaseRequest) (*Database, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateDatabase not implemented")
+}
+func (UnimplementedPilaServer) GetDatabase(context.Context, *DatabaseRequest) (*Database, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDatabase not implemented")
+}
+func (UnimplementedPilaServer) DeleteDatabase(context.Context, *DatabaseRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteDatabase not implemented")
+}
+func (UnimplementedPilaServer) ListStacks(context.Context, *DatabaseRequest) (*ListStacksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListStacks not implemented")
+}
+func (UnimplementedPilaServer) CreateStack(context.Context, *CreateStackRequest) (*Stack, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateStack not implemented")
+}
+func (UnimplementedPilaServer) GetStack(context.Context, *StackRequest) (*Stack, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStack not implemented")
+}
+func (UnimplementedPilaServer) DeleteStack(context.Context, *StackRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteStack not implemented")
+}
+func (UnimplementedPilaServer) Push(context.Context, *PushRequest) (*Element, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Push not implemented")
+}
+func (UnimplementedPilaServer) Pop(context.Context, *PopRequest) (*PopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Pop not implemented")
+}
+func (UnimplementedPilaServer) Peek(context.Context, *StackRequest) (*Element, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Peek not implemented")
+}
+func (UnimplementedPilaServer) Size(context.Context, *StackRequest) (*SizeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Size not implemented")
+}
+func (UnimplementedPilaServer) Flush(context.Context, *StackRequest) (*Stack, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Flush not implemented")
+}
+func (UnimplementedPilaServer) StreamPops(*StackRequest, grpc.ServerStreamingServer[Element]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamPops not implemented")
+}
+func (UnimplementedPilaServer) mustEmbedUnimplementedPilaServer() {}
+func (UnimplementedPilaServer) testEmbeddedByValue()              {}
+
+// UnsafePilaServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PilaServer will
+// result in compilation errors.
+type UnsafePilaServer interface {
+	mustEmbedUnimplementedPilaServer()
+}
+
+func RegisterPilaServer(s grpc.ServiceRegistrar, srv PilaServer) {
+	// If the following call pancis, it indicates UnimplementedPilaServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Pila_ServiceDesc, srv)
+}
+
+func _Pila_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).Status(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_ListDatabases_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).ListDatabases(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_ListDatabases_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).ListDatabases(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_CreateDatabase_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateDatabaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).CreateDatabase(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_CreateDatabase_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).CreateDatabase(ctx, req.(*CreateDatabaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_GetDatabase_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DatabaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).GetDatabase(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_GetDatabase_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).GetDatabase(ctx, req.(*DatabaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_DeleteDatabase_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DatabaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).DeleteDatabase(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_DeleteDatabase_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).DeleteDatabase(ctx, req.(*DatabaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_ListStacks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DatabaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).ListStacks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_ListStacks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).ListStacks(ctx, req.(*DatabaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_CreateStack_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateStackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).CreateStack(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_CreateStack_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).CreateStack(ctx, req.(*CreateStackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_GetStack_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).GetStack(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_GetStack_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).GetStack(ctx, req.(*StackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_DeleteStack_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).DeleteStack(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_DeleteStack_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).DeleteStack(ctx, req.(*StackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_Push_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PushRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).Push(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_Push_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).Push(ctx, req.(*PushRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_Pop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).Pop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_Pop_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).Pop(ctx, req.(*PopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_Peek_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).Peek(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_Peek_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).Peek(ctx, req.(*StackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_Size_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).Size(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_Size_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).Size(ctx, req.(*StackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_Flush_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PilaServer).Flush(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pila_Flush_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PilaServer).Flush(ctx, req.(*StackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pila_StreamPops_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StackRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PilaServer).StreamPops(m, &grpc.GenericServerStream[StackRequest, Element]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pila_StreamPopsServer = grpc.ServerStreamingServer[Element]
+
+// Pila_ServiceDesc is the grpc.ServiceDesc for Pila service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Pila_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pila.Pila",
+	HandlerType: (*PilaServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Status",
+			Handler:    _Pila_Status_Handler,
+		},
+		{
+			MethodName: "ListDatabases",
+			Handler:    _Pila_ListDatabases_Handler,
+		},
+		{
+			MethodName: "CreateDatabase",
+			Handler:    _Pila_CreateDatabase_Handler,
+		},
+		{
+			MethodName: "GetDatabase",
+			Handler:    _Pila_GetDatabase_Handler,
+		},
+		{
+			MethodName: "DeleteDatabase",
+			Handler:    _Pila_DeleteDatabase_Handler,
+		},
+		{
+			MethodName: "ListStacks",
+			Handler:    _Pila_ListStacks_Handler,
+		},
+		{
+			MethodName: "CreateStack",
+			Handler:    _Pila_CreateStack_Handler,
+		},
+		{
+			MethodName: "GetStack",
+			Handler:    _Pila_GetStack_Handler,
+		},
+		{
+			MethodName: "DeleteStack",
+			Handler:    _Pila_DeleteStack_Handler,
+		},
+		{
+			MethodName: "Push",
+			Handler:    _Pila_Push_Handler,
+		},
+		{
+			MethodName: "Pop",
+			Handler:    _Pila_Pop_Handler,
+		},
+		{
+			MethodName: "Peek",
+			Handler:    _Pila_Peek_Handler,
+		},
+		{
+			MethodName: "Size",
+			Handler:    _Pila_Size_Handler,
+		},
+		{
+			MethodName: "Flush",
+			Handler:    _Pila_Flush_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamPops",
+			Handler:       _Pila_StreamPops_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pila.proto",
+}
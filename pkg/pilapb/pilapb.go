@@ -0,0 +1,51 @@
+// Package pilapb contains the protocol buffers and the gRPC service
+// of the piladb API, generated from pila.proto, and helpers to convert
+// elements from and into protocol buffer values.
+package pilapb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pila.proto
+
+import (
+	"encoding/json"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// NewValue returns the protocol buffer value of an element, which
+// must be suitable for a JSON encoding.
+func NewValue(element interface{}) (*structpb.Value, error) {
+	if v, err := structpb.NewValue(element); err == nil {
+		return v, nil
+	}
+
+	// types other than the ones decoded from JSON are
+	// converted through their JSON encoding.
+	b, err := json.Marshal(element)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return nil, err
+	}
+	return structpb.NewValue(decoded)
+}
+
+// NewElement returns an Element given its value, which must be
+// suitable for a JSON encoding.
+func NewElement(element interface{}) (*Element, error) {
+	v, err := NewValue(element)
+	if err != nil {
+		return nil, err
+	}
+	return &Element{Value: v}, nil
+}
+
+// Interface returns the value of the Element as a Go value, the
+// same way it would be decoded from JSON, or nil if it has none.
+func (x *Element) Interface() interface{} {
+	if x.GetValue() == nil {
+		return nil
+	}
+	return x.Value.AsInterface()
+}
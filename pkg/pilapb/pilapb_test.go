@@ -0,0 +1,51 @@
+package pilapb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewElement(t *testing.T) {
+	inputOutput := []struct {
+		input  interface{}
+		output interface{}
+	}{
+		{"foo", "foo"},
+		{8.5, 8.5},
+		{8, float64(8)},
+		{true, true},
+		{nil, nil},
+		{[]interface{}{"foo", 1.0}, []interface{}{"foo", 1.0}},
+		{map[string]interface{}{"foo": "bar"}, map[string]interface{}{"foo": "bar"}},
+		{[]string{"foo", "bar"}, []interface{}{"foo", "bar"}},
+		{struct {
+			Foo string `json:"foo"`
+		}{"bar"}, map[string]interface{}{"foo": "bar"}},
+	}
+
+	for _, io := range inputOutput {
+		element, err := NewElement(io.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := element.Interface(); !reflect.DeepEqual(v, io.output) {
+			t.Errorf("element is %#v, expected %#v", v, io.output)
+		}
+	}
+}
+
+func TestNewElement_Error(t *testing.T) {
+	if _, err := NewElement(make(chan int)); err == nil {
+		t.Error("err is nil, expected error")
+	}
+}
+
+func TestElementInterface_Nil(t *testing.T) {
+	var element *Element
+	if v := element.Interface(); v != nil {
+		t.Errorf("element is %v, expected nil", v)
+	}
+	if v := (&Element{}).Interface(); v != nil {
+		t.Errorf("element is %v, expected nil", v)
+	}
+}
@@ -0,0 +1,166 @@
+// Package resp implements the parts of the Redis serialization
+// protocol (RESP2) needed to serve commands sent by Redis clients.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Limits of the commands that can be read, to avoid allocating
+// unbounded memory on malformed input.
+const (
+	// MaxArgs is the maximum number of arguments of a command.
+	MaxArgs = 1024 * 1024
+	// MaxBulkSize is the maximum size in bytes of an argument.
+	MaxBulkSize = 512 * 1024 * 1024
+)
+
+// ErrProtocol is returned when the input does not follow the
+// protocol. The connection should be closed after replying it,
+// as the rest of the input cannot be trusted.
+var ErrProtocol = errors.New("protocol error")
+
+// Reader reads commands sent by Redis clients.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// ReadCommand reads the next command, and returns its name and
+// arguments. Commands are sent as arrays of bulk strings, or as
+// inline commands separated by spaces, as typed in a terminal.
+// Empty inline commands are skipped.
+func (r *Reader) ReadCommand() ([]string, error) {
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		if line[0] != '*' {
+			if args := strings.Fields(line); len(args) > 0 {
+				return args, nil
+			}
+			continue
+		}
+
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n > MaxArgs {
+			return nil, fmt.Errorf("%v: invalid multibulk length", ErrProtocol)
+		}
+		if n <= 0 {
+			continue
+		}
+
+		args := make([]string, n)
+		for i := range args {
+			if args[i], err = r.readBulk(); err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		return args, nil
+	}
+}
+
+// readBulk reads a bulk string.
+func (r *Reader) readBulk() (string, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return "", err
+	}
+	if len(line) == 0 || line[0] != '$' {
+		return "", fmt.Errorf("%v: expected '$', got '%s'", ErrProtocol, line)
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 || n > MaxBulkSize {
+		return "", fmt.Errorf("%v: invalid bulk length", ErrProtocol)
+	}
+
+	b := make([]byte, n+2)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return "", err
+	}
+	if b[n] != '\r' || b[n+1] != '\n' {
+		return "", fmt.Errorf("%v: bulk string not terminated by CRLF", ErrProtocol)
+	}
+	return string(b[:n]), nil
+}
+
+// readLine reads a line, without its trailing CRLF.
+func (r *Reader) readLine() (string, error) {
+	line, err := r.r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line != "" {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(line[:len(line)-1], "\r"), nil
+}
+
+// Writer writes replies to Redis clients. Replies are buffered
+// until Flush is called.
+type Writer struct {
+	w *bufio.Writer
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// WriteSimpleString writes a status reply, such as OK. It
+// must not contain CR or LF characters.
+func (w *Writer) WriteSimpleString(s string) error {
+	_, err := fmt.Fprintf(w.w, "+%s\r\n", s)
+	return err
+}
+
+// WriteError writes an error reply given its message, which
+// starts with an error code such as ERR by convention. CR and
+// LF characters are replaced by spaces.
+func (w *Writer) WriteError(message string) error {
+	message = strings.NewReplacer("\r", " ", "\n", " ").Replace(message)
+	_, err := fmt.Fprintf(w.w, "-%s\r\n", message)
+	return err
+}
+
+// WriteInteger writes an integer reply.
+func (w *Writer) WriteInteger(n int64) error {
+	_, err := fmt.Fprintf(w.w, ":%d\r\n", n)
+	return err
+}
+
+// WriteBulkString writes a bulk string reply, which can
+// contain any binary data.
+func (w *Writer) WriteBulkString(s string) error {
+	_, err := fmt.Fprintf(w.w, "$%d\r\n%s\r\n", len(s), s)
+	return err
+}
+
+// WriteNull writes a null bulk string reply, used when
+// there is no value to return.
+func (w *Writer) WriteNull() error {
+	_, err := w.w.WriteString("$-1\r\n")
+	return err
+}
+
+// Flush writes the buffered replies.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,82 @@
+package resp
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReaderReadCommand(t *testing.T) {
+	input := "*2\r\n$4\r\nLLEN\r\n$5\r\nstack\r\n" +
+		"*0\r\n" +
+		"\r\n" +
+		"PING\r\n" +
+		"lpush  stack foo\n" +
+		"*3\r\n$5\r\nLPUSH\r\n$5\r\nstack\r\n$6\r\nfoo\r\nb\r\n" +
+		"*1\r\n$0\r\n\r\n"
+	r := NewReader(strings.NewReader(input))
+
+	for _, expected := range [][]string{
+		{"LLEN", "stack"},
+		{"PING"},
+		{"lpush", "stack", "foo"},
+		{"LPUSH", "stack", "foo\r\nb"},
+		{""},
+	} {
+		args, err := r.ReadCommand()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(args, expected) {
+			t.Errorf("command is %q, expected %q", args, expected)
+		}
+	}
+
+	if _, err := r.ReadCommand(); err != io.EOF {
+		t.Errorf("err is %v, expected %v", err, io.EOF)
+	}
+}
+
+func TestReaderReadCommand_Error(t *testing.T) {
+	for _, input := range []string{
+		"*a\r\n",
+		"*2000000\r\n",
+		"*1\r\n:1\r\n",
+		"*1\r\n$-1\r\n",
+		"*1\r\n$a\r\n",
+		"*1\r\n$3\r\nfoobar\r\n",
+		"*1\r\n$3\r\nfo",
+		"*2\r\n$3\r\nfoo\r\n",
+		"PING",
+	} {
+		r := NewReader(strings.NewReader(input))
+		if _, err := r.ReadCommand(); err == nil || err == io.EOF {
+			t.Errorf("%q: err is %v, expected error", input, err)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	_ = w.WriteSimpleString("OK")
+	_ = w.WriteError("ERR bad\r\ncommand")
+	_ = w.WriteInteger(42)
+	_ = w.WriteBulkString("foo")
+	_ = w.WriteBulkString("")
+	_ = w.WriteNull()
+
+	if b.Len() != 0 {
+		t.Errorf("replies were written before flushing")
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "+OK\r\n-ERR bad  command\r\n:42\r\n$3\r\nfoo\r\n$0\r\n\r\n$-1\r\n"
+	if b.String() != expected {
+		t.Errorf("output is %q, expected %q", b.String(), expected)
+	}
+}
@@ -0,0 +1,422 @@
+package stack
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultSegmentSize is the default number of elements
+// contained by each segment of a DiskStack.
+const DefaultSegmentSize = 1024
+
+// segmentExt is the extension of the segment files.
+const segmentExt = ".seg"
+
+// checkpointFile is the name of the file of a checkpoint
+// that contains its size.
+const checkpointFile = "checkpoint.json"
+
+// DiskStack implements the Stacker interface, and represents a
+// stack whose elements are stored in a directory, split into segment
+// files of a fixed number of elements. Only the topmost elements are
+// kept in memory, in a head cache of up to two segments, so a DiskStack
+// can grow past the available memory.
+//
+// Elements are stored in JSON format, so they must be encodable
+// as such, and they are decoded into JSON types when read back
+// from disk, e.g. numbers are decoded as float64.
+type DiskStack struct {
+	dir         string
+	segmentSize int
+
+	// head contains the topmost elements, sorted from
+	// bottom to top. It is never empty if there are segments.
+	head     []interface{}
+	segments int
+	size     int
+	err      error
+	mux      sync.RWMutex
+}
+
+// NewDiskStack returns a blank DiskStack that stores its segments,
+// of segmentSize elements each, in dir. The directory is created if
+// it does not exist, and any previous segment is removed.
+func NewDiskStack(dir string, segmentSize int) (*DiskStack, error) {
+	if segmentSize < 1 {
+		return nil, fmt.Errorf("invalid segment size %d", segmentSize)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &DiskStack{
+		dir:         dir,
+		segmentSize: segmentSize,
+	}
+	if err := s.removeSegments(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// checkpointInfo is the content of the file of a checkpoint, with
+// the number of elements and segments of the checkpointed stack.
+type checkpointInfo struct {
+	Size     int `json:"size"`
+	Segments int `json:"segments"`
+}
+
+// OpenDiskStack returns a DiskStack that stores its segments, of
+// segmentSize elements each, in dir, containing the elements saved
+// in the checkpoint directory by Checkpoint. The directory is created
+// if it does not exist, and any previous segment is removed. Segments
+// are hard-linked from the checkpoint, so only the topmost one is read,
+// and the checkpoint is left untouched.
+func OpenDiskStack(dir string, segmentSize int, checkpoint string) (*DiskStack, error) {
+	b, err := ioutil.ReadFile(filepath.Join(checkpoint, checkpointFile))
+	if err != nil {
+		return nil, err
+	}
+	var info checkpointInfo
+	if err := json.Unmarshal(b, &info); err != nil {
+		return nil, err
+	}
+
+	s, err := NewDiskStack(dir, segmentSize)
+	if err != nil {
+		return nil, err
+	}
+	for n := 0; n < info.Segments; n++ {
+		if err := os.Link(segmentPath(checkpoint, n), s.segmentPath(n)); err != nil {
+			return nil, err
+		}
+		s.segments++
+	}
+	if s.segments > 0 {
+		if err := s.loadSegment(); err != nil {
+			return nil, err
+		}
+	}
+	s.size = info.Size
+
+	return s, nil
+}
+
+// Checkpoint saves the elements of the stack into the directory dir,
+// which is created, so they can be restored by OpenDiskStack even if
+// the stack changes meanwhile. Segments are hard-linked instead of
+// copied, as they are never modified, so only the elements of the head
+// cache are written, and dir must be in the same file system as the
+// directory of the stack.
+func (s *DiskStack) Checkpoint(dir string) error {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	segments := s.segments
+	for n := 0; n < segments; n++ {
+		if err := os.Link(s.segmentPath(n), segmentPath(dir, n)); err != nil {
+			return err
+		}
+	}
+	if len(s.head) > 0 {
+		if err := writeSegment(segmentPath(dir, segments), s.head); err != nil {
+			return err
+		}
+		segments++
+	}
+
+	// Do not check error as the info contains
+	// types suitable for a JSON encoding.
+	b, _ := json.Marshal(checkpointInfo{Size: s.size, Segments: segments})
+	return ioutil.WriteFile(filepath.Join(dir, checkpointFile), b, 0644)
+}
+
+// Push adds a new element on top of the stack. The bottom segment of
+// the head cache is written to disk once the cache is full. If that
+// fails, the elements are kept in memory and the error is available
+// through Err.
+func (s *DiskStack) Push(element interface{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.push(element)
+}
+
+// Pop removes and returns the element on top of the stack. If the
+// stack was empty, or the next segment could not be read from disk,
+// it returns false.
+func (s *DiskStack) Pop() (interface{}, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.pop()
+}
+
+// PushMany adds elements on top of the stack in order, so
+// the last one becomes the top.
+func (s *DiskStack) PushMany(elements []interface{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, element := range elements {
+		s.push(element)
+	}
+}
+
+// PopMany removes up to n elements from the top of the stack,
+// and returns them from top to bottom.
+func (s *DiskStack) PopMany(n int) []interface{} {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	var elements []interface{}
+	for ; n > 0; n-- {
+		element, ok := s.pop()
+		if !ok {
+			break
+		}
+		elements = append(elements, element)
+	}
+	return elements
+}
+
+func (s *DiskStack) push(element interface{}) {
+	s.head = append(s.head, element)
+	s.size++
+
+	if len(s.head) < 2*s.segmentSize {
+		return
+	}
+
+	if err := s.writeSegment(s.head[:s.segmentSize]); err != nil {
+		s.err = err
+		return
+	}
+	s.head = append(s.head[:0:0], s.head[s.segmentSize:]...)
+}
+
+func (s *DiskStack) pop() (interface{}, bool) {
+	if len(s.head) == 0 {
+		return nil, false
+	}
+
+	element := s.head[len(s.head)-1]
+	s.head[len(s.head)-1] = nil
+	s.head = s.head[:len(s.head)-1]
+	s.size--
+
+	if len(s.head) == 0 && s.segments > 0 {
+		if err := s.loadSegment(); err != nil {
+			s.err = err
+		}
+	}
+
+	return element, true
+}
+
+// Size returns the number of elements that a stack contains.
+func (s *DiskStack) Size() int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return s.size
+}
+
+// Peek returns the element on top of the stack.
+func (s *DiskStack) Peek() interface{} {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	if len(s.head) == 0 {
+		return nil
+	}
+	return s.head[len(s.head)-1]
+}
+
+// Flush flushes the content of the stack, removing all its
+// segments from disk.
+func (s *DiskStack) Flush() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.head = nil
+	s.size = 0
+	s.segments = 0
+	if err := s.removeSegments(); err != nil {
+		s.err = err
+	}
+}
+
+// Walk calls fn for each element of the stack, from top to bottom,
+// until fn returns false. Segments are read from disk one by one, and
+// the walk stops if any of them cannot be read.
+func (s *DiskStack) Walk(fn func(element interface{}) bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for i := len(s.head) - 1; i >= 0; i-- {
+		if !fn(s.head[i]) {
+			return
+		}
+	}
+
+	for n := s.segments - 1; n >= 0; n-- {
+		elements, err := s.readSegment(n)
+		if err != nil {
+			return
+		}
+		for i := len(elements) - 1; i >= 0; i-- {
+			if !fn(elements[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the last error found reading or writing
+// segments, if any.
+func (s *DiskStack) Err() error {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return s.err
+}
+
+// Close removes the stack from disk, including its directory.
+// The stack must not be used afterwards.
+func (s *DiskStack) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.head = nil
+	s.size = 0
+	s.segments = 0
+	return os.RemoveAll(s.dir)
+}
+
+// segmentPath returns the path of the nth segment.
+func (s *DiskStack) segmentPath(n int) string {
+	return segmentPath(s.dir, n)
+}
+
+// segmentPath returns the path of the nth segment
+// stored in dir.
+func segmentPath(dir string, n int) string {
+	return filepath.Join(dir, fmt.Sprintf("%010d%s", n, segmentExt))
+}
+
+// writeSegment writes elements into a new segment on top of
+// the existing ones.
+func (s *DiskStack) writeSegment(elements []interface{}) error {
+	if err := writeSegment(s.segmentPath(s.segments), elements); err != nil {
+		return err
+	}
+
+	s.segments++
+	return nil
+}
+
+// writeSegment writes elements into a new segment file. Any
+// previous file is removed first, and never overwritten, as
+// it could be hard-linked by a checkpoint.
+func writeSegment(path string, elements []interface{}) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+	for _, element := range elements {
+		if err := encoder.Encode(element); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// readSegment returns the elements of the nth segment,
+// sorted from bottom to top.
+func (s *DiskStack) readSegment(n int) ([]interface{}, error) {
+	f, err := os.Open(s.segmentPath(n))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	elements := make([]interface{}, 0, s.segmentSize)
+	decoder := json.NewDecoder(bufio.NewReader(f))
+	for decoder.More() {
+		var element interface{}
+		if err := decoder.Decode(&element); err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+	}
+
+	return elements, nil
+}
+
+// loadSegment moves the topmost segment from disk into
+// the head cache.
+func (s *DiskStack) loadSegment() error {
+	n := s.segments - 1
+	elements, err := s.readSegment(n)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.segmentPath(n)); err != nil {
+		return err
+	}
+
+	s.head = elements
+	s.segments--
+	return nil
+}
+
+// removeSegments removes all segment files of the
+// stack directory.
+func (s *DiskStack) removeSegments() error {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), segmentExt) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, file.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+package stack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var (
+	_ Stacker      = (*DiskStack)(nil)
+	_ Walker       = (*DiskStack)(nil)
+	_ Batcher      = (*DiskStack)(nil)
+	_ Checkpointer = (*DiskStack)(nil)
+)
+
+func testDiskStack(t *testing.T, segmentSize int) (*DiskStack, string) {
+	dir, err := ioutil.TempDir("", "piladb-disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stack, err := NewDiskStack(filepath.Join(dir, "stack"), segmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stack, dir
+}
+
+func segmentFiles(t *testing.T, stack *DiskStack) int {
+	files, err := filepath.Glob(filepath.Join(stack.dir, "*"+segmentExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestNewDiskStack(t *testing.T) {
+	stack, dir := testDiskStack(t, 4)
+	defer os.RemoveAll(dir)
+
+	if stack.head != nil {
+		t.Error("stack.head is not nil")
+	}
+	if stack.size != 0 {
+		t.Errorf("stack.size is %v, expected 0", stack.size)
+	}
+	if info, err := os.Stat(stack.dir); err != nil || !info.IsDir() {
+		t.Errorf("stack directory %s was not created", stack.dir)
+	}
+}
+
+func TestNewDiskStack_RemovesSegments(t *testing.T) {
+	stack, dir := testDiskStack(t, 1)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 5; i++ {
+		stack.Push(i)
+	}
+	if segmentFiles(t, stack) == 0 {
+		t.Fatal("no segments were written")
+	}
+
+	stack, err := NewDiskStack(stack.dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := segmentFiles(t, stack); n != 0 {
+		t.Errorf("stack has %d segments, expected %d", n, 0)
+	}
+}
+
+func TestNewDiskStack_Error(t *testing.T) {
+	if _, err := NewDiskStack(os.TempDir(), 0); err == nil {
+		t.Error("err is nil")
+	}
+
+	f, err := ioutil.TempFile("", "piladb-disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	if _, err := NewDiskStack(f.Name(), 4); err == nil {
+		t.Error("err is nil")
+	}
+}
+
+func TestDiskStackCheckpoint(t *testing.T) {
+	stack, dir := testDiskStack(t, 2)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 7; i++ {
+		stack.Push(i)
+	}
+	checkpoint := filepath.Join(dir, "checkpoint")
+	if err := stack.Checkpoint(checkpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	// changes after the checkpoint are not restored
+	stack.PopMany(6)
+	stack.Push("x")
+
+	restored, err := OpenDiskStack(filepath.Join(dir, "restored"), 2, checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Size() != 7 {
+		t.Errorf("size is %d, expected %d", restored.Size(), 7)
+	}
+	expected := []interface{}{6.0, 5.0, 4.0, 3.0, 2.0, 1.0, 0.0}
+	if elements := restored.PopMany(7); !reflect.DeepEqual(elements, expected) {
+		t.Errorf("elements are %v, expected %v", elements, expected)
+	}
+	if err := restored.Err(); err != nil {
+		t.Errorf("err is %v, expected nil", err)
+	}
+
+	// the checkpoint can be opened again
+	restored, err = OpenDiskStack(filepath.Join(dir, "restored"), 2, checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Size() != 7 || restored.Peek() != 6.0 {
+		t.Errorf("size is %d and peek %v, expected %d and %v", restored.Size(), restored.Peek(), 7, 6.0)
+	}
+	if elements := stack.PopMany(2); !reflect.DeepEqual(elements, []interface{}{"x", 0.0}) {
+		t.Errorf("elements are %v, expected [x 0]", elements)
+	}
+}
+
+func TestOpenDiskStack_Error(t *testing.T) {
+	if _, err := OpenDiskStack(os.TempDir(), 4, "/no/exist"); err == nil {
+		t.Error("err is nil")
+	}
+}
+
+func TestDiskStackPushPop(t *testing.T) {
+	stack, dir := testDiskStack(t, 3)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 20; i++ {
+		stack.Push(i)
+	}
+
+	if stack.Size() != 20 {
+		t.Errorf("stack.Size() is %d, expected %d", stack.Size(), 20)
+	}
+	if len(stack.head) >= 2*3 {
+		t.Errorf("stack head has %d elements, expected less than %d", len(stack.head), 2*3)
+	}
+	if n := segmentFiles(t, stack); n != stack.segments || n == 0 {
+		t.Errorf("stack has %d segment files, expected %d", n, stack.segments)
+	}
+
+	// elements in the head cache keep their type
+	if peek := stack.Peek(); peek != 19 {
+		t.Errorf("stack.Peek() is %v, expected %v", peek, 19)
+	}
+
+	for i := 19; i >= 0; i-- {
+		element, ok := stack.Pop()
+		if !ok {
+			t.Fatalf("stack.Pop() not ok at %d", i)
+		}
+		if float64(i) != toFloat(element) {
+			t.Errorf("element is %v, expected %v", element, i)
+		}
+	}
+
+	if _, ok := stack.Pop(); ok {
+		t.Error("stack.Pop() is ok on empty stack")
+	}
+	if stack.Size() != 0 {
+		t.Errorf("stack.Size() is %d, expected %d", stack.Size(), 0)
+	}
+	if n := segmentFiles(t, stack); n != 0 {
+		t.Errorf("stack has %d segment files, expected %d", n, 0)
+	}
+	if err := stack.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func toFloat(element interface{}) float64 {
+	switch v := element.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return -1
+}
+
+func TestDiskStackPeek(t *testing.T) {
+	stack, dir := testDiskStack(t, 2)
+	defer os.RemoveAll(dir)
+
+	if stack.Peek() != nil {
+		t.Error("stack.Peek() is not nil")
+	}
+
+	stack.Push("one")
+	stack.Push("two")
+	stack.Push("three")
+	stack.Push("four")
+	stack.Pop()
+	stack.Pop()
+	stack.Pop()
+	if stack.Peek() != "one" {
+		t.Errorf("stack.Peek() is %v, expected %v", stack.Peek(), "one")
+	}
+}
+
+func TestDiskStackFlush(t *testing.T) {
+	stack, dir := testDiskStack(t, 2)
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 10; i++ {
+		stack.Push(i)
+	}
+	stack.Flush()
+
+	if stack.Peek() != nil {
+		t.Error("stack.Peek() is not nil")
+	}
+	if stack.Size() != 0 {
+		t.Errorf("stack.Size() is %d, expected %d", stack.Size(), 0)
+	}
+	if n := segmentFiles(t, stack); n != 0 {
+		t.Errorf("stack has %d segment files, expected %d", n, 0)
+	}
+
+	stack.Push("foo")
+	if stack.Peek() != "foo" {
+		t.Errorf("stack.Peek() is %v, expected %v", stack.Peek(), "foo")
+	}
+}
+
+func TestDiskStackWalk(t *testing.T) {
+	stack, dir := testDiskStack(t, 2)
+	defer os.RemoveAll(dir)
+
+	for _, element := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		stack.Push(element)
+	}
+
+	var elements []interface{}
+	stack.Walk(func(element interface{}) bool {
+		elements = append(elements, element)
+		return true
+	})
+
+	expected := []interface{}{"g", "f", "e", "d", "c", "b", "a"}
+	if !reflect.DeepEqual(elements, expected) {
+		t.Errorf("elements are %v, expected %v", elements, expected)
+	}
+
+	var n int
+	stack.Walk(func(element interface{}) bool {
+		n++
+		return n < 5
+	})
+	if n != 5 {
+		t.Errorf("walked %d elements, expected %d", n, 5)
+	}
+}
+
+func TestDiskStackPush_Error(t *testing.T) {
+	stack, dir := testDiskStack(t, 1)
+	defer os.RemoveAll(dir)
+
+	stack.Push(make(chan int))
+	stack.Push("foo")
+
+	if stack.Err() == nil {
+		t.Error("stack.Err() is nil")
+	}
+	if stack.Size() != 2 {
+		t.Errorf("stack.Size() is %d, expected %d", stack.Size(), 2)
+	}
+	if stack.Peek() != "foo" {
+		t.Errorf("stack.Peek() is %v, expected %v", stack.Peek(), "foo")
+	}
+}
+
+func TestDiskStackClose(t *testing.T) {
+	stack, dir := testDiskStack(t, 1)
+	defer os.RemoveAll(dir)
+
+	stack.Push("foo")
+	stack.Push("bar")
+
+	if err := stack.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stack.dir); !os.IsNotExist(err) {
+		t.Errorf("stack directory %s was not removed", stack.dir)
+	}
+}
+
+func TestDiskStackRace(t *testing.T) {
+	stack, dir := testDiskStack(t, 1)
+	defer os.RemoveAll(dir)
+
+	go func() { stack.Push(1) }()
+	go func() { stack.Pop() }()
+	go func() { stack.Size() }()
+	go func() { stack.Peek() }()
+	go func() { stack.Walk(func(interface{}) bool { return true }) }()
+}
+
+func TestDiskStackPushManyPopMany(t *testing.T) {
+	stack, dir := testDiskStack(t, 1)
+	defer os.RemoveAll(dir)
+
+	testPushManyPopMany(t, stack)
+}
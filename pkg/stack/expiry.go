@@ -0,0 +1,94 @@
+package stack
+
+import "time"
+
+// now returns the current time. It is a variable
+// so tests can control the expiration of elements.
+var now = time.Now
+
+// expiry keeps track of the elements of a stack that expire,
+// so the stack is only traversed when any of them is due.
+type expiry struct {
+	// count is the number of elements that expire, and
+	// next is the earliest expiration time among them. Both
+	// are only exact after a traversal, so count may include
+	// elements already removed, and next may be too early.
+	count int
+	next  time.Time
+	// clock returns the current time to expire the
+	// elements, the package one if nil.
+	clock func() time.Time
+}
+
+// now returns the current time to expire the elements.
+func (e *expiry) now() time.Time {
+	if e.clock != nil {
+		return e.clock()
+	}
+	return now()
+}
+
+// add keeps track of an element that expires at t, if any.
+func (e *expiry) add(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	e.count++
+	if e.next.IsZero() || t.Before(e.next) {
+		e.next = t
+	}
+}
+
+// remove stops keeping track of an element that expires
+// at t, if any.
+func (e *expiry) remove(t time.Time) {
+	if !t.IsZero() && e.count > 0 {
+		e.count--
+	}
+	if e.count == 0 {
+		e.next = time.Time{}
+	}
+}
+
+// due returns true if any element may be expired at t.
+func (e *expiry) due(t time.Time) bool {
+	return e.count > 0 && !t.Before(e.next)
+}
+
+// reset stops keeping track of any element.
+func (e *expiry) reset() {
+	e.count = 0
+	e.next = time.Time{}
+}
+
+// expired returns true if an element expiring at
+// expiresAt is expired at t.
+func expired(expiresAt, t time.Time) bool {
+	return !expiresAt.IsZero() && !t.Before(expiresAt)
+}
+
+// expiring wraps an element that expires in stacks that
+// store bare elements, so the ones that do not expire
+// carry no extra data.
+type expiring struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// unwrap returns an element stored in a stack, and the
+// time it expires at, if any.
+func unwrap(element interface{}) (interface{}, time.Time) {
+	if e, ok := element.(expiring); ok {
+		return e.value, e.expiresAt
+	}
+	return element, time.Time{}
+}
+
+// wrap returns the element to store in a stack given an
+// element and the time it expires at, if any.
+func wrap(element interface{}, expiresAt time.Time) interface{} {
+	if expiresAt.IsZero() {
+		return element
+	}
+	return expiring{value: element, expiresAt: expiresAt}
+}
@@ -0,0 +1,247 @@
+package stack
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// setNow sets the current time of the package to t, and
+// returns a function that restores it.
+func setNow(t time.Time) func() {
+	now = func() time.Time { return t }
+	return func() { now = time.Now }
+}
+
+func expirers(t *testing.T) map[string]func() Expirer {
+	return map[string]func() Expirer{
+		"Stack":      func() Expirer { return NewStack() },
+		"SliceStack": func() Expirer { return NewSliceStack(2) },
+		"RingStack": func() Expirer {
+			s, err := NewRingStack(10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+	}
+}
+
+func walkExpiring(stack Expirer) ([]interface{}, []time.Time) {
+	var elements []interface{}
+	var expirations []time.Time
+	stack.WalkExpiring(func(element interface{}, expiresAt time.Time) bool {
+		elements = append(elements, element)
+		expirations = append(expirations, expiresAt)
+		return true
+	})
+	return elements, expirations
+}
+
+func TestExpirer(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, newExpirer := range expirers(t) {
+		restore := setNow(start)
+
+		e := newExpirer()
+		s := e.(Stacker)
+		s.Push("a")
+		e.PushExpiring("b", start.Add(2*time.Second))
+		s.Push("c")
+		e.PushExpiring("d", start.Add(time.Second))
+
+		if s.Size() != 4 || s.Peek() != "d" {
+			t.Errorf("%s: size is %d and peek %v, expected %d and %v", name, s.Size(), s.Peek(), 4, "d")
+		}
+		elements, expirations := walkExpiring(e)
+		if expected := []interface{}{"d", "c", "b", "a"}; !reflect.DeepEqual(elements, expected) {
+			t.Errorf("%s: elements are %v, expected %v", name, elements, expected)
+		}
+		if expected := []time.Time{start.Add(time.Second), {}, start.Add(2 * time.Second), {}}; !reflect.DeepEqual(expirations, expected) {
+			t.Errorf("%s: expirations are %v, expected %v", name, expirations, expected)
+		}
+
+		setNow(start.Add(time.Second))
+		if s.Size() != 3 || s.Peek() != "c" {
+			t.Errorf("%s: size is %d and peek %v, expected %d and %v", name, s.Size(), s.Peek(), 3, "c")
+		}
+
+		setNow(start.Add(2 * time.Second))
+		if element, ok := s.Pop(); !ok || element != "c" {
+			t.Errorf("%s: pop is %v, %v, expected %v, true", name, element, ok, "c")
+		}
+		if element, ok := s.Pop(); !ok || element != "a" {
+			t.Errorf("%s: pop is %v, %v, expected %v, true", name, element, ok, "a")
+		}
+		if _, ok := s.Pop(); ok {
+			t.Errorf("%s: pop is true, expected false", name)
+		}
+
+		restore()
+	}
+}
+
+func TestExpirerExpire(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, newExpirer := range expirers(t) {
+		restore := setNow(start)
+
+		e := newExpirer()
+		s := e.(Stacker)
+		for i := 0; i < 5; i++ {
+			e.PushExpiring(i, start.Add(time.Duration(i%2+1)*time.Second))
+			s.Push("x")
+		}
+
+		if n := e.Expire(); n != 0 {
+			t.Errorf("%s: expired %d elements, expected %d", name, n, 0)
+		}
+
+		setNow(start.Add(time.Second))
+		if n := e.Expire(); n != 3 {
+			t.Errorf("%s: expired %d elements, expected %d", name, n, 3)
+		}
+		if elements := walk(e.(Walker)); !reflect.DeepEqual(elements, []interface{}{"x", "x", 3, "x", "x", 1, "x"}) {
+			t.Errorf("%s: elements are %v", name, elements)
+		}
+
+		setNow(start.Add(time.Hour))
+		if n := e.Expire(); n != 2 {
+			t.Errorf("%s: expired %d elements, expected %d", name, n, 2)
+		}
+		if s.Size() != 5 {
+			t.Errorf("%s: size is %d, expected %d", name, s.Size(), 5)
+		}
+
+		s.Push("y")
+		if s.Size() != 6 || s.Peek() != "y" {
+			t.Errorf("%s: size is %d and peek %v, expected %d and %v", name, s.Size(), s.Peek(), 6, "y")
+		}
+
+		restore()
+	}
+}
+
+func TestExpirerPopMany(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, newExpirer := range expirers(t) {
+		restore := setNow(start)
+
+		e := newExpirer()
+		e.(Stacker).Push("a")
+		e.PushExpiring("b", start.Add(time.Second))
+		e.(Stacker).Push("c")
+
+		setNow(start.Add(time.Second))
+		if elements := e.(Batcher).PopMany(3); !reflect.DeepEqual(elements, []interface{}{"c", "a"}) {
+			t.Errorf("%s: elements are %v, expected [c a]", name, elements)
+		}
+
+		restore()
+	}
+}
+
+func TestExpirerFlush(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, newExpirer := range expirers(t) {
+		restore := setNow(start)
+
+		e := newExpirer()
+		e.PushExpiring("a", start.Add(time.Second))
+		e.(Stacker).Flush()
+		e.(Stacker).Push("b")
+
+		setNow(start.Add(time.Second))
+		if n := e.Expire(); n != 0 {
+			t.Errorf("%s: expired %d elements, expected %d", name, n, 0)
+		}
+		if s := e.(Stacker).Size(); s != 1 {
+			t.Errorf("%s: size is %d, expected %d", name, s, 1)
+		}
+
+		restore()
+	}
+}
+
+func TestExpirerSetClock(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, newExpirer := range expirers(t) {
+		restore := setNow(start.Add(time.Hour))
+
+		e := newExpirer()
+		clock := start
+		e.(Clocked).SetClock(func() time.Time { return clock })
+		e.(Stacker).Push("a")
+		e.PushExpiring("b", start.Add(time.Second))
+
+		if element, ok := e.(Stacker).Pop(); !ok || element != "b" {
+			t.Errorf("%s: pop is %v, %v, expected %v, true", name, element, ok, "b")
+		}
+
+		e.PushExpiring("c", start.Add(time.Second))
+		clock = start.Add(time.Second)
+		if peek := e.(Stacker).Peek(); peek != "a" {
+			t.Errorf("%s: peek is %v, expected %v", name, peek, "a")
+		}
+
+		e.PushExpiring("d", start.Add(time.Minute))
+		e.(Clocked).SetClock(nil)
+		if size := e.(Stacker).Size(); size != 1 {
+			t.Errorf("%s: size is %d, expected %d", name, size, 1)
+		}
+
+		restore()
+	}
+}
+
+func TestRingStackExpire_Full(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer setNow(start)()
+
+	s, _ := NewRingStack(3)
+	s.PushExpiring("a", start.Add(time.Second))
+	s.Push("b")
+	s.PushExpiring("c", start.Add(time.Second))
+	s.Push("d")
+
+	setNow(start.Add(time.Second))
+	if n := s.Expire(); n != 1 {
+		t.Errorf("expired %d elements, expected %d", n, 1)
+	}
+
+	s.Push("e")
+	s.Push("f")
+	if elements := walk(s); !reflect.DeepEqual(elements, []interface{}{"f", "e", "d"}) {
+		t.Errorf("elements are %v, expected [f e d]", elements)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var e expiry
+	if e.due(start) {
+		t.Error("due is true, expected false")
+	}
+
+	e.add(time.Time{})
+	e.add(start.Add(2 * time.Second))
+	e.add(start.Add(time.Second))
+	if e.count != 2 || e.next != start.Add(time.Second) {
+		t.Errorf("count is %d and next %v, expected %d and %v", e.count, e.next, 2, start.Add(time.Second))
+	}
+	if e.due(start) || !e.due(start.Add(time.Second)) {
+		t.Error("due is wrong")
+	}
+
+	e.remove(start.Add(time.Second))
+	e.remove(start.Add(2 * time.Second))
+	if e.count != 0 || !e.next.IsZero() || e.due(start.Add(time.Hour)) {
+		t.Errorf("count is %d and next %v, expected 0 and zero", e.count, e.next)
+	}
+}
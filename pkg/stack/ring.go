@@ -0,0 +1,255 @@
+package stack
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RingStack implements the Stacker interface, and represents a stack
+// of a fixed capacity backed by a ring buffer. Once the stack is full,
+// every Push discards the element at the bottom of the stack, so it
+// always keeps the latest pushed elements.
+type RingStack struct {
+	// elements grows up to capacity, and then it is
+	// used as a ring buffer, starting at bottom. Elements
+	// that expire are wrapped with their expiration time.
+	elements []interface{}
+	capacity int
+	bottom   int
+	size     int
+	expiry   expiry
+	mux      sync.RWMutex
+}
+
+// NewRingStack returns a blank RingStack that can contain up to
+// capacity elements. It returns an error if capacity is not positive.
+func NewRingStack(capacity int) (*RingStack, error) {
+	if capacity < 1 {
+		return nil, fmt.Errorf("invalid capacity %d", capacity)
+	}
+	return &RingStack{capacity: capacity}, nil
+}
+
+// Push adds a new element on top of the stack. If the stack is
+// full, the element at the bottom of the stack is discarded.
+func (s *RingStack) Push(element interface{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.push(element)
+}
+
+// PushExpiring adds a new element on top of the stack that expires
+// at the given time. If the stack is full, the element at the bottom
+// of the stack is discarded.
+func (s *RingStack) PushExpiring(element interface{}, expiresAt time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.push(wrap(element, expiresAt))
+	s.expiry.add(expiresAt)
+}
+
+// Pop removes and returns the element on top of the stack. If the
+// stack was empty, it returns false.
+func (s *RingStack) Pop() (interface{}, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.expire(s.expiry.now())
+	return s.pop()
+}
+
+// PushMany adds elements on top of the stack in order, so the
+// last one becomes the top. Bottom elements are discarded if
+// the stack overflows.
+func (s *RingStack) PushMany(elements []interface{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, element := range elements {
+		s.push(element)
+	}
+}
+
+// PopMany removes up to n elements from the top of the stack,
+// and returns them from top to bottom.
+func (s *RingStack) PopMany(n int) []interface{} {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.expire(s.expiry.now())
+	var elements []interface{}
+	for ; n > 0; n-- {
+		element, ok := s.pop()
+		if !ok {
+			break
+		}
+		elements = append(elements, element)
+	}
+	return elements
+}
+
+func (s *RingStack) push(element interface{}) {
+	switch {
+	case s.size == len(s.elements) && s.size < s.capacity:
+		// the buffer is not full yet, so bottom is 0
+		s.elements = append(s.elements, element)
+		s.size++
+	case s.size < s.capacity:
+		s.elements[s.index(s.size)] = element
+		s.size++
+	default:
+		_, expiresAt := unwrap(s.elements[s.bottom])
+		s.expiry.remove(expiresAt)
+		s.elements[s.bottom] = element
+		s.bottom = s.index(1)
+	}
+}
+
+func (s *RingStack) pop() (interface{}, bool) {
+	if s.size == 0 {
+		return nil, false
+	}
+
+	top := s.index(s.size - 1)
+	element, expiresAt := unwrap(s.elements[top])
+	s.expiry.remove(expiresAt)
+	// release the reference so the element can be collected
+	s.elements[top] = nil
+	s.size--
+	return element, true
+}
+
+// Size returns the number of elements that a stack contains.
+func (s *RingStack) Size() int {
+	s.rlock()
+	defer s.mux.RUnlock()
+
+	return s.size
+}
+
+// Peek returns the element on top of the stack.
+func (s *RingStack) Peek() interface{} {
+	s.rlock()
+	defer s.mux.RUnlock()
+
+	if s.size == 0 {
+		return nil
+	}
+	element, _ := unwrap(s.elements[s.index(s.size-1)])
+	return element
+}
+
+// Flush flushes the content of the stack.
+func (s *RingStack) Flush() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.elements = nil
+	s.bottom = 0
+	s.size = 0
+	s.expiry.reset()
+}
+
+// Walk calls fn for each element of the stack, from top to
+// bottom, until fn returns false. The stack is locked for
+// reading during the walk, so fn must not modify it.
+func (s *RingStack) Walk(fn func(element interface{}) bool) {
+	s.WalkExpiring(func(element interface{}, _ time.Time) bool {
+		return fn(element)
+	})
+}
+
+// WalkExpiring calls fn for each element of the stack and the
+// time it expires at, zero if it does not expire, from top to
+// bottom, until fn returns false. The stack is locked for
+// reading during the walk, so fn must not modify it.
+func (s *RingStack) WalkExpiring(fn func(element interface{}, expiresAt time.Time) bool) {
+	s.rlock()
+	defer s.mux.RUnlock()
+
+	t := s.expiry.now()
+	for i := s.size - 1; i >= 0; i-- {
+		element, expiresAt := unwrap(s.elements[s.index(i)])
+		if expired(expiresAt, t) {
+			continue
+		}
+		if !fn(element, expiresAt) {
+			return
+		}
+	}
+}
+
+// Expire removes the expired elements of the stack, and
+// returns how many were removed.
+func (s *RingStack) Expire() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.expire(s.expiry.now())
+}
+
+// SetClock sets the function that returns the current time to
+// expire the elements of the stack, or the system clock if nil.
+func (s *RingStack) SetClock(clock func() time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.expiry.clock = clock
+}
+
+// Capacity returns the maximum number of elements that
+// the stack can contain.
+func (s *RingStack) Capacity() int {
+	return s.capacity
+}
+
+// rlock locks the stack for reading, removing its
+// expired elements first, if any.
+func (s *RingStack) rlock() {
+	s.mux.RLock()
+	if !s.expiry.due(s.expiry.now()) {
+		return
+	}
+	s.mux.RUnlock()
+
+	s.mux.Lock()
+	s.expire(s.expiry.now())
+	s.mux.Unlock()
+	s.mux.RLock()
+}
+
+// expire removes the elements expired at t, if any is due,
+// keeping the order of the rest, and returns how many were
+// removed. The buffer is rebuilt starting at the bottom.
+func (s *RingStack) expire(t time.Time) int {
+	if !s.expiry.due(t) {
+		return 0
+	}
+
+	s.expiry.reset()
+	elements := make([]interface{}, 0, s.size)
+	for i := 0; i < s.size; i++ {
+		element := s.elements[s.index(i)]
+		_, expiresAt := unwrap(element)
+		if expired(expiresAt, t) {
+			continue
+		}
+		s.expiry.add(expiresAt)
+		elements = append(elements, element)
+	}
+
+	removed := s.size - len(elements)
+	s.elements = elements
+	s.bottom = 0
+	s.size = len(elements)
+	return removed
+}
+
+// index returns the position in the buffer of the i-th
+// element of the stack, starting from the bottom.
+func (s *RingStack) index(i int) int {
+	return (s.bottom + i) % len(s.elements)
+}
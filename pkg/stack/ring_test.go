@@ -0,0 +1,206 @@
+package stack
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	_ Stacker = (*RingStack)(nil)
+	_ Walker  = (*RingStack)(nil)
+	_ Batcher = (*RingStack)(nil)
+	_ Bounded = (*RingStack)(nil)
+)
+
+func walk(stack Walker) []interface{} {
+	var elements []interface{}
+	stack.Walk(func(element interface{}) bool {
+		elements = append(elements, element)
+		return true
+	})
+	return elements
+}
+
+func TestNewRingStack(t *testing.T) {
+	stack, err := NewRingStack(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stack.Capacity() != 3 {
+		t.Errorf("stack.Capacity() is %d, expected %d", stack.Capacity(), 3)
+	}
+	if stack.Size() != 0 {
+		t.Errorf("stack.Size() is %d, expected %d", stack.Size(), 0)
+	}
+}
+
+func TestNewRingStack_Error(t *testing.T) {
+	for _, capacity := range []int{0, -1} {
+		if _, err := NewRingStack(capacity); err == nil {
+			t.Errorf("err is nil for capacity %d", capacity)
+		}
+	}
+}
+
+func TestRingStackPush_Evicts(t *testing.T) {
+	stack, _ := NewRingStack(3)
+	for i := 0; i < 5; i++ {
+		stack.Push(i)
+	}
+
+	if stack.Size() != 3 {
+		t.Errorf("stack.Size() is %d, expected %d", stack.Size(), 3)
+	}
+	if len(stack.elements) != 3 {
+		t.Errorf("buffer has %d elements, expected %d", len(stack.elements), 3)
+	}
+	if elements, expected := walk(stack), []interface{}{4, 3, 2}; !reflect.DeepEqual(elements, expected) {
+		t.Errorf("elements are %v, expected %v", elements, expected)
+	}
+}
+
+func TestRingStackPushExpiring_Evicts(t *testing.T) {
+	stack, _ := NewRingStack(2)
+	expiresAt := time.Now().Add(time.Hour)
+	stack.PushExpiring("a", expiresAt)
+	stack.PushExpiring("b", expiresAt)
+	stack.Push("c")
+	stack.Push("d")
+
+	// evicted elements are not tracked as expiring anymore
+	if stack.expiry.count != 0 {
+		t.Errorf("expiring count is %d, expected %d", stack.expiry.count, 0)
+	}
+	if !stack.expiry.next.IsZero() {
+		t.Errorf("next expiration is %v, expected zero", stack.expiry.next)
+	}
+
+	stack.PushExpiring("e", expiresAt)
+	if stack.expiry.count != 1 {
+		t.Errorf("expiring count is %d, expected %d", stack.expiry.count, 1)
+	}
+}
+
+func TestRingStackPushPop(t *testing.T) {
+	stack, _ := NewRingStack(4)
+	for i := 0; i < 6; i++ {
+		stack.Push(i)
+	}
+
+	// pop and push again across the end of the buffer
+	for _, expected := range []int{5, 4} {
+		if element, ok := stack.Pop(); !ok || element != expected {
+			t.Errorf("stack.Pop() is %v, %v, expected %v, true", element, ok, expected)
+		}
+	}
+	stack.Push("a")
+	stack.Push("b")
+	stack.Push("c")
+
+	if elements, expected := walk(stack), []interface{}{"c", "b", "a", 3}; !reflect.DeepEqual(elements, expected) {
+		t.Errorf("elements are %v, expected %v", elements, expected)
+	}
+
+	for stack.Size() > 0 {
+		stack.Pop()
+	}
+	if _, ok := stack.Pop(); ok {
+		t.Error("stack.Pop() is ok on empty stack")
+	}
+	for i, element := range stack.elements {
+		if element != nil {
+			t.Errorf("popped element %v at %d is still referenced", element, i)
+		}
+	}
+}
+
+func TestRingStackPushPop_NotFull(t *testing.T) {
+	stack, _ := NewRingStack(4)
+	stack.Push(1)
+	stack.Push(2)
+	stack.Pop()
+	stack.Push(3)
+	stack.Push(4)
+
+	if elements, expected := walk(stack), []interface{}{4, 3, 1}; !reflect.DeepEqual(elements, expected) {
+		t.Errorf("elements are %v, expected %v", elements, expected)
+	}
+}
+
+func TestRingStackPeek(t *testing.T) {
+	stack, _ := NewRingStack(2)
+	if stack.Peek() != nil {
+		t.Error("stack.Peek() is not nil")
+	}
+
+	stack.Push("one")
+	stack.Push("two")
+	stack.Push("three")
+	if stack.Peek() != "three" {
+		t.Errorf("stack.Peek() is %v, expected %v", stack.Peek(), "three")
+	}
+}
+
+func TestRingStackFlush(t *testing.T) {
+	stack, _ := NewRingStack(2)
+	for i := 0; i < 5; i++ {
+		stack.Push(i)
+	}
+	stack.Flush()
+
+	if stack.Size() != 0 {
+		t.Errorf("stack.Size() is %d, expected %d", stack.Size(), 0)
+	}
+	if stack.Peek() != nil {
+		t.Error("stack.Peek() is not nil")
+	}
+
+	stack.Push("foo")
+	if stack.Peek() != "foo" {
+		t.Errorf("stack.Peek() is %v, expected %v", stack.Peek(), "foo")
+	}
+}
+
+func TestRingStackWalk(t *testing.T) {
+	stack, _ := NewRingStack(3)
+	if elements := walk(stack); elements != nil {
+		t.Errorf("elements are %v, expected none", elements)
+	}
+
+	for i := 0; i < 4; i++ {
+		stack.Push(i)
+	}
+
+	var n int
+	stack.Walk(func(element interface{}) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("walked %d elements, expected %d", n, 1)
+	}
+}
+
+func TestRingStackRace(t *testing.T) {
+	stack, _ := NewRingStack(1)
+	go func() { stack.Push(1) }()
+	go func() { stack.Pop() }()
+	go func() { stack.Size() }()
+	go func() { stack.Peek() }()
+	go func() { stack.Walk(func(interface{}) bool { return true }) }()
+}
+
+func TestRingStackPushManyPopMany(t *testing.T) {
+	stack, _ := NewRingStack(10)
+	testPushManyPopMany(t, stack)
+}
+
+func TestRingStackPushMany_Evicts(t *testing.T) {
+	stack, _ := NewRingStack(3)
+	stack.PushMany([]interface{}{1, 2, 3, 4, 5})
+
+	if elements, expected := walk(stack), []interface{}{5, 4, 3}; !reflect.DeepEqual(elements, expected) {
+		t.Errorf("elements are %v, expected %v", elements, expected)
+	}
+}
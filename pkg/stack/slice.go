@@ -0,0 +1,276 @@
+package stack
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultChunkSize is the default number of elements by which
+// the capacity of a SliceStack grows or shrinks.
+const DefaultChunkSize = 1024
+
+// SliceStack implements the Stacker interface, and represents the
+// stack data structure as a growable slice, whose last element is
+// the top of the stack. Unlike Stack, it does not allocate on every
+// Push: its capacity grows in chunks, doubling each time, and shrinks
+// by half once it is only a quarter full, so alternating pushes and
+// pops at the boundary do not cause reallocations.
+type SliceStack struct {
+	// elements that expire are wrapped with
+	// their expiration time
+	elements  []interface{}
+	chunkSize int
+	expiry    expiry
+	mux       sync.RWMutex
+}
+
+// NewSliceStack returns a blank SliceStack whose capacity grows in
+// chunks of chunkSize elements. DefaultChunkSize is used if chunkSize
+// is not positive.
+func NewSliceStack(chunkSize int) *SliceStack {
+	if chunkSize < 1 {
+		chunkSize = DefaultChunkSize
+	}
+	return &SliceStack{chunkSize: chunkSize}
+}
+
+// Push adds a new element on top of the stack, growing its
+// capacity if full.
+func (s *SliceStack) Push(element interface{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.push(element)
+}
+
+// PushExpiring adds a new element on top of the stack that
+// expires at the given time, growing its capacity if full.
+func (s *SliceStack) PushExpiring(element interface{}, expiresAt time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.push(wrap(element, expiresAt))
+	s.expiry.add(expiresAt)
+}
+
+func (s *SliceStack) push(element interface{}) {
+	if len(s.elements) == cap(s.elements) {
+		s.resize(2 * cap(s.elements))
+	}
+	s.elements = append(s.elements, element)
+}
+
+// PushMany adds elements on top of the stack in order, so the last
+// one becomes the top, growing its capacity at most once.
+func (s *SliceStack) PushMany(elements []interface{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if n := len(s.elements) + len(elements); n > cap(s.elements) {
+		c := 2 * cap(s.elements)
+		if c < n {
+			c = n
+		}
+		s.resize(c)
+	}
+	s.elements = append(s.elements, elements...)
+}
+
+// Pop removes and returns the element on top of the stack,
+// shrinking its capacity if it is a quarter full. If the
+// stack was empty, it returns false.
+func (s *SliceStack) Pop() (interface{}, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.expire(s.expiry.now())
+	n := len(s.elements)
+	if n == 0 {
+		return nil, false
+	}
+
+	element, expiresAt := unwrap(s.elements[n-1])
+	s.expiry.remove(expiresAt)
+	// release the reference so the element can be collected
+	s.elements[n-1] = nil
+	s.elements = s.elements[:n-1]
+
+	s.shrink()
+	return element, true
+}
+
+// PopMany removes up to n elements from the top of the stack, and
+// returns them from top to bottom, shrinking its capacity at most once.
+func (s *SliceStack) PopMany(n int) []interface{} {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.expire(s.expiry.now())
+	if n > len(s.elements) {
+		n = len(s.elements)
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	elements := make([]interface{}, n)
+	for i := range elements {
+		top := len(s.elements) - 1 - i
+		var expiresAt time.Time
+		elements[i], expiresAt = unwrap(s.elements[top])
+		s.expiry.remove(expiresAt)
+		s.elements[top] = nil
+	}
+	s.elements = s.elements[:len(s.elements)-n]
+
+	s.shrink()
+	return elements
+}
+
+// Size returns the number of elements that a stack contains.
+func (s *SliceStack) Size() int {
+	s.rlock()
+	defer s.mux.RUnlock()
+
+	return len(s.elements)
+}
+
+// Peek returns the element on top of the stack.
+func (s *SliceStack) Peek() interface{} {
+	s.rlock()
+	defer s.mux.RUnlock()
+
+	if len(s.elements) == 0 {
+		return nil
+	}
+	element, _ := unwrap(s.elements[len(s.elements)-1])
+	return element
+}
+
+// Flush flushes the content of the stack, releasing
+// its capacity.
+func (s *SliceStack) Flush() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.elements = nil
+	s.expiry.reset()
+}
+
+// Walk calls fn for each element of the stack, from top to
+// bottom, until fn returns false. The stack is locked for
+// reading during the walk, so fn must not modify it.
+func (s *SliceStack) Walk(fn func(element interface{}) bool) {
+	s.WalkExpiring(func(element interface{}, _ time.Time) bool {
+		return fn(element)
+	})
+}
+
+// WalkExpiring calls fn for each element of the stack and the
+// time it expires at, zero if it does not expire, from top to
+// bottom, until fn returns false. The stack is locked for
+// reading during the walk, so fn must not modify it.
+func (s *SliceStack) WalkExpiring(fn func(element interface{}, expiresAt time.Time) bool) {
+	s.rlock()
+	defer s.mux.RUnlock()
+
+	t := s.expiry.now()
+	for i := len(s.elements) - 1; i >= 0; i-- {
+		element, expiresAt := unwrap(s.elements[i])
+		if expired(expiresAt, t) {
+			continue
+		}
+		if !fn(element, expiresAt) {
+			return
+		}
+	}
+}
+
+// Expire removes the expired elements of the stack, shrinking
+// its capacity if it is a quarter full, and returns how many
+// were removed.
+func (s *SliceStack) Expire() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.expire(s.expiry.now())
+}
+
+// SetClock sets the function that returns the current time to
+// expire the elements of the stack, or the system clock if nil.
+func (s *SliceStack) SetClock(clock func() time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.expiry.clock = clock
+}
+
+// rlock locks the stack for reading, removing its
+// expired elements first, if any.
+func (s *SliceStack) rlock() {
+	s.mux.RLock()
+	if !s.expiry.due(s.expiry.now()) {
+		return
+	}
+	s.mux.RUnlock()
+
+	s.mux.Lock()
+	s.expire(s.expiry.now())
+	s.mux.Unlock()
+	s.mux.RLock()
+}
+
+// expire removes the elements expired at t, if any is due,
+// keeping the order of the rest, and returns how many were
+// removed.
+func (s *SliceStack) expire(t time.Time) int {
+	if !s.expiry.due(t) {
+		return 0
+	}
+
+	s.expiry.reset()
+	var n int
+	for _, element := range s.elements {
+		_, expiresAt := unwrap(element)
+		if expired(expiresAt, t) {
+			continue
+		}
+		s.expiry.add(expiresAt)
+		s.elements[n] = element
+		n++
+	}
+
+	removed := len(s.elements) - n
+	// release the references so the elements can be collected
+	for i := n; i < len(s.elements); i++ {
+		s.elements[i] = nil
+	}
+	s.elements = s.elements[:n]
+
+	s.shrink()
+	return removed
+}
+
+// shrink halves the capacity of the stack, down to a chunk,
+// while it is only a quarter full.
+func (s *SliceStack) shrink() {
+	c := cap(s.elements)
+	for c > s.chunkSize && len(s.elements) <= c/4 {
+		c /= 2
+	}
+	if c != cap(s.elements) {
+		s.resize(c)
+	}
+}
+
+// resize sets the capacity of the stack to c, rounded up
+// to a multiple of the chunk size.
+func (s *SliceStack) resize(c int) {
+	if r := c % s.chunkSize; r != 0 || c == 0 {
+		c += s.chunkSize - r
+	}
+
+	elements := make([]interface{}, len(s.elements), c)
+	copy(elements, s.elements)
+	s.elements = elements
+}
@@ -0,0 +1,298 @@
+package stack
+
+import (
+	"reflect"
+	"testing"
+)
+
+var (
+	_ Stacker = (*SliceStack)(nil)
+	_ Walker  = (*SliceStack)(nil)
+	_ Batcher = (*SliceStack)(nil)
+)
+
+func TestNewSliceStack(t *testing.T) {
+	stack := NewSliceStack(4)
+	if stack.elements != nil {
+		t.Error("stack.elements is not nil")
+	}
+	if stack.chunkSize != 4 {
+		t.Errorf("stack.chunkSize is %v, expected %v", stack.chunkSize, 4)
+	}
+
+	stack = NewSliceStack(0)
+	if stack.chunkSize != DefaultChunkSize {
+		t.Errorf("stack.chunkSize is %v, expected %v", stack.chunkSize, DefaultChunkSize)
+	}
+}
+
+func TestSliceStackPushPop(t *testing.T) {
+	stack := NewSliceStack(4)
+
+	for i := 0; i < 20; i++ {
+		stack.Push(i)
+	}
+	if stack.Size() != 20 {
+		t.Errorf("stack.Size() is %d, expected %d", stack.Size(), 20)
+	}
+
+	for i := 19; i >= 0; i-- {
+		element, ok := stack.Pop()
+		if !ok {
+			t.Fatalf("stack.Pop() not ok at %d", i)
+		}
+		if element != i {
+			t.Errorf("element is %v, expected %v", element, i)
+		}
+	}
+
+	if _, ok := stack.Pop(); ok {
+		t.Error("stack.Pop() is ok on empty stack")
+	}
+	if stack.Size() != 0 {
+		t.Errorf("stack.Size() is %d, expected %d", stack.Size(), 0)
+	}
+}
+
+func TestSliceStackGrowth(t *testing.T) {
+	stack := NewSliceStack(4)
+
+	capacities := []int{4, 4, 4, 4, 8, 8, 8, 8, 16}
+	for i, c := range capacities {
+		stack.Push(i)
+		if cap(stack.elements) != c {
+			t.Errorf("capacity after %d pushes is %d, expected %d", i+1, cap(stack.elements), c)
+		}
+	}
+}
+
+func TestSliceStackShrink(t *testing.T) {
+	stack := NewSliceStack(4)
+	for i := 0; i < 32; i++ {
+		stack.Push(i)
+	}
+	if cap(stack.elements) != 32 {
+		t.Fatalf("capacity is %d, expected %d", cap(stack.elements), 32)
+	}
+
+	// capacity is kept until the stack is a quarter full
+	for i := 0; i < 23; i++ {
+		stack.Pop()
+	}
+	if cap(stack.elements) != 32 {
+		t.Errorf("capacity is %d, expected %d", cap(stack.elements), 32)
+	}
+	stack.Pop()
+	if cap(stack.elements) != 16 {
+		t.Errorf("capacity is %d, expected %d", cap(stack.elements), 16)
+	}
+
+	// pushing and popping at the boundary does not resize
+	stack.Push("foo")
+	stack.Pop()
+	if cap(stack.elements) != 16 {
+		t.Errorf("capacity is %d, expected %d", cap(stack.elements), 16)
+	}
+
+	// capacity never shrinks below a chunk
+	for stack.Size() > 0 {
+		stack.Pop()
+	}
+	if cap(stack.elements) != 4 {
+		t.Errorf("capacity is %d, expected %d", cap(stack.elements), 4)
+	}
+	if stack.Peek() != nil {
+		t.Error("stack.Peek() is not nil")
+	}
+}
+
+func TestSliceStackPop_ReleasesElement(t *testing.T) {
+	stack := NewSliceStack(4)
+	stack.Push("foo")
+	stack.Push("bar")
+	stack.Pop()
+
+	if element := stack.elements[:2][1]; element != nil {
+		t.Errorf("popped element %v is still referenced", element)
+	}
+}
+
+func TestSliceStackPeek(t *testing.T) {
+	stack := NewSliceStack(4)
+	if stack.Peek() != nil {
+		t.Error("stack.Peek() is not nil")
+	}
+
+	stack.Push("test")
+	stack.Push(8)
+	if stack.Peek() != 8 {
+		t.Errorf("stack.Peek() is %v, expected %v", stack.Peek(), 8)
+	}
+	if stack.Size() != 2 {
+		t.Errorf("stack.Size() is %d, expected %d", stack.Size(), 2)
+	}
+}
+
+func TestSliceStackFlush(t *testing.T) {
+	stack := NewSliceStack(4)
+	for i := 0; i < 10; i++ {
+		stack.Push(i)
+	}
+	stack.Flush()
+
+	if stack.Size() != 0 {
+		t.Errorf("stack.Size() is %d, expected %d", stack.Size(), 0)
+	}
+	if cap(stack.elements) != 0 {
+		t.Errorf("capacity is %d, expected %d", cap(stack.elements), 0)
+	}
+
+	stack.Push("foo")
+	if stack.Peek() != "foo" {
+		t.Errorf("stack.Peek() is %v, expected %v", stack.Peek(), "foo")
+	}
+}
+
+func TestSliceStackWalk(t *testing.T) {
+	stack := NewSliceStack(2)
+	for _, element := range []string{"a", "b", "c"} {
+		stack.Push(element)
+	}
+
+	var elements []interface{}
+	stack.Walk(func(element interface{}) bool {
+		elements = append(elements, element)
+		return true
+	})
+
+	expected := []interface{}{"c", "b", "a"}
+	if !reflect.DeepEqual(elements, expected) {
+		t.Errorf("elements are %v, expected %v", elements, expected)
+	}
+
+	var n int
+	stack.Walk(func(element interface{}) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("walked %d elements, expected %d", n, 1)
+	}
+}
+
+func TestSliceStackRace(t *testing.T) {
+	stack := NewSliceStack(1)
+	go func() { stack.Push(1) }()
+	go func() { stack.Pop() }()
+	go func() { stack.Size() }()
+	go func() { stack.Peek() }()
+	go func() { stack.Walk(func(interface{}) bool { return true }) }()
+}
+
+func BenchmarkSliceStackPush(b *testing.B) {
+	benchmarkPush(b, NewSliceStack(DefaultChunkSize))
+}
+
+func BenchmarkSliceStackPushPop(b *testing.B) {
+	benchmarkPushPop(b, NewSliceStack(DefaultChunkSize))
+}
+
+func BenchmarkSliceStackPopBatch(b *testing.B) {
+	benchmarkPopBatch(b, NewSliceStack(DefaultChunkSize))
+}
+
+// benchmarkPush pushes b.N elements into stack.
+func benchmarkPush(b *testing.B, stack Stacker) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		stack.Push(i)
+	}
+}
+
+// benchmarkPushPop pushes and pops an element b.N times
+// on top of a stack containing a thousand elements.
+func benchmarkPushPop(b *testing.B, stack Stacker) {
+	for i := 0; i < 1000; i++ {
+		stack.Push(i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stack.Push(i)
+		stack.Pop()
+	}
+}
+
+// benchmarkPopBatch fills stack with a million elements
+// and empties it, b.N times.
+func benchmarkPopBatch(b *testing.B, stack Stacker) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 1000000; j++ {
+			stack.Push(j)
+		}
+		for stack.Size() > 0 {
+			stack.Pop()
+		}
+	}
+}
+
+func TestSliceStackPushManyPopMany(t *testing.T) {
+	testPushManyPopMany(t, NewSliceStack(2))
+}
+
+func TestSliceStackPushMany_Growth(t *testing.T) {
+	stack := NewSliceStack(4)
+	stack.PushMany(make([]interface{}, 10))
+	if cap(stack.elements) != 12 {
+		t.Errorf("capacity is %d, expected %d", cap(stack.elements), 12)
+	}
+
+	stack.PopMany(9)
+	if cap(stack.elements) != 4 {
+		t.Errorf("capacity is %d, expected %d", cap(stack.elements), 4)
+	}
+	for i, element := range stack.elements[:cap(stack.elements)][1:] {
+		if element != nil {
+			t.Errorf("popped element %v at %d is still referenced", element, i+1)
+		}
+	}
+}
+
+// testPushManyPopMany tests the Batcher implementation of stack.
+// It only uses strings, as they are not altered by any Stacker.
+func testPushManyPopMany(t *testing.T, stack Batcher) {
+	stack.PushMany([]interface{}{"1", "2", "3"})
+	stack.PushMany(nil)
+	stack.PushMany([]interface{}{"a", "b"})
+
+	s := stack.(Stacker)
+	if s.Size() != 5 {
+		t.Errorf("stack.Size() is %d, expected %d", s.Size(), 5)
+	}
+	if s.Peek() != "b" {
+		t.Errorf("stack.Peek() is %v, expected %v", s.Peek(), "b")
+	}
+
+	if elements := stack.PopMany(0); len(elements) != 0 {
+		t.Errorf("elements are %v, expected none", elements)
+	}
+
+	elements, expected := stack.PopMany(3), []interface{}{"b", "a", "3"}
+	if !reflect.DeepEqual(elements, expected) {
+		t.Errorf("elements are %v, expected %v", elements, expected)
+	}
+
+	elements, expected = stack.PopMany(10), []interface{}{"2", "1"}
+	if !reflect.DeepEqual(elements, expected) {
+		t.Errorf("elements are %v, expected %v", elements, expected)
+	}
+
+	if elements := stack.PopMany(1); len(elements) != 0 {
+		t.Errorf("elements are %v, expected none", elements)
+	}
+	if s.Size() != 0 {
+		t.Errorf("stack.Size() is %d, expected %d", s.Size(), 0)
+	}
+}
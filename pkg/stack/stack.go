@@ -2,7 +2,10 @@
 // of a stack using a linked list.
 package stack
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 // Stack implements the Stacker interface, and represents the stack
 // data structure as a linked list, containing a pointer
@@ -10,16 +13,19 @@ import "sync"
 // It also contain a mutex to lock and unlock
 // the access to the stack at I/O operations.
 type Stack struct {
-	head *frame
-	size int
-	mux  sync.RWMutex
+	head   *frame
+	size   int
+	expiry expiry
+	mux    sync.RWMutex
 }
 
 // frame represents an element of the stack. It contains
-// data and the link to the next Frame as a pointer.
+// data, the time it expires at, if any, and the link to
+// the next Frame as a pointer.
 type frame struct {
-	data interface{}
-	next *frame
+	data      interface{}
+	expiresAt time.Time
+	next      *frame
 }
 
 // NewStack returns a blank stack, where head is nil and size
@@ -35,12 +41,16 @@ func (s *Stack) Push(element interface{}) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	head := &frame{
-		data: element,
-		next: s.head,
-	}
-	s.head = head
-	s.size++
+	s.push(element, time.Time{})
+}
+
+// PushExpiring adds a new element on top of the stack that
+// expires at the given time.
+func (s *Stack) PushExpiring(element interface{}, expiresAt time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.push(element, expiresAt)
 }
 
 // Pop removes and returns the element on top of the stack,
@@ -50,19 +60,42 @@ func (s *Stack) Pop() (interface{}, bool) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	if s.head == nil {
-		return nil, false
+	s.expire(s.expiry.now())
+	return s.pop()
+}
+
+// PushMany adds elements on top of the stack in order, so
+// the last one becomes the new head.
+func (s *Stack) PushMany(elements []interface{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, element := range elements {
+		s.push(element, time.Time{})
 	}
+}
 
-	element := s.head.data
-	s.head = s.head.next
-	s.size--
-	return element, true
+// PopMany removes up to n elements from the top of the stack,
+// and returns them from top to bottom.
+func (s *Stack) PopMany(n int) []interface{} {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.expire(s.expiry.now())
+	var elements []interface{}
+	for ; n > 0; n-- {
+		element, ok := s.pop()
+		if !ok {
+			break
+		}
+		elements = append(elements, element)
+	}
+	return elements
 }
 
 // Size returns the number of elements that a stack contains.
 func (s *Stack) Size() int {
-	s.mux.RLock()
+	s.rlock()
 	defer s.mux.RUnlock()
 
 	return s.size
@@ -70,7 +103,7 @@ func (s *Stack) Size() int {
 
 // Peek returns the element on top of the stack.
 func (s *Stack) Peek() interface{} {
-	s.mux.RLock()
+	s.rlock()
 	defer s.mux.RUnlock()
 
 	if s.head == nil {
@@ -86,4 +119,111 @@ func (s *Stack) Flush() {
 
 	s.size = 0
 	s.head = nil
+	s.expiry.reset()
+}
+
+// Walk calls fn for each element of the stack, from top to
+// bottom, until fn returns false. The stack is locked for
+// reading during the walk, so fn must not modify it.
+func (s *Stack) Walk(fn func(element interface{}) bool) {
+	s.WalkExpiring(func(element interface{}, _ time.Time) bool {
+		return fn(element)
+	})
+}
+
+// WalkExpiring calls fn for each element of the stack and the
+// time it expires at, zero if it does not expire, from top to
+// bottom, until fn returns false. The stack is locked for
+// reading during the walk, so fn must not modify it.
+func (s *Stack) WalkExpiring(fn func(element interface{}, expiresAt time.Time) bool) {
+	s.rlock()
+	defer s.mux.RUnlock()
+
+	t := s.expiry.now()
+	for f := s.head; f != nil; f = f.next {
+		if expired(f.expiresAt, t) {
+			continue
+		}
+		if !fn(f.data, f.expiresAt) {
+			return
+		}
+	}
+}
+
+// Expire removes the expired elements of the stack, and
+// returns how many were removed.
+func (s *Stack) Expire() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.expire(s.expiry.now())
+}
+
+// SetClock sets the function that returns the current time to
+// expire the elements of the stack, or the system clock if nil.
+func (s *Stack) SetClock(clock func() time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.expiry.clock = clock
+}
+
+func (s *Stack) push(element interface{}, expiresAt time.Time) {
+	head := &frame{
+		data:      element,
+		expiresAt: expiresAt,
+		next:      s.head,
+	}
+	s.head = head
+	s.size++
+	s.expiry.add(expiresAt)
+}
+
+func (s *Stack) pop() (interface{}, bool) {
+	if s.head == nil {
+		return nil, false
+	}
+
+	element := s.head.data
+	s.expiry.remove(s.head.expiresAt)
+	s.head = s.head.next
+	s.size--
+	return element, true
+}
+
+// rlock locks the stack for reading, removing its
+// expired elements first, if any.
+func (s *Stack) rlock() {
+	s.mux.RLock()
+	if !s.expiry.due(s.expiry.now()) {
+		return
+	}
+	s.mux.RUnlock()
+
+	s.mux.Lock()
+	s.expire(s.expiry.now())
+	s.mux.Unlock()
+	s.mux.RLock()
+}
+
+// expire removes the elements expired at t, if any is due,
+// and returns how many were removed.
+func (s *Stack) expire(t time.Time) int {
+	if !s.expiry.due(t) {
+		return 0
+	}
+
+	var removed int
+	s.expiry.reset()
+	for f := &s.head; *f != nil; {
+		if expired((*f).expiresAt, t) {
+			*f = (*f).next
+			removed++
+			continue
+		}
+		s.expiry.add((*f).expiresAt)
+		f = &(*f).next
+	}
+	s.size -= removed
+	return removed
 }
@@ -1,6 +1,9 @@
 package stack
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestNewStack(t *testing.T) {
 	stack := NewStack()
@@ -130,3 +133,54 @@ func TestStackRace(t *testing.T) {
 	go func() { stack.Peek() }()
 	go func() { stack.Flush() }()
 }
+
+func TestStackWalk(t *testing.T) {
+	stack := NewStack()
+	stack.Push("one")
+	stack.Push("two")
+	stack.Push("three")
+
+	var elements []interface{}
+	stack.Walk(func(element interface{}) bool {
+		elements = append(elements, element)
+		return true
+	})
+
+	expected := []interface{}{"three", "two", "one"}
+	if !reflect.DeepEqual(elements, expected) {
+		t.Errorf("elements are %v, expected %v", elements, expected)
+	}
+
+	var n int
+	stack.Walk(func(element interface{}) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Errorf("walked %d elements, expected %d", n, 2)
+	}
+}
+
+func TestStackWalk_Empty(t *testing.T) {
+	stack := NewStack()
+	stack.Walk(func(element interface{}) bool {
+		t.Errorf("walked element %v on empty stack", element)
+		return true
+	})
+}
+
+func BenchmarkStackPush(b *testing.B) {
+	benchmarkPush(b, NewStack())
+}
+
+func BenchmarkStackPushPop(b *testing.B) {
+	benchmarkPushPop(b, NewStack())
+}
+
+func BenchmarkStackPopBatch(b *testing.B) {
+	benchmarkPopBatch(b, NewStack())
+}
+
+func TestStackPushManyPopMany(t *testing.T) {
+	testPushManyPopMany(t, NewStack())
+}
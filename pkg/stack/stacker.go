@@ -1,5 +1,7 @@
 package stack
 
+import "time"
+
 // Stacker represents an interface that contains all the
 // required methods to implement a Stack that can be
 // used in piladb.
@@ -15,3 +17,66 @@ type Stacker interface {
 	// Flush flushes a Stack
 	Flush()
 }
+
+// Walker represents an optional interface for Stackers whose
+// elements can be traversed without modifying the Stack.
+type Walker interface {
+	// Walk calls fn for each element of the Stack, from top
+	// to bottom, until fn returns false.
+	Walk(fn func(element interface{}) bool)
+}
+
+// Bounded represents an optional interface for Stackers that
+// can contain a limited number of elements.
+type Bounded interface {
+	// Capacity returns the maximum number of elements
+	// of the Stack.
+	Capacity() int
+}
+
+// Batcher represents an optional interface for Stackers that can
+// push and pop several elements at once, atomically.
+type Batcher interface {
+	// PushMany pushes elements into the Stack in order, so
+	// the last one ends up on top of the Stack.
+	PushMany(elements []interface{})
+	// PopMany pops up to n elements from the Stack, and
+	// returns them from top to bottom.
+	PopMany(n int) []interface{}
+}
+
+// Expirer represents an optional interface for Stackers whose
+// elements can expire. Expired elements are not visible to any
+// other method of the Stack, and they are eventually removed.
+type Expirer interface {
+	// PushExpiring pushes an element into the Stack that
+	// expires at the given time.
+	PushExpiring(element interface{}, expiresAt time.Time)
+	// Expire removes the expired elements of the Stack, and
+	// returns how many were removed.
+	Expire() int
+	// WalkExpiring calls fn for each element of the Stack and
+	// its expiration time, zero if it does not expire, from top
+	// to bottom, until fn returns false.
+	WalkExpiring(fn func(element interface{}, expiresAt time.Time) bool)
+}
+
+// Clocked represents an optional interface for Expirers whose
+// elements can expire according to a clock other than the system
+// one, e.g. to replay operations at the time they were applied.
+type Clocked interface {
+	// SetClock sets the function that returns the current time
+	// to expire the elements of the Stack, or the system clock
+	// if it is nil.
+	SetClock(clock func() time.Time)
+}
+
+// Checkpointer represents an optional interface for Stackers stored
+// on disk, whose elements can be saved by reference to their files
+// instead of by copying them.
+type Checkpointer interface {
+	// Checkpoint saves the elements of the Stack into the
+	// directory dir, so they can be restored later even if
+	// the Stack changes meanwhile.
+	Checkpoint(dir string) error
+}